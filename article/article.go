@@ -11,13 +11,33 @@ type Article struct {
 	// Unique identifier used internally
 	ID uint64
 
+	// Unique name used in the URL of the article, generated from the title
+	// unless the author picks their own
+	Slug string
+
 	// Unique identifier of the Author
 	AuthorID uint64
 
 	// Date of release, used for sorting articles on run page
+	// scheduled articles are published at this time
 	// TODO change the type to time.Time
 	Timestamp uint64
 
+	// only published articles are shown to readers
+	Status Status
+
 	// type template.HTML allows unescaped html
+	// it's rendered from Source by the Store, so it should be safe to show
 	Content template.HTML
+
+	// the article as written by its author, Markdown by default
+	// empty for articles written in HTML before Markdown was supported
+	Source string
+
+	// optional summary written by the author, used as the preview instead of
+	// the beginning of the article
+	Summary string
+
+	// names of the tags of the article, see ParseTags
+	Tags []string
 }
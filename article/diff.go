@@ -0,0 +1,94 @@
+package article
+
+import (
+	"strings"
+)
+
+// DiffKind says how a row of a diff has changed
+type DiffKind string
+
+const (
+	DiffEqual   DiffKind = "equal"
+	DiffRemoved DiffKind = "removed"
+	DiffAdded   DiffKind = "added"
+	DiffChanged DiffKind = "changed"
+)
+
+// DiffRow is a single row of a side-by-side diff
+// Left is the line from the old text, Right from the new one, one of them is
+// empty if the line was added or removed
+type DiffRow struct {
+	Kind  DiffKind
+	Left  string
+	Right string
+}
+
+// Diff compares two texts line by line and returns a side-by-side diff
+// Removed lines directly followed by added lines are paired as changed lines
+func Diff(old string, new string) []DiffRow {
+	a := splitLines(old)
+	b := splitLines(new)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var rows []DiffRow
+	var removed, added []string
+
+	// pairs up the pending removed and added lines
+	flush := func() {
+		for k := 0; k < len(removed) || k < len(added); k++ {
+			switch {
+			case k < len(removed) && k < len(added):
+				rows = append(rows, DiffRow{Kind: DiffChanged, Left: removed[k], Right: added[k]})
+			case k < len(removed):
+				rows = append(rows, DiffRow{Kind: DiffRemoved, Left: removed[k]})
+			default:
+				rows = append(rows, DiffRow{Kind: DiffAdded, Right: added[k]})
+			}
+		}
+		removed, added = removed[:0], added[:0]
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			rows = append(rows, DiffRow{Kind: DiffEqual, Left: a[i], Right: b[j]})
+			i++
+			j++
+		case j >= len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	flush()
+
+	return rows
+}
+
+// splits the text into lines, an empty text has no lines
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
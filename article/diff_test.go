@@ -0,0 +1,45 @@
+package article
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []DiffRow
+	}{
+		{
+			name: "equal",
+			old:  "a\nb",
+			new:  "a\nb",
+			want: []DiffRow{{DiffEqual, "a", "a"}, {DiffEqual, "b", "b"}},
+		},
+		{
+			name: "changed line",
+			old:  "a\nb\nc",
+			new:  "a\nB\nc",
+			want: []DiffRow{{DiffEqual, "a", "a"}, {DiffChanged, "b", "B"}, {DiffEqual, "c", "c"}},
+		},
+		{
+			name: "added and removed lines",
+			old:  "a\nb\nc",
+			new:  "b\nc\nd",
+			want: []DiffRow{{DiffRemoved, "a", ""}, {DiffEqual, "b", "b"}, {DiffEqual, "c", "c"}, {DiffAdded, "", "d"}},
+		},
+		{
+			name: "from nothing",
+			old:  "",
+			new:  "a\r\nb",
+			want: []DiffRow{{DiffAdded, "", "a"}, {DiffAdded, "", "b"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := Diff(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: Diff() = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
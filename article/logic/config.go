@@ -2,7 +2,10 @@ package logic
 
 import (
 	storePkg "github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/cache"
+	"github.com/david-sorm/montesquieu/store/mock"
 	"github.com/david-sorm/montesquieu/store/postgres"
+	"github.com/david-sorm/montesquieu/store/sqlite"
 )
 
 func ParseStore(str string) storePkg.Store {
@@ -10,6 +13,23 @@ func ParseStore(str string) storePkg.Store {
 		store := postgres.Store{}
 		return &store
 	}
+	if str == "sqlite" {
+		store := sqlite.Store{}
+		return &store
+	}
+	if str == "mock" {
+		store := mock.Store{}
+		return &store
+	}
+
+	return nil
+}
+
+func ParseCachingStore(str string) storePkg.CachingStore {
+	if str == "internal" {
+		cachingStore := cache.Store{}
+		return &cachingStore
+	}
 
 	return nil
 }
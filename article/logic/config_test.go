@@ -3,7 +3,10 @@ package logic_test
 import (
 	"github.com/david-sorm/montesquieu/article/logic"
 	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/cache"
+	"github.com/david-sorm/montesquieu/store/mock"
 	"github.com/david-sorm/montesquieu/store/postgres"
+	"github.com/david-sorm/montesquieu/store/sqlite"
 	"reflect"
 	"testing"
 )
@@ -22,6 +25,16 @@ func TestParseStore(t *testing.T) {
 			args: args{str: "postgres"},
 			want: &postgres.Store{},
 		},
+		{
+			name: "sqlite store",
+			args: args{str: "sqlite"},
+			want: &sqlite.Store{},
+		},
+		{
+			name: "mock store",
+			args: args{str: "mock"},
+			want: &mock.Store{},
+		},
 		{
 			name: "invalid store",
 			args: args{str: "this store shouldn't exist"},
@@ -36,3 +49,32 @@ func TestParseStore(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCachingStore(t *testing.T) {
+	type args struct {
+		str string
+	}
+	tests := []struct {
+		name string
+		args args
+		want store.CachingStore
+	}{
+		{
+			name: "internal caching store",
+			args: args{str: "internal"},
+			want: &cache.Store{},
+		},
+		{
+			name: "caching store turned off",
+			args: args{str: "off"},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logic.ParseCachingStore(tt.args.str); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCachingStore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
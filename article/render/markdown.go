@@ -0,0 +1,30 @@
+package render
+
+import (
+	"bytes"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+	"html/template"
+)
+
+// converter used by all Markdown renderers
+var converter = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	// HTML inside of Markdown is allowed, articles written before Markdown
+	// support was added are plain HTML, which is valid Markdown too
+	// it's removed by Sanitize if it's dangerous
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// Markdown is a Renderer for articles written in (GitHub Flavored) Markdown
+type Markdown struct{}
+
+// Render implements Renderer's Render function
+func (Markdown) Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := converter.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return Sanitize(buf.String()), nil
+}
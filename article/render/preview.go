@@ -0,0 +1,115 @@
+package render
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"html/template"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// MoreMarker can be put into the source of an article, everything before it is
+// used as the preview
+const MoreMarker = "<!--more-->"
+
+// DefaultPreviewLength is the number of words in a preview, unless it's configured
+const DefaultPreviewLength = 50
+
+// elements which don't have a closing tag
+var voidElements = map[string]bool{
+	"area": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// RenderArticle renders the content and the preview of an article.
+// If the summary isn't empty, the preview is rendered from it.
+// Otherwise the preview is everything before MoreMarker, or the first
+// previewLength words of the content if there's no marker in the source
+func RenderArticle(r Renderer, source string, summary string, previewLength uint64) (content template.HTML, preview template.HTML, err error) {
+	content, err = r.Render(source)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch {
+	case strings.TrimSpace(summary) != "":
+		preview, err = r.Render(summary)
+	case strings.Contains(source, MoreMarker):
+		preview, err = r.Render(source[:strings.Index(source, MoreMarker)])
+	default:
+		preview = Truncate(content, previewLength)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return content, preview, nil
+}
+
+// Truncate cuts the HTML after the given number of words, closes all tags which
+// were left open and appends an ellipsis if anything was cut off
+func Truncate(content template.HTML, words uint64) template.HTML {
+	var buf bytes.Buffer
+	var open []string
+
+	z := html.NewTokenizer(strings.NewReader(string(content)))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				// the sanitizer produces valid HTML, this shouldn't happen
+				return ""
+			}
+			return content
+		}
+
+		switch tt {
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			if !voidElements[string(name)] {
+				open = append(open, string(name))
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == string(name) {
+					open = open[:i]
+					break
+				}
+			}
+		case html.TextToken:
+			text, n, cut := cutWords(string(z.Raw()), words)
+			words -= n
+			if cut {
+				buf.WriteString(text)
+				buf.WriteString("…")
+				for i := len(open) - 1; i >= 0; i-- {
+					buf.WriteString("</" + open[i] + ">")
+				}
+				return template.HTML(buf.String())
+			}
+		}
+		buf.Write(z.Raw())
+	}
+}
+
+// cutWords returns the text cut after the given number of words, the number of
+// words in the returned text and whether anything has been cut off
+func cutWords(text string, words uint64) (string, uint64, bool) {
+	var count uint64
+	inWord := false
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			inWord = false
+			continue
+		}
+		if !inWord {
+			if count == words {
+				return strings.TrimRightFunc(text[:i], unicode.IsSpace), count, true
+			}
+			count++
+			inWord = true
+		}
+	}
+	return text, count, false
+}
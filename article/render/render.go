@@ -0,0 +1,41 @@
+package render
+
+import (
+	"github.com/microcosm-cc/bluemonday"
+	"html/template"
+	"sync"
+)
+
+// Renderer turns the source of an article, as written by its author, into HTML
+// which can be safely shown to readers.
+// Renderers are used by Stores when an article is added or edited, so the HTML
+// is only rendered once and not on every request
+type Renderer interface {
+	Render(source string) (template.HTML, error)
+}
+
+// the policy is built only once, since it's safe for concurrent use
+var policy *bluemonday.Policy
+var policyOnce sync.Once
+
+// Sanitize removes everything from the HTML that isn't explicitly allowed.
+// Allowed are the usual formatting elements (paragraphs, headings, lists, quotes,
+// code, tables, images, links...), links are forced to have rel="nofollow" and
+// scripts, styles, iframes, forms and event handlers are removed.
+// Every Renderer should pass its output through Sanitize
+func Sanitize(html string) template.HTML {
+	policyOnce.Do(func() {
+		policy = bluemonday.UGCPolicy()
+		// used by code blocks to mark the language
+		policy.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("code")
+	})
+	return template.HTML(policy.Sanitize(html))
+}
+
+// HTML is a Renderer for articles written directly in HTML
+type HTML struct{}
+
+// Render implements Renderer's Render function
+func (HTML) Render(source string) (template.HTML, error) {
+	return Sanitize(source), nil
+}
@@ -0,0 +1,136 @@
+package render
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestMarkdown_Render(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   template.HTML
+	}{
+		{
+			name:   "markdown",
+			source: "# Title\n\nSome **bold** text",
+			want:   "<h1>Title</h1>\n<p>Some <strong>bold</strong> text</p>\n",
+		},
+		{
+			name:   "links get nofollow",
+			source: "[home](https://example.com)",
+			want:   "<p><a href=\"https://example.com\" rel=\"nofollow\">home</a></p>\n",
+		},
+		{
+			name:   "scripts are removed",
+			source: "Hello<script>alert(1)</script>",
+			want:   "<p>Hello</p>\n",
+		},
+		{
+			name:   "event handlers are removed",
+			source: "<img src=\"/a.png\" onerror=\"alert(1)\">",
+			want:   "<img src=\"/a.png\">",
+		},
+		{
+			name:   "javascript links are removed",
+			source: "[click](javascript:alert(1))",
+			want:   "<p>click</p>\n",
+		},
+		{
+			// articles from before Markdown was supported
+			name:   "plain html",
+			source: "Thank you for choosing <b>Montesquieu</b>!",
+			want:   "<p>Thank you for choosing <b>Montesquieu</b>!</p>\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := Markdown{}.Render(tt.source)
+		if err != nil {
+			t.Errorf("%v: Render() returned an error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v: Render() = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHTML_Render(t *testing.T) {
+	got, _ := HTML{}.Render("<p onclick=\"alert(1)\">Hi<iframe src=\"https://example.com\"></iframe></p>")
+	if want := template.HTML("<p>Hi</p>"); got != want {
+		t.Errorf("Render() = %#v, want %#v", got, want)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content template.HTML
+		words   uint64
+		want    template.HTML
+	}{
+		{
+			name:    "short enough",
+			content: "<p>One two three</p>",
+			words:   3,
+			want:    "<p>One two three</p>",
+		},
+		{
+			name:    "cut in the middle",
+			content: "<p>One <b>two three</b> four</p>",
+			words:   2,
+			want:    "<p>One <b>two…</b></p>",
+		},
+		{
+			name:    "nested tags",
+			content: "<ul><li><a href=\"/\">One two</a></li><li>three</li></ul>",
+			words:   1,
+			want:    "<ul><li><a href=\"/\">One…</a></li></ul>",
+		},
+		{
+			name:    "void elements",
+			content: "<p>One<br>two<img src=\"/a.png\"> three</p>",
+			words:   2,
+			want:    "<p>One<br>two<img src=\"/a.png\">…</p>",
+		},
+		{
+			name:    "entities",
+			content: "<p>Tom &amp; Jerry &lt;3</p>",
+			words:   3,
+			want:    "<p>Tom &amp; Jerry…</p>",
+		},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.content, tt.words); got != tt.want {
+			t.Errorf("%v: Truncate() = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderArticle(t *testing.T) {
+	source := "First paragraph\n\n" + MoreMarker + "\n\nSecond paragraph"
+
+	content, preview, err := RenderArticle(Markdown{}, source, "", 1)
+	if err != nil {
+		t.Fatalf("RenderArticle() returned an error: %v", err)
+	}
+	if want := template.HTML("<p>First paragraph</p>\n\n<p>Second paragraph</p>\n"); content != want {
+		t.Errorf("RenderArticle() content = %#v, want %#v", content, want)
+	}
+	// the marker wins over the preview length
+	if want := template.HTML("<p>First paragraph</p>\n"); preview != want {
+		t.Errorf("RenderArticle() preview = %#v, want %#v", preview, want)
+	}
+
+	// the summary wins over everything
+	_, preview, _ = RenderArticle(Markdown{}, source, "*Summary*", 1)
+	if want := template.HTML("<p><em>Summary</em></p>\n"); preview != want {
+		t.Errorf("RenderArticle() preview with a summary = %#v, want %#v", preview, want)
+	}
+
+	// without a marker, the content is truncated
+	_, preview, _ = RenderArticle(Markdown{}, "One two three", "", 2)
+	if want := template.HTML("<p>One two…</p>"); preview != want {
+		t.Errorf("RenderArticle() truncated preview = %#v, want %#v", preview, want)
+	}
+}
@@ -0,0 +1,28 @@
+package article
+
+import (
+	"time"
+)
+
+// Revision is a version of an article, saved every time the article is changed
+type Revision struct {
+	// Unique identifier of the revision
+	ID uint64
+
+	// the article this is a version of
+	ArticleID uint64
+
+	// ID of the user who made the change, zero if unknown (for example for the
+	// original version of articles written before revisions were introduced)
+	UserID uint64
+
+	// display name of the user who made the change
+	UserName string
+
+	// when the change was made
+	Time time.Time
+
+	Title   string
+	Source  string
+	Summary string
+}
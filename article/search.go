@@ -0,0 +1,113 @@
+package article
+
+import (
+	"golang.org/x/text/transform"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// SearchResult is an article found by a search
+type SearchResult struct {
+	Article
+
+	// part of the article with the searched words highlighted
+	Snippet template.HTML
+}
+
+// HighlightStart and HighlightStop surround the searched words in snippets
+// before they're turned into HTML by Highlight
+// They're from the Unicode private use area, so they shouldn't appear in
+// articles
+const (
+	HighlightStart = "\ue000"
+	HighlightStop  = "\ue001"
+)
+
+// how many words long the snippets of search results are
+const SnippetLength = 30
+
+// matches HTML tags, block tags separate words, inline ones don't
+var (
+	blockTags = regexp.MustCompile(`(?i)</?(p|div|br|h[1-6]|ul|ol|li|blockquote|pre|table|tr|td|th|hr)\b[^>]*>`)
+	tags      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// StripTags removes all tags from the HTML, HTML entities are left as they are
+func StripTags(content template.HTML) string {
+	text := blockTags.ReplaceAllString(string(content), " ")
+	text = tags.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Tokenize splits the text into lowercase words without diacritics, used for
+// searching
+func Tokenize(text string) []string {
+	text, _, _ = transform.String(removeMarks, text)
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Highlight turns text made by StripTags, with the searched words surrounded
+// by HighlightStart and HighlightStop, into safe HTML with the words in <mark>
+func Highlight(text string) template.HTML {
+	escaped := template.HTMLEscapeString(html.UnescapeString(text))
+	escaped = strings.ReplaceAll(escaped, HighlightStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, HighlightStop, "</mark>")
+	return template.HTML(escaped)
+}
+
+// Snippet makes a snippet of the content around the first of the terms it
+// contains, the terms have to be tokenized by Tokenize
+// It's used by Stores which can't make snippets by themselves
+func Snippet(content template.HTML, terms []string, length int) template.HTML {
+	searched := make(map[string]bool)
+	for _, t := range terms {
+		searched[t] = true
+	}
+	matches := func(word string) bool {
+		for _, t := range Tokenize(word) {
+			if searched[t] {
+				return true
+			}
+		}
+		return false
+	}
+
+	words := strings.Fields(StripTags(content))
+	first := 0
+	for k, w := range words {
+		if matches(w) {
+			first = k
+			break
+		}
+	}
+
+	// show a bit of the text before the first match too
+	start := first - length/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + length
+	if end > len(words) {
+		end = len(words)
+	}
+
+	snippet := make([]string, 0, end-start+2)
+	if start > 0 {
+		snippet = append(snippet, "…")
+	}
+	for _, w := range words[start:end] {
+		if matches(w) {
+			w = HighlightStart + w + HighlightStop
+		}
+		snippet = append(snippet, w)
+	}
+	if end < len(words) {
+		snippet = append(snippet, "…")
+	}
+	return Highlight(strings.Join(snippet, " "))
+}
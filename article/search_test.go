@@ -0,0 +1,67 @@
+package article
+
+import (
+	"html/template"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Příliš žluťoučký kůň, 2020!")
+	want := []string{"prilis", "zlutoucky", "kun", "2020"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %#v, want %#v", got, want)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("a &amp; <b> " + HighlightStart + "word" + HighlightStop)
+	want := template.HTML("a &amp; &lt;b&gt; <mark>word</mark>")
+	if got != want {
+		t.Errorf("Highlight() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		name    string
+		content template.HTML
+		terms   []string
+		length  int
+		want    template.HTML
+	}{
+		{
+			name:    "match at the start",
+			content: "<p>Hello <b>world</b>, how are you</p>",
+			terms:   []string{"hello"},
+			length:  3,
+			want:    "<mark>Hello</mark> world, how …",
+		},
+		{
+			name:    "match in the middle",
+			content: "<p>one two three four five six seven eight</p>",
+			terms:   []string{"six"},
+			length:  4,
+			want:    "… five <mark>six</mark> seven eight",
+		},
+		{
+			name:    "no match",
+			content: "<p>one two three</p>",
+			terms:   []string{"zero"},
+			length:  2,
+			want:    "one two …",
+		},
+		{
+			name:    "escaped",
+			content: "<p>Tom &amp; Jerry &lt;3</p>",
+			terms:   []string{"jerry"},
+			length:  10,
+			want:    "Tom &amp; <mark>Jerry</mark> &lt;3",
+		},
+	}
+	for _, tt := range tests {
+		if got := Snippet(tt.content, tt.terms, tt.length); got != tt.want {
+			t.Errorf("%v: Snippet() = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
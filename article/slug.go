@@ -0,0 +1,77 @@
+package article
+
+import (
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// used when nothing is left of the title
+const defaultSlug = "article"
+
+// removes diacritics, so "Příliš žluťoučký" becomes "Prilis zlutoucky"
+var removeMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// Slugify makes a slug usable in URLs out of a title, for example
+// "Hello, World!" becomes "hello-world"
+// Slugs made only of digits get a prefix, so "2020" becomes "article-2020",
+// otherwise they'd hide /article/{id} of other articles
+func Slugify(title string) string {
+	slug := urlName(title)
+	if strings.Trim(slug, "0123456789") == "" {
+		return defaultSlug + "-" + slug
+	}
+	return slug
+}
+
+// urlName makes a name usable in URLs out of a string, which can be a title of
+// an article or a name of a tag
+func urlName(title string) string {
+	title, _, _ = transform.String(removeMarks, title)
+
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return defaultSlug
+	}
+	return slug
+}
+
+// UniqueSlug returns the slug, or if it's already taken, the slug with the
+// lowest free numeric suffix, for example "hello-world-2"
+// taken should report whether a slug is already used by another article
+func UniqueSlug(slug string, taken func(slug string) (bool, error)) (string, error) {
+	candidate := slug
+	for i := 2; ; i++ {
+		isTaken, err := taken(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !isTaken {
+			return candidate, nil
+		}
+		candidate = slug + "-" + strconv.Itoa(i)
+	}
+}
+
+// URL returns the canonical URL of the article
+func (a Article) URL() string {
+	if a.Slug == "" {
+		return "/article/" + strconv.FormatUint(a.ID, 10)
+	}
+	return "/article/" + a.Slug
+}
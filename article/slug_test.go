@@ -0,0 +1,42 @@
+package article
+
+import (
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Hello, World!", want: "hello-world"},
+		{title: "  Trailing and leading  ", want: "trailing-and-leading"},
+		{title: "Příliš žluťoučký kůň", want: "prilis-zlutoucky-kun"},
+		{title: "2020 in review", want: "2020-in-review"},
+		{title: "???", want: "article"},
+		{title: "2020", want: "article-2020"},
+		{title: "#42!", want: "article-42"},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.title); got != tt.want {
+			t.Errorf("Slugify(%#v) = %#v, want %#v", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueSlug(t *testing.T) {
+	taken := map[string]bool{"hello": true, "hello-2": true}
+	got, err := UniqueSlug("hello", func(slug string) (bool, error) {
+		return taken[slug], nil
+	})
+	if err != nil || got != "hello-3" {
+		t.Errorf("UniqueSlug() = %#v, %v, want \"hello-3\"", got, err)
+	}
+
+	got, _ = UniqueSlug("free", func(slug string) (bool, error) {
+		return taken[slug], nil
+	})
+	if got != "free" {
+		t.Errorf("UniqueSlug() = %#v, want \"free\"", got)
+	}
+}
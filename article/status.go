@@ -0,0 +1,48 @@
+package article
+
+import (
+	"errors"
+	"time"
+)
+
+// Status says whether the article can be seen by readers
+type Status string
+
+const (
+	// Draft articles are only visible in the admin panel
+	Draft Status = "draft"
+
+	// Scheduled articles become published once their Timestamp has passed
+	Scheduled Status = "scheduled"
+
+	// Published articles are visible to everyone
+	Published Status = "published"
+
+	// Archived articles were published before, but aren't visible anymore
+	Archived Status = "archived"
+)
+
+// Statuses lists all valid statuses in the order they're usually used in
+var Statuses = []Status{Draft, Scheduled, Published, Archived}
+
+// ParseStatus converts a string to a Status, returning an error if it's not
+// a valid status
+func ParseStatus(str string) (Status, error) {
+	for _, s := range Statuses {
+		if string(s) == str {
+			return s, nil
+		}
+	}
+	return "", errors.New("invalid article status: " + str)
+}
+
+// Schedule makes published articles with a time in the future scheduled, and
+// scheduled articles with a time in the past published
+func (a *Article) Schedule(now time.Time) {
+	switch {
+	case a.Status == Published && a.Timestamp > uint64(now.Unix()):
+		a.Status = Scheduled
+	case a.Status == Scheduled && a.Timestamp <= uint64(now.Unix()):
+		a.Status = Published
+	}
+}
@@ -0,0 +1,41 @@
+package article
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArticle_Schedule(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tests := []struct {
+		name      string
+		status    Status
+		timestamp uint64
+		want      Status
+	}{
+		{name: "published in the future", status: Published, timestamp: 1600000060, want: Scheduled},
+		{name: "published in the past", status: Published, timestamp: 1599999940, want: Published},
+		{name: "scheduled in the past", status: Scheduled, timestamp: 1600000000, want: Published},
+		{name: "scheduled in the future", status: Scheduled, timestamp: 1600000060, want: Scheduled},
+		{name: "drafts stay drafts", status: Draft, timestamp: 1600000060, want: Draft},
+		{name: "archived stay archived", status: Archived, timestamp: 1599999940, want: Archived},
+	}
+	for _, tt := range tests {
+		a := Article{Status: tt.status, Timestamp: tt.timestamp}
+		a.Schedule(now)
+		if a.Status != tt.want {
+			t.Errorf("%v: Schedule() changed the status to %v, want %v", tt.name, a.Status, tt.want)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for _, s := range Statuses {
+		if got, err := ParseStatus(string(s)); err != nil || got != s {
+			t.Errorf("ParseStatus(%#v) = %#v, %v", string(s), got, err)
+		}
+	}
+	if _, err := ParseStatus("live"); err == nil {
+		t.Errorf("ParseStatus(\"live\") didn't return an error")
+	}
+}
@@ -0,0 +1,85 @@
+package article
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// TagCount is a tag together with the number of published articles which
+// have it
+type TagCount struct {
+	Name  string
+	Count uint64
+}
+
+// CloudTag is a tag prepared for a tag cloud
+type CloudTag struct {
+	Name  string
+	Count uint64
+
+	// from 1 to the number of levels passed to TagCloud, tags used the most
+	// have the highest level
+	Level int
+}
+
+// ParseTags makes a list of tags out of a comma separated string
+// Tag names are made usable in URLs the same way slugs are, so "Go, Web dev"
+// becomes "go" and "web-dev", but names made only of digits stay as they are.
+// Empty and duplicate tags are left out
+func ParseTags(str string) []string {
+	tags := make([]string, 0, 0)
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(str, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		name = urlName(name)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags
+}
+
+// TagURL returns the URL of the page listing articles with the tag
+func TagURL(name string) string {
+	return "/tag/" + name
+}
+
+// TagCloud sorts the tags by name and splits them into levels by how often
+// they're used, the scale is logarithmic, so a few popular tags don't push all
+// the others to the lowest level
+func TagCloud(tags []TagCount, levels int) []CloudTag {
+	cloud := make([]CloudTag, 0, len(tags))
+	if len(tags) == 0 || levels < 1 {
+		return cloud
+	}
+
+	min, max := tags[0].Count, tags[0].Count
+	for _, t := range tags {
+		if t.Count < min {
+			min = t.Count
+		}
+		if t.Count > max {
+			max = t.Count
+		}
+	}
+
+	spread := math.Log(float64(max+1)) - math.Log(float64(min+1))
+	for _, t := range tags {
+		level := levels
+		if spread > 0 {
+			weight := (math.Log(float64(t.Count+1)) - math.Log(float64(min+1))) / spread
+			level = 1 + int(math.Round(weight*float64(levels-1)))
+		}
+		cloud = append(cloud, CloudTag{Name: t.Name, Count: t.Count, Level: level})
+	}
+
+	sort.Slice(cloud, func(i, j int) bool {
+		return cloud[i].Name < cloud[j].Name
+	})
+	return cloud
+}
@@ -0,0 +1,42 @@
+package article
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		str  string
+		want []string
+	}{
+		{str: "Go, Web dev", want: []string{"go", "web-dev"}},
+		{str: "go,GO, go ", want: []string{"go"}},
+		{str: " , ,", want: []string{}},
+		{str: "", want: []string{}},
+		{str: "2020, Go 1.14", want: []string{"2020", "go-1-14"}},
+	}
+	for _, tt := range tests {
+		if got := ParseTags(tt.str); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTags(%#v) = %#v, want %#v", tt.str, got, tt.want)
+		}
+	}
+}
+
+func TestTagCloud(t *testing.T) {
+	got := TagCloud([]TagCount{{Name: "web", Count: 1}, {Name: "go", Count: 100}, {Name: "sql", Count: 10}}, 5)
+	want := []CloudTag{
+		{Name: "go", Count: 100, Level: 5},
+		{Name: "sql", Count: 10, Level: 3},
+		{Name: "web", Count: 1, Level: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TagCloud() = %v, want %v", got, want)
+	}
+
+	// all tags are used equally
+	got = TagCloud([]TagCount{{Name: "a", Count: 2}, {Name: "b", Count: 2}}, 3)
+	if got[0].Level != 3 || got[1].Level != 3 {
+		t.Errorf("TagCloud() = %v, want all tags at level 3", got)
+	}
+}
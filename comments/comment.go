@@ -0,0 +1,143 @@
+package comments
+
+import (
+	"errors"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// Status says whether the comment is shown to readers
+type Status string
+
+const (
+	// Pending comments wait until an admin approves them
+	Pending Status = "pending"
+
+	// Visible comments are shown under the article
+	Visible Status = "visible"
+
+	// Hidden comments were hidden by an admin
+	Hidden Status = "hidden"
+)
+
+// Statuses lists all valid statuses in the order they're usually used in
+var Statuses = []Status{Pending, Visible, Hidden}
+
+// ParseStatus converts a string to a Status, returning an error if it's not
+// a valid status
+func ParseStatus(str string) (Status, error) {
+	for _, s := range Statuses {
+		if string(s) == str {
+			return s, nil
+		}
+	}
+	return "", errors.New("invalid comment status")
+}
+
+// Comment is written by a reader under an article
+type Comment struct {
+	// Unique identifier used internally
+	ID uint64
+
+	// The article the comment belongs to
+	ArticleID uint64
+
+	// The comment this one replies to, 0 if it's not a reply
+	ParentID uint64
+
+	// The user who has written the comment, 0 for anonymous comments
+	UserID uint64
+
+	// Display name of the user, or the name written by an anonymous commenter
+	Name string
+
+	// When the comment was written
+	Time time.Time
+
+	Status Status
+
+	// The comment as written by the commenter
+	// It can contain anything, so it always has to be escaped, see HTML
+	UnsafeContent string
+}
+
+// RemovedUserName is shown instead of the name of users who have been removed
+const RemovedUserName = "Removed user"
+
+// UserRemoved returns true if the comment has been written by a user who has
+// been removed since, Stores keep such comments without their user and name
+func (c Comment) UserRemoved() bool {
+	return c.UserID == 0 && c.Name == ""
+}
+
+// Author returns the name shown with the comment
+func (c Comment) Author() string {
+	if c.UserRemoved() {
+		return RemovedUserName
+	}
+	return c.Name
+}
+
+// HTML returns the escaped content of the comment, paragraphs are separated by
+// empty lines and lines are kept as they were written
+func (c Comment) HTML() template.HTML {
+	content := strings.ReplaceAll(strings.TrimSpace(c.UnsafeContent), "\r\n", "\n")
+
+	var b strings.Builder
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		lines := strings.Split(paragraph, "\n")
+		for k, line := range lines {
+			lines[k] = template.HTMLEscapeString(line)
+		}
+		b.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>")
+	}
+	return template.HTML(b.String())
+}
+
+// Thread is a comment together with all replies to it
+type Thread struct {
+	Comment
+
+	Replies []Thread
+}
+
+// Threads arranges the comments into threads, the order of the comments is
+// kept. Replies to comments which aren't in the slice are treated as if they
+// weren't replies
+func Threads(comments []Comment) []Thread {
+	known := make(map[uint64]bool)
+	for _, c := range comments {
+		known[c.ID] = true
+	}
+
+	replies := make(map[uint64][]Comment)
+	roots := make([]Comment, 0, 0)
+	for _, c := range comments {
+		if c.ParentID != 0 && c.ParentID != c.ID && known[c.ParentID] {
+			replies[c.ParentID] = append(replies[c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	// every comment is visited once, even if the replies form a cycle
+	visited := make(map[uint64]bool)
+	var build func(cs []Comment) []Thread
+	build = func(cs []Comment) []Thread {
+		threads := make([]Thread, 0, len(cs))
+		for _, c := range cs {
+			if visited[c.ID] {
+				continue
+			}
+			visited[c.ID] = true
+			threads = append(threads, Thread{Comment: c, Replies: build(replies[c.ID])})
+		}
+		return threads
+	}
+	return build(roots)
+}
@@ -0,0 +1,67 @@
+package comments
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestComment_HTML(t *testing.T) {
+	tests := []struct {
+		content string
+		want    template.HTML
+	}{
+		{content: "Hello", want: "<p>Hello</p>"},
+		{content: "<script>alert(1)</script>", want: "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{content: "one\r\ntwo\n\n\nthree ", want: "<p>one<br>two</p><p>three</p>"},
+		{content: "  ", want: ""},
+	}
+	for _, tt := range tests {
+		if got := (Comment{UnsafeContent: tt.content}).HTML(); got != tt.want {
+			t.Errorf("HTML() of %#v = %#v, want %#v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestComment_Author(t *testing.T) {
+	tests := []struct {
+		comment Comment
+		want    string
+	}{
+		{comment: Comment{UserID: 1, Name: "Jane"}, want: "Jane"},
+		{comment: Comment{Name: "Anonymous"}, want: "Anonymous"},
+		{comment: Comment{}, want: RemovedUserName},
+	}
+	for _, tt := range tests {
+		if got := tt.comment.Author(); got != tt.want {
+			t.Errorf("Author() of %#v = %#v, want %#v", tt.comment, got, tt.want)
+		}
+	}
+}
+
+func TestThreads(t *testing.T) {
+	threads := Threads([]Comment{
+		{ID: 1},
+		{ID: 2, ParentID: 1},
+		{ID: 3},
+		{ID: 4, ParentID: 2},
+		// the parent isn't on this page
+		{ID: 5, ParentID: 100},
+	})
+
+	if len(threads) != 3 || threads[0].ID != 1 || threads[1].ID != 3 || threads[2].ID != 5 {
+		t.Fatalf("Threads() returned wrong roots: %v", threads)
+	}
+	replies := threads[0].Replies
+	if len(replies) != 1 || replies[0].ID != 2 || len(replies[0].Replies) != 1 || replies[0].Replies[0].ID != 4 {
+		t.Errorf("Threads() returned wrong replies: %v", replies)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	if s, err := ParseStatus("hidden"); err != nil || s != Hidden {
+		t.Errorf("ParseStatus(\"hidden\") = %v, %v", s, err)
+	}
+	if _, err := ParseStatus("deleted"); err == nil {
+		t.Errorf("ParseStatus(\"deleted\") didn't return an error")
+	}
+}
@@ -0,0 +1,228 @@
+package spam
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaults used by Default
+const (
+	DefaultIPLimit      = 5
+	DefaultUserLimit    = 10
+	DefaultRateWindow   = 10 * time.Minute
+	DefaultHoneypotName = "website"
+	DefaultMinTime      = 3 * time.Second
+	DefaultMaxTime      = 24 * time.Hour
+	DefaultMaxLinks     = 2
+)
+
+// RateLimit rejects comments from IP addresses and users who have sent too
+// many comments recently
+// It's kept in memory, so every instance of Montesquieu counts on its own
+// Addresses are the ones of Submission.IP, without TrustedProxies in the
+// config, every reader behind the same reverse proxy shares a single limit
+type RateLimit struct {
+	// how many comments can be sent during Window, 0 means no limit
+	PerIP   int
+	PerUser int
+	Window  time.Duration
+
+	m sync.Mutex
+
+	// times of recent comments, keyed by "ip:{address}" or "user:{id}"
+	sent map[string][]time.Time
+
+	// the last time old entries were dropped from sent
+	swept time.Time
+}
+
+// NewRateLimit returns a RateLimit allowing perIP comments from a single IP
+// address and perUser comments from a single user per window
+func NewRateLimit(perIP int, perUser int, window time.Duration) *RateLimit {
+	return &RateLimit{
+		PerIP:   perIP,
+		PerUser: perUser,
+		Window:  window,
+		sent:    make(map[string][]time.Time),
+	}
+}
+
+// Check implements Check's Check function
+// Rejected attempts are counted too, so bots don't get through by retrying
+func (rl *RateLimit) Check(ctx context.Context, s Submission) (Result, error) {
+	rl.m.Lock()
+	defer rl.m.Unlock()
+
+	rl.sweep(s.Time)
+
+	exceeded := rl.hit("ip:"+s.IP, rl.PerIP, s.Time)
+	if s.Comment.UserID != 0 {
+		exceeded = rl.hit("user:"+strconv.FormatUint(s.Comment.UserID, 10), rl.PerUser, s.Time) || exceeded
+	}
+
+	if exceeded {
+		return Result{Verdict: Reject, Reason: "You're commenting too often, please try again later"}, nil
+	}
+	return Result{Verdict: Accept}, nil
+}
+
+// hit records a comment sent under key and returns true if there were more
+// than limit of them during the window
+// Assumes rl.m is locked
+func (rl *RateLimit) hit(key string, limit int, now time.Time) bool {
+	times := append(rl.recent(rl.sent[key], now), now)
+	rl.sent[key] = times
+	return limit > 0 && len(times) > limit
+}
+
+// recent returns only the times which are still within the window
+func (rl *RateLimit) recent(times []time.Time, now time.Time) []time.Time {
+	for len(times) > 0 && now.Sub(times[0]) >= rl.Window {
+		times = times[1:]
+	}
+	return times
+}
+
+// sweep forgets about everyone who hasn't commented during the window, so the
+// map doesn't grow forever
+// Assumes rl.m is locked
+func (rl *RateLimit) sweep(now time.Time) {
+	if rl.sent == nil {
+		rl.sent = make(map[string][]time.Time)
+	}
+	if now.Sub(rl.swept) < rl.Window {
+		return
+	}
+	rl.swept = now
+	for key, times := range rl.sent {
+		if times = rl.recent(times, now); len(times) == 0 {
+			delete(rl.sent, key)
+		} else {
+			rl.sent[key] = times
+		}
+	}
+}
+
+// Honeypot moderates comments which have a hidden field filled in
+// People don't see the field, but bots usually fill in every field they find
+type Honeypot struct {
+	// name of the field
+	Name string
+}
+
+// Fields implements FormCheck's Fields function
+func (h Honeypot) Fields(now time.Time) []Field {
+	return []Field{{Name: h.Name, Honeypot: true}}
+}
+
+// Check implements Check's Check function
+func (h Honeypot) Check(ctx context.Context, s Submission) (Result, error) {
+	if s.Form.Get(h.Name) != "" {
+		return Result{Verdict: Moderate, Reason: "a hidden field was filled in"}, nil
+	}
+	return Result{Verdict: Accept}, nil
+}
+
+// the name of the field with the token of TimeToken
+const timeTokenName = "token"
+
+// TimeToken moderates comments which were sent too quickly after the form
+// was shown, or which were sent without a valid form at all
+// The form carries the time it was rendered, signed so it can't be forged
+type TimeToken struct {
+	// the shortest time a person needs to write a comment
+	Min time.Duration
+
+	// forms older than this are considered stale
+	Max time.Duration
+
+	// the key tokens are signed with
+	key []byte
+}
+
+// NewTimeToken returns a TimeToken with a random key
+// Tokens issued before a restart aren't valid afterwards
+func NewTimeToken(min time.Duration, max time.Duration) *TimeToken {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("can't generate the key of time tokens: " + err.Error())
+	}
+	return &TimeToken{Min: min, Max: max, key: key}
+}
+
+// Fields implements FormCheck's Fields function
+func (tt *TimeToken) Fields(now time.Time) []Field {
+	return []Field{{Name: timeTokenName, Value: tt.token(now)}}
+}
+
+// Check implements Check's Check function
+func (tt *TimeToken) Check(ctx context.Context, s Submission) (Result, error) {
+	token := s.Form.Get(timeTokenName)
+	dot := strings.IndexByte(token, '.')
+	if dot == -1 {
+		return Result{Verdict: Moderate, Reason: "the form token is missing"}, nil
+	}
+	unix, err := strconv.ParseInt(token[:dot], 10, 64)
+	if err != nil || !hmac.Equal([]byte(token), []byte(tt.token(time.Unix(unix, 0)))) {
+		return Result{Verdict: Moderate, Reason: "the form token is invalid"}, nil
+	}
+
+	elapsed := s.Time.Sub(time.Unix(unix, 0))
+	if elapsed < tt.Min {
+		return Result{Verdict: Moderate, Reason: "the form was sent too quickly"}, nil
+	}
+	if tt.Max > 0 && elapsed > tt.Max {
+		return Result{Verdict: Moderate, Reason: "the form has expired"}, nil
+	}
+	return Result{Verdict: Accept}, nil
+}
+
+// token returns "{unix time}.{signature}" for the given time
+func (tt *TimeToken) token(t time.Time) string {
+	unix := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, tt.key)
+	mac.Write([]byte(unix))
+	return unix + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Links moderates comments with too many links
+type Links struct {
+	Max int
+}
+
+// Check implements Check's Check function
+func (l Links) Check(ctx context.Context, s Submission) (Result, error) {
+	text := strings.ToLower(s.Comment.Name + " " + s.Comment.UnsafeContent)
+	links := strings.Count(text, "http://") + strings.Count(text, "https://") +
+		strings.Count(text, "www.")
+	// "https://www." is a single link
+	links -= strings.Count(text, "://www.")
+
+	if links > l.Max {
+		return Result{Verdict: Moderate, Reason: "the comment contains too many links"}, nil
+	}
+	return Result{Verdict: Accept}, nil
+}
+
+// Blocklist moderates comments containing any of the words, ignoring case
+type Blocklist struct {
+	Words []string
+}
+
+// Check implements Check's Check function
+func (b Blocklist) Check(ctx context.Context, s Submission) (Result, error) {
+	text := strings.ToLower(s.Comment.Name + " " + s.Comment.UnsafeContent)
+	for _, w := range b.Words {
+		if w != "" && strings.Contains(text, strings.ToLower(w)) {
+			return Result{Verdict: Moderate, Reason: "the comment contains a blocked word"}, nil
+		}
+	}
+	return Result{Verdict: Accept}, nil
+}
@@ -0,0 +1,136 @@
+// Package spam decides whether comments sent by readers look like spam
+// Every heuristic is a Check, Checks are chained into a Pipeline, so blogs can
+// add checks of their own
+package spam
+
+import (
+	"context"
+	"github.com/david-sorm/montesquieu/comments"
+	"net/url"
+	"time"
+)
+
+// Verdict says what should happen with a comment
+// Stricter verdicts are greater, so they can be compared
+type Verdict int
+
+const (
+	// Accept lets the comment through
+	Accept Verdict = iota
+
+	// Moderate saves the comment as pending, so an admin has to approve it
+	Moderate
+
+	// Reject doesn't save the comment at all
+	Reject
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Accept:
+		return "accept"
+	case Moderate:
+		return "moderate"
+	case Reject:
+		return "reject"
+	}
+	return "unknown"
+}
+
+// Result is the verdict of a Check together with a reason
+type Result struct {
+	Verdict Verdict
+
+	// Why the comment wasn't accepted, it's shown to the commenter if the
+	// comment is rejected
+	Reason string
+}
+
+// Submission is a comment which is being sent, together with what's known
+// about its sender
+type Submission struct {
+	// The comment as it would be saved, Comment.UserID is 0 for anonymous
+	// commenters
+	Comment comments.Comment
+
+	// IP address of the sender, behind a reverse proxy it's only the address
+	// of the reader if the proxy is one of the TrustedProxies of the config
+	IP string
+
+	// The whole sent form, including the fields added by FormChecks
+	Form url.Values
+
+	// When the comment was sent
+	Time time.Time
+}
+
+// Check is a single heuristic
+type Check interface {
+	// Check returns the verdict for the submission, an error means the check
+	// couldn't be done, not that the comment is spam
+	Check(ctx context.Context, s Submission) (Result, error)
+}
+
+// Field is a field a FormCheck needs in the comment form
+type Field struct {
+	Name  string
+	Value string
+
+	// Honeypots are text fields hidden from people, but visible to bots
+	// Other fields are simply hidden inputs
+	Honeypot bool
+}
+
+// FormCheck is a Check which needs its own fields in the comment form
+type FormCheck interface {
+	Check
+
+	// Fields returns the fields of a form rendered at the given time
+	Fields(now time.Time) []Field
+}
+
+// Pipeline runs its Checks one after another, the strictest verdict wins
+type Pipeline []Check
+
+// Check implements Check's Check function
+// A rejection stops the pipeline, the remaining checks aren't run
+func (p Pipeline) Check(ctx context.Context, s Submission) (Result, error) {
+	result := Result{Verdict: Accept}
+	for _, c := range p {
+		r, err := c.Check(ctx, s)
+		if err != nil {
+			return Result{}, err
+		}
+		if r.Verdict > result.Verdict {
+			result = r
+		}
+		if result.Verdict == Reject {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Fields implements FormCheck's Fields function
+// It returns the fields of all FormChecks in the pipeline
+func (p Pipeline) Fields(now time.Time) []Field {
+	var fields []Field
+	for _, c := range p {
+		if fc, ok := c.(FormCheck); ok {
+			fields = append(fields, fc.Fields(now)...)
+		}
+	}
+	return fields
+}
+
+// Default returns the pipeline used unless configured otherwise
+// Comments containing any of the words in blocklist are moderated
+func Default(blocklist []string) Pipeline {
+	return Pipeline{
+		NewRateLimit(DefaultIPLimit, DefaultUserLimit, DefaultRateWindow),
+		Honeypot{Name: DefaultHoneypotName},
+		NewTimeToken(DefaultMinTime, DefaultMaxTime),
+		Links{Max: DefaultMaxLinks},
+		Blocklist{Words: blocklist},
+	}
+}
@@ -0,0 +1,156 @@
+package spam
+
+import (
+	"context"
+	"github.com/david-sorm/montesquieu/comments"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2020, 8, 1, 12, 0, 0, 0, time.UTC)
+
+// returns a submission of the content by an anonymous commenter from 10.0.0.1
+func submission(content string) Submission {
+	return Submission{
+		Comment: comments.Comment{Name: "Jane", UnsafeContent: content},
+		IP:      "10.0.0.1",
+		Form:    url.Values{},
+		Time:    now,
+	}
+}
+
+func check(t *testing.T, c Check, s Submission) Verdict {
+	r, err := c.Check(context.Background(), s)
+	if err != nil {
+		t.Fatalf("Check() returned an error: %v", err)
+	}
+	return r.Verdict
+}
+
+func TestRateLimit(t *testing.T) {
+	rl := NewRateLimit(2, 3, time.Minute)
+
+	s := submission("Hello")
+	for i := 0; i < 2; i++ {
+		if v := check(t, rl, s); v != Accept {
+			t.Fatalf("comment %v from an IP: got %v, want %v", i, v, Accept)
+		}
+	}
+	if v := check(t, rl, s); v != Reject {
+		t.Errorf("third comment from an IP: got %v, want %v", v, Reject)
+	}
+
+	// users are limited on their own, no matter which IP they use
+	for i := 0; i < 3; i++ {
+		s := submission("Hello")
+		s.IP = "10.0.1." + strconv.Itoa(i)
+		s.Comment.UserID = 7
+		if v := check(t, rl, s); v != Accept {
+			t.Fatalf("comment %v from a user: got %v, want %v", i, v, Accept)
+		}
+	}
+	s.IP, s.Comment.UserID = "10.0.0.9", 7
+	if v := check(t, rl, s); v != Reject {
+		t.Errorf("fourth comment from a user: got %v, want %v", v, Reject)
+	}
+
+	// the limit is over once the window passes
+	s = submission("Hello")
+	s.Time = now.Add(time.Minute)
+	if v := check(t, rl, s); v != Accept {
+		t.Errorf("comment after the window: got %v, want %v", v, Accept)
+	}
+}
+
+func TestHoneypot(t *testing.T) {
+	h := Honeypot{Name: "website"}
+	s := submission("Hello")
+	if v := check(t, h, s); v != Accept {
+		t.Errorf("empty honeypot: got %v, want %v", v, Accept)
+	}
+	s.Form.Set("website", "http://spam.example")
+	if v := check(t, h, s); v != Moderate {
+		t.Errorf("filled honeypot: got %v, want %v", v, Moderate)
+	}
+}
+
+func TestTimeToken(t *testing.T) {
+	tt := NewTimeToken(3*time.Second, time.Hour)
+	fields := tt.Fields(now)
+	if len(fields) != 1 {
+		t.Fatalf("Fields() returned %v", fields)
+	}
+	forged := NewTimeToken(0, time.Hour).Fields(now.Add(-time.Minute))[0].Value
+
+	tests := []struct {
+		name  string
+		token string
+		sent  time.Duration
+		want  Verdict
+	}{
+		{name: "valid", token: fields[0].Value, sent: 10 * time.Second, want: Accept},
+		{name: "too quick", token: fields[0].Value, sent: time.Second, want: Moderate},
+		{name: "expired", token: fields[0].Value, sent: 2 * time.Hour, want: Moderate},
+		{name: "missing", token: "", sent: 10 * time.Second, want: Moderate},
+		{name: "garbage", token: "a.b", sent: 10 * time.Second, want: Moderate},
+		{name: "forged", token: forged, sent: 10 * time.Second, want: Moderate},
+	}
+	for _, test := range tests {
+		s := submission("Hello")
+		s.Form.Set(fields[0].Name, test.token)
+		s.Time = now.Add(test.sent)
+		if v := check(t, tt, s); v != test.want {
+			t.Errorf("%v token: got %v, want %v", test.name, v, test.want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		content string
+		want    Verdict
+	}{
+		{content: "No links here", want: Accept},
+		{content: "See https://www.example.com and http://example.org", want: Accept},
+		{content: "www.a.example www.b.example HTTPS://c.example", want: Moderate},
+	}
+	for _, tt := range tests {
+		if v := check(t, Links{Max: 2}, submission(tt.content)); v != tt.want {
+			t.Errorf("%#v: got %v, want %v", tt.content, v, tt.want)
+		}
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	b := Blocklist{Words: []string{"", "Casino"}}
+	if v := check(t, b, submission("Nice article")); v != Accept {
+		t.Errorf("clean comment: got %v, want %v", v, Accept)
+	}
+	if v := check(t, b, submission("Best CASINO bonuses")); v != Moderate {
+		t.Errorf("blocked word: got %v, want %v", v, Moderate)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p := Pipeline{Links{Max: 0}, Honeypot{Name: "website"}, NewRateLimit(1, 1, time.Minute)}
+
+	if fields := p.Fields(now); len(fields) != 1 || fields[0].Name != "website" || !fields[0].Honeypot {
+		t.Errorf("Fields() returned %v", fields)
+	}
+
+	s := submission("https://example.com")
+	r, err := p.Check(context.Background(), s)
+	if err != nil {
+		t.Fatalf("Check() returned an error: %v", err)
+	}
+	if r.Verdict != Moderate || r.Reason == "" {
+		t.Errorf("first comment: got %v", r)
+	}
+
+	// the strictest verdict wins
+	if v := check(t, p, s); v != Reject {
+		t.Errorf("second comment: got %v, want %v", v, Reject)
+	}
+}
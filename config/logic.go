@@ -5,10 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -34,12 +38,19 @@ func (cfg *file) verifyConfig() string {
 		str += "ArticlesPerPage has to be a valid positive integer\n"
 	}
 
+	// verify preview length, it's optional
+	if cfg.PreviewLength != "" {
+		if num, err := strconv.Atoi(cfg.PreviewLength); err != nil || num <= 0 {
+			str += "PreviewLength has to be a valid positive integer\n"
+		}
+	}
+
 	// verify database type
 	validType := false
 	switch cfg.Store {
 	case "":
 		str += "Store can't be empty\n"
-	case "postgres":
+	case "postgres", "sqlite", "mock":
 		validType = true
 	}
 
@@ -47,6 +58,21 @@ func (cfg *file) verifyConfig() string {
 		str += "Store is invalid\n"
 	}
 
+	// verify store schema, it's optional, postgres doesn't allow longer names
+	if len(cfg.StoreSchema) > 63 {
+		str += "StoreSchema can't be longer than 63 bytes\n"
+	}
+
+	// verify how the Store is connected to
+	str += cfg.verifyStoreConnection()
+
+	// verify store timeout, it's optional
+	if cfg.StoreTimeout != "" {
+		if timeout, err := time.ParseDuration(cfg.StoreTimeout); err != nil || timeout <= 0 {
+			str += "StoreTimeout has to be a valid positive duration, for example 5s\n"
+		}
+	}
+
 	// verify caching engine
 	if cfg.CachingStore == "" {
 		str += "CachingStore can't be empty\n"
@@ -66,6 +92,29 @@ func (cfg *file) verifyConfig() string {
 		}
 	}
 
+	// verify anonymous comments, they're optional
+	if cfg.AnonymousComments != "" && !(strings.ToLower(cfg.AnonymousComments) == "yes" ||
+		strings.ToLower(cfg.AnonymousComments) == "no") {
+		str += "AnonymousComments can only be either 'yes' or 'no'\n"
+	}
+
+	// verify trusted proxies, they're optional
+	if _, err := parseTrustedProxies(cfg.TrustedProxies); err != nil {
+		str += err.Error() + "\n"
+	}
+
+	// verify shutdown timeout, it's optional
+	if cfg.ShutdownTimeout != "" {
+		if timeout, err := time.ParseDuration(cfg.ShutdownTimeout); err != nil || timeout <= 0 {
+			str += "ShutdownTimeout has to be a valid positive duration, for example 10s\n"
+		}
+	}
+
+	// verify other blogs, they're optional
+	if _, err := parseBlogs(cfg.Blogs); err != nil {
+		str += err.Error() + "\n"
+	}
+
 	// verify live templates
 	if cfg.HotSwapTemplates == "" {
 		str += "HotSwapTemplates can't be empty"
@@ -76,6 +125,100 @@ func (cfg *file) verifyConfig() string {
 	return str
 }
 
+// verifies the connection settings of the Store, which are all optional unless
+// the Store needs them
+func (cfg *file) verifyStoreConnection() string {
+	str := ""
+
+	if cfg.StoreURL != "" {
+		if u, err := url.Parse(cfg.StoreURL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			str += "StoreURL has to be a valid URL starting with postgres://\n"
+		}
+		if cfg.StoreHost != "" || cfg.StoreDB != "" || cfg.StoreUser != "" || cfg.StorePassword != "" || cfg.StorePort != "" {
+			str += "StoreHost, StoreDB, StoreUser, StorePassword and StorePort have to be empty if StoreURL is set\n"
+		}
+	}
+
+	if cfg.StorePort != "" {
+		if port, err := strconv.Atoi(cfg.StorePort); err != nil || port <= 0 || port > 65535 {
+			str += "StorePort has to be a valid port number\n"
+		}
+	}
+
+	switch cfg.StoreSSLMode {
+	case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		str += "StoreSSLMode can only be disable, allow, prefer, require, verify-ca or verify-full\n"
+	}
+
+	// the certificates have to be readable files
+	certs := []struct {
+		name string
+		path string
+	}{
+		{"StoreSSLRootCert", cfg.StoreSSLRootCert},
+		{"StoreSSLCert", cfg.StoreSSLCert},
+		{"StoreSSLKey", cfg.StoreSSLKey},
+	}
+	for _, cert := range certs {
+		if cert.path == "" {
+			continue
+		}
+		if info, err := os.Stat(cert.path); err != nil || info.IsDir() {
+			str += cert.name + " has to be the path of an existing file\n"
+		}
+	}
+	if (cfg.StoreSSLCert == "") != (cfg.StoreSSLKey == "") {
+		str += "StoreSSLCert and StoreSSLKey have to be set together\n"
+	}
+
+	// postgres counts connections in int32
+	var minConns, maxConns uint64
+	if cfg.StoreMinConns != "" {
+		var err error
+		if minConns, err = strconv.ParseUint(cfg.StoreMinConns, 10, 31); err != nil {
+			str += "StoreMinConns has to be a valid non-negative integer\n"
+		}
+	}
+	if cfg.StoreMaxConns != "" {
+		var err error
+		if maxConns, err = strconv.ParseUint(cfg.StoreMaxConns, 10, 31); err != nil || maxConns == 0 {
+			str += "StoreMaxConns has to be a valid positive integer\n"
+		}
+	}
+	if maxConns != 0 && minConns > maxConns {
+		str += "StoreMinConns can't be larger than StoreMaxConns\n"
+	}
+
+	durations := []struct {
+		name  string
+		value string
+	}{
+		{"StoreConnectTimeout", cfg.StoreConnectTimeout},
+		{"StoreRetryBackoff", cfg.StoreRetryBackoff},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if duration, err := time.ParseDuration(d.value); err != nil || duration <= 0 {
+			str += d.name + " has to be a valid positive duration, for example 1s\n"
+		}
+	}
+
+	// the file is created if it doesn't exist, but its directory isn't
+	if cfg.Store == "sqlite" && cfg.StorePath == "" {
+		str += "StorePath can't be empty if Store is sqlite\n"
+	}
+	if cfg.StorePath != "" {
+		if info, err := os.Stat(filepath.Dir(cfg.StorePath)); err != nil || !info.IsDir() {
+			str += "StorePath has to be in an existing directory\n"
+		}
+	}
+
+	return str
+}
+
 // returns true if the config is empty (all values at "") or false if not
 func (cfg *file) configEmpty() bool {
 	v := reflect.ValueOf(*cfg)
@@ -92,38 +235,134 @@ func (cfg *file) configEmpty() bool {
 	return true
 }
 
-// reads the config from config.json
-func (cfg *file) readConfigFile() {
+// parses the Blogs of the config into a map of config files by host
+// hosts are lower-case and without a port, since that's how requests are matched
+func parseBlogs(blogs string) (map[string]string, error) {
+	files := map[string]string{}
+	for _, entry := range strings.Split(blogs, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("Blogs has to be a comma-separated list of host=file, %#v isn't", entry)
+		}
+		host := strings.ToLower(strings.TrimSpace(entry[:i]))
+		path := strings.TrimSpace(entry[i+1:])
+		if host == "" || path == "" || strings.Contains(host, ":") {
+			return nil, fmt.Errorf("Blogs has to be a comma-separated list of host=file, %#v isn't", entry)
+		}
+		if _, ok := files[host]; ok {
+			return nil, fmt.Errorf("Blogs contains the host %v more than once", host)
+		}
+		files[host] = path
+	}
+	return files, nil
+}
+
+// parses the TrustedProxies of the config into networks, a single address is
+// a network of its own
+func parseTrustedProxies(proxies string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(proxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			entry = fmt.Sprintf("%v/%v", entry, bits)
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("TrustedProxies has to be a comma-separated list of addresses or networks, %#v isn't", entry)
+		}
+		nets = append(nets, network)
+	}
+	return nets, nil
+}
+
+// reads the config from the file at path
+func (cfg *file) readConfigFile(path string) {
 	// open file
-	file, err := os.Open("config.json")
+	file, err := os.Open(path)
 	if err != nil {
-		panic("Can't read config.json")
+		panic("Can't read " + path)
 	}
 
 	// read json, unmarshal and return
 	bytes, err := ioutil.ReadAll(file)
 	if err != nil {
-		panic("Error while reading config.json")
+		panic("Error while reading " + path)
 	}
 	if json.Unmarshal(bytes, &cfg) != nil {
-		panic("The syntax of config.json is invalid")
+		panic("The syntax of " + path + " is invalid")
 	}
 }
 
-// reads the config from environmental variables passed by the shell/docker engine
-func (cfg *file) readConfigEnv() {
-	cfg.BlogName = os.Getenv("BLOG_NAME")
-	cfg.ArticlesPerPage = os.Getenv("ARTICLES_PER_PAGE")
-	cfg.ListenOn = os.Getenv("LISTEN_ON")
-	cfg.Store = os.Getenv("STORE")
-	cfg.StoreHost = os.Getenv("STORE_HOST")
-	cfg.StoreDB = os.Getenv("STORE_DB")
-	cfg.StoreUser = os.Getenv("STORE_USER")
-	cfg.StorePassword = os.Getenv("STORE_PASSWORD")
-	cfg.StorePort = os.Getenv("STORE_PORT")
-	cfg.CachingStore = os.Getenv("CACHING_STORE")
-	cfg.HotSwapTemplates = os.Getenv("HOT_SWAP_TEMPLATES")
+// reads, verifies and parses the configs of the other blogs of cfg
+func (cfg *file) readBlogs() (map[string]*Config, error) {
+	files, err := parseBlogs(cfg.Blogs)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	blogs := map[string]*Config{}
+	for host, path := range files {
+		blogCfg := &file{}
+		blogCfg.readConfigFile(path)
+
+		// the server is shared, so these come from the main config
+		blogCfg.ListenOn = cfg.ListenOn
+		blogCfg.HotSwapTemplates = cfg.HotSwapTemplates
+		blogCfg.ShutdownTimeout = cfg.ShutdownTimeout
+
+		errs := blogCfg.verifyConfig()
+		if blogCfg.Blogs != "" {
+			errs += "Blogs can only be set in config.json\n"
+		}
+		if len(errs) != 0 {
+			return nil, errors.New("in " + path + ":\n" + errs)
+		}
+		blogs[host] = blogCfg.parseFile()
+	}
+	return blogs, nil
+}
 
+// overrides the postgres connection settings with environmental variables
+// passed by the shell/docker engine, so secrets don't have to be kept in the
+// config file, variables which aren't set or are empty don't change anything
+// They're named like the settings in upper snake case, only StoreURL is read
+// from DATABASE_URL, which is what most hosting providers set
+func (cfg *file) readConfigEnv() {
+	vars := []struct {
+		name    string
+		setting *string
+	}{
+		{"STORE_HOST", &cfg.StoreHost},
+		{"STORE_DB", &cfg.StoreDB},
+		{"STORE_USER", &cfg.StoreUser},
+		{"STORE_PASSWORD", &cfg.StorePassword},
+		{"STORE_PORT", &cfg.StorePort},
+		{"DATABASE_URL", &cfg.StoreURL},
+		{"STORE_SSL_MODE", &cfg.StoreSSLMode},
+		{"STORE_SSL_ROOT_CERT", &cfg.StoreSSLRootCert},
+		{"STORE_SSL_CERT", &cfg.StoreSSLCert},
+		{"STORE_SSL_KEY", &cfg.StoreSSLKey},
+		{"STORE_MIN_CONNS", &cfg.StoreMinConns},
+		{"STORE_MAX_CONNS", &cfg.StoreMaxConns},
+		{"STORE_CONNECT_TIMEOUT", &cfg.StoreConnectTimeout},
+		{"STORE_RETRY_BACKOFF", &cfg.StoreRetryBackoff},
+	}
+	for _, v := range vars {
+		if value := os.Getenv(v.name); value != "" {
+			*v.setting = value
+		}
+	}
 }
 
 func (cfg *file) createConfig() {
@@ -137,8 +376,12 @@ func (cfg *file) createConfig() {
 	cfg.BlogName = "My blog"
 	cfg.ListenOn = ":8080"
 	cfg.Store = "postgres"
+	cfg.StoreTimeout = "5s"
 	cfg.CachingStore = "off"
 	cfg.ArticlesPerPage = "5"
+	cfg.PreviewLength = "50"
+	cfg.AnonymousComments = "no"
+	cfg.ShutdownTimeout = "10s"
 
 	// marshal json and save
 	bytes, _ := json.MarshalIndent(cfg, "", "\t")
@@ -162,8 +405,10 @@ func NewConfig() (*Config, error) {
 		cfg.createConfig()
 	}
 
-	// read and verify the config
-	cfg.readConfigFile()
+	// read and verify the config, the environment takes precedence over the
+	// file, so secrets like DATABASE_URL don't have to be kept in it
+	cfg.readConfigFile("config.json")
+	cfg.readConfigEnv()
 	errs := cfg.verifyConfig()
 
 	// if any errors were found, lets return the errors
@@ -171,6 +416,12 @@ func NewConfig() (*Config, error) {
 		return nil, errors.New(errs)
 	}
 
-	// parse and return config
-	return cfg.parseFile(), nil
+	// parse and return config, together with the configs of other blogs
+	blogs, err := cfg.readBlogs()
+	if err != nil {
+		return nil, err
+	}
+	parsedCfg := cfg.parseFile()
+	parsedCfg.Blogs = blogs
+	return parsedCfg, nil
 }
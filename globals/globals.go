@@ -1,6 +1,7 @@
 package globals
 
 import (
+	"context"
 	"github.com/david-sorm/montesquieu/config"
 )
 
@@ -11,3 +12,21 @@ type BlogInformation struct {
 var BlogInfo BlogInformation
 
 var Cfg *config.Config
+
+// the key of the blog's config in the context of a request
+type configKey struct{}
+
+// WithConfig returns a copy of ctx carrying the config of the blog a request
+// belongs to, it's used when several blogs are served by one process
+func WithConfig(ctx context.Context, cfg *config.Config) context.Context {
+	return context.WithValue(ctx, configKey{}, cfg)
+}
+
+// Config returns the config of the blog ctx belongs to, which is Cfg unless
+// the request was routed to another blog by its Host
+func Config(ctx context.Context) *config.Config {
+	if cfg, ok := ctx.Value(configKey{}).(*config.Config); ok {
+		return cfg
+	}
+	return Cfg
+}
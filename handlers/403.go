@@ -0,0 +1,10 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// Generic 403 page, for users which don't have access to the requested page
+func Handle403(rw http.ResponseWriter, req *http.Request) {
+	HandleError(rw, req, http.StatusForbidden)
+}
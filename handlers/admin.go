@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"fmt"
+	"github.com/david-sorm/montesquieu/article"
 	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/users"
 	"net/http"
+	"strconv"
+	"strings"
 )
 import templates "github.com/david-sorm/montesquieu/template"
 
@@ -13,36 +17,116 @@ func HandleAdminPanel(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// parses the ID of whatever the admin panel shows from URLs like
+// /admin/panel/articles/edit/{id} or /admin/panel/users/role/{id}
+func idFromPath(req *http.Request, prefix string) (uint64, bool) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(req.URL.Path, prefix), 10, 64)
+	return id, err == nil
+}
+
+// loads articles of all statuses which the viewer can edit, sorted from latest
+func loadEditableArticles(req *http.Request, v viewer, from uint64, to uint64) ([]article.Article, error) {
+	cfg := globals.Config(req.Context())
+	if v.Can(users.EditAllArticles) {
+		return cfg.Store.LoadAllArticlesSortedByLatest(req.Context(), from, to)
+	}
+	// users who aren't linked to an author don't have any articles
+	if v.AuthorID == 0 {
+		return []article.Article{}, nil
+	}
+	return cfg.Store.LoadArticlesByAuthor(req.Context(), v.AuthorID, from, to)
+}
+
+// authors only see their own articles
 func HandleAdminPanelArticles(rw http.ResponseWriter, req *http.Request) {
-	data := globals.Cfg.Store.LoadArticlesSortedByLatest(0, 100)
+	data, err := loadEditableArticles(req, viewerFrom(req), 0, 100)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
 	if err := templates.Store.Lookup("adminPanelArticles.gohtml").Execute(rw, data); err != nil {
 		fmt.Println("Error while parsing template:", err.Error())
 	}
 }
 
+type UsersView struct {
+	Users []users.User
+
+	// roles which can be picked for users
+	Roles []users.Role
+
+	// the logged in admin, who can't change their own role
+	ViewerID uint64
+}
+
 func HandleAdminPanelUsers(rw http.ResponseWriter, req *http.Request) {
-	data := globals.Cfg.Store.ListUsers(0, 100)
-	if err := templates.Store.Lookup("adminPanelUsers.gohtml").Execute(rw, data); err != nil {
+	data, err := globals.Config(req.Context()).Store.ListUsers(req.Context(), 0, 100)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	view := UsersView{Users: data, Roles: users.Roles, ViewerID: viewerFrom(req).UserID}
+	if err := templates.Store.Lookup("adminPanelUsers.gohtml").Execute(rw, view); err != nil {
 		fmt.Println("Error while parsing template:", err.Error())
 	}
 }
 
+// handles /admin/panel/users/role/{id}
+// POST changes the role of the user
+func HandleAdminPanelUserRole(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", "POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+		return
+	}
+	id, valid := idFromPath(req, "/admin/panel/users/role/")
+	if !valid {
+		Handle404(rw, req)
+		return
+	}
+	role, err := users.ParseRole(req.PostFormValue("role"))
+	if err != nil {
+		HandleError(rw, req, http.StatusBadRequest)
+		return
+	}
+	// nobody would be able to change it back if they were the last admin
+	if id == viewerFrom(req).UserID {
+		HandleError(rw, req, http.StatusConflict)
+		return
+	}
+
+	if err := globals.Config(req.Context()).Store.SetRole(req.Context(), id, role); err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	http.Redirect(rw, req, "/admin/panel/users", http.StatusSeeOther)
+}
+
 func HandleAdminPanelAuthors(rw http.ResponseWriter, req *http.Request) {
-	data := globals.Cfg.Store.ListAuthors(0, 100)
+	data, err := globals.Config(req.Context()).Store.ListAuthors(req.Context(), 0, 100)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
 	if err := templates.Store.Lookup("adminPanelAuthors.gohtml").Execute(rw, data); err != nil {
 		fmt.Println("Error while parsing template:", err.Error())
 	}
 }
 
 func HandleAdminPanelAdmins(rw http.ResponseWriter, req *http.Request) {
-	data := globals.Cfg.Store.ListAdmins(0, 100)
+	data, err := globals.Config(req.Context()).Store.ListAdmins(req.Context(), 0, 100)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
 	if err := templates.Store.Lookup("adminPanelAdmins.gohtml").Execute(rw, data); err != nil {
 		fmt.Println("Error while parsing template:", err.Error())
 	}
 }
 
 func HandleAdminPanelConfiguration(rw http.ResponseWriter, req *http.Request) {
-	cachingEngine := globals.Cfg.CachingStore != nil
+	cfg := globals.Config(req.Context())
+	cachingEngine := cfg.CachingStore != nil
 	data := struct {
 		BlogName        string
 		ArticlesPerPage uint64
@@ -53,14 +137,14 @@ func HandleAdminPanelConfiguration(rw http.ResponseWriter, req *http.Request) {
 		CachingStore    bool
 		ListenOn        string
 	}{
-		globals.Cfg.BlogName,
-		globals.Cfg.ArticlesPerPage,
+		cfg.BlogName,
+		cfg.ArticlesPerPage,
 		"something",
-		globals.Cfg.StoreHost,
-		globals.Cfg.StoreDB,
-		globals.Cfg.StoreUser,
+		cfg.StoreHost,
+		cfg.StoreDB,
+		cfg.StoreUser,
 		cachingEngine,
-		globals.Cfg.ListenOn,
+		cfg.ListenOn,
 	}
 	if err := templates.Store.Lookup("adminPanelConfiguration.gohtml").Execute(rw, data); err != nil {
 		fmt.Println("Error while parsing template:", err.Error())
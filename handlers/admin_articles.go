@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	templates "github.com/david-sorm/montesquieu/template"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// format used by <input type="datetime-local">
+const datetimeLocalFormat = "2006-01-02T15:04"
+
+type ArticleEditorView struct {
+	// the article which is being edited, empty for new articles
+	Article article.Article
+
+	// true if a new article is being made
+	New bool
+
+	// authors which can be picked for the article, authors can only pick
+	// themselves
+	Authors []users.Author
+
+	// statuses which can be picked for the article, only editors can publish
+	Statuses []article.Status
+
+	// tags of the article separated by commas
+	Tags string
+
+	// time of the article, formatted for the datetime-local input
+	Time string
+
+	// shown to the user if the article couldn't be saved
+	Error string
+}
+
+// parses the article sent from the article editor
+func parseArticleForm(req *http.Request) (article.Article, error) {
+	a := article.Article{
+		Title:   strings.TrimSpace(req.PostFormValue("title")),
+		Source:  req.PostFormValue("content"),
+		Summary: strings.TrimSpace(req.PostFormValue("summary")),
+		Slug:    strings.TrimSpace(req.PostFormValue("slug")),
+		Tags:    article.ParseTags(req.PostFormValue("tags")),
+	}
+
+	if a.Title == "" {
+		return a, errors.New("The title can't be empty")
+	}
+
+	authorID, err := strconv.ParseUint(req.PostFormValue("author"), 10, 64)
+	if err != nil {
+		return a, errors.New("Please pick an author")
+	}
+	a.AuthorID = authorID
+
+	// the time is optional, articles without it are published right now
+	a.Timestamp = uint64(time.Now().Unix())
+	if str := req.PostFormValue("time"); str != "" {
+		t, err := time.Parse(datetimeLocalFormat, str)
+		if err != nil {
+			return a, errors.New("The time is invalid")
+		}
+		a.Timestamp = uint64(t.Unix())
+	}
+
+	status, err := article.ParseStatus(req.PostFormValue("status"))
+	if err != nil {
+		return a, errors.New("Please pick a status")
+	}
+	a.Status = status
+	a.Schedule(time.Now())
+
+	return a, nil
+}
+
+// loads the article and makes sure the viewer can edit it, if ok is false, an
+// error page has already been sent
+func loadEditableArticle(rw http.ResponseWriter, req *http.Request, id uint64) (a article.Article, ok bool) {
+	a, err := globals.Config(req.Context()).Store.GetArticleByID(req.Context(), id)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return a, false
+	}
+	if !viewerFrom(req).CanEditArticle(a) {
+		Handle403(rw, req)
+		return a, false
+	}
+	return a, true
+}
+
+// renders the article editor, or an error page if the authors can't be loaded
+// old is the article before it's changed, it's empty for new articles
+func renderArticleEditor(rw http.ResponseWriter, req *http.Request, view ArticleEditorView, old article.Article) {
+	cfg := globals.Config(req.Context())
+	v := viewerFrom(req)
+	var authors []users.Author
+	var err error
+	if v.Can(users.EditAllArticles) {
+		authors, err = cfg.Store.ListAuthors(req.Context(), 0, 100)
+	} else if v.AuthorID != 0 {
+		var author users.Author
+		author, err = cfg.Store.GetAuthorByID(req.Context(), v.AuthorID)
+		authors = []users.Author{author}
+	}
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	view.Authors = authors
+	view.Statuses = v.Statuses(old.Status)
+	view.Tags = strings.Join(view.Article.Tags, ", ")
+
+	// articles written before Markdown was supported don't have a source, but
+	// their HTML is valid Markdown too
+	if view.Article.Source == "" {
+		view.Article.Source = string(view.Article.Content)
+	}
+
+	if view.Article.Timestamp != 0 {
+		view.Time = time.Unix(int64(view.Article.Timestamp), 0).UTC().Format(datetimeLocalFormat)
+	}
+
+	if view.Error != "" {
+		rw.WriteHeader(http.StatusBadRequest)
+	}
+	if err := templates.Store.Lookup("adminPanelArticleEditor.gohtml").Execute(rw, view); err != nil {
+		fmt.Println("Error while parsing template:", err.Error())
+	}
+}
+
+// saves the article from the editor using save, the editor is shown again with
+// an error message if the article is invalid or if the viewer can't save it
+// old is the article before it's changed, it's empty for new articles
+func saveArticle(rw http.ResponseWriter, req *http.Request, view ArticleEditorView, old article.Article,
+	save func(a article.Article) error) {
+	a, err := parseArticleForm(req)
+	a.ID = old.ID
+	view.Article = a
+	if err == nil {
+		err = viewerFrom(req).checkArticle(a, old)
+	}
+	if err != nil {
+		view.Error = err.Error()
+		renderArticleEditor(rw, req, view, old)
+		return
+	}
+
+	err = save(a)
+	if errors.Is(err, store.ErrInvalidInput) {
+		view.Error = "The article couldn't be saved, please check if the author exists"
+		renderArticleEditor(rw, req, view, old)
+		return
+	}
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	http.Redirect(rw, req, "/admin/panel/articles", http.StatusSeeOther)
+}
+
+// handles /admin/panel/articles/new
+func HandleAdminPanelArticleNew(rw http.ResponseWriter, req *http.Request) {
+	view := ArticleEditorView{New: true, Article: article.Article{Status: article.Draft}}
+
+	switch req.Method {
+	case http.MethodGet:
+		renderArticleEditor(rw, req, view, article.Article{})
+	case http.MethodPost:
+		saveArticle(rw, req, view, article.Article{}, func(a article.Article) error {
+			_, err := globals.Config(req.Context()).Store.AddArticle(req.Context(), a, viewerFrom(req).UserID)
+			return err
+		})
+	default:
+		rw.Header().Set("Allow", "GET, POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+	}
+}
+
+// handles /admin/panel/articles/edit/{id}
+// Authors can only edit their own articles
+func HandleAdminPanelArticleEdit(rw http.ResponseWriter, req *http.Request) {
+	id, valid := idFromPath(req, "/admin/panel/articles/edit/")
+	if !valid {
+		Handle404(rw, req)
+		return
+	}
+
+	old, ok := loadEditableArticle(rw, req, id)
+	if !ok {
+		return
+	}
+	view := ArticleEditorView{Article: old}
+
+	switch req.Method {
+	case http.MethodGet:
+		renderArticleEditor(rw, req, view, old)
+	case http.MethodPost:
+		saveArticle(rw, req, view, old, func(a article.Article) error {
+			return globals.Config(req.Context()).Store.EditArticle(req.Context(), a, viewerFrom(req).UserID)
+		})
+	default:
+		rw.Header().Set("Allow", "GET, POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+	}
+}
+
+// handles /admin/panel/articles/delete/{id}
+// GET shows a confirmation, POST deletes the article
+func HandleAdminPanelArticleDelete(rw http.ResponseWriter, req *http.Request) {
+	id, valid := idFromPath(req, "/admin/panel/articles/delete/")
+	if !valid {
+		Handle404(rw, req)
+		return
+	}
+
+	a, ok := loadEditableArticle(rw, req, id)
+	if !ok {
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		if err := templates.Store.Lookup("adminPanelArticleDelete.gohtml").Execute(rw, a); err != nil {
+			fmt.Println("Error while parsing template:", err.Error())
+		}
+	case http.MethodPost:
+		if err := globals.Config(req.Context()).Store.RemoveArticle(req.Context(), id); err != nil {
+			handleStoreError(rw, req, err)
+			return
+		}
+		http.Redirect(rw, req, "/admin/panel/articles", http.StatusSeeOther)
+	default:
+		rw.Header().Set("Allow", "GET, POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+	}
+}
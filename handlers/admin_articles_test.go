@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseArticleForm(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    url.Values
+		wantErr bool
+		want    uint64
+	}{
+		{
+			name: "complete",
+			form: url.Values{"title": {"Title"}, "author": {"2"}, "time": {"2020-08-01T12:30"}, "content": {"<p>Hi</p>"}, "status": {"published"}},
+			want: uint64(time.Date(2020, 8, 1, 12, 30, 0, 0, time.UTC).Unix()),
+		},
+		{name: "empty title", form: url.Values{"title": {"  "}, "author": {"2"}}, wantErr: true},
+		{name: "missing author", form: url.Values{"title": {"Title"}}, wantErr: true},
+		{name: "invalid time", form: url.Values{"title": {"Title"}, "author": {"2"}, "time": {"yesterday"}}, wantErr: true},
+		{name: "invalid status", form: url.Values{"title": {"Title"}, "author": {"2"}, "status": {"live"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/admin/panel/articles/new", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		a, err := parseArticleForm(req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: parseArticleForm() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.want != 0 && a.Timestamp != tt.want {
+			t.Errorf("%v: parseArticleForm() timestamp = %v, want %v", tt.name, a.Timestamp, tt.want)
+		}
+	}
+}
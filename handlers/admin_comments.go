@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/david-sorm/montesquieu/comments"
+	"github.com/david-sorm/montesquieu/globals"
+	templates "github.com/david-sorm/montesquieu/template"
+	"net/http"
+	"net/url"
+)
+
+// how many comments are listed in the moderation queue at once
+const commentsPerModerationPage = 100
+
+type CommentsModerationView struct {
+	// the status of the listed comments
+	Status   comments.Status
+	Statuses []comments.Status
+	Comments []comments.Comment
+}
+
+// handles /admin/panel/comments?status={status}
+// pending comments are listed unless another status is asked for
+func HandleAdminPanelComments(rw http.ResponseWriter, req *http.Request) {
+	status := comments.Pending
+	if s := req.URL.Query().Get("status"); s != "" {
+		var err error
+		status, err = comments.ParseStatus(s)
+		if err != nil {
+			Handle404(rw, req)
+			return
+		}
+	}
+
+	cs, err := globals.Config(req.Context()).Store.ListCommentsByStatus(req.Context(), status, 0, commentsPerModerationPage)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	view := CommentsModerationView{
+		Status:   status,
+		Statuses: comments.Statuses,
+		Comments: cs,
+	}
+	if err := templates.Store.Lookup("adminPanelComments.gohtml").Execute(rw, view); err != nil {
+		fmt.Println("Error while parsing template:", err.Error())
+	}
+}
+
+// handles POST /admin/panel/comments/{id}
+// action is either approve, hide or delete
+func HandleAdminPanelComment(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", "POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, valid := idFromPath(req, "/admin/panel/comments/")
+	if !valid {
+		Handle404(rw, req)
+		return
+	}
+
+	var err error
+	switch req.PostFormValue("action") {
+	case "approve":
+		err = cfg.Store.SetCommentStatus(req.Context(), id, comments.Visible)
+	case "hide":
+		err = cfg.Store.SetCommentStatus(req.Context(), id, comments.Hidden)
+	case "delete":
+		err = cfg.Store.RemoveComment(req.Context(), id)
+	default:
+		HandleError(rw, req, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	// go back to the list the comment was moderated from
+	back := "/admin/panel/comments"
+	if status, err := comments.ParseStatus(req.PostFormValue("status")); err == nil {
+		back += "?status=" + url.QueryEscape(string(status))
+	}
+	http.Redirect(rw, req, back, http.StatusSeeOther)
+}
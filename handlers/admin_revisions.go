@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/globals"
+	templates "github.com/david-sorm/montesquieu/template"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// how many revisions of an article are listed in the admin panel
+const revisionsPerArticle = 100
+
+type ArticleRevisionsView struct {
+	Article   article.Article
+	Revisions []article.Revision
+}
+
+type RevisionView struct {
+	Revision article.Revision
+
+	// false if this is the first revision of the article, so there's nothing
+	// to compare it with
+	HasPrevious bool
+
+	// the diffs against the previous revision
+	TitleDiff   []article.DiffRow
+	SourceDiff  []article.DiffRow
+	SummaryDiff []article.DiffRow
+}
+
+// handles /admin/panel/articles/revisions/{id}
+func HandleAdminPanelArticleRevisions(rw http.ResponseWriter, req *http.Request) {
+	id, valid := idFromPath(req, "/admin/panel/articles/revisions/")
+	if !valid {
+		Handle404(rw, req)
+		return
+	}
+
+	a, ok := loadEditableArticle(rw, req, id)
+	if !ok {
+		return
+	}
+	revisions, err := globals.Config(req.Context()).Store.ListRevisions(req.Context(), id, 0, revisionsPerArticle)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	view := ArticleRevisionsView{Article: a, Revisions: revisions}
+	if err := templates.Store.Lookup("adminPanelArticleRevisions.gohtml").Execute(rw, view); err != nil {
+		fmt.Println("Error while parsing template:", err.Error())
+	}
+}
+
+// handles /admin/panel/revisions/{id}
+// GET shows what the revision changed, POST restores the article to it
+func HandleAdminPanelRevision(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	id, valid := idFromPath(req, "/admin/panel/revisions/")
+	if !valid {
+		Handle404(rw, req)
+		return
+	}
+
+	r, err := cfg.Store.GetRevision(req.Context(), id)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	if _, ok := loadEditableArticle(rw, req, r.ArticleID); !ok {
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		renderRevision(rw, req, r)
+	case http.MethodPost:
+		if err := cfg.Store.RestoreRevision(req.Context(), id, viewerFrom(req).UserID); err != nil {
+			handleStoreError(rw, req, err)
+			return
+		}
+		http.Redirect(rw, req, "/admin/panel/articles/revisions/"+
+			url.PathEscape(strconv.FormatUint(r.ArticleID, 10)), http.StatusSeeOther)
+	default:
+		rw.Header().Set("Allow", "GET, POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+	}
+}
+
+// renders the revision compared to the one before it
+func renderRevision(rw http.ResponseWriter, req *http.Request, r article.Revision) {
+	revisions, err := globals.Config(req.Context()).Store.ListRevisions(req.Context(), r.ArticleID, 0, revisionsPerArticle)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	// revisions are sorted from the latest, so the previous one comes right
+	// after this one
+	previous := article.Revision{}
+	view := RevisionView{Revision: r}
+	for k, v := range revisions {
+		if v.ID == r.ID && k+1 < len(revisions) {
+			previous = revisions[k+1]
+			view.HasPrevious = true
+		}
+	}
+
+	view.TitleDiff = article.Diff(previous.Title, r.Title)
+	view.SourceDiff = article.Diff(previous.Source, r.Source)
+	view.SummaryDiff = article.Diff(previous.Summary, r.Summary)
+
+	if err := templates.Store.Lookup("adminPanelRevision.gohtml").Execute(rw, view); err != nil {
+		fmt.Println("Error while parsing template:", err.Error())
+	}
+}
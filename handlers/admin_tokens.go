@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/david-sorm/montesquieu/globals"
+	templates "github.com/david-sorm/montesquieu/template"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// the longest name of an API token
+const maxTokenNameLength = 100
+
+type TokensView struct {
+	// API tokens of the logged in user
+	Tokens []users.Token
+
+	// scopes which can be picked for a new token
+	Scopes []users.TokenScope
+
+	// the token which has just been made, it can't be shown ever again
+	NewToken string
+
+	// the sent form, shown again if it's invalid
+	Name  string
+	Error string
+}
+
+// handles /admin/panel/tokens
+// GET lists tokens of the logged in user, POST makes a new one
+func HandleAdminPanelTokens(rw http.ResponseWriter, req *http.Request) {
+	userID, err := currentUserID(req)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	view := TokensView{}
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		token, err := parseTokenForm(req)
+		if err != nil {
+			view.Name = token.Name
+			view.Error = err.Error()
+			break
+		}
+		token.UserID = userID
+		view.NewToken, err = addToken(req, token)
+		if err != nil {
+			handleStoreError(rw, req, err)
+			return
+		}
+	default:
+		rw.Header().Set("Allow", "GET, POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+		return
+	}
+
+	renderTokens(rw, req, userID, view)
+}
+
+// renders the list of the user's tokens together with the form for a new one
+func renderTokens(rw http.ResponseWriter, req *http.Request, userID uint64, view TokensView) {
+	var err error
+	view.Tokens, err = globals.Config(req.Context()).Store.ListTokens(req.Context(), userID)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	view.Scopes = users.TokenScopes
+
+	// the page with a new token must not end up in any cache
+	if view.NewToken != "" {
+		rw.Header().Set("Cache-Control", "no-store")
+	}
+	if view.Error != "" {
+		rw.WriteHeader(http.StatusBadRequest)
+	}
+	if err := templates.Store.Lookup("adminPanelTokens.gohtml").Execute(rw, view); err != nil {
+		fmt.Println("Error while parsing template:", err.Error())
+	}
+}
+
+// parses the form for a new token
+func parseTokenForm(req *http.Request) (users.Token, error) {
+	t := users.Token{Name: strings.TrimSpace(req.PostFormValue("name"))}
+
+	if t.Name == "" {
+		return t, errors.New("The name can't be empty")
+	}
+	if utf8.RuneCountInString(t.Name) > maxTokenNameLength {
+		return t, errors.New("The name can't be longer than " + strconv.Itoa(maxTokenNameLength) + " characters")
+	}
+
+	scope, err := users.ParseTokenScope(req.PostFormValue("scope"))
+	if err != nil {
+		return t, errors.New("Please pick a scope")
+	}
+	t.Scope = scope
+	return t, nil
+}
+
+// saves the token with a new secret and returns the token which is given to
+// the user
+func addToken(req *http.Request, t users.Token) (string, error) {
+	secret, hash, err := users.GenerateTokenSecret()
+	if err != nil {
+		return "", err
+	}
+	t.Hash = hash
+	t.Created = time.Now()
+
+	id, err := globals.Config(req.Context()).Store.AddToken(req.Context(), t)
+	if err != nil {
+		return "", err
+	}
+	return users.FormatToken(id, secret), nil
+}
+
+// handles POST /admin/panel/tokens/{id}, which revokes the token
+// Users can only revoke their own tokens
+func HandleAdminPanelToken(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", "POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, valid := idFromPath(req, "/admin/panel/tokens/")
+	if !valid {
+		Handle404(rw, req)
+		return
+	}
+	userID, err := currentUserID(req)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	token, err := cfg.Store.GetToken(req.Context(), id)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	if token.UserID != userID {
+		Handle404(rw, req)
+		return
+	}
+	if err := cfg.Store.RemoveToken(req.Context(), id); err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	http.Redirect(rw, req, "/admin/panel/tokens", http.StatusSeeOther)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestHandleAdminPanelToken(t *testing.T) {
+	adminID, userID := prepareSessionTest(t)
+	ctx := context.Background()
+	cookie := login(t, "admin", "correct horse")
+
+	own, _ := globals.Cfg.Store.AddToken(ctx, users.Token{UserID: adminID, Name: "own", Scope: users.ScopeRead})
+	other, _ := globals.Cfg.Store.AddToken(ctx, users.Token{UserID: userID, Name: "other", Scope: users.ScopeRead})
+
+	revoke := func(id uint64) int {
+		req := httptest.NewRequest("POST", "/admin/panel/tokens/"+strconv.FormatUint(id, 10), nil)
+		req.AddCookie(cookie)
+		rw := httptest.NewRecorder()
+		HandleAdminPanelToken(rw, req)
+		return rw.Code
+	}
+
+	// tokens of other users can't be revoked
+	if code := revoke(other); code != http.StatusNotFound {
+		t.Errorf("revoking a token of another user: got status code %v, want %v", code, http.StatusNotFound)
+	}
+	if _, err := globals.Cfg.Store.GetToken(ctx, other); err != nil {
+		t.Errorf("a token of another user was revoked")
+	}
+
+	if code := revoke(own); code != http.StatusSeeOther {
+		t.Errorf("revoking an own token: got status code %v, want %v", code, http.StatusSeeOther)
+	}
+	if _, err := globals.Cfg.Store.GetToken(ctx, own); err == nil {
+		t.Errorf("the token wasn't revoked")
+	}
+}
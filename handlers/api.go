@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/users"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// all API routes start with this prefix, incompatible changes get a new version
+const apiPrefix = "/api/v1/"
+
+// pagination of lists in the API
+const (
+	apiDefaultLimit = 20
+	apiMaxLimit     = 100
+)
+
+// the largest request body the API accepts
+const apiMaxBodySize = 1 << 20
+
+// apiError is the body of every response with an error status code
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	// the same as the status code of the response
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiList is the body of responses with lists of items
+type apiList struct {
+	Items  interface{} `json:"items"`
+	Offset uint64      `json:"offset"`
+
+	// the limit which was asked for, the last page has fewer items than that
+	Limit uint64 `json:"limit"`
+
+	// the number of all items, only known for some lists
+	Total *uint64 `json:"total,omitempty"`
+}
+
+// writeJSON sends v as the JSON body of the response
+func writeJSON(rw http.ResponseWriter, code int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(code)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		fmt.Println("Error while writing a JSON response:", err.Error())
+	}
+}
+
+// writeAPIError sends an error body, the message is the description of the
+// status code if it's empty
+func writeAPIError(rw http.ResponseWriter, code int, message string) {
+	if message == "" {
+		message = http.StatusText(code)
+	}
+	writeJSON(rw, code, apiError{Error: apiErrorBody{Code: code, Message: message}})
+}
+
+// handleAPIStoreError is handleStoreError for the API
+func handleAPIStoreError(rw http.ResponseWriter, req *http.Request, err error) {
+	// the client has gone away, so there's nobody to respond to
+	if req.Context().Err() != nil {
+		return
+	}
+
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeAPIError(rw, http.StatusNotFound, "")
+	case errors.Is(err, store.ErrConflict):
+		writeAPIError(rw, http.StatusConflict, "")
+	case errors.Is(err, store.ErrInvalidInput):
+		writeAPIError(rw, http.StatusBadRequest, "")
+	case errors.Is(err, store.ErrUnavailable):
+		fmt.Println("Store is unavailable:", err.Error())
+		writeAPIError(rw, http.StatusServiceUnavailable, "")
+	default:
+		fmt.Println("An error has happened in the Store:", err.Error())
+		writeAPIError(rw, http.StatusInternalServerError, "")
+	}
+}
+
+// apiMethodNotAllowed responds with 405 and lists the allowed methods
+func apiMethodNotAllowed(rw http.ResponseWriter, allow string) {
+	rw.Header().Set("Allow", allow)
+	writeAPIError(rw, http.StatusMethodNotAllowed, "")
+}
+
+// readJSON decodes the body of the request into v, unknown fields aren't
+// allowed, so typos don't go unnoticed
+func readJSON(rw http.ResponseWriter, req *http.Request, v interface{}) error {
+	if ct := req.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		return errors.New("the body has to be JSON")
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(rw, req.Body, apiMaxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return errors.New("the body is invalid: " + err.Error())
+	}
+	return nil
+}
+
+// parsePagination reads ?offset= and ?limit= and returns the range of items
+// which should be listed
+func parsePagination(req *http.Request) (from uint64, to uint64, err error) {
+	q := req.URL.Query()
+	limit := uint64(apiDefaultLimit)
+	if str := q.Get("limit"); str != "" {
+		limit, err = strconv.ParseUint(str, 10, 64)
+		if err != nil || limit == 0 || limit > apiMaxLimit {
+			return 0, 0, fmt.Errorf("limit has to be between 1 and %v", apiMaxLimit)
+		}
+	}
+	if str := q.Get("offset"); str != "" {
+		from, err = strconv.ParseUint(str, 10, 64)
+		if err != nil || from > math.MaxUint64-limit {
+			return 0, 0, errors.New("offset has to be a non-negative integer")
+		}
+	}
+	return from, from + limit, nil
+}
+
+// writeAPIList sends a page of items, 'from' and 'to' are the page which was
+// asked for, even if there are fewer items, total is nil if it isn't known
+func writeAPIList(rw http.ResponseWriter, items interface{}, from uint64, to uint64, total *uint64) {
+	writeJSON(rw, http.StatusOK, apiList{
+		Items:  items,
+		Offset: from,
+		Limit:  to - from,
+		Total:  total,
+	})
+}
+
+// apiIDFromPath parses the ID from paths like /api/v1/articles/{id}
+// The collection itself, /api/v1/articles/, has no ID, so hasID is false
+func apiIDFromPath(req *http.Request, collection string) (id uint64, hasID bool, valid bool) {
+	rest := strings.TrimPrefix(req.URL.Path, apiPrefix+collection)
+	rest = strings.TrimPrefix(rest, "/")
+	if rest == "" {
+		return 0, false, true
+	}
+	id, err := strconv.ParseUint(rest, 10, 64)
+	return id, true, err == nil
+}
+
+// errInvalidToken is returned if the request has a bearer token, but it's not
+// valid, such requests aren't treated as anonymous
+var errInvalidToken = errors.New("the API token is invalid")
+
+// don't write to the Store on every request just to update the last usage
+const tokenTouchInterval = time.Minute
+
+// apiAuthenticate finds out who sends the request, either from the bearer token
+// in the Authorization header or from the session cookie
+// If there's neither, store.ErrNotFound is returned
+func apiAuthenticate(req *http.Request) (viewer, error) {
+	cfg := globals.Config(req.Context())
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		v, _, err := sessionViewer(req)
+		return v, err
+	}
+
+	const scheme = "bearer "
+	if len(header) <= len(scheme) || strings.ToLower(header[:len(scheme)]) != scheme {
+		return viewer{}, errInvalidToken
+	}
+	id, secret, err := users.ParseToken(strings.TrimSpace(header[len(scheme):]))
+	if err != nil {
+		return viewer{}, errInvalidToken
+	}
+
+	token, err := cfg.Store.GetToken(req.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return viewer{}, errInvalidToken
+	}
+	if err != nil {
+		return viewer{}, err
+	}
+	valid, err := token.Verify(secret)
+	if err != nil || !valid {
+		return viewer{}, errInvalidToken
+	}
+
+	now := time.Now()
+	if now.Sub(token.LastUsed) > tokenTouchInterval {
+		if err := cfg.Store.TouchToken(req.Context(), id, now); err != nil {
+			// the token is valid anyway, so this isn't fatal
+			fmt.Println("Error while touching a token:", err.Error())
+		}
+	}
+	return loadViewer(req.Context(), token.UserID, token.Scope)
+}
+
+// writeAPIAuthError responds to requests which couldn't be authenticated
+func writeAPIAuthError(rw http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		rw.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		writeAPIError(rw, http.StatusUnauthorized, "")
+	case errors.Is(err, errInvalidToken):
+		rw.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		writeAPIError(rw, http.StatusUnauthorized, err.Error())
+	default:
+		handleAPIStoreError(rw, req, err)
+	}
+}
+
+// handles everything under /api/ which doesn't exist
+func HandleAPINotFound(rw http.ResponseWriter, req *http.Request) {
+	writeAPIError(rw, http.StatusNotFound, "")
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiArticle is an article as it's shown by the API
+type apiArticle struct {
+	ID       uint64         `json:"id"`
+	Slug     string         `json:"slug"`
+	URL      string         `json:"url"`
+	Title    string         `json:"title"`
+	AuthorID uint64         `json:"authorId"`
+	Time     time.Time      `json:"time"`
+	Status   article.Status `json:"status"`
+	Tags     []string       `json:"tags"`
+	Summary  string         `json:"summary"`
+
+	// the source is only known when a single article is requested
+	Source string `json:"source,omitempty"`
+
+	// rendered HTML, lists only contain previews
+	Content string `json:"content,omitempty"`
+	Preview string `json:"preview,omitempty"`
+}
+
+// apiArticleInput is what's sent to make or change an article
+// Fields which aren't sent are nil, changed articles keep them as they are
+type apiArticleInput struct {
+	Title    *string    `json:"title"`
+	Slug     *string    `json:"slug"`
+	AuthorID *uint64    `json:"authorId"`
+	Time     *time.Time `json:"time"`
+	Status   *string    `json:"status"`
+	Tags     []string   `json:"tags"`
+	Summary  *string    `json:"summary"`
+	Source   *string    `json:"source"`
+}
+
+// newAPIArticle converts an article, preview says whether its content is only
+// a preview
+func newAPIArticle(a article.Article, preview bool) apiArticle {
+	tags := a.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	converted := apiArticle{
+		ID:       a.ID,
+		Slug:     a.Slug,
+		URL:      a.URL(),
+		Title:    a.Title,
+		AuthorID: a.AuthorID,
+		Time:     time.Unix(int64(a.Timestamp), 0).UTC(),
+		Status:   a.Status,
+		Tags:     tags,
+		Summary:  a.Summary,
+		Source:   a.Source,
+	}
+	if preview {
+		converted.Preview = string(a.Content)
+	} else {
+		converted.Content = string(a.Content)
+	}
+	return converted
+}
+
+// article applies the input to old the same way parseArticleForm converts the
+// form, new articles are made from an empty old article
+func (in apiArticleInput) article(old article.Article) (article.Article, error) {
+	a := old
+	if in.Title != nil {
+		a.Title = strings.TrimSpace(*in.Title)
+	}
+	if in.Slug != nil {
+		a.Slug = strings.TrimSpace(*in.Slug)
+	}
+	if in.AuthorID != nil {
+		a.AuthorID = *in.AuthorID
+	}
+	if in.Summary != nil {
+		a.Summary = strings.TrimSpace(*in.Summary)
+	}
+	if in.Source != nil {
+		a.Source = *in.Source
+	}
+	if in.Tags != nil {
+		a.Tags = article.ParseTags(strings.Join(in.Tags, ","))
+	}
+
+	if a.Title == "" {
+		return a, errors.New("title can't be empty")
+	}
+	if a.AuthorID == 0 {
+		return a, errors.New("authorId is required")
+	}
+
+	// the time is optional, new articles without it are published right now
+	if in.Time != nil && !in.Time.IsZero() {
+		a.Timestamp = uint64(in.Time.Unix())
+	} else if a.Timestamp == 0 {
+		a.Timestamp = uint64(time.Now().Unix())
+	}
+
+	// new articles are drafts unless said otherwise
+	if in.Status != nil && *in.Status != "" {
+		status, err := article.ParseStatus(*in.Status)
+		if err != nil {
+			return a, errors.New("status is invalid")
+		}
+		a.Status = status
+	} else if a.Status == "" {
+		a.Status = article.Draft
+	}
+	a.Schedule(time.Now())
+
+	return a, nil
+}
+
+// handles /api/v1/articles and /api/v1/articles/{id}
+// Published articles can be read by anyone, everything else is only for those
+// who can edit the articles
+func HandleAPIArticles(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	id, hasID, valid := apiIDFromPath(req, "articles")
+	if !valid {
+		writeAPIError(rw, http.StatusNotFound, "")
+		return
+	}
+
+	if !hasID {
+		switch req.Method {
+		case http.MethodGet:
+			listAPIArticles(rw, req)
+		case http.MethodPost:
+			if v, ok := authorizeAPI(rw, req, users.WriteArticles); ok {
+				saveAPIArticle(rw, req, 0, v)
+			}
+		default:
+			apiMethodNotAllowed(rw, "GET, POST")
+		}
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		getAPIArticle(rw, req, id)
+	case http.MethodPut:
+		if v, ok := authorizeAPI(rw, req, users.WriteArticles); ok {
+			saveAPIArticle(rw, req, id, v)
+		}
+	case http.MethodDelete:
+		v, ok := authorizeAPI(rw, req, users.WriteArticles)
+		if !ok {
+			return
+		}
+		a, err := cfg.Store.GetArticleByID(req.Context(), id)
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		if !v.CanEditArticle(a) {
+			writeAPIError(rw, http.StatusForbidden, "")
+			return
+		}
+		if err := cfg.Store.RemoveArticle(req.Context(), id); err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		apiMethodNotAllowed(rw, "GET, PUT, DELETE")
+	}
+}
+
+// lists published articles, or articles of all statuses with ?all=true
+// Authors only get their own articles when they list articles of all statuses
+func listAPIArticles(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	from, to, err := parsePagination(req)
+	if err != nil {
+		writeAPIError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	all, _ := strconv.ParseBool(req.URL.Query().Get("all"))
+
+	var articles []article.Article
+	var total *uint64
+	if all {
+		v, ok := authorizeAPI(rw, req, users.WriteArticles)
+		if !ok {
+			return
+		}
+		articles, err = loadEditableArticles(req, v, from, to)
+	} else {
+		var num uint64
+		num, err = cfg.Store.GetArticleNumber(req.Context())
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		total = &num
+		// Stores don't have to cope with ranges past the last article
+		last := to
+		if last > num {
+			last = num
+		}
+		if from < last {
+			articles, err = cfg.Store.LoadArticlesSortedByLatest(req.Context(), from, last)
+		}
+	}
+	if err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+
+	items := make([]apiArticle, 0, len(articles))
+	for _, a := range articles {
+		items = append(items, newAPIArticle(a, true))
+	}
+	writeAPIList(rw, items, from, to, total)
+}
+
+// sends a single article, articles which aren't published don't exist for
+// anyone who can't edit them
+func getAPIArticle(rw http.ResponseWriter, req *http.Request, id uint64) {
+	a, err := globals.Config(req.Context()).Store.GetArticleByID(req.Context(), id)
+	if err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+	if a.Status != article.Published {
+		v, ok := apiViewer(rw, req)
+		if !ok {
+			return
+		}
+		if !v.CanEditArticle(a) {
+			writeAPIError(rw, http.StatusNotFound, "")
+			return
+		}
+	}
+	writeJSON(rw, http.StatusOK, newAPIArticle(a, false))
+}
+
+// makes a new article if id is 0, otherwise changes the fields of the article
+// with the ID which have been sent
+// The revision is saved as made by the viewer
+func saveAPIArticle(rw http.ResponseWriter, req *http.Request, id uint64, v viewer) {
+	cfg := globals.Config(req.Context())
+	in := apiArticleInput{}
+	if err := readJSON(rw, req, &in); err != nil {
+		writeAPIError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	old := article.Article{}
+	if id != 0 {
+		var err error
+		old, err = cfg.Store.GetArticleByID(req.Context(), id)
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		if !v.CanEditArticle(old) {
+			writeAPIError(rw, http.StatusForbidden, "")
+			return
+		}
+	}
+	a, err := in.article(old)
+	if err != nil {
+		writeAPIError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := v.checkArticle(a, old); err != nil {
+		writeAPIError(rw, http.StatusForbidden, err.Error())
+		return
+	}
+
+	code := http.StatusOK
+	if id == 0 {
+		code = http.StatusCreated
+		id, err = cfg.Store.AddArticle(req.Context(), a, v.UserID)
+	} else {
+		a.ID = id
+		err = cfg.Store.EditArticle(req.Context(), a, v.UserID)
+	}
+	if errors.Is(err, store.ErrInvalidInput) {
+		writeAPIError(rw, http.StatusBadRequest, "the article couldn't be saved, please check if the author exists")
+		return
+	}
+	if err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+
+	saved, err := cfg.Store.GetArticleByID(req.Context(), id)
+	if err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+	if code == http.StatusCreated {
+		rw.Header().Set("Location", apiPrefix+"articles/"+strconv.FormatUint(id, 10))
+	}
+	writeJSON(rw, code, newAPIArticle(saved, false))
+}
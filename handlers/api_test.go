@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sends a request to the API handler, logged in if cookie isn't nil
+func apiRequest(handler http.HandlerFunc, method string, path string, body string,
+	cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rw := httptest.NewRecorder()
+	handler(rw, req)
+	return rw
+}
+
+// decodes the JSON body of the response into v
+func decodeBody(t *testing.T, rw *httptest.ResponseRecorder, v interface{}) {
+	if ct := rw.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("got Content-Type %#v, want JSON", ct)
+	}
+	if err := json.NewDecoder(rw.Body).Decode(v); err != nil {
+		t.Fatalf("the body isn't valid JSON: %v", err)
+	}
+}
+
+func TestHandleAPIArticles(t *testing.T) {
+	prepareSessionTest(t)
+
+	rw := apiRequest(HandleAPIArticles, "GET", "/api/v1/articles?offset=1&limit=3", "", nil)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("listing articles: got status code %v", rw.Code)
+	}
+	list := struct {
+		Items  []apiArticle
+		Offset uint64
+		Limit  uint64
+		Total  uint64
+	}{}
+	decodeBody(t, rw, &list)
+	num, _ := globals.Cfg.Store.GetArticleNumber(context.Background())
+	if len(list.Items) != 3 || list.Offset != 1 || list.Limit != 3 || list.Total != num {
+		t.Errorf("listing articles returned %+v", list)
+	}
+
+	// the last page keeps the limit which was asked for, like lists of users
+	rw = apiRequest(HandleAPIArticles, "GET", "/api/v1/articles?offset="+strconv.FormatUint(num-1, 10)+"&limit=5", "", nil)
+	list.Items = nil
+	decodeBody(t, rw, &list)
+	if len(list.Items) != 1 || list.Offset != num-1 || list.Limit != 5 {
+		t.Errorf("listing the last page of articles returned %+v", list)
+	}
+
+	rw = apiRequest(HandleAPIArticles, "GET", "/api/v1/articles/2", "", nil)
+	a := apiArticle{}
+	decodeBody(t, rw, &a)
+	if rw.Code != http.StatusOK || a.ID != 2 || a.Title != "Article 2" || a.URL != "/article/article-2" {
+		t.Errorf("getting an article: got status code %v and %+v", rw.Code, a)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{method: "GET", path: "/api/v1/articles?limit=0", code: http.StatusBadRequest},
+		{method: "GET", path: "/api/v1/articles?limit=101", code: http.StatusBadRequest},
+		{method: "GET", path: "/api/v1/articles?all=true", code: http.StatusUnauthorized},
+		{method: "GET", path: "/api/v1/articles/250604", code: http.StatusNotFound},
+		{method: "GET", path: "/api/v1/articles/first", code: http.StatusNotFound},
+		{method: "POST", path: "/api/v1/articles", code: http.StatusUnauthorized},
+		{method: "PUT", path: "/api/v1/articles/2", code: http.StatusUnauthorized},
+		{method: "DELETE", path: "/api/v1/articles/2", code: http.StatusUnauthorized},
+		{method: "PATCH", path: "/api/v1/articles/2", code: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rw := apiRequest(HandleAPIArticles, tt.method, tt.path, "", nil)
+		body := apiError{}
+		decodeBody(t, rw, &body)
+		if rw.Code != tt.code || body.Error.Code != tt.code || body.Error.Message == "" {
+			t.Errorf("%v %v: got status code %v and %+v, want %v", tt.method, tt.path, rw.Code, body, tt.code)
+		}
+	}
+}
+
+func TestHandleAPIArticles_Invalid(t *testing.T) {
+	prepareSessionTest(t)
+	cookie := login(t, "admin", "correct horse")
+
+	tests := []string{
+		`{"title": "", "authorId": 1}`,
+		`{"title": "Hello"}`,
+		`{"title": "Hello", "authorId": 1, "status": "secret"}`,
+		`{"title": "Hello", "authorId": 1, "unknown": true}`,
+		`[]`,
+	}
+	for _, body := range tests {
+		rw := apiRequest(HandleAPIArticles, "POST", "/api/v1/articles", body, cookie)
+		if rw.Code != http.StatusBadRequest {
+			t.Errorf("%v: got status code %v, want %v", body, rw.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleAPIArticles_Put(t *testing.T) {
+	prepareSessionTest(t)
+	cookie := login(t, "admin", "correct horse")
+
+	// puts the body to article 2 and returns the saved article
+	put := func(body string) apiArticle {
+		rw := apiRequest(HandleAPIArticles, "PUT", "/api/v1/articles/2", body, cookie)
+		a := apiArticle{}
+		decodeBody(t, rw, &a)
+		if rw.Code != http.StatusOK {
+			t.Fatalf("%v: got status code %v", body, rw.Code)
+		}
+		return a
+	}
+
+	rw := apiRequest(HandleAPIArticles, "GET", "/api/v1/articles/2", "", nil)
+	before := apiArticle{}
+	decodeBody(t, rw, &before)
+	put(`{"tags": ["Go", "Web dev"]}`)
+
+	// fields which aren't sent are kept
+	a := put(`{"title": " Renamed "}`)
+	if a.Title != "Renamed" || a.Source != before.Source || a.Summary != before.Summary || a.Slug != before.Slug ||
+		a.AuthorID != before.AuthorID || a.Status != before.Status || !a.Time.Equal(before.Time) ||
+		strings.Join(a.Tags, ",") != "go,web-dev" {
+		t.Errorf("changing the title: got %+v, want the rest of %+v", a, before)
+	}
+
+	// empty tags are sent, so they're removed
+	if a := put(`{"tags": [], "source": "New text"}`); len(a.Tags) != 0 || a.Source != "New text" || a.Title != "Renamed" {
+		t.Errorf("removing the tags: got %+v", a)
+	}
+	rw = apiRequest(HandleAPIArticles, "PUT", "/api/v1/articles/2", `{"title": ""}`, cookie)
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("removing the title: got status code %v, want %v", rw.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAuthorizeAPI(t *testing.T) {
+	prepareSessionTest(t)
+	handler := AuthorizeAPI(users.ManageUsers, HandleAPIUsers)
+
+	if rw := apiRequest(handler, "GET", "/api/v1/users/1", "", nil); rw.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous request: got status code %v, want %v", rw.Code, http.StatusUnauthorized)
+	}
+	user := login(t, "user", "correct horse")
+	if rw := apiRequest(handler, "GET", "/api/v1/users/1", "", user); rw.Code != http.StatusForbidden {
+		t.Errorf("request of a user: got status code %v, want %v", rw.Code, http.StatusForbidden)
+	}
+	admin := login(t, "admin", "correct horse")
+	if rw := apiRequest(handler, "GET", "/api/v1/users/1", "", admin); rw.Code != http.StatusOK {
+		t.Errorf("request of an admin: got status code %v, want %v", rw.Code, http.StatusOK)
+	}
+}
+
+func TestHandleAPIUsers(t *testing.T) {
+	adminID, _ := prepareSessionTest(t)
+	ctx := context.Background()
+	cookie := login(t, "admin", "correct horse")
+
+	body := `{"displayName": "Jane Doe", "login": "jane", "password": "secret"}`
+	rw := apiRequest(AuthorizeAPI(users.ManageUsers, HandleAPIUsers), "POST", "/api/v1/users", body, cookie)
+	if strings.Contains(rw.Body.String(), "secret") {
+		t.Errorf("the response contains the password")
+	}
+	u := apiUser{}
+	decodeBody(t, rw, &u)
+	if rw.Code != http.StatusCreated || u.Login != "jane" || u.ID == 0 {
+		t.Fatalf("adding a user: got status code %v and %+v", rw.Code, u)
+	}
+	if loc := rw.Header().Get("Location"); loc != "/api/v1/users/"+strconv.FormatUint(u.ID, 10) {
+		t.Errorf("adding a user: got Location %v", loc)
+	}
+	if rw := apiRequest(AuthorizeAPI(users.ManageUsers, HandleAPIUsers), "POST", "/api/v1/users", body, cookie); rw.Code != http.StatusConflict {
+		t.Errorf("adding a user twice: got status code %v, want %v", rw.Code, http.StatusConflict)
+	}
+
+	// the password stays the same unless it's sent
+	before, _ := globals.Cfg.Store.GetUser(ctx, u.ID)
+	rw = apiRequest(AuthorizeAPI(users.ManageUsers, HandleAPIUsers), "PUT", "/api/v1/users/"+strconv.FormatUint(u.ID, 10),
+		`{"displayName": "Jane", "login": "jane"}`, cookie)
+	after, _ := globals.Cfg.Store.GetUser(ctx, u.ID)
+	if rw.Code != http.StatusOK || after.DisplayName != "Jane" || after.Password != before.Password {
+		t.Errorf("editing a user: got status code %v and %+v", rw.Code, after)
+	}
+
+	// admins
+	rw = apiRequest(AuthorizeAPI(users.ManageUsers, HandleAPIAdmins), "PUT", "/api/v1/admins/"+strconv.FormatUint(u.ID, 10), "", cookie)
+	if isAdmin, _ := globals.Cfg.Store.IsAdmin(ctx, u.ID); rw.Code != http.StatusNoContent || !isAdmin {
+		t.Errorf("promoting a user: got status code %v, admin %v", rw.Code, isAdmin)
+	}
+	rw = apiRequest(AuthorizeAPI(users.ManageUsers, HandleAPIAdmins), "DELETE", "/api/v1/admins/"+strconv.FormatUint(adminID, 10), "", cookie)
+	if rw.Code != http.StatusConflict {
+		t.Errorf("demoting yourself: got status code %v, want %v", rw.Code, http.StatusConflict)
+	}
+}
+
+func TestAPIBearerTokens(t *testing.T) {
+	adminID, userID := prepareSessionTest(t)
+	ctx := context.Background()
+
+	// makes a token and returns the Authorization header carrying it
+	newToken := func(userID uint64, scope users.TokenScope) string {
+		token, err := addToken(httptest.NewRequest("POST", "/admin/panel/tokens", nil),
+			users.Token{UserID: userID, Name: "test", Scope: scope})
+		if err != nil {
+			t.Fatalf("addToken() returned an error: %v", err)
+		}
+		return "Bearer " + token
+	}
+	read := newToken(adminID, users.ScopeRead)
+	publish := newToken(adminID, users.ScopePublish)
+	notAdmin := newToken(userID, users.ScopeAdmin)
+
+	tests := []struct {
+		name   string
+		header string
+		method string
+		path   string
+		code   int
+	}{
+		{name: "read token reading", header: read, method: "GET", path: "/api/v1/users/1", code: http.StatusOK},
+		{name: "read token writing", header: read, method: "DELETE", path: "/api/v1/users/2", code: http.StatusForbidden},
+		{name: "publish token managing users", header: publish, method: "DELETE", path: "/api/v1/users/2", code: http.StatusForbidden},
+		{name: "token of a user", header: notAdmin, method: "GET", path: "/api/v1/users/1", code: http.StatusForbidden},
+		{name: "unknown token", header: "Bearer mqt_424242_secret", method: "GET", path: "/api/v1/users/1", code: http.StatusUnauthorized},
+		{name: "wrong secret", header: read + "x", method: "GET", path: "/api/v1/users/1", code: http.StatusUnauthorized},
+		{name: "other scheme", header: "Basic YWRtaW46YWRtaW4=", method: "GET", path: "/api/v1/users/1", code: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", tt.header)
+		rw := httptest.NewRecorder()
+		AuthorizeAPI(users.ManageUsers, HandleAPIUsers)(rw, req)
+		if rw.Code != tt.code {
+			t.Errorf("%v: got status code %v, want %v", tt.name, rw.Code, tt.code)
+		}
+		if rw.Code == http.StatusUnauthorized && rw.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%v: WWW-Authenticate isn't set", tt.name)
+		}
+	}
+
+	// publish tokens can manage articles, but they can't skip validation
+	req := httptest.NewRequest("POST", "/api/v1/articles", strings.NewReader(`{"title": ""}`))
+	req.Header.Set("Authorization", publish)
+	rw := httptest.NewRecorder()
+	HandleAPIArticles(rw, req)
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("publish token making an article: got status code %v, want %v", rw.Code, http.StatusBadRequest)
+	}
+
+	// using a token is remembered
+	tokens, _ := globals.Cfg.Store.ListTokens(ctx, adminID)
+	if len(tokens) != 2 || tokens[0].LastUsed.IsZero() || tokens[1].LastUsed.IsZero() {
+		t.Errorf("the last usage of tokens wasn't saved: %+v", tokens)
+	}
+}
+
+func TestAPIPagination(t *testing.T) {
+	prepareSessionTest(t)
+	checkAPIPagination(t)
+}
+
+// checks that ?offset= and ?limit= select the right page of users in the
+// Store of globals, which has to be prepared by prepareStoreTest
+func checkAPIPagination(t *testing.T) {
+	ctx := context.Background()
+	for i := 1; i <= 30; i++ {
+		if err := globals.Cfg.Store.AddUser(ctx, "Page User", "page"+strconv.Itoa(i), ""); err != nil {
+			t.Fatalf("AddUser() returned an error: %v", err)
+		}
+	}
+	all, err := globals.Cfg.Store.ListUsers(ctx, 0, 100)
+	if err != nil || len(all) < 30 {
+		t.Fatalf("ListUsers() = %v, %v", all, err)
+	}
+	cookie := login(t, "admin", "correct horse")
+
+	rw := apiRequest(AuthorizeAPI(users.ManageUsers, HandleAPIUsers), "GET", "/api/v1/users?offset=20&limit=5", "", cookie)
+	list := struct {
+		Items  []apiUser
+		Offset uint64
+		Limit  uint64
+	}{}
+	decodeBody(t, rw, &list)
+	if rw.Code != http.StatusOK || len(list.Items) != 5 || list.Items[0].ID != all[20].ID ||
+		list.Offset != 20 || list.Limit != 5 {
+		t.Errorf("listing users from 20: got status code %v and %+v, want 5 users starting with %v",
+			rw.Code, list, all[20].ID)
+	}
+
+	rw = apiRequest(AuthorizeAPI(users.ManageUsers, HandleAPIUsers), "GET", "/api/v1/users?offset=18446744073709551615", "", cookie)
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("listing users from the largest offset: got status code %v, want %v", rw.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// apiUser is a user as it's shown by the API, without the password
+type apiUser struct {
+	ID          uint64     `json:"id"`
+	DisplayName string     `json:"displayName"`
+	Login       string     `json:"login"`
+	Role        users.Role `json:"role"`
+}
+
+// apiUserInput is what's sent to make or change a user
+// The password is optional when a user is changed, the role is optional always
+type apiUserInput struct {
+	DisplayName string `json:"displayName"`
+	Login       string `json:"login"`
+	Password    string `json:"password"`
+	Role        string `json:"role"`
+}
+
+// apiAuthor is an author as it's shown by the API
+type apiAuthor struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+
+	// the user linked to the author, if it's known
+	UserID uint64 `json:"userId,omitempty"`
+}
+
+// apiAuthorInput is what's sent to make an author or to link it to a user
+type apiAuthorInput struct {
+	UserID uint64 `json:"userId"`
+	Name   string `json:"name"`
+}
+
+func newAPIUser(u users.User) apiUser {
+	return apiUser{ID: u.ID, DisplayName: u.DisplayName, Login: u.Login, Role: u.Role}
+}
+
+func newAPIAuthor(a users.Author) apiAuthor {
+	return apiAuthor{ID: a.AuthorID, Name: a.AuthorName, UserID: a.ID}
+}
+
+// handles /api/v1/users and /api/v1/users/{id}
+func HandleAPIUsers(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	id, hasID, valid := apiIDFromPath(req, "users")
+	if !valid {
+		writeAPIError(rw, http.StatusNotFound, "")
+		return
+	}
+
+	if !hasID {
+		switch req.Method {
+		case http.MethodGet:
+			from, to, err := parsePagination(req)
+			if err != nil {
+				writeAPIError(rw, http.StatusBadRequest, err.Error())
+				return
+			}
+			list, err := cfg.Store.ListUsers(req.Context(), from, to)
+			if err != nil {
+				handleAPIStoreError(rw, req, err)
+				return
+			}
+			items := make([]apiUser, 0, len(list))
+			for _, u := range list {
+				items = append(items, newAPIUser(u))
+			}
+			writeAPIList(rw, items, from, to, nil)
+		case http.MethodPost:
+			addAPIUser(rw, req)
+		default:
+			apiMethodNotAllowed(rw, "GET, POST")
+		}
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		u, err := cfg.Store.GetUser(req.Context(), id)
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, newAPIUser(u))
+	case http.MethodPut:
+		editAPIUser(rw, req, id)
+	case http.MethodDelete:
+		err := cfg.Store.RemoveUser(req.Context(), id)
+		if errors.Is(err, store.ErrConflict) {
+			writeAPIError(rw, http.StatusConflict, "the user is still linked to an author")
+			return
+		}
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		apiMethodNotAllowed(rw, "GET, PUT, DELETE")
+	}
+}
+
+// parses the user, the password is only required if required is true
+// The role is left empty if it isn't sent
+func (in apiUserInput) user(passwordRequired bool) (users.User, error) {
+	u := users.User{
+		DisplayName: strings.TrimSpace(in.DisplayName),
+		Login:       strings.TrimSpace(in.Login),
+	}
+	if in.Role != "" {
+		role, err := users.ParseRole(in.Role)
+		if err != nil {
+			return u, errors.New("role is invalid")
+		}
+		u.Role = role
+	}
+	if u.DisplayName == "" {
+		return u, errors.New("displayName can't be empty")
+	}
+	if u.Login == "" {
+		return u, errors.New("login can't be empty")
+	}
+	if in.Password == "" {
+		if passwordRequired {
+			return u, errors.New("password can't be empty")
+		}
+		return u, nil
+	}
+
+	hash, err := users.HashPassword(in.Password)
+	if err != nil {
+		return u, err
+	}
+	u.Password = hash
+	return u, nil
+}
+
+// makes a new user
+func addAPIUser(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	in := apiUserInput{}
+	if err := readJSON(rw, req, &in); err != nil {
+		writeAPIError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	u, err := in.user(true)
+	if err != nil {
+		writeAPIError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = cfg.Store.AddUser(req.Context(), u.DisplayName, u.Login, u.Password)
+	if errors.Is(err, store.ErrConflict) {
+		writeAPIError(rw, http.StatusConflict, "the login is already taken")
+		return
+	}
+	if err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+
+	id, err := cfg.Store.GetUserID(req.Context(), u.Login)
+	if err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+	u.ID = id
+
+	if u.Role == "" {
+		u.Role = users.RoleReader
+	} else if err := cfg.Store.SetRole(req.Context(), id, u.Role); err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+	rw.Header().Set("Location", apiPrefix+"users/"+strconv.FormatUint(id, 10))
+	writeJSON(rw, http.StatusCreated, newAPIUser(u))
+}
+
+// changes the user, the password and the role stay the same unless new ones
+// are sent
+// Changing the password logs the user out everywhere
+func editAPIUser(rw http.ResponseWriter, req *http.Request, id uint64) {
+	cfg := globals.Config(req.Context())
+	in := apiUserInput{}
+	if err := readJSON(rw, req, &in); err != nil {
+		writeAPIError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	u, err := in.user(false)
+	if err != nil {
+		writeAPIError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	old, err := cfg.Store.GetUser(req.Context(), id)
+	if err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+	u.ID = id
+	if u.Password == "" {
+		u.Password = old.Password
+	}
+	if u.Role == "" {
+		u.Role = old.Role
+	}
+	// nobody would be able to change it back if they were the last admin
+	if u.Role != old.Role && viewerFrom(req).UserID == id {
+		writeAPIError(rw, http.StatusConflict, "admins can't change their own role")
+		return
+	}
+
+	err = cfg.Store.EditUser(req.Context(), u)
+	if errors.Is(err, store.ErrConflict) {
+		writeAPIError(rw, http.StatusConflict, "the login is already taken")
+		return
+	}
+	if err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+
+	if u.Role != old.Role {
+		if err := cfg.Store.SetRole(req.Context(), id, u.Role); err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+	}
+
+	if u.Password != old.Password {
+		if err := cfg.Store.RemoveUserSessions(req.Context(), id); err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+	}
+	writeJSON(rw, http.StatusOK, newAPIUser(u))
+}
+
+// handles /api/v1/authors and /api/v1/authors/{id}
+// PUT links the author to another user
+func HandleAPIAuthors(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	id, hasID, valid := apiIDFromPath(req, "authors")
+	if !valid {
+		writeAPIError(rw, http.StatusNotFound, "")
+		return
+	}
+
+	if !hasID {
+		switch req.Method {
+		case http.MethodGet:
+			from, to, err := parsePagination(req)
+			if err != nil {
+				writeAPIError(rw, http.StatusBadRequest, err.Error())
+				return
+			}
+			list, err := cfg.Store.ListAuthors(req.Context(), from, to)
+			if err != nil {
+				handleAPIStoreError(rw, req, err)
+				return
+			}
+			items := make([]apiAuthor, 0, len(list))
+			for _, a := range list {
+				items = append(items, newAPIAuthor(a))
+			}
+			writeAPIList(rw, items, from, to, nil)
+		case http.MethodPost:
+			addAPIAuthor(rw, req)
+		default:
+			apiMethodNotAllowed(rw, "GET, POST")
+		}
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		a, err := cfg.Store.GetAuthorByID(req.Context(), id)
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, newAPIAuthor(a))
+	case http.MethodPut:
+		in := apiAuthorInput{}
+		if err := readJSON(rw, req, &in); err != nil {
+			writeAPIError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+		if in.Name != "" {
+			writeAPIError(rw, http.StatusBadRequest, "the name of an author can't be changed")
+			return
+		}
+		if err := cfg.Store.LinkAuthor(req.Context(), id, in.UserID); err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		a, err := cfg.Store.GetAuthorByID(req.Context(), id)
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		a.ID = in.UserID
+		writeJSON(rw, http.StatusOK, newAPIAuthor(a))
+	case http.MethodDelete:
+		err := cfg.Store.RemoveAuthor(req.Context(), id)
+		if errors.Is(err, store.ErrConflict) {
+			writeAPIError(rw, http.StatusConflict, "the author still has articles")
+			return
+		}
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		apiMethodNotAllowed(rw, "GET, PUT, DELETE")
+	}
+}
+
+// makes the user an author
+func addAPIAuthor(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	in := apiAuthorInput{}
+	if err := readJSON(rw, req, &in); err != nil {
+		writeAPIError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		writeAPIError(rw, http.StatusBadRequest, "name can't be empty")
+		return
+	}
+	if in.UserID == 0 {
+		writeAPIError(rw, http.StatusBadRequest, "userId is required")
+		return
+	}
+
+	if err := cfg.Store.AddAuthor(req.Context(), in.UserID, in.Name); err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+	a, err := cfg.Store.GetAuthor(req.Context(), in.UserID)
+	if err != nil {
+		handleAPIStoreError(rw, req, err)
+		return
+	}
+	a.ID = in.UserID
+	rw.Header().Set("Location", apiPrefix+"authors/"+strconv.FormatUint(a.AuthorID, 10))
+	writeJSON(rw, http.StatusCreated, newAPIAuthor(a))
+}
+
+// handles /api/v1/admins and /api/v1/admins/{user id}
+// PUT makes the user an admin, DELETE takes it back
+func HandleAPIAdmins(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	id, hasID, valid := apiIDFromPath(req, "admins")
+	if !valid {
+		writeAPIError(rw, http.StatusNotFound, "")
+		return
+	}
+
+	if !hasID {
+		if req.Method != http.MethodGet {
+			apiMethodNotAllowed(rw, "GET")
+			return
+		}
+		from, to, err := parsePagination(req)
+		if err != nil {
+			writeAPIError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+		list, err := cfg.Store.ListAdmins(req.Context(), from, to)
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		items := make([]apiUser, 0, len(list))
+		for _, u := range list {
+			items = append(items, newAPIUser(u))
+		}
+		writeAPIList(rw, items, from, to, nil)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		isAdmin, err := cfg.Store.IsAdmin(req.Context(), id)
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		if !isAdmin {
+			writeAPIError(rw, http.StatusNotFound, "the user isn't an admin")
+			return
+		}
+		u, err := cfg.Store.GetUser(req.Context(), id)
+		if err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, newAPIUser(u))
+	case http.MethodPut:
+		// promoting an admin again changes nothing, so it isn't an error
+		err := cfg.Store.PromoteToAdmin(req.Context(), id)
+		if err != nil && !errors.Is(err, store.ErrConflict) {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		// nobody would be able to promote them back if they were the last admin
+		if viewerFrom(req).UserID == id {
+			writeAPIError(rw, http.StatusConflict, "admins can't demote themselves")
+			return
+		}
+		if err := cfg.Store.DemoteFromAdmin(req.Context(), id); err != nil {
+			handleAPIStoreError(rw, req, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		apiMethodNotAllowed(rw, "GET, PUT, DELETE")
+	}
+}
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	articlePkg "github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/comments"
+	"github.com/david-sorm/montesquieu/comments/spam"
 	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
 	templates "github.com/david-sorm/montesquieu/template"
 	"net/http"
 	"strconv"
@@ -14,37 +19,122 @@ type ArticleView struct {
 	BlogName string
 	Article  articlePkg.Article
 	RootURL  string
+
+	// visible comments on the current page of comments, arranged into threads
+	Comments []comments.Thread
+
+	// the number of all visible comments
+	CommentNumber uint64
+
+	// pages of comments work the same way as in IndexView
+	CommentLastPage uint64
+	CommentPage     uint64
+	CommentNextPage uint64
+	CommentMaxPage  uint64
+
+	// whether the viewer can write comments
+	CanComment bool
+
+	// the viewer isn't logged in, so they have to write their name
+	Anonymous bool
+
+	// the comment the viewer is replying to, empty if they aren't replying
+	ReplyTo comments.Comment
+
+	// the comment form sent by the viewer, shown again if it's invalid
+	CommentName    string
+	CommentContent string
+	CommentError   string
+
+	// extra fields needed by the spam checks, like honeypots
+	CommentFields []spam.Field
+
+	// the viewer's comment has been saved, but it has to be approved first
+	CommentPending bool
 }
 
-func HandleArticle(rw http.ResponseWriter, req *http.Request) {
-	// split the uri (example: /articles/1 )
-	split := strings.Split(req.RequestURI, "/")
+// finds the article by its current or old slug, or by its ID for URLs like
+// /article/{id} and /article/{id}-{slug}
+func findArticle(ctx context.Context, path string) (articlePkg.Article, error) {
+	cfg := globals.Config(ctx)
+	article, err := cfg.Store.GetArticleBySlug(ctx, path)
+	if !errors.Is(err, store.ErrNotFound) {
+		return article, err
+	}
 
-	// make sure there are 3 splits
-	if len(split) != 3 {
+	id := path
+	if i := strings.IndexByte(path, '-'); i >= 0 {
+		id = path[:i]
+	}
+	convertInt, convertErr := strconv.ParseUint(id, 10, 64)
+	if convertErr != nil {
+		return article, err
+	}
+	return cfg.Store.GetArticleByID(ctx, convertInt)
+}
+
+func HandleArticle(rw http.ResponseWriter, req *http.Request) {
+	// get the slug from the path (example: /article/hello-world )
+	path := strings.TrimPrefix(req.URL.Path, "/article/")
+	if path == "" || strings.Contains(path, "/") {
 		Handle404(rw, req)
 		return
 	}
 
-	convertInt, err := strconv.Atoi(split[2])
+	// make sure article exists
+	article, err := findArticle(req.Context(), path)
 	if err != nil {
-		fmt.Println("An error has happened while converting article id from request:", err)
+		handleStoreError(rw, req, err)
+		return
 	}
 
-	// make sure article with the ID exists
-	article, exists := globals.Cfg.Store.GetArticleByID(uint64(convertInt))
-	if !exists {
-		Handle404(rw, req)
+	// articles which aren't published can only be previewed by those who can
+	// edit them
+	if article.Status != articlePkg.Published {
+		v, _, err := sessionViewer(req)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			handleStoreError(rw, req, err)
+			return
+		}
+		if !v.CanEditArticle(article) {
+			Handle404(rw, req)
+			return
+		}
+	}
+
+	// numeric URLs and old slugs are redirected to the current URL
+	if req.URL.Path != article.URL() {
+		http.Redirect(rw, req, article.URL(), http.StatusMovedPermanently)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		renderArticle(rw, req, article, ArticleView{})
+	case http.MethodPost:
+		addComment(rw, req, article)
+	default:
+		rw.Header().Set("Allow", "GET, HEAD, POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+	}
+}
+
+// renders the article together with a page of its comments, view can contain
+// the comment form sent by the viewer
+func renderArticle(rw http.ResponseWriter, req *http.Request, article articlePkg.Article, view ArticleView) {
+	view.BlogName = globals.Config(req.Context()).BlogName
+	view.Article = article
+	view.RootURL = "//" + req.Host + "/"
+
+	if err := loadComments(req, &view); err != nil {
+		handleStoreError(rw, req, err)
 		return
 	}
 
-	// respond
-	articleView := ArticleView{
-		BlogName: globals.Cfg.BlogName,
-		Article:  article,
-		RootURL:  "//" + req.Host + "/",
+	if view.CommentError != "" {
+		rw.WriteHeader(http.StatusBadRequest)
 	}
-	if err := templates.Store.Lookup("article.gohtml").Execute(rw, articleView); err != nil {
+	if err := templates.Store.Lookup("article.gohtml").Execute(rw, view); err != nil {
 		fmt.Println("Error while parsing template:", err.Error())
 	}
 }
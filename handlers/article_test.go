@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/config"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleArticle_Redirects(t *testing.T) {
+	s := &mock.Store{}
+	if err := s.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() returned an error: %v", err)
+	}
+	if err := s.Seed(); err != nil {
+		t.Fatalf("Seed() returned an error: %v", err)
+	}
+	globals.Cfg = &config.Config{Store: s}
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{path: "/article/2", code: http.StatusMovedPermanently, location: "/article/article-2"},
+		{path: "/article/2?utm_source=feed", code: http.StatusMovedPermanently, location: "/article/article-2"},
+		{path: "/article/2-old-title", code: http.StatusMovedPermanently, location: "/article/article-2"},
+		{path: "/article/250604", code: http.StatusNotFound},
+		{path: "/article/no-such-slug", code: http.StatusNotFound},
+		{path: "/article/article-2/more", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rw := httptest.NewRecorder()
+		HandleArticle(rw, httptest.NewRequest("GET", tt.path, nil))
+		if rw.Code != tt.code {
+			t.Errorf("%v: got status code %v, want %v", tt.path, rw.Code, tt.code)
+		}
+		if got := rw.Header().Get("Location"); got != tt.location {
+			t.Errorf("%v: redirected to %#v, want %#v", tt.path, got, tt.location)
+		}
+	}
+}
+
+// draftStore has a single draft besides the mock articles
+type draftStore struct {
+	mock.Store
+}
+
+func (ds *draftStore) GetArticleBySlug(ctx context.Context, slug string) (article.Article, error) {
+	if slug == "draft" {
+		return article.Article{ID: 500, Slug: "draft", Status: article.Draft}, nil
+	}
+	return ds.Store.GetArticleBySlug(ctx, slug)
+}
+
+func TestHandleArticle_Draft(t *testing.T) {
+	s := &draftStore{}
+	if err := s.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() returned an error: %v", err)
+	}
+	if err := s.Seed(); err != nil {
+		t.Fatalf("Seed() returned an error: %v", err)
+	}
+	globals.Cfg = &config.Config{Store: s}
+
+	// readers can't see drafts
+	rw := httptest.NewRecorder()
+	HandleArticle(rw, httptest.NewRequest("GET", "/article/draft", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("draft got status code %v, want 404", rw.Code)
+	}
+}
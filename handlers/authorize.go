@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"net/url"
+)
+
+// viewer is a logged in user sending a request, together with what they can do
+// Anonymous users are represented by an empty viewer, which can't do anything
+type viewer struct {
+	UserID uint64
+	Role   users.Role
+
+	// the author linked to the user, 0 if there's none, authors can only work
+	// with articles of this author
+	AuthorID uint64
+
+	// what the API token allows, users logged in by a session can use all
+	// permissions of their role
+	Scope users.TokenScope
+}
+
+// the key of the viewer in the context of requests let through by Authorize
+// and AuthorizeAPI
+type viewerKey struct{}
+
+// loadViewer finds out what the user can do
+func loadViewer(ctx context.Context, userID uint64, scope users.TokenScope) (viewer, error) {
+	cfg := globals.Config(ctx)
+	u, err := cfg.Store.GetUser(ctx, userID)
+	if err != nil {
+		return viewer{}, err
+	}
+
+	v := viewer{UserID: u.ID, Role: u.Role, Scope: scope}
+	if v.Can(users.WriteArticles) {
+		author, err := cfg.Store.GetAuthor(ctx, userID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return viewer{}, err
+		}
+		v.AuthorID = author.AuthorID
+	}
+	return v, nil
+}
+
+// sessionViewer returns the viewer logged in by the session cookie together
+// with the session
+// If the user isn't logged in, store.ErrNotFound is returned
+func sessionViewer(req *http.Request) (viewer, users.Session, error) {
+	session, err := currentSession(req)
+	if err != nil {
+		return viewer{}, users.Session{}, err
+	}
+	v, err := loadViewer(req.Context(), session.UserID, users.ScopeAdmin)
+	return v, session, err
+}
+
+// viewerFrom returns the viewer of a request let through by Authorize or
+// AuthorizeAPI
+func viewerFrom(req *http.Request) viewer {
+	v, _ := req.Context().Value(viewerKey{}).(viewer)
+	return v
+}
+
+// Can returns true if the role of the viewer has the permission
+// The scope of API tokens is checked by AuthorizeAPI, not here
+func (v viewer) Can(p users.Permission) bool {
+	return v.Role.Can(p)
+}
+
+// CanEditArticle returns true if the viewer can see, edit and delete the
+// article no matter its status
+func (v viewer) CanEditArticle(a article.Article) bool {
+	if v.Can(users.EditAllArticles) {
+		return true
+	}
+	return v.Can(users.WriteArticles) && v.AuthorID != 0 && a.AuthorID == v.AuthorID
+}
+
+// CanSetStatus returns true if the viewer can change the status of an article
+// from old to status, old is empty for new articles
+// Only those who can publish articles can publish or schedule them, everyone
+// else can only keep the status which the article already has
+func (v viewer) CanSetStatus(old article.Status, status article.Status) bool {
+	if v.Can(users.PublishArticles) || status == old {
+		return true
+	}
+	return status != article.Published && status != article.Scheduled
+}
+
+// Statuses returns the statuses the viewer can pick for an article which has
+// the status old
+func (v viewer) Statuses(old article.Status) []article.Status {
+	statuses := make([]article.Status, 0, len(article.Statuses))
+	for _, s := range article.Statuses {
+		if v.CanSetStatus(old, s) {
+			statuses = append(statuses, s)
+		}
+	}
+	return statuses
+}
+
+// checkArticle returns an error describing why the viewer can't save the
+// article a, old is the article before it's changed, it's empty for new ones
+// Whether the viewer can edit old at all is checked using CanEditArticle
+func (v viewer) checkArticle(a article.Article, old article.Article) error {
+	if !v.Can(users.EditAllArticles) && a.AuthorID != v.AuthorID {
+		return errors.New("You can only write articles as your own author")
+	}
+	if !v.CanSetStatus(old.Status, a.Status) {
+		return errors.New("You can't publish or schedule articles")
+	}
+	return nil
+}
+
+// Authorize lets only logged in users with the permission through to the
+// handler, which can get them using viewerFrom
+// Users which aren't logged in are redirected to the login page, the rest gets
+// a 403
+func Authorize(p users.Permission, handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		v, session, err := sessionViewer(req)
+		if errors.Is(err, store.ErrNotFound) {
+			http.Redirect(rw, req, "/login?next="+url.QueryEscape(req.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			handleStoreError(rw, req, err)
+			return
+		}
+		if !v.Can(p) {
+			Handle403(rw, req)
+			return
+		}
+
+		refreshSession(rw, req, session)
+		handler(rw, req.WithContext(context.WithValue(req.Context(), viewerKey{}, v)))
+	}
+}
+
+// authorizeAPI responds with an error and returns false unless the request
+// comes from a user with the permission, either logged in or using a token
+// Tokens need the read scope for GET requests and the scope of the permission
+// for the rest
+func authorizeAPI(rw http.ResponseWriter, req *http.Request, p users.Permission) (viewer, bool) {
+	v, err := apiAuthenticate(req)
+	if err != nil {
+		writeAPIAuthError(rw, req, err)
+		return viewer{}, false
+	}
+
+	scope := p.Scope()
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		scope = users.ScopeRead
+	}
+	if !v.Scope.Includes(scope) {
+		writeAPIError(rw, http.StatusForbidden, "the token needs the "+string(scope)+" scope")
+		return viewer{}, false
+	}
+	if !v.Can(p) {
+		writeAPIError(rw, http.StatusForbidden, "")
+		return viewer{}, false
+	}
+	return v, true
+}
+
+// AuthorizeAPI is Authorize for the API, it responds with JSON errors instead
+// of redirecting to the login page
+func AuthorizeAPI(p users.Permission, handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		v, ok := authorizeAPI(rw, req, p)
+		if !ok {
+			return
+		}
+		handler(rw, req.WithContext(context.WithValue(req.Context(), viewerKey{}, v)))
+	}
+}
+
+// apiViewer returns the viewer sending an API request, anonymous requests get
+// an empty viewer
+// If ok is false, an error has already been sent
+func apiViewer(rw http.ResponseWriter, req *http.Request) (v viewer, ok bool) {
+	v, err := apiAuthenticate(req)
+	if errors.Is(err, store.ErrNotFound) {
+		return viewer{}, true
+	}
+	if err != nil {
+		writeAPIAuthError(rw, req, err)
+		return viewer{}, false
+	}
+	return v, true
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestViewer_CheckArticle(t *testing.T) {
+	author := viewer{Role: users.RoleAuthor, AuthorID: 7}
+	editor := viewer{Role: users.RoleEditor}
+	own := article.Article{ID: 1, AuthorID: 7, Status: article.Draft}
+	others := article.Article{ID: 2, AuthorID: 8, Status: article.Draft}
+	published := article.Article{ID: 3, AuthorID: 7, Status: article.Published}
+
+	tests := []struct {
+		name string
+		v    viewer
+		a    article.Article
+		old  article.Article
+		ok   bool
+	}{
+		{name: "author writing a draft", v: author, a: own, old: article.Article{}, ok: true},
+		{name: "author writing as someone else", v: author, a: others, old: article.Article{}, ok: false},
+		{name: "author publishing", v: author, a: published, old: own, ok: false},
+		{name: "author editing a published article", v: author, a: published, old: published, ok: true},
+		{name: "editor publishing", v: editor, a: published, old: own, ok: true},
+		{name: "editor writing as someone else", v: editor, a: others, old: article.Article{}, ok: true},
+	}
+	for _, tt := range tests {
+		if err := tt.v.checkArticle(tt.a, tt.old); (err == nil) != tt.ok {
+			t.Errorf("%v: checkArticle() returned %v", tt.name, err)
+		}
+	}
+
+	if !author.CanEditArticle(own) || author.CanEditArticle(others) || !editor.CanEditArticle(others) {
+		t.Errorf("CanEditArticle() doesn't limit authors to their own articles")
+	}
+	if (viewer{Role: users.RoleAuthor}).CanEditArticle(article.Article{}) {
+		t.Errorf("CanEditArticle() of an author without a linked author = true")
+	}
+	if statuses := author.Statuses(article.Draft); len(statuses) != 2 {
+		t.Errorf("Statuses() of an author = %v, want draft and archived", statuses)
+	}
+}
+
+func TestAuthorize_Authors(t *testing.T) {
+	_, userID := prepareSessionTest(t)
+	ctx := context.Background()
+	if err := globals.Cfg.Store.SetRole(ctx, userID, users.RoleAuthor); err != nil {
+		t.Fatalf("SetRole() returned an error: %v", err)
+	}
+	if err := globals.Cfg.Store.AddAuthor(ctx, userID, "User"); err != nil {
+		t.Fatalf("AddAuthor() returned an error: %v", err)
+	}
+	author, err := globals.Cfg.Store.GetAuthor(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetAuthor() returned an error: %v", err)
+	}
+	authorCookie := login(t, "user", "correct horse")
+	adminCookie := login(t, "admin", "correct horse")
+
+	// sends an article to the API and checks the status code
+	put := func(name string, authorID uint64, status article.Status, cookie *http.Cookie, code int) {
+		body := `{"title": "Mine", "source": "text", "authorId": ` + strconv.FormatUint(authorID, 10) +
+			`, "status": "` + string(status) + `"}`
+		rw := apiRequest(HandleAPIArticles, "PUT", "/api/v1/articles/5", body, cookie)
+		if rw.Code != code {
+			t.Errorf("%v: got status code %v, want %v", name, rw.Code, code)
+		}
+	}
+	// lists articles of all statuses and returns how many there are
+	listAll := func() int {
+		rw := apiRequest(HandleAPIArticles, "GET", "/api/v1/articles?all=true", "", authorCookie)
+		list := struct{ Items []apiArticle }{}
+		decodeBody(t, rw, &list)
+		return len(list.Items)
+	}
+
+	if n := listAll(); n != 0 {
+		t.Errorf("the author without articles got %v articles", n)
+	}
+	put("author taking an article", author.AuthorID, article.Draft, authorCookie, http.StatusForbidden)
+	put("admin giving the article to the author", author.AuthorID, article.Draft, adminCookie, http.StatusOK)
+	if n := listAll(); n != 1 {
+		t.Errorf("the author got %v articles, want 1", n)
+	}
+
+	put("author editing the draft", author.AuthorID, article.Draft, authorCookie, http.StatusOK)
+	put("author publishing", author.AuthorID, article.Published, authorCookie, http.StatusForbidden)
+	put("author giving the article away", author.AuthorID+1, article.Draft, authorCookie, http.StatusForbidden)
+
+	// drafts can be read by their authors, but not by anyone else
+	if rw := apiRequest(HandleAPIArticles, "GET", "/api/v1/articles/5", "", authorCookie); rw.Code != http.StatusOK {
+		t.Errorf("author reading the draft: got status code %v", rw.Code)
+	}
+	if rw := apiRequest(HandleAPIArticles, "GET", "/api/v1/articles/5", "", nil); rw.Code != http.StatusNotFound {
+		t.Errorf("anonymous request of the draft: got status code %v", rw.Code)
+	}
+
+	// articles of others can't be deleted in the admin panel
+	handler := Authorize(users.WriteArticles, HandleAdminPanelArticleDelete)
+	req := httptest.NewRequest("POST", "/admin/panel/articles/delete/4", nil)
+	req.AddCookie(authorCookie)
+	rw := httptest.NewRecorder()
+	handler(rw, req)
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("author deleting an article of someone else: got status code %v", rw.Code)
+	}
+
+	// authors can't moderate comments
+	req = httptest.NewRequest("GET", "/admin/panel/comments", nil)
+	req.AddCookie(authorCookie)
+	rw = httptest.NewRecorder()
+	Authorize(users.ModerateComments, HandleAdminPanelComments)(rw, req)
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("author moderating comments: got status code %v", rw.Code)
+	}
+}
+
+func TestHandleAdminPanelUserRole(t *testing.T) {
+	adminID, userID := prepareSessionTest(t)
+	cookie := login(t, "admin", "correct horse")
+
+	// changes the role of the user with the ID and returns the status code
+	setRole := func(id uint64, role string) int {
+		form := url.Values{"role": {role}}
+		req := httptest.NewRequest("POST", "/admin/panel/users/role/"+strconv.FormatUint(id, 10),
+			strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		rw := httptest.NewRecorder()
+		Authorize(users.ManageUsers, HandleAdminPanelUserRole)(rw, req)
+		return rw.Code
+	}
+
+	if code := setRole(userID, "editor"); code != http.StatusSeeOther {
+		t.Errorf("changing the role: got status code %v", code)
+	}
+	if u, _ := globals.Cfg.Store.GetUser(context.Background(), userID); u.Role != users.RoleEditor {
+		t.Errorf("the role of the user is %#v, want %#v", u.Role, users.RoleEditor)
+	}
+	if code := setRole(userID, "owner"); code != http.StatusBadRequest {
+		t.Errorf("invalid role: got status code %v", code)
+	}
+	if code := setRole(adminID, "reader"); code != http.StatusConflict {
+		t.Errorf("changing your own role: got status code %v", code)
+	}
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	articlePkg "github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/comments"
+	"github.com/david-sorm/montesquieu/comments/spam"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// how many comments are shown on one page under an article
+const commentsPerPage = 50
+
+// limits of what readers can write
+const (
+	maxCommentLength     = 5000
+	maxCommentNameLength = 100
+)
+
+// loads the page of comments requested by ?comments={page} into the view,
+// together with what the viewer can do with them
+func loadComments(req *http.Request, view *ArticleView) error {
+	cfg := globals.Config(req.Context())
+	ctx := req.Context()
+
+	_, err := currentSession(req)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	view.Anonymous = err != nil
+	view.CanComment = !view.Anonymous || cfg.AnonymousComments
+	view.CommentPending = req.URL.Query().Get("comment") == "pending"
+	if fc, ok := cfg.CommentChecks.(spam.FormCheck); ok && view.CanComment {
+		view.CommentFields = fc.Fields(time.Now())
+	}
+
+	num, err := cfg.Store.GetCommentNumber(ctx, view.Article.ID)
+	if err != nil {
+		return err
+	}
+	view.CommentNumber = num
+	view.CommentMaxPage = countMaxPage(num, commentsPerPage)
+
+	// invalid pages just show the first page
+	if page, err := strconv.ParseUint(req.URL.Query().Get("comments"), 10, 64); err == nil &&
+		page <= view.CommentMaxPage {
+		view.CommentPage = page
+	}
+	view.CommentLastPage = view.CommentPage - 1
+	view.CommentNextPage = view.CommentPage + 1
+
+	from := view.CommentPage * commentsPerPage
+	to := from + commentsPerPage
+	if to > num {
+		to = num
+	}
+	cs, err := cfg.Store.ListComments(ctx, view.Article.ID, from, to)
+	if err != nil {
+		return err
+	}
+	view.Comments = comments.Threads(cs)
+
+	// the comment the viewer is replying to comes either from the sent form or
+	// from ?reply={id}
+	replyID := view.ReplyTo.ID
+	if replyID == 0 {
+		replyID, _ = strconv.ParseUint(req.URL.Query().Get("reply"), 10, 64)
+	}
+	view.ReplyTo = comments.Comment{}
+	if replyID != 0 {
+		parent, err := cfg.Store.GetComment(ctx, replyID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		// readers can only reply to visible comments of the article
+		if err == nil && parent.ArticleID == view.Article.ID && parent.Status == comments.Visible {
+			view.ReplyTo = parent
+		}
+	}
+	return nil
+}
+
+// parses the comment sent from the comment form
+func parseCommentForm(req *http.Request) (comments.Comment, error) {
+	c := comments.Comment{
+		Name:          strings.TrimSpace(req.PostFormValue("name")),
+		UnsafeContent: strings.TrimSpace(req.PostFormValue("content")),
+	}
+
+	// parsed first, so the reply isn't lost if there's something wrong with
+	// the rest of the form
+	if str := req.PostFormValue("parent"); str != "" {
+		parent, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return c, errors.New("The comment you're replying to doesn't exist")
+		}
+		c.ParentID = parent
+	}
+
+	if c.UnsafeContent == "" {
+		return c, errors.New("The comment can't be empty")
+	}
+	if utf8.RuneCountInString(c.UnsafeContent) > maxCommentLength {
+		return c, errors.New("The comment can't be longer than " + strconv.Itoa(maxCommentLength) + " characters")
+	}
+	if utf8.RuneCountInString(c.Name) > maxCommentNameLength {
+		return c, errors.New("The name can't be longer than " + strconv.Itoa(maxCommentNameLength) + " characters")
+	}
+
+	return c, nil
+}
+
+// saves the comment sent to the article
+// Comments of users are shown right away, anonymous comments have to be
+// approved by an admin first
+func addComment(rw http.ResponseWriter, req *http.Request, article articlePkg.Article) {
+	cfg := globals.Config(req.Context())
+	// only published articles can be commented
+	if article.Status != articlePkg.Published {
+		Handle403(rw, req)
+		return
+	}
+
+	session, err := currentSession(req)
+	anonymous := errors.Is(err, store.ErrNotFound)
+	if err != nil && !anonymous {
+		handleStoreError(rw, req, err)
+		return
+	}
+	if anonymous && !cfg.AnonymousComments {
+		Handle403(rw, req)
+		return
+	}
+
+	c, err := parseCommentForm(req)
+	if err == nil && anonymous && c.Name == "" {
+		err = errors.New("Please write your name")
+	}
+	view := ArticleView{
+		CommentName:    c.Name,
+		CommentContent: c.UnsafeContent,
+		ReplyTo:        comments.Comment{ID: c.ParentID},
+	}
+	if err != nil {
+		view.CommentError = err.Error()
+		renderArticle(rw, req, article, view)
+		return
+	}
+
+	// readers can only reply to visible comments of the article
+	if c.ParentID != 0 {
+		parent, err := cfg.Store.GetComment(req.Context(), c.ParentID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			handleStoreError(rw, req, err)
+			return
+		}
+		if err != nil || parent.ArticleID != article.ID || parent.Status != comments.Visible {
+			view.CommentError = "The comment you're replying to doesn't exist"
+			view.ReplyTo = comments.Comment{}
+			renderArticle(rw, req, article, view)
+			return
+		}
+	}
+
+	c.ArticleID = article.ID
+	if anonymous {
+		c.Status = comments.Pending
+	} else {
+		c.UserID = session.UserID
+		c.Status = comments.Visible
+	}
+
+	// suspicious comments have to be approved, even if they're written by users
+	result, err := checkSpam(req, c)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	switch result.Verdict {
+	case spam.Reject:
+		view.CommentError = result.Reason
+		renderArticle(rw, req, article, view)
+		return
+	case spam.Moderate:
+		c.Status = comments.Pending
+	}
+
+	err = cfg.Store.AddComment(req.Context(), c)
+	if errors.Is(err, store.ErrInvalidInput) {
+		view.CommentError = "The comment you're replying to doesn't exist"
+		view.ReplyTo = comments.Comment{}
+		renderArticle(rw, req, article, view)
+		return
+	}
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	if c.Status == comments.Pending {
+		http.Redirect(rw, req, article.URL()+"?comment=pending#comments", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(rw, req, article.URL()+"#comments", http.StatusSeeOther)
+}
+
+// runs the comment through the configured spam checks
+func checkSpam(req *http.Request, c comments.Comment) (spam.Result, error) {
+	cfg := globals.Config(req.Context())
+	if cfg.CommentChecks == nil {
+		return spam.Result{Verdict: spam.Accept}, nil
+	}
+
+	return cfg.CommentChecks.Check(req.Context(), spam.Submission{
+		Comment: c,
+		IP:      clientIP(req, cfg.TrustedProxies),
+		Form:    req.PostForm,
+		Time:    time.Now(),
+	})
+}
+
+// returns the address of the reader who sent the request
+// Requests sent by trusted proxies come from the last address in their
+// X-Forwarded-For which isn't a trusted proxy too, the ones before it could've
+// been made up by the reader
+func clientIP(req *http.Request, trusted []*net.IPNet) string {
+	// the port changes with every connection, so only the host is used
+	ip := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	forwarded := strings.Split(strings.Join(req.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0 && trustedProxy(ip, trusted); i-- {
+		next := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(next) == nil {
+			break
+		}
+		ip = next
+	}
+	return ip
+}
+
+// returns true if the address belongs to any of the trusted networks
+func trustedProxy(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	for _, network := range trusted {
+		if parsed != nil && network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
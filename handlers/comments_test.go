@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"github.com/david-sorm/montesquieu/comments"
+	"github.com/david-sorm/montesquieu/comments/spam"
+	"github.com/david-sorm/montesquieu/globals"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// sends the comment form to article-2, logged in if cookie isn't nil
+func postComment(form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/article/article-2", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rw := httptest.NewRecorder()
+	HandleArticle(rw, req)
+	return rw
+}
+
+func TestHandleArticle_Comments(t *testing.T) {
+	_, userID := prepareSessionTest(t)
+	ctx := context.Background()
+
+	// anonymous comments are disabled by default
+	rw := postComment(url.Values{"name": {"Anonymous"}, "content": {"Hello"}}, nil)
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("anonymous comment: got status code %v, want %v", rw.Code, http.StatusForbidden)
+	}
+
+	// comments of users are shown right away
+	rw = postComment(url.Values{"content": {"First!"}}, login(t, "user", "correct horse"))
+	if rw.Code != http.StatusSeeOther {
+		t.Fatalf("user's comment: got status code %v, want %v", rw.Code, http.StatusSeeOther)
+	}
+	if loc := rw.Header().Get("Location"); loc != "/article/article-2#comments" {
+		t.Errorf("user's comment redirected to %v", loc)
+	}
+	cs, err := globals.Cfg.Store.ListComments(ctx, 2, 0, 10)
+	if err != nil {
+		t.Fatalf("ListComments() returned an error: %v", err)
+	}
+	if len(cs) != 1 || cs[0].UnsafeContent != "First!" || cs[0].UserID != userID {
+		t.Errorf("ListComments() returned %#v", cs)
+	}
+
+	// anonymous comments wait for approval
+	globals.Cfg.AnonymousComments = true
+	rw = postComment(url.Values{"name": {"Anonymous"}, "content": {"Hello"}}, nil)
+	if rw.Code != http.StatusSeeOther {
+		t.Fatalf("anonymous comment: got status code %v, want %v", rw.Code, http.StatusSeeOther)
+	}
+	if loc := rw.Header().Get("Location"); loc != "/article/article-2?comment=pending#comments" {
+		t.Errorf("anonymous comment redirected to %v", loc)
+	}
+	pending, err := globals.Cfg.Store.ListCommentsByStatus(ctx, comments.Pending, 0, 10)
+	if err != nil {
+		t.Fatalf("ListCommentsByStatus() returned an error: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Name != "Anonymous" || pending[0].UserID != 0 {
+		t.Errorf("ListCommentsByStatus() returned %#v", pending)
+	}
+}
+
+func TestParseCommentForm(t *testing.T) {
+	tests := []struct {
+		form   url.Values
+		want   comments.Comment
+		hasErr bool
+	}{
+		{form: url.Values{"content": {" Hello "}}, want: comments.Comment{UnsafeContent: "Hello"}},
+		{form: url.Values{"name": {"Jane"}, "content": {"Hi"}, "parent": {"3"}},
+			want: comments.Comment{Name: "Jane", UnsafeContent: "Hi", ParentID: 3}},
+		{form: url.Values{"content": {"  "}}, hasErr: true},
+		{form: url.Values{"content": {strings.Repeat("a", maxCommentLength+1)}}, hasErr: true},
+		{form: url.Values{"content": {"Hi"}, "parent": {"first"}}, hasErr: true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/article/article-2", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		got, err := parseCommentForm(req)
+		if (err != nil) != tt.hasErr {
+			t.Errorf("parseCommentForm(%v) returned error %v", tt.form, err)
+			continue
+		}
+		if !tt.hasErr && got != tt.want {
+			t.Errorf("parseCommentForm(%v) = %#v, want %#v", tt.form, got, tt.want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []*net.IPNet{proxies}
+	tests := []struct {
+		remote    string
+		forwarded []string
+		trusted   []*net.IPNet
+		want      string
+	}{
+		{remote: "192.0.2.1:1234", want: "192.0.2.1"},
+		{remote: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		// the header is ignored unless it's sent by a trusted proxy
+		{remote: "192.0.2.1:1234", forwarded: []string{"198.51.100.7"}, want: "192.0.2.1"},
+		{remote: "192.0.2.1:1234", forwarded: []string{"198.51.100.7"}, trusted: trusted, want: "192.0.2.1"},
+		{remote: "10.0.0.2:1234", forwarded: []string{"198.51.100.7"}, trusted: trusted, want: "198.51.100.7"},
+		// addresses added by the reader before the proxies are skipped
+		{remote: "10.0.0.2:1234", forwarded: []string{"203.0.113.9, 198.51.100.7", "10.0.0.3"}, trusted: trusted,
+			want: "198.51.100.7"},
+		{remote: "10.0.0.2:1234", forwarded: []string{"made-up, 10.0.0.3"}, trusted: trusted, want: "10.0.0.3"},
+		{remote: "10.0.0.2:1234", trusted: trusted, want: "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/article/article-2", nil)
+		req.RemoteAddr = tt.remote
+		for _, f := range tt.forwarded {
+			req.Header.Add("X-Forwarded-For", f)
+		}
+		if got := clientIP(req, tt.trusted); got != tt.want {
+			t.Errorf("clientIP(%v, %v) = %v, want %v", tt.remote, tt.forwarded, got, tt.want)
+		}
+	}
+}
+
+func TestHandleArticle_SpamComments(t *testing.T) {
+	prepareSessionTest(t)
+	globals.Cfg.CommentChecks = spam.Pipeline{spam.Honeypot{Name: "website"}}
+	cookie := login(t, "user", "correct horse")
+
+	// even users' comments have to be approved if they look like spam
+	rw := postComment(url.Values{"content": {"Hello"}, "website": {"http://spam.example"}}, cookie)
+	if rw.Code != http.StatusSeeOther {
+		t.Fatalf("spam comment: got status code %v, want %v", rw.Code, http.StatusSeeOther)
+	}
+	if loc := rw.Header().Get("Location"); loc != "/article/article-2?comment=pending#comments" {
+		t.Errorf("spam comment redirected to %v", loc)
+	}
+	pending, err := globals.Cfg.Store.ListCommentsByStatus(context.Background(), comments.Pending, 0, 10)
+	if err != nil {
+		t.Fatalf("ListCommentsByStatus() returned an error: %v", err)
+	}
+	if len(pending) != 1 || pending[0].UnsafeContent != "Hello" {
+		t.Errorf("ListCommentsByStatus() returned %#v", pending)
+	}
+
+	rw = postComment(url.Values{"content": {"Hello again"}}, cookie)
+	if loc := rw.Header().Get("Location"); loc != "/article/article-2#comments" {
+		t.Errorf("comment without spam redirected to %v", loc)
+	}
+}
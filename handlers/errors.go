@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/david-sorm/montesquieu/store"
+	"net/http"
+)
+
+// Generic error page, writes the status code and its description
+func HandleError(rw http.ResponseWriter, _ *http.Request, code int) {
+	rw.WriteHeader(code)
+	_, err := fmt.Fprintf(rw, "Error %v: %v\n", code, http.StatusText(code))
+
+	if err != nil {
+		fmt.Printf("Error while writing a %v response: %v\n", code, err.Error())
+	}
+}
+
+// handleStoreError responds with a status code matching the kind of error
+// which was returned by the Store
+func handleStoreError(rw http.ResponseWriter, req *http.Request, err error) {
+	// the client has gone away, so there's nobody to respond to
+	if req.Context().Err() != nil {
+		return
+	}
+
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		Handle404(rw, req)
+	case errors.Is(err, store.ErrConflict):
+		HandleError(rw, req, http.StatusConflict)
+	case errors.Is(err, store.ErrInvalidInput):
+		HandleError(rw, req, http.StatusBadRequest)
+	case errors.Is(err, store.ErrUnavailable):
+		fmt.Println("Store is unavailable:", err.Error())
+		HandleError(rw, req, http.StatusServiceUnavailable)
+	default:
+		fmt.Println("An error has happened in the Store:", err.Error())
+		HandleError(rw, req, http.StatusInternalServerError)
+	}
+}
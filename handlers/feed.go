@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"net/http"
+	"time"
+)
+
+// how many of the latest articles are in the feeds
+const feedLength = 20
+
+// an article prepared for the feeds
+type feedItem struct {
+	article.Article
+
+	// absolute URL of the article
+	URL string
+
+	// name of the author of the article
+	Author string
+
+	Published time.Time
+}
+
+// Atom 1.0, RFC 4287
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Link      atomLink   `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Author    atomAuthor `xml:"author"`
+	Summary   atomText   `xml:"summary"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// RSS 2.0
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	// RSS has no element for the name of the author, dc:creator is used instead
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Author      string  `xml:"dc:creator"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// returns the URL of the blog without the trailing slash, based on the request
+func rootURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host
+}
+
+// returns the title of the feed and the absolute URL of the page it belongs to,
+// the feed itself is in the same directory
+// the feed of the whole blog is used if tag is empty
+func feedPage(blogName string, root string, tag string) (string, string) {
+	if tag == "" {
+		return blogName, root + "/"
+	}
+	return blogName + ": " + tag, root + article.TagURL(tag) + "/"
+}
+
+// loads the latest articles together with the names of their authors
+// only articles with the tag are loaded, unless it's empty
+func loadFeedItems(ctx context.Context, root string, tag string) ([]feedItem, error) {
+	cfg := globals.Config(ctx)
+	var num uint64
+	var err error
+	if tag == "" {
+		num, err = cfg.Store.GetArticleNumber(ctx)
+	} else {
+		num, err = cfg.Store.GetArticleNumberByTag(ctx, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if num > feedLength {
+		num = feedLength
+	}
+
+	var articles []article.Article
+	if tag == "" {
+		articles, err = cfg.Store.LoadArticlesSortedByLatest(ctx, 0, num)
+	} else {
+		articles, err = cfg.Store.LoadArticlesByTag(ctx, tag, 0, num)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// most articles are usually written by a few authors
+	authors := make(map[uint64]string)
+	items := make([]feedItem, 0, len(articles))
+	for _, a := range articles {
+		name, found := authors[a.AuthorID]
+		if !found {
+			author, err := cfg.Store.GetAuthorByID(ctx, a.AuthorID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return nil, err
+			}
+			name = author.AuthorName
+			if name == "" {
+				name = author.DisplayName
+			}
+			// feed readers require a name
+			if name == "" {
+				name = cfg.BlogName
+			}
+			authors[a.AuthorID] = name
+		}
+
+		items = append(items, feedItem{
+			Article:   a,
+			URL:       root + a.URL(),
+			Author:    name,
+			Published: time.Unix(int64(a.Timestamp), 0).UTC(),
+		})
+	}
+	return items, nil
+}
+
+// time of the latest article, or zero if there are no articles
+func lastModified(items []feedItem) time.Time {
+	var latest time.Time
+	for _, item := range items {
+		if item.Published.After(latest) {
+			latest = item.Published
+		}
+	}
+	return latest
+}
+
+func buildAtom(title string, page string, items []feedItem) interface{} {
+	feed := atomFeed{
+		Title:   title,
+		ID:      page,
+		Updated: lastModified(items).Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: page},
+			{Rel: "self", Type: "application/atom+xml", Href: page + "feed.atom"},
+		},
+	}
+	for _, item := range items {
+		published := item.Published.Format(time.RFC3339)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:     item.Title,
+			ID:        item.URL,
+			Link:      atomLink{Href: item.URL},
+			Published: published,
+			Updated:   published,
+			Author:    atomAuthor{Name: item.Author},
+			Summary:   atomText{Type: "html", Body: string(item.Content)},
+		})
+	}
+	return feed
+}
+
+func buildRSS(title string, page string, items []feedItem) interface{} {
+	feed := rssFeed{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       title,
+			Link:        page,
+			Description: "Latest articles from " + title,
+		},
+	}
+	if len(items) > 0 {
+		feed.Channel.LastBuildDate = lastModified(items).Format(time.RFC1123Z)
+	}
+	for _, item := range items {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        rssGUID{IsPermaLink: true, Value: item.URL},
+			Author:      item.Author,
+			PubDate:     item.Published.Format(time.RFC1123Z),
+			Description: string(item.Content),
+		})
+	}
+	return feed
+}
+
+// serves a feed built by build, conditional requests are answered using the
+// ETag (hash of the feed) and Last-Modified (time of the latest article)
+// the feed contains only articles with the tag, unless it's empty
+func serveFeed(rw http.ResponseWriter, req *http.Request, tag string, contentType string,
+	build func(title string, page string, items []feedItem) interface{}) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		rw.Header().Set("Allow", "GET, HEAD")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+		return
+	}
+
+	root := rootURL(req)
+	items, err := loadFeedItems(req.Context(), root, tag)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	title, page := feedPage(globals.Config(req.Context()).BlogName, root, tag)
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(build(title, page, items)); err != nil {
+		fmt.Println("Error while encoding feed:", err.Error())
+		HandleError(rw, req, http.StatusInternalServerError)
+		return
+	}
+
+	hash := sha256.Sum256(buf.Bytes())
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("ETag", `"`+hex.EncodeToString(hash[:16])+`"`)
+
+	// ServeContent takes care of If-None-Match and If-Modified-Since
+	http.ServeContent(rw, req, "", lastModified(items), bytes.NewReader(buf.Bytes()))
+}
+
+// handles /feed.atom
+func HandleAtomFeed(rw http.ResponseWriter, req *http.Request) {
+	serveFeed(rw, req, "", "application/atom+xml; charset=utf-8", buildAtom)
+}
+
+// handles /feed.rss
+func HandleRSSFeed(rw http.ResponseWriter, req *http.Request) {
+	serveFeed(rw, req, "", "application/rss+xml; charset=utf-8", buildRSS)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"github.com/david-sorm/montesquieu/config"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFeeds(t *testing.T) {
+	s := &mock.Store{}
+	if err := s.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() returned an error: %v", err)
+	}
+	if err := s.Seed(); err != nil {
+		t.Fatalf("Seed() returned an error: %v", err)
+	}
+	globals.Cfg = &config.Config{Store: s, BlogName: "Test blog"}
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		contentType string
+		feed        interface{}
+	}{
+		{name: "atom", handler: HandleAtomFeed, contentType: "application/atom+xml; charset=utf-8", feed: &atomFeed{}},
+		{name: "rss", handler: HandleRSSFeed, contentType: "application/rss+xml; charset=utf-8", feed: &rssFeed{}},
+	}
+	for _, tt := range tests {
+		rw := httptest.NewRecorder()
+		tt.handler(rw, httptest.NewRequest("GET", "/feed."+tt.name, nil))
+		if rw.Code != http.StatusOK {
+			t.Fatalf("%v: got status code %v, want 200", tt.name, rw.Code)
+		}
+		if got := rw.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%v: got Content-Type %v, want %v", tt.name, got, tt.contentType)
+		}
+		if err := xml.Unmarshal(rw.Body.Bytes(), tt.feed); err != nil {
+			t.Errorf("%v: feed isn't valid XML: %v", tt.name, err)
+		}
+
+		// the feed hasn't changed, so it doesn't have to be sent again
+		etag := rw.Header().Get("ETag")
+		lastModified := rw.Header().Get("Last-Modified")
+		if etag == "" || lastModified == "" {
+			t.Fatalf("%v: ETag (%#v) or Last-Modified (%#v) is missing", tt.name, etag, lastModified)
+		}
+		req := httptest.NewRequest("GET", "/feed."+tt.name, nil)
+		req.Header.Set("If-None-Match", etag)
+		rw = httptest.NewRecorder()
+		tt.handler(rw, req)
+		if rw.Code != http.StatusNotModified {
+			t.Errorf("%v: conditional request got status code %v, want 304", tt.name, rw.Code)
+		}
+	}
+
+	atom := tests[0].feed.(*atomFeed)
+	if len(atom.Entries) != 11 {
+		t.Fatalf("atom feed has %v entries, want 11", len(atom.Entries))
+	}
+	if e := atom.Entries[0]; e.Link.Href != "http://example.com/article/welcome-to-your-brand-new-montesquieu-installation" || e.Author.Name != "Montesquieu" ||
+		e.Published != "2020-04-02T11:52:31Z" {
+		t.Errorf("unexpected first atom entry: %#v", e)
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"github.com/david-sorm/montesquieu/config"
+	"github.com/david-sorm/montesquieu/globals"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RouteByHost serves requests for the hosts of blogs with their config,
+// requests for any other host are served with globals.Cfg
+func RouteByHost(blogs map[string]*config.Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// hosts of blogs are lower-case and without a port
+		host := strings.ToLower(req.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if cfg, ok := blogs[host]; ok {
+			req = req.WithContext(globals.WithConfig(req.Context(), cfg))
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"github.com/david-sorm/montesquieu/config"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// makes a config of a blog with its own mock Store
+func prepareBlog(t *testing.T, name string) *config.Config {
+	s := &mock.Store{}
+	if err := s.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() returned an error: %v", err)
+	}
+	if err := s.Seed(); err != nil {
+		t.Fatalf("Seed() returned an error: %v", err)
+	}
+	return &config.Config{BlogName: name, Store: s, ArticlesPerPage: 5}
+}
+
+func TestRouteByHost(t *testing.T) {
+	globals.Cfg = prepareBlog(t, "Main blog")
+	other := prepareBlog(t, "Other blog")
+	if err := other.Store.RemoveArticle(context.Background(), 100); err != nil {
+		t.Fatalf("RemoveArticle() returned an error: %v", err)
+	}
+
+	// returns the name of the blog and the number of articles the request got
+	var name string
+	var num uint64
+	handler := RouteByHost(map[string]*config.Config{"other.org": other},
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			cfg := globals.Config(req.Context())
+			name = cfg.BlogName
+			num, _ = cfg.Store.GetArticleNumber(req.Context())
+		}))
+
+	tests := []struct {
+		host string
+		name string
+		num  uint64
+	}{
+		{host: "other.org", name: "Other blog", num: 10},
+		{host: "Other.org:8080", name: "Other blog", num: 10},
+		{host: "example.com", name: "Main blog", num: 11},
+		{host: "127.0.0.1:8080", name: "Main blog", num: 11},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tt.host
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if name != tt.name || num != tt.num {
+			t.Errorf("%v: served by %#v with %v articles, want %#v with %v", tt.host, name, num, tt.name, tt.num)
+		}
+	}
+
+	// the handlers use the blog of the request too
+	req := httptest.NewRequest("GET", "/feed.atom", nil)
+	req.Host = "other.org"
+	rw := httptest.NewRecorder()
+	RouteByHost(map[string]*config.Config{"other.org": other}, http.HandlerFunc(HandleAtomFeed)).ServeHTTP(rw, req)
+	if !strings.Contains(rw.Body.String(), "<title>Other blog</title>") {
+		t.Errorf("the feed of other.org doesn't belong to it:\n%v", rw.Body.String())
+	}
+}
@@ -29,8 +29,21 @@ type IndexView struct {
 
 	// the biggest page
 	MaxPage uint64
+
+	// the tag whose articles are listed, empty on the main index
+	Tag string
+
+	// path of the index, page numbers are appended to it
+	// "/" for the main index, "/tag/{name}/" for tags
+	BasePath string
+
+	// all tags for the tag cloud
+	Tags []article.CloudTag
 }
 
+// how many sizes of tags there are in the tag cloud
+const tagCloudLevels = 5
+
 // makes sure that we have the correct number of pages
 func countMaxPage(NumOfArticles uint64, ArticlesPerPage uint64) uint64 {
 	tempFloat := float64(NumOfArticles)/float64(ArticlesPerPage) - 1.0
@@ -47,27 +60,51 @@ func countMaxPage(NumOfArticles uint64, ArticlesPerPage uint64) uint64 {
 
 // executes
 func HandleIndex(rw http.ResponseWriter, req *http.Request) {
-	uri := req.URL.RequestURI()
-	indexView := IndexView{
-		BlogName: globals.Cfg.BlogName,
-
-		// first page if we don't specify below
-		Page: 0,
+	cfg := globals.Config(req.Context())
+	articleNum, err := cfg.Store.GetArticleNumber(req.Context())
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
 
-		// -1 since pages are zero-indexed
-		MaxPage: countMaxPage(globals.Cfg.Store.GetArticleNumber(), globals.Cfg.ArticlesPerPage),
+	indexView := IndexView{
+		BlogName: cfg.BlogName,
+		BasePath: "/",
 	}
 	// get rid of the '/' at the beginning
-	uri = strings.TrimPrefix(uri, "/")
+	page := strings.TrimPrefix(req.URL.Path, "/")
+
+	renderIndex(rw, req, indexView, page, articleNum, func(from uint64, to uint64) ([]article.Article, error) {
+		return cfg.Store.LoadArticlesSortedByLatest(req.Context(), from, to)
+	})
+}
+
+// renders the page of an index of articles, which is shared by the main index
+// and the pages of tags
+// page is the part of the URL after view.BasePath, articleNum the number of all
+// articles in the index, load loads the articles between two positions
+func renderIndex(rw http.ResponseWriter, req *http.Request, indexView IndexView, page string,
+	articleNum uint64, load func(from uint64, to uint64) ([]article.Article, error)) {
+	cfg := globals.Config(req.Context())
+	// first page if we don't specify below
+	indexView.Page = 0
+
+	// -1 since pages are zero-indexed
+	indexView.MaxPage = countMaxPage(articleNum, cfg.ArticlesPerPage)
 
 	// if there's something more than just '', try to figure out whether we've got this page or not
-	if len(uri) > 0 {
-		uriNum, err := strconv.ParseUint(uri, 10, 64)
+	if len(page) > 0 {
+		uriNum, err := strconv.ParseUint(page, 10, 64)
 		if err == nil {
 
 			// redirect page /0 to /, since it looks ugly
 			if uriNum == 0 {
-				http.Redirect(rw, req, "/", 301)
+				target := indexView.BasePath
+				if len(target) > 1 {
+					target = strings.TrimSuffix(target, "/")
+				}
+				http.Redirect(rw, req, target, 301)
+				return
 			}
 
 			/*
@@ -90,17 +127,28 @@ func HandleIndex(rw http.ResponseWriter, req *http.Request) {
 
 	// calculate the articles
 	// articles starting from
-	starti := globals.Cfg.ArticlesPerPage * indexView.Page
+	starti := cfg.ArticlesPerPage * indexView.Page
 	// and ending with these...
-	endi := starti + globals.Cfg.ArticlesPerPage
+	endi := starti + cfg.ArticlesPerPage
 
-	articleNum := globals.Cfg.Store.GetArticleNumber()
 	if endi > articleNum {
 		endi = articleNum
 	}
 
 	// insert the actual articles into page
-	indexView.Articles = globals.Cfg.Store.LoadArticlesSortedByLatest(starti, endi)
+	var err error
+	indexView.Articles, err = load(starti, endi)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+
+	tags, err := cfg.Store.ListTags(req.Context())
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	indexView.Tags = article.TagCloud(tags, tagCloudLevels)
 
 	// execute template
 
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	templates "github.com/david-sorm/montesquieu/template"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"strings"
+)
+
+type LoginView struct {
+	BlogName string
+
+	// login which was filled in by the user, so they don't have to type it again
+	Login string
+
+	// page where the user should be sent after logging in
+	Next string
+
+	// shown to the user if the login has failed
+	Error string
+}
+
+// sanitizes the page where the user should be redirected after logging in, so
+// we don't redirect to other sites
+func sanitizeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") ||
+		strings.HasPrefix(next, "/\\") {
+		return "/admin/panel"
+	}
+	return next
+}
+
+// checks the login and password, returns the ID of the user if they match
+// A wrong login or password isn't an error, only the bool is false
+func authenticate(ctx context.Context, login string, password string) (uint64, bool, error) {
+	cfg := globals.Config(ctx)
+	id, err := cfg.Store.GetUserID(ctx, login)
+	if errors.Is(err, store.ErrNotFound) {
+		// unknown logins take as long as wrong passwords, so they can't be
+		// told apart
+		users.VerifyPassword("", password)
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	user, err := cfg.Store.GetUser(ctx, id)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// VerifyPassword returns an error for wrong passwords too, so only the bool
+	// really matters
+	valid, _ := users.VerifyPassword(user.Password, password)
+	return id, valid, nil
+}
+
+func HandleLogin(rw http.ResponseWriter, req *http.Request) {
+	loginView := LoginView{
+		BlogName: globals.Config(req.Context()).BlogName,
+		Next:     sanitizeNext(req.URL.Query().Get("next")),
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		// already logged in users don't need to log in again
+		if _, err := currentSession(req); err == nil {
+			http.Redirect(rw, req, loginView.Next, http.StatusSeeOther)
+			return
+		}
+	case http.MethodPost:
+		loginView.Login = req.PostFormValue("login")
+		loginView.Next = sanitizeNext(req.PostFormValue("next"))
+
+		id, valid, err := authenticate(req.Context(), loginView.Login, req.PostFormValue("password"))
+		if err != nil {
+			handleStoreError(rw, req, err)
+			return
+		}
+		if valid {
+			if err := startSession(rw, req, id); err != nil {
+				handleStoreError(rw, req, err)
+				return
+			}
+			http.Redirect(rw, req, loginView.Next, http.StatusSeeOther)
+			return
+		}
+
+		rw.WriteHeader(http.StatusUnauthorized)
+		loginView.Error = "Invalid login or password"
+	default:
+		rw.Header().Set("Allow", "GET, POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := templates.Store.Lookup("login.gohtml").Execute(rw, loginView); err != nil {
+		fmt.Println("Error while parsing template:", err.Error())
+	}
+}
+
+func HandleLogout(rw http.ResponseWriter, req *http.Request) {
+	// logging out using GET would allow other sites to log our users out
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", "POST")
+		HandleError(rw, req, http.StatusMethodNotAllowed)
+		return
+	}
+
+	endSession(rw, req)
+	http.Redirect(rw, req, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/globals"
+	templates "github.com/david-sorm/montesquieu/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// longer queries are cut, nobody needs to search for whole paragraphs
+const maxQueryLength = 200
+
+type SearchView struct {
+	BlogName string
+
+	// the query as written by the reader
+	Query string
+
+	// the results on this page
+	Results []article.SearchResult
+
+	// the number of all results
+	ResultNumber uint64
+
+	// pages work the same way as in IndexView
+	LastPage uint64
+	Page     uint64
+	NextPage uint64
+	MaxPage  uint64
+}
+
+// handles /search?q={query}&page={page}
+func HandleSearch(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	query := cutQuery(req.URL.Query().Get("q"))
+
+	searchView := SearchView{
+		BlogName: cfg.BlogName,
+		Query:    query,
+	}
+
+	// an empty query only shows the search form
+	if query != "" {
+		num, err := cfg.Store.GetSearchResultNumber(req.Context(), query)
+		if err != nil {
+			handleStoreError(rw, req, err)
+			return
+		}
+		searchView.ResultNumber = num
+		searchView.MaxPage = countMaxPage(num, cfg.ArticlesPerPage)
+
+		if str := req.URL.Query().Get("page"); str != "" {
+			page, err := strconv.ParseUint(str, 10, 64)
+			if err != nil {
+				Handle404(rw, req)
+				return
+			}
+			if page <= searchView.MaxPage {
+				searchView.Page = page
+			}
+		}
+		searchView.LastPage = searchView.Page - 1
+		searchView.NextPage = searchView.Page + 1
+
+		from := cfg.ArticlesPerPage * searchView.Page
+		to := from + cfg.ArticlesPerPage
+		if to > num {
+			to = num
+		}
+		searchView.Results, err = cfg.Store.SearchArticles(req.Context(), query, from, to)
+		if err != nil {
+			handleStoreError(rw, req, err)
+			return
+		}
+	}
+
+	if err := templates.Store.Lookup("search.gohtml").Execute(rw, searchView); err != nil {
+		fmt.Println("Error while parsing template:", err.Error())
+	}
+}
+
+// cutQuery trims the query and cuts it to maxQueryLength characters, invalid
+// UTF-8 is left out, since databases would refuse it
+func cutQuery(query string) string {
+	query = strings.TrimSpace(strings.ToValidUTF8(query, ""))
+	if runes := []rune(query); len(runes) > maxQueryLength {
+		query = strings.TrimSpace(string(runes[:maxQueryLength]))
+	}
+	return query
+}
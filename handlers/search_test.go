@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCutQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "  hello world ", want: "hello world"},
+		{query: "caf\xe9", want: "caf"},
+		{query: strings.Repeat("a", maxQueryLength+10), want: strings.Repeat("a", maxQueryLength)},
+		{query: strings.Repeat("ž", maxQueryLength+10), want: strings.Repeat("ž", maxQueryLength)},
+	}
+	for _, tt := range tests {
+		got := cutQuery(tt.query)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("cutQuery(%#v) = %#v, want %#v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// name of the cookie which carries the session ID
+const sessionCookieName = "montesquieu_session"
+
+// how long a user stays logged in
+const sessionDuration = 7 * 24 * time.Hour
+
+// generates a new random session ID
+// Postgres doesn't know unsigned integers, so the ID is kept within int64
+func newSessionID() (uint64, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b) >> 1, nil
+}
+
+// startSession makes a new session for the user and sends its cookie
+func startSession(rw http.ResponseWriter, req *http.Request, userID uint64) error {
+	id, err := newSessionID()
+	if err != nil {
+		return err
+	}
+
+	session := users.Session{
+		ID:         id,
+		UserID:     userID,
+		ValidUntil: time.Now().Add(sessionDuration),
+	}
+	if err := globals.Config(req.Context()).Store.AddSession(req.Context(), session); err != nil {
+		return err
+	}
+
+	setSessionCookie(rw, req, session)
+	return nil
+}
+
+// setSessionCookie sends the cookie of the session to the user
+func setSessionCookie(rw http.ResponseWriter, req *http.Request, session users.Session) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    strconv.FormatUint(session.ID, 10),
+		Path:     "/",
+		Expires:  session.ValidUntil,
+		Secure:   req.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// refreshSession extends sessions which are past half of their lifetime, so
+// active users don't get logged out
+func refreshSession(rw http.ResponseWriter, req *http.Request, session users.Session) {
+	if time.Until(session.ValidUntil) > sessionDuration/2 {
+		return
+	}
+
+	session.ValidUntil = time.Now().Add(sessionDuration)
+	if err := globals.Config(req.Context()).Store.ExtendSession(req.Context(), session.ID, session.ValidUntil); err != nil {
+		// the session is still valid for a while, so this isn't fatal
+		fmt.Println("Error while extending a session:", err.Error())
+		return
+	}
+	setSessionCookie(rw, req, session)
+}
+
+// currentSession returns the valid session of the user sending the request
+// If the user isn't logged in, store.ErrNotFound is returned
+func currentSession(req *http.Request) (users.Session, error) {
+	cookie, err := req.Cookie(sessionCookieName)
+	if err != nil {
+		return users.Session{}, store.NewError(store.ErrNotFound, "reading the session cookie", err)
+	}
+
+	id, err := strconv.ParseUint(cookie.Value, 10, 64)
+	if err != nil {
+		return users.Session{}, store.NewError(store.ErrNotFound, "reading the session cookie", err)
+	}
+
+	return globals.Config(req.Context()).Store.GetSession(req.Context(), id)
+}
+
+// endSession removes the user's session, if there's one, and deletes the cookie
+func endSession(rw http.ResponseWriter, req *http.Request) {
+	if session, err := currentSession(req); err == nil {
+		if err := globals.Config(req.Context()).Store.RemoveSession(req.Context(), session.ID); err != nil {
+			fmt.Println("Error while removing a session:", err.Error())
+		}
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   req.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// currentUserID returns the ID of the logged in user sending the request
+func currentUserID(req *http.Request) (uint64, error) {
+	session, err := currentSession(req)
+	if err != nil {
+		return 0, err
+	}
+	return session.UserID, nil
+}
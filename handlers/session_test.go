@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"github.com/david-sorm/montesquieu/config"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/mock"
+	"github.com/david-sorm/montesquieu/users"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// prepares globals with a mock store containing an admin and a regular user
+// besides the example data of the mock store
+func prepareSessionTest(t *testing.T) (adminID uint64, userID uint64) {
+	s := &mock.Store{}
+	adminID, userID = prepareStoreTest(t, s, store.StoreConfig{})
+	if err := s.Seed(); err != nil {
+		t.Fatalf("Seed() returned an error: %v", err)
+	}
+	return adminID, userID
+}
+
+// prepares globals with the store the same way as prepareSessionTest
+func prepareStoreTest(t *testing.T, s store.Store, cfg store.StoreConfig) (adminID uint64, userID uint64) {
+	ctx := context.Background()
+	if err := s.Init(func() {}, cfg); err != nil {
+		t.Fatalf("Init() returned an error: %v", err)
+	}
+	globals.Cfg = &config.Config{Store: s}
+
+	hash, err := users.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword() returned an error: %v", err)
+	}
+	s.AddUser(ctx, "Admin", "admin", hash)
+	s.AddUser(ctx, "User", "user", hash)
+
+	adminID, err = s.GetUserID(ctx, "admin")
+	if err != nil {
+		t.Fatalf("GetUserID() returned an error: %v", err)
+	}
+	userID, _ = s.GetUserID(ctx, "user")
+	if err := s.PromoteToAdmin(ctx, adminID); err != nil {
+		t.Fatalf("PromoteToAdmin() returned an error: %v", err)
+	}
+	return adminID, userID
+}
+
+// logs in using the login handler and returns the session cookie
+func login(t *testing.T, login string, password string) *http.Cookie {
+	form := url.Values{"login": {login}, "password": {password}, "next": {"/admin/panel"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+
+	HandleLogin(rw, req)
+
+	if rw.Code != http.StatusSeeOther {
+		t.Fatalf("login as %v returned status code %v, want %v", login, rw.Code, http.StatusSeeOther)
+	}
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			return c
+		}
+	}
+	t.Fatalf("login as %v didn't set a session cookie", login)
+	return nil
+}
+
+func TestAuthorize(t *testing.T) {
+	prepareSessionTest(t)
+
+	reached := false
+	handler := Authorize(users.ManageUsers, func(rw http.ResponseWriter, req *http.Request) {
+		reached = true
+	})
+
+	// anonymous users are sent to the login page
+	rw := httptest.NewRecorder()
+	handler(rw, httptest.NewRequest("GET", "/admin/panel/users", nil))
+	if rw.Code != http.StatusSeeOther || reached {
+		t.Errorf("anonymous request: got status %v, reached %v", rw.Code, reached)
+	}
+	if loc := rw.Header().Get("Location"); loc != "/login?next=%2Fadmin%2Fpanel%2Fusers" {
+		t.Errorf("anonymous request redirected to %v", loc)
+	}
+
+	// regular users get a 403
+	req := httptest.NewRequest("GET", "/admin/panel/users", nil)
+	req.AddCookie(login(t, "user", "correct horse"))
+	rw = httptest.NewRecorder()
+	handler(rw, req)
+	if rw.Code != http.StatusForbidden || reached {
+		t.Errorf("request of a user: got status %v, reached %v", rw.Code, reached)
+	}
+
+	// admins get through
+	adminCookie := login(t, "admin", "correct horse")
+	req = httptest.NewRequest("GET", "/admin/panel/users", nil)
+	req.AddCookie(adminCookie)
+	handler(httptest.NewRecorder(), req)
+	if !reached {
+		t.Errorf("request of an admin didn't reach the handler")
+	}
+
+	// after logging out, the session isn't valid anymore
+	req = httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(adminCookie)
+	HandleLogout(httptest.NewRecorder(), req)
+
+	reached = false
+	req = httptest.NewRequest("GET", "/admin/panel/users", nil)
+	req.AddCookie(adminCookie)
+	rw = httptest.NewRecorder()
+	handler(rw, req)
+	if rw.Code != http.StatusSeeOther || reached {
+		t.Errorf("request after logout: got status %v, reached %v", rw.Code, reached)
+	}
+}
+
+func TestSanitizeNext(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{next: "/admin/panel/users", want: "/admin/panel/users"},
+		{next: "", want: "/admin/panel"},
+		{next: "https://example.com", want: "/admin/panel"},
+		{next: "//example.com", want: "/admin/panel"},
+		{next: "/\\example.com", want: "/admin/panel"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeNext(tt.next); got != tt.want {
+			t.Errorf("sanitizeNext(%#v) = %#v, want %#v", tt.next, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+//go:build cgo
+// +build cgo
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/sqlite"
+	"github.com/david-sorm/montesquieu/users"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// The mock Store is written to behave like the SQL ones, the tests below make
+// sure handlers page through a real database the same way
+
+// prepares globals with an empty sqlite store the same way as
+// prepareSessionTest, the store is removed once the test is done
+func prepareSQLiteTest(t *testing.T) (adminID uint64, userID uint64) {
+	dir, err := ioutil.TempDir("", "montesquieu")
+	if err != nil {
+		t.Fatalf("TempDir() returned an error: %v", err)
+	}
+	s := &sqlite.Store{}
+	t.Cleanup(func() {
+		s.Close()
+		os.RemoveAll(dir)
+	})
+	return prepareStoreTest(t, s, store.StoreConfig{Path: filepath.Join(dir, "blog.db")})
+}
+
+func TestAPIPagination_SQLite(t *testing.T) {
+	prepareSQLiteTest(t)
+	checkAPIPagination(t)
+}
+
+func TestAPIUnlinkAuthor_SQLite(t *testing.T) {
+	_, userID := prepareSQLiteTest(t)
+	ctx := context.Background()
+	if err := globals.Cfg.Store.AddAuthor(ctx, userID, "Author"); err != nil {
+		t.Fatalf("AddAuthor() returned an error: %v", err)
+	}
+	a, _ := globals.Cfg.Store.GetAuthor(ctx, userID)
+	cookie := login(t, "admin", "correct horse")
+
+	path := "/api/v1/authors/" + strconv.FormatUint(a.AuthorID, 10)
+	rw := apiRequest(AuthorizeAPI(users.ManageUsers, HandleAPIAuthors), "PUT", path, `{"userId": 0}`, cookie)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("unlinking the author: got status code %v, want %v", rw.Code, http.StatusOK)
+	}
+	if _, err := globals.Cfg.Store.GetAuthor(ctx, userID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAuthor() of the unlinked user returned %v, want ErrNotFound", err)
+	}
+}
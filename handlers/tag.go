@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/globals"
+	"net/http"
+	"strings"
+)
+
+// handles /tag/{name}, /tag/{name}/{page} and the feeds of the tag at
+// /tag/{name}/feed.atom and /tag/{name}/feed.rss
+func HandleTag(rw http.ResponseWriter, req *http.Request) {
+	cfg := globals.Config(req.Context())
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/tag/"), "/", 2)
+	tag := parts[0]
+	page := ""
+	if len(parts) > 1 {
+		page = parts[1]
+	}
+	if tag == "" {
+		Handle404(rw, req)
+		return
+	}
+
+	switch page {
+	case "feed.atom":
+		serveFeed(rw, req, tag, "application/atom+xml; charset=utf-8", buildAtom)
+		return
+	case "feed.rss":
+		serveFeed(rw, req, tag, "application/rss+xml; charset=utf-8", buildRSS)
+		return
+	}
+
+	articleNum, err := cfg.Store.GetArticleNumberByTag(req.Context(), tag)
+	if err != nil {
+		handleStoreError(rw, req, err)
+		return
+	}
+	// tags exist only as long as some published article has them
+	if articleNum == 0 {
+		Handle404(rw, req)
+		return
+	}
+
+	indexView := IndexView{
+		BlogName: cfg.BlogName,
+		Tag:      tag,
+		BasePath: article.TagURL(tag) + "/",
+	}
+	renderIndex(rw, req, indexView, page, articleNum, func(from uint64, to uint64) ([]article.Article, error) {
+		return cfg.Store.LoadArticlesByTag(req.Context(), tag, from, to)
+	})
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"github.com/david-sorm/montesquieu/config"
+	"github.com/david-sorm/montesquieu/globals"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleTag(t *testing.T) {
+	s := &mock.Store{}
+	if err := s.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() returned an error: %v", err)
+	}
+	if err := s.Seed(); err != nil {
+		t.Fatalf("Seed() returned an error: %v", err)
+	}
+	globals.Cfg = &config.Config{Store: s, BlogName: "Test blog", ArticlesPerPage: 2}
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{path: "/tag/even/0", code: http.StatusMovedPermanently, location: "/tag/even"},
+		{path: "/tag/even/page", code: http.StatusNotFound},
+		{path: "/tag/no-such-tag", code: http.StatusNotFound},
+		{path: "/tag/", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rw := httptest.NewRecorder()
+		HandleTag(rw, httptest.NewRequest("GET", tt.path, nil))
+		if rw.Code != tt.code {
+			t.Errorf("%v: got status code %v, want %v", tt.path, rw.Code, tt.code)
+		}
+		if got := rw.Header().Get("Location"); got != tt.location {
+			t.Errorf("%v: redirected to %#v, want %#v", tt.path, got, tt.location)
+		}
+	}
+
+	// the feed of a tag contains only articles with the tag
+	rw := httptest.NewRecorder()
+	HandleTag(rw, httptest.NewRequest("GET", "/tag/even/feed.atom", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("got status code %v, want 200", rw.Code)
+	}
+	feed := atomFeed{}
+	if err := xml.Unmarshal(rw.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("feed isn't valid XML: %v", err)
+	}
+	if len(feed.Entries) != 5 {
+		t.Errorf("feed has %v entries, want 5", len(feed.Entries))
+	}
+	if feed.ID != "http://example.com/tag/even/" {
+		t.Errorf("feed has ID %v, want the URL of the tag", feed.ID)
+	}
+}
@@ -1,39 +1,64 @@
 package run
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"github.com/david-sorm/montesquieu/article/render"
 	"github.com/david-sorm/montesquieu/config"
 	"github.com/david-sorm/montesquieu/globals"
 	"github.com/david-sorm/montesquieu/handlers"
 	"github.com/david-sorm/montesquieu/store"
 	templates "github.com/david-sorm/montesquieu/template"
+	"github.com/david-sorm/montesquieu/users"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func Main() {
+	listMigrations := flag.Bool("migrations", false,
+		"list the database migrations which would be applied on startup and exit, without applying them")
+	flag.Parse()
+
 	fmt.Println("Montesquieu starting...")
 	// get the config
 	fmt.Println("Loading config...")
 	var err error
 	globals.Cfg, err = config.NewConfig()
 	if err != nil {
-		fmt.Printf("While verifying the config, some errors in config.json were found. Please fix them before running Montesquieu:\n%s", err.Error())
+		fmt.Printf("While verifying the config, some errors in the config were found. Please fix them before running Montesquieu:\n%s", err.Error())
 		return
 	}
 
-	// init
-	fmt.Println("Initializing Store...")
-	asCfg := store.StoreConfig{
-		Host:                 globals.Cfg.StoreHost,
-		Database:             globals.Cfg.StoreDB,
-		Username:             globals.Cfg.StoreUser,
-		Password:             globals.Cfg.StorePassword,
-		Port:                 globals.Cfg.StorePort,
-		ArticlesPerIndexPage: globals.Cfg.ArticlesPerPage,
+	// every blog has its own Store, the first one is the blog of config.json
+	blogs := []*config.Config{globals.Cfg}
+	for _, blog := range globals.Cfg.Blogs {
+		blogs = append(blogs, blog)
 	}
-	err = globals.Cfg.Store.Init(func() {}, asCfg)
-	if err != nil {
-		fmt.Println("An error has happened while initializing Store: ", err.Error())
+
+	if *listMigrations {
+		for _, blog := range blogs {
+			printMigrations(blog)
+		}
+		return
+	}
+
+	// the background jobs of the Stores are stopped and waited for before the
+	// Stores are closed
+	jobs, stopJobs := context.WithCancel(context.Background())
+	var running sync.WaitGroup
+	for k, blog := range blogs {
+		if err := initStore(jobs, &running, blog); err != nil {
+			fmt.Println("An error has happened while initializing Store of", blog.BlogName+":", err.Error())
+			stopJobs()
+			running.Wait()
+			closeStores(blogs[:k])
+			os.Exit(1)
+		}
 	}
 
 	// prepare data for Views
@@ -53,14 +78,43 @@ func Main() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", handlers.HandleIndex)
 	mux.HandleFunc("/article/", handlers.HandleArticle)
-	mux.HandleFunc("/admin/panel", handlers.HandleAdminPanel)
-	mux.HandleFunc("/admin/panel/articles", handlers.HandleAdminPanelArticles)
-	mux.HandleFunc("/admin/panel/users", handlers.HandleAdminPanelUsers)
-	mux.HandleFunc("/admin/panel/authors", handlers.HandleAdminPanelAuthors)
-	mux.HandleFunc("/admin/panel/admins", handlers.HandleAdminPanelAdmins)
-	mux.HandleFunc("/admin/panel/configuration", handlers.HandleAdminPanelConfiguration)
-	// TODO handle login
-	// mux.HandleFunc("/login", handlers.HandleLogin)
+	mux.HandleFunc("/tag/", handlers.HandleTag)
+	mux.HandleFunc("/search", handlers.HandleSearch)
+	mux.HandleFunc("/feed.atom", handlers.HandleAtomFeed)
+	mux.HandleFunc("/feed.rss", handlers.HandleRSSFeed)
+	mux.HandleFunc("/login", handlers.HandleLogin)
+	mux.HandleFunc("/logout", handlers.HandleLogout)
+
+	// the API, everything but published articles is only accessible to users
+	// whose role allows it, either logged in or using API tokens
+	mux.HandleFunc("/api/", handlers.HandleAPINotFound)
+	mux.HandleFunc("/api/v1/articles", handlers.HandleAPIArticles)
+	mux.HandleFunc("/api/v1/articles/", handlers.HandleAPIArticles)
+	mux.HandleFunc("/api/v1/users", handlers.AuthorizeAPI(users.ManageUsers, handlers.HandleAPIUsers))
+	mux.HandleFunc("/api/v1/users/", handlers.AuthorizeAPI(users.ManageUsers, handlers.HandleAPIUsers))
+	mux.HandleFunc("/api/v1/authors", handlers.AuthorizeAPI(users.ManageUsers, handlers.HandleAPIAuthors))
+	mux.HandleFunc("/api/v1/authors/", handlers.AuthorizeAPI(users.ManageUsers, handlers.HandleAPIAuthors))
+	mux.HandleFunc("/api/v1/admins", handlers.AuthorizeAPI(users.ManageUsers, handlers.HandleAPIAdmins))
+	mux.HandleFunc("/api/v1/admins/", handlers.AuthorizeAPI(users.ManageUsers, handlers.HandleAPIAdmins))
+
+	// the admin panel is accessible to authors and everyone above them, the
+	// handlers of articles check whether authors can edit the article
+	mux.HandleFunc("/admin/panel", handlers.Authorize(users.AccessAdminPanel, handlers.HandleAdminPanel))
+	mux.HandleFunc("/admin/panel/articles", handlers.Authorize(users.WriteArticles, handlers.HandleAdminPanelArticles))
+	mux.HandleFunc("/admin/panel/articles/new", handlers.Authorize(users.WriteArticles, handlers.HandleAdminPanelArticleNew))
+	mux.HandleFunc("/admin/panel/articles/edit/", handlers.Authorize(users.WriteArticles, handlers.HandleAdminPanelArticleEdit))
+	mux.HandleFunc("/admin/panel/articles/delete/", handlers.Authorize(users.WriteArticles, handlers.HandleAdminPanelArticleDelete))
+	mux.HandleFunc("/admin/panel/articles/revisions/", handlers.Authorize(users.WriteArticles, handlers.HandleAdminPanelArticleRevisions))
+	mux.HandleFunc("/admin/panel/revisions/", handlers.Authorize(users.WriteArticles, handlers.HandleAdminPanelRevision))
+	mux.HandleFunc("/admin/panel/comments", handlers.Authorize(users.ModerateComments, handlers.HandleAdminPanelComments))
+	mux.HandleFunc("/admin/panel/comments/", handlers.Authorize(users.ModerateComments, handlers.HandleAdminPanelComment))
+	mux.HandleFunc("/admin/panel/users", handlers.Authorize(users.ManageUsers, handlers.HandleAdminPanelUsers))
+	mux.HandleFunc("/admin/panel/users/role/", handlers.Authorize(users.ManageUsers, handlers.HandleAdminPanelUserRole))
+	mux.HandleFunc("/admin/panel/authors", handlers.Authorize(users.ManageUsers, handlers.HandleAdminPanelAuthors))
+	mux.HandleFunc("/admin/panel/admins", handlers.Authorize(users.ManageUsers, handlers.HandleAdminPanelAdmins))
+	mux.HandleFunc("/admin/panel/configuration", handlers.Authorize(users.ManageUsers, handlers.HandleAdminPanelConfiguration))
+	mux.HandleFunc("/admin/panel/tokens", handlers.Authorize(users.AccessAdminPanel, handlers.HandleAdminPanelTokens))
+	mux.HandleFunc("/admin/panel/tokens/", handlers.Authorize(users.AccessAdminPanel, handlers.HandleAdminPanelToken))
 
 	// http.StripPrefix is needed for FileServer handlers so the paths work correctly
 	mux.Handle("/css/", http.StripPrefix("/css/", handleCss))
@@ -69,8 +123,169 @@ func Main() {
 
 	fmt.Println("Server starting at port", globals.Cfg.ListenOn)
 
-	// start the web server
-	if err := http.ListenAndServe(globals.Cfg.ListenOn, mux); err != nil {
+	// requests for the other blogs are served with their own config
+	var handler http.Handler = mux
+	if len(globals.Cfg.Blogs) > 0 {
+		handler = handlers.RouteByHost(globals.Cfg.Blogs, mux)
+	}
+
+	// start the web server, it's shut down gracefully on SIGINT and SIGTERM
+	server := &http.Server{Addr: globals.Cfg.ListenOn, Handler: handler}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	failed := false
+	select {
+	case err := <-serverErr:
 		fmt.Println("Error while starting web server:", err.Error())
+		failed = true
+	case sig := <-signals:
+		fmt.Println("Received", sig.String()+", shutting down...")
+		shutdown(server, globals.Cfg.ShutdownTimeout)
+	}
+
+	stopJobs()
+	running.Wait()
+	closeStores(blogs)
+	fmt.Println("Montesquieu stopped")
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// closeStores closes the Stores of the blogs, their background jobs have to be
+// stopped already
+func closeStores(blogs []*config.Config) {
+	for _, blog := range blogs {
+		if err := blog.Store.Close(); err != nil {
+			fmt.Println("An error has happened while closing Store of", blog.BlogName+":", err.Error())
+		}
+	}
+}
+
+// how long requests can take once Montesquieu is asked to stop, unless it's set
+// in the config
+const defaultShutdownTimeout = 10 * time.Second
+
+// shutdown stops the server from accepting new requests and waits for the ones
+// being served, until the timeout runs out
+func shutdown(server *http.Server, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Println("Error while shutting down web server:", err.Error())
+	}
+}
+
+// storeConfig returns the StoreConfig of the blog
+func storeConfig(cfg *config.Config) store.StoreConfig {
+	return store.StoreConfig{
+		Host:                 cfg.StoreHost,
+		Database:             cfg.StoreDB,
+		Username:             cfg.StoreUser,
+		Password:             cfg.StorePassword,
+		Port:                 cfg.StorePort,
+		ArticlesPerIndexPage: cfg.ArticlesPerPage,
+		Timeout:              cfg.StoreTimeout,
+		Renderer:             render.Markdown{},
+		PreviewLength:        cfg.PreviewLength,
+		Schema:               cfg.StoreSchema,
+		URL:                  cfg.StoreURL,
+		SSLMode:              cfg.StoreSSLMode,
+		SSLRootCert:          cfg.StoreSSLRootCert,
+		SSLCert:              cfg.StoreSSLCert,
+		SSLKey:               cfg.StoreSSLKey,
+		MinConns:             cfg.StoreMinConns,
+		MaxConns:             cfg.StoreMaxConns,
+		ConnectTimeout:       cfg.StoreConnectTimeout,
+		RetryBackoff:         cfg.StoreRetryBackoff,
+		Path:                 cfg.StorePath,
+	}
+}
+
+// initStore initializes the Store of the blog and starts its background jobs,
+// which run until ctx is cancelled, running is done once all of them have
+// returned
+func initStore(ctx context.Context, running *sync.WaitGroup, cfg *config.Config) error {
+	fmt.Println("Initializing Store of", cfg.BlogName+"...")
+
+	// the CachingStore doesn't pass Seed through, so it has to be kept aside
+	seeder, seed := cfg.Store.(store.Seeder)
+
+	// if there's a CachingStore, it sits between the handlers and the Store
+	if cfg.CachingStore != nil {
+		fmt.Println("Using", cfg.CachingStore.Info().Name, "CachingStore...")
+		cfg.CachingStore.Use(cfg.Store)
+		cfg.Store = cfg.CachingStore
+	}
+
+	if err := cfg.Store.Init(func() {}, storeConfig(cfg)); err != nil {
+		return err
+	}
+	if seed {
+		if err := seeder.Seed(); err != nil {
+			return err
+		}
+	}
+
+	// expired sessions would stay in the Store forever otherwise
+	running.Add(2)
+	go every(ctx, running, time.Hour, func() {
+		if err := cfg.Store.RemoveExpiredSessions(ctx); err != nil {
+			fmt.Println("An error has happened while removing expired sessions:", err.Error())
+		}
+	})
+
+	// scheduled articles have to be published once their time comes
+	go every(ctx, running, time.Minute, func() {
+		if _, err := cfg.Store.PublishScheduledArticles(ctx, time.Now()); err != nil {
+			fmt.Println("An error has happened while publishing scheduled articles:", err.Error())
+		}
+	})
+	return nil
+}
+
+// every runs job right away and then once per interval, until ctx is cancelled
+// running is told once the last job has returned
+func every(ctx context.Context, running *sync.WaitGroup, interval time.Duration, job func()) {
+	defer running.Done()
+	for {
+		job()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// printMigrations lists the migrations which the Store of the blog would apply
+// on startup
+func printMigrations(cfg *config.Config) {
+	migrator, ok := cfg.Store.(store.Migrator)
+	if !ok {
+		fmt.Println("The", cfg.Store.Info().Name, "Store of", cfg.BlogName, "doesn't have any migrations")
+		return
+	}
+
+	pending, err := migrator.PendingMigrations(storeConfig(cfg))
+	if err != nil {
+		fmt.Println("An error has happened while listing migrations of", cfg.BlogName+":", err.Error())
+		return
+	}
+	if len(pending) == 0 {
+		fmt.Println("The database of", cfg.BlogName, "is up to date, there are no migrations to apply")
+		return
+	}
+	fmt.Println("These migrations would be applied to the database of", cfg.BlogName, "on startup:")
+	for _, m := range pending {
+		fmt.Printf("%v: %v\n", m.Version, m.Name)
 	}
 }
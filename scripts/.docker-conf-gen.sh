@@ -1,4 +1,4 @@
 #!/bin/bash
 # Used to generate config.json from environment variables passed to docker container
 
-echo "{ \"BlogName\":\"${BLOGNAME}\",\"ArticlesPerPage\":\"${ARTICLESPERPAGE}\",	\"ListenOn\":\"${LISTENON}\",\"Store\":\"${STORE}\",\"StoreHost\":\"${STORE_HOST}\",\"StoreDB\":\"${STORE_DB}\",\"StoreUser\":\"${STORE_USER}\",\"StorePassword\":\"${STORE_PASSWORD}\",\"CachingStore\":\"${CACHINGSTORE}\",\"HotSwapTemplates\": \"${HOTSWAPTEMPLATES}\"}" > config.json
\ No newline at end of file
+echo "{ \"BlogName\":\"${BLOGNAME}\",\"ArticlesPerPage\":\"${ARTICLESPERPAGE}\",\"PreviewLength\":\"${PREVIEW_LENGTH}\",	\"ListenOn\":\"${LISTENON}\",\"Store\":\"${STORE}\",\"StoreHost\":\"${STORE_HOST}\",\"StoreDB\":\"${STORE_DB}\",\"StoreUser\":\"${STORE_USER}\",\"StorePassword\":\"${STORE_PASSWORD}\",\"StoreSchema\":\"${STORE_SCHEMA}\",\"StorePort\":\"${STORE_PORT}\",\"StoreURL\":\"${DATABASE_URL}\",\"StoreSSLMode\":\"${STORE_SSL_MODE}\",\"StoreSSLRootCert\":\"${STORE_SSL_ROOT_CERT}\",\"StoreSSLCert\":\"${STORE_SSL_CERT}\",\"StoreSSLKey\":\"${STORE_SSL_KEY}\",\"StoreMinConns\":\"${STORE_MIN_CONNS}\",\"StoreMaxConns\":\"${STORE_MAX_CONNS}\",\"StoreConnectTimeout\":\"${STORE_CONNECT_TIMEOUT}\",\"StoreRetryBackoff\":\"${STORE_RETRY_BACKOFF}\",\"StorePath\":\"${STORE_PATH}\",\"StoreTimeout\":\"${STORE_TIMEOUT}\",\"CachingStore\":\"${CACHINGSTORE}\",\"AnonymousComments\":\"${ANONYMOUS_COMMENTS}\",\"CommentBlocklist\":\"${COMMENT_BLOCKLIST}\",\"TrustedProxies\":\"${TRUSTED_PROXIES}\",\"HotSwapTemplates\": \"${HOTSWAPTEMPLATES}\",\"ShutdownTimeout\":\"${SHUTDOWN_TIMEOUT}\",\"Blogs\":\"${BLOGS}\"}" > config.json
\ No newline at end of file
@@ -6,5 +6,6 @@ rm config.json | true
 ./docker-conf-gen.sh
 
 # run
-# `serve` is the artefact made from compiling montesquieu
-./serve
\ No newline at end of file
+# `serve` is the artefact made from compiling montesquieu, it replaces the
+# shell, so it gets the SIGTERM of `docker stop` and shuts down gracefully
+exec ./serve
\ No newline at end of file
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"context"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/store"
+	"sync"
+	"time"
+)
+
+// Store is an in-process implementation of CachingStore
+// Articles are kept in memory after they have been loaded from the underlying
+// Store once, everything else is passed through to the underlying Store
+type Store struct {
+	// the underlying Store, all methods which aren't cached are passed through
+	store.Store
+
+	m sync.RWMutex
+
+	// pages of articles, indexed by the arguments of LoadArticlesSortedByLatest
+	articlesByRange map[articleRange][]article.Article
+
+	// articles indexed by their IDs
+	articlesByID map[uint64]article.Article
+
+	// the cached result of GetArticleNumber, only valid if articleNumberCached
+	// is true
+	articleNumber       uint64
+	articleNumberCached bool
+
+	// the cached result of ListTags, nil if it's not cached, it's shown on
+	// every index page
+	tags []article.TagCount
+
+	// incremented on every invalidation, so results of queries which were
+	// running while the cache was being invalidated don't get cached
+	generation uint64
+}
+
+// articleRange is used as a key for pages of articles
+type articleRange struct {
+	from uint64
+	to   uint64
+}
+
+// Use implements CachingStore's Use function
+func (c *Store) Use(s store.Store) {
+	c.Store = s
+}
+
+// Info implements Store's Info function
+func (c *Store) Info() store.StoreInfo {
+	return store.StoreInfo{
+		Name:      "internal",
+		Developer: "david-sorm",
+	}
+}
+
+// Init implements Store's Init function
+// The underlying Store is initialised first, every change reported by it drops
+// the cache before f gets called
+func (c *Store) Init(f func(), cfg store.StoreConfig) error {
+	c.invalidate()
+
+	return c.Store.Init(func() {
+		c.invalidate()
+		if f != nil {
+			f()
+		}
+	}, cfg)
+}
+
+// Close implements Store's Close function
+// The cache is dropped and the underlying Store is closed
+func (c *Store) Close() error {
+	c.invalidate()
+	return c.Store.Close()
+}
+
+// invalidate drops everything that's been cached so far
+func (c *Store) invalidate() {
+	c.m.Lock()
+	c.articlesByRange = make(map[articleRange][]article.Article)
+	c.articlesByID = make(map[uint64]article.Article)
+	c.articleNumberCached = false
+	c.tags = nil
+	c.generation++
+	c.m.Unlock()
+}
+
+// LoadArticlesSortedByLatest implements Store's LoadArticlesSortedByLatest function
+func (c *Store) LoadArticlesSortedByLatest(ctx context.Context, from uint64, to uint64) ([]article.Article, error) {
+	key := articleRange{from: from, to: to}
+
+	c.m.RLock()
+	cached, exists := c.articlesByRange[key]
+	gen := c.generation
+	c.m.RUnlock()
+	if exists {
+		return copyArticles(cached), nil
+	}
+
+	articles, err := c.Store.LoadArticlesSortedByLatest(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	c.m.Lock()
+	if gen == c.generation {
+		c.articlesByRange[key] = copyArticles(articles)
+	}
+	c.m.Unlock()
+
+	return articles, nil
+}
+
+// GetArticleByID implements Store's GetArticleByID function
+func (c *Store) GetArticleByID(ctx context.Context, id uint64) (article.Article, error) {
+	c.m.RLock()
+	cached, exists := c.articlesByID[id]
+	gen := c.generation
+	c.m.RUnlock()
+	if exists {
+		return cached, nil
+	}
+
+	// articles which don't exist aren't cached, so we don't fill the memory with
+	// garbage from random URLs
+	a, err := c.Store.GetArticleByID(ctx, id)
+	if err != nil {
+		return article.Article{}, err
+	}
+
+	c.m.Lock()
+	if gen == c.generation {
+		c.articlesByID[id] = a
+	}
+	c.m.Unlock()
+
+	return a, nil
+}
+
+// GetArticleNumber implements Store's GetArticleNumber function
+func (c *Store) GetArticleNumber(ctx context.Context) (uint64, error) {
+	c.m.RLock()
+	num, cached := c.articleNumber, c.articleNumberCached
+	gen := c.generation
+	c.m.RUnlock()
+	if cached {
+		return num, nil
+	}
+
+	num, err := c.Store.GetArticleNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	c.m.Lock()
+	if gen == c.generation {
+		c.articleNumber = num
+		c.articleNumberCached = true
+	}
+	c.m.Unlock()
+
+	return num, nil
+}
+
+// ListTags implements Store's ListTags function
+func (c *Store) ListTags(ctx context.Context) ([]article.TagCount, error) {
+	c.m.RLock()
+	cached := c.tags
+	gen := c.generation
+	c.m.RUnlock()
+	if cached != nil {
+		return copyTags(cached), nil
+	}
+
+	tags, err := c.Store.ListTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.m.Lock()
+	if gen == c.generation {
+		c.tags = copyTags(tags)
+	}
+	c.m.Unlock()
+
+	return tags, nil
+}
+
+// AddArticle implements Store's AddArticle function
+func (c *Store) AddArticle(ctx context.Context, a article.Article, userId uint64) (uint64, error) {
+	defer c.invalidate()
+	return c.Store.AddArticle(ctx, a, userId)
+}
+
+// PublishScheduledArticles implements Store's PublishScheduledArticles function
+func (c *Store) PublishScheduledArticles(ctx context.Context, now time.Time) (uint64, error) {
+	num, err := c.Store.PublishScheduledArticles(ctx, now)
+	// it's called often, but it usually doesn't publish anything
+	if num > 0 {
+		c.invalidate()
+	}
+	return num, err
+}
+
+// EditArticle implements Store's EditArticle function
+func (c *Store) EditArticle(ctx context.Context, a article.Article, userId uint64) error {
+	defer c.invalidate()
+	return c.Store.EditArticle(ctx, a, userId)
+}
+
+// RestoreRevision implements Store's RestoreRevision function
+func (c *Store) RestoreRevision(ctx context.Context, id uint64, userId uint64) error {
+	defer c.invalidate()
+	return c.Store.RestoreRevision(ctx, id, userId)
+}
+
+// RemoveArticle implements Store's RemoveArticle function
+func (c *Store) RemoveArticle(ctx context.Context, id uint64) error {
+	defer c.invalidate()
+	return c.Store.RemoveArticle(ctx, id)
+}
+
+// copyTags makes sure nobody outside the cache can modify its contents
+func copyTags(tags []article.TagCount) []article.TagCount {
+	c := make([]article.TagCount, len(tags))
+	copy(c, tags)
+	return c
+}
+
+// copyArticles makes sure nobody outside the cache can modify its contents
+func copyArticles(articles []article.Article) []article.Article {
+	c := make([]article.Article, len(articles))
+	copy(c, articles)
+	return c
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/mock"
+	"reflect"
+	"testing"
+)
+
+// countingStore counts how many times the cached methods reached the
+// underlying Store
+type countingStore struct {
+	mock.Store
+
+	loads   int
+	gets    int
+	numbers int
+	tags    int
+	edits   int
+	closes  int
+}
+
+func (cs *countingStore) LoadArticlesSortedByLatest(ctx context.Context, from uint64, to uint64) ([]article.Article, error) {
+	cs.loads++
+	return cs.Store.LoadArticlesSortedByLatest(ctx, from, to)
+}
+
+func (cs *countingStore) GetArticleByID(ctx context.Context, id uint64) (article.Article, error) {
+	cs.gets++
+	return cs.Store.GetArticleByID(ctx, id)
+}
+
+func (cs *countingStore) GetArticleNumber(ctx context.Context) (uint64, error) {
+	cs.numbers++
+	return cs.Store.GetArticleNumber(ctx)
+}
+
+func (cs *countingStore) ListTags(ctx context.Context) ([]article.TagCount, error) {
+	cs.tags++
+	return cs.Store.ListTags(ctx)
+}
+
+func (cs *countingStore) EditArticle(ctx context.Context, a article.Article, userId uint64) error {
+	cs.edits++
+	return cs.Store.EditArticle(ctx, a, userId)
+}
+
+func (cs *countingStore) Close() error {
+	cs.closes++
+	return cs.Store.Close()
+}
+
+// context used for all calls to stores
+var ctx = context.Background()
+
+// prepares a caching store backed by an initialised and seeded mock store
+func newTestStore(t *testing.T) (*Store, *countingStore) {
+	backend := &countingStore{}
+	c := &Store{}
+	c.Use(backend)
+	if err := c.Init(func() {}, store.StoreConfig{ArticlesPerIndexPage: 5}); err != nil {
+		t.Fatalf("Init() returned an error: %v", err)
+	}
+	if err := backend.Seed(); err != nil {
+		t.Fatalf("Seed() returned an error: %v", err)
+	}
+	return c, backend
+}
+
+func TestStore_LoadArticlesSortedByLatest(t *testing.T) {
+	c, backend := newTestStore(t)
+
+	first, err := c.LoadArticlesSortedByLatest(ctx, 0, 5)
+	if err != nil {
+		t.Fatalf("LoadArticlesSortedByLatest() returned an error: %v", err)
+	}
+	second, _ := c.LoadArticlesSortedByLatest(ctx, 0, 5)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("cached result differs: got %v, want %v", second, first)
+	}
+	if backend.loads != 1 {
+		t.Errorf("underlying store was queried %v times, want 1", backend.loads)
+	}
+
+	// a different range is a different page
+	c.LoadArticlesSortedByLatest(ctx, 5, 10)
+	if backend.loads != 2 {
+		t.Errorf("underlying store was queried %v times, want 2", backend.loads)
+	}
+
+	// modifying the returned slice mustn't modify the cache
+	first[0].Title = "Modified"
+	if got, _ := c.LoadArticlesSortedByLatest(ctx, 0, 5); got[0].Title == "Modified" {
+		t.Errorf("cache contents were modified through a returned slice")
+	}
+}
+
+func TestStore_GetArticleByID(t *testing.T) {
+	c, backend := newTestStore(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetArticleByID(ctx, 100); err != nil {
+			t.Fatalf("GetArticleByID(100) didn't find an existing article")
+		}
+	}
+	if backend.gets != 1 {
+		t.Errorf("underlying store was queried %v times, want 1", backend.gets)
+	}
+
+	// missing articles shouldn't be cached
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetArticleByID(ctx, 250604); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetArticleByID(250604) found an article which doesn't exist")
+		}
+	}
+	if backend.gets != 4 {
+		t.Errorf("underlying store was queried %v times, want 4", backend.gets)
+	}
+}
+
+func TestStore_Invalidation(t *testing.T) {
+	c, backend := newTestStore(t)
+
+	c.GetArticleNumber(ctx)
+	c.GetArticleNumber(ctx)
+	c.GetArticleByID(ctx, 100)
+	c.LoadArticlesSortedByLatest(ctx, 0, 5)
+	c.ListTags(ctx)
+	c.ListTags(ctx)
+	if backend.numbers != 1 || backend.gets != 1 || backend.loads != 1 || backend.tags != 1 {
+		t.Fatalf("cache didn't cache: %v numbers, %v gets, %v loads, %v tags",
+			backend.numbers, backend.gets, backend.loads, backend.tags)
+	}
+
+	// writes have to go through and drop the cache
+	c.EditArticle(ctx, article.Article{ID: 100}, 1)
+	if backend.edits != 1 {
+		t.Errorf("EditArticle() wasn't passed to the underlying store")
+	}
+
+	c.GetArticleNumber(ctx)
+	c.GetArticleByID(ctx, 100)
+	c.LoadArticlesSortedByLatest(ctx, 0, 5)
+	c.ListTags(ctx)
+	if backend.numbers != 2 || backend.gets != 2 || backend.loads != 2 || backend.tags != 2 {
+		t.Errorf("cache wasn't invalidated: %v numbers, %v gets, %v loads, %v tags",
+			backend.numbers, backend.gets, backend.loads, backend.tags)
+	}
+}
+
+func TestStore_Close(t *testing.T) {
+	c, backend := newTestStore(t)
+
+	c.GetArticleNumber(ctx)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() returned an error: %v", err)
+	}
+	if backend.closes != 1 {
+		t.Errorf("Close() wasn't passed to the underlying store")
+	}
+
+	// nothing cached before closing can be served afterwards
+	c.GetArticleNumber(ctx)
+	if backend.numbers != 2 {
+		t.Errorf("cache wasn't dropped on Close()")
+	}
+}
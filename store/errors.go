@@ -0,0 +1,60 @@
+package store
+
+import "errors"
+
+// Kinds of errors returned by Stores
+// Errors returned by a Store can be matched against these using errors.Is, for
+// example errors.Is(err, store.ErrNotFound)
+var (
+	// The requested item doesn't exist
+	ErrNotFound = errors.New("not found")
+
+	// The change can't be made, because it collides with data which already
+	// exists (a duplicate login, a user which is still an author...)
+	ErrConflict = errors.New("conflict")
+
+	// The Store can't be reached or it has failed to do its job
+	ErrUnavailable = errors.New("store unavailable")
+
+	// The data passed to the Store doesn't make sense
+	ErrInvalidInput = errors.New("invalid input")
+)
+
+// Error is the error returned by Stores
+// Kind is always one of the errors above, Err is the original error (if there's
+// one) which has caused it
+type Error struct {
+	Kind error
+
+	// what the Store was doing, for example "adding a user"
+	Activity string
+
+	Err error
+}
+
+// NewError makes a new Error of the kind
+func NewError(kind error, activity string, err error) *Error {
+	return &Error{
+		Kind:     kind,
+		Activity: activity,
+		Err:      err,
+	}
+}
+
+func (e *Error) Error() string {
+	str := e.Kind.Error() + " while " + e.Activity
+	if e.Err != nil {
+		str += ": " + e.Err.Error()
+	}
+	return str
+}
+
+// Is makes errors.Is work with the kinds of errors
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Unwrap makes errors.Is and errors.As work with the original error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
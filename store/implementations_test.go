@@ -2,23 +2,37 @@ package store_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/comments"
 	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/store/mock"
 	"github.com/david-sorm/montesquieu/store/postgres"
+	"github.com/david-sorm/montesquieu/store/sqlite"
 	"github.com/david-sorm/montesquieu/users"
 	"github.com/jackc/pgx/v4"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
 
 var storesToTest []store.Store
 
+// context used for all calls to stores
+var ctx = context.Background()
+
 var storeConfig store.StoreConfig
 
+// the connection string of postgres, used to change it behind the Store's back
+var pgConnString string
+
 type stores struct {
 	currentStore int
 	init         bool
@@ -41,6 +55,7 @@ func prepare() {
 		pgport = "5432"
 	}
 	connString := fmt.Sprintf("postgres://%v:%v@%v:%v/%v", pguser, pgpassword, pghost, pgport, pgdatabase)
+	pgConnString = connString
 
 	// wait until it starts up
 	err := errors.New("")
@@ -81,10 +96,33 @@ func (s *stores) Current() store.Store {
 // needed since we need to prepare the store before testing starts
 func TestMain(m *testing.M) {
 
-	// load all stores that are meant to be tested
+	// load all stores that are meant to be tested, STORES can pick some of
+	// them, like STORES=sqlite when there's no postgres to test against
+	names := os.Getenv("STORES")
+	if names == "" {
+		names = "postgres,sqlite,mock"
+	}
 	storesToTest = make([]store.Store, 0, 0)
-	postgresStore := &postgres.Store{}
-	storesToTest = append(storesToTest, postgresStore)
+	testPostgres := false
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "postgres":
+			storesToTest = append(storesToTest, &postgres.Store{})
+			testPostgres = true
+		case "sqlite":
+			storesToTest = append(storesToTest, &sqlite.Store{})
+		case "mock":
+			storesToTest = append(storesToTest, &mock.Store{})
+		default:
+			panic("Unknown store " + name + " in STORES")
+		}
+	}
+
+	// sqlite starts with an empty database in a temporary directory
+	dir, err := ioutil.TempDir("", "montesquieu")
+	if err != nil {
+		panic("Couldn't create a temporary directory for the sqlite store: " + err.Error())
+	}
 
 	// load mock config
 	storeConfig = store.StoreConfig{
@@ -94,14 +132,22 @@ func TestMain(m *testing.M) {
 		Password:             os.Getenv("PGPASSWORD"),
 		Port:                 os.Getenv("PGPORT"),
 		ArticlesPerIndexPage: 0,
+		Path:                 filepath.Join(dir, "test.db"),
 	}
 
 	// prepare all stores and their dependencies
-	prepare()
+	if testPostgres {
+		prepare()
+	}
 
 	// run the tests
 	exitCode := m.Run()
 
+	for _, s := range storesToTest {
+		s.Close()
+	}
+	os.RemoveAll(dir)
+
 	// end
 	os.Exit(exitCode)
 }
@@ -143,16 +189,18 @@ func Test_Users(t *testing.T) {
 		var passwordGot string
 		var login string
 		var id uint64
-		var exists bool
 		for i := 1; i < 101; i++ {
 			passwordWant = "neco" + strconv.Itoa(i)
 			login = "nekdo" + strconv.Itoa(i)
-			s.AddUser("Nekdo", login, passwordWant)
-			id, exists = s.GetUserID(login)
-			if !exists {
-				t.Fatalf("User could not be found using GetUserID")
+			if err := s.AddUser(ctx, "Nekdo", login, passwordWant); err != nil {
+				t.Fatalf("AddUser() returned an error: %v", err)
+			}
+			id = getUserID(t, s, login)
+			u, err := s.GetUser(ctx, id)
+			if err != nil {
+				t.Fatalf("GetUser() returned an error: %v", err)
 			}
-			passwordGot = s.GetUser(id).Password
+			passwordGot = u.Password
 			if passwordWant != passwordGot {
 				t.Errorf("password: got %#v, want %#v", passwordGot, passwordWant)
 			}
@@ -160,23 +208,28 @@ func Test_Users(t *testing.T) {
 
 		// check if all users are ok first
 		checkGotWant("ListUsers(0,100)",
-			s.ListUsers(0, 100),
+			listUsers(t, s, 0, 100),
 			[]users.User{{ID: 0x1, DisplayName: "Nekdo", Login: "nekdo1", Password: ""}, {ID: 0x2, DisplayName: "Nekdo", Login: "nekdo2", Password: ""}, {ID: 0x3, DisplayName: "Nekdo", Login: "nekdo3", Password: ""}, {ID: 0x4, DisplayName: "Nekdo", Login: "nekdo4", Password: ""}, {ID: 0x5, DisplayName: "Nekdo", Login: "nekdo5", Password: ""}, {ID: 0x6, DisplayName: "Nekdo", Login: "nekdo6", Password: ""}, {ID: 0x7, DisplayName: "Nekdo", Login: "nekdo7", Password: ""}, {ID: 0x8, DisplayName: "Nekdo", Login: "nekdo8", Password: ""}, {ID: 0x9, DisplayName: "Nekdo", Login: "nekdo9", Password: ""}, {ID: 0xa, DisplayName: "Nekdo", Login: "nekdo10", Password: ""}, {ID: 0xb, DisplayName: "Nekdo", Login: "nekdo11", Password: ""}, {ID: 0xc, DisplayName: "Nekdo", Login: "nekdo12", Password: ""}, {ID: 0xd, DisplayName: "Nekdo", Login: "nekdo13", Password: ""}, {ID: 0xe, DisplayName: "Nekdo", Login: "nekdo14", Password: ""}, {ID: 0xf, DisplayName: "Nekdo", Login: "nekdo15", Password: ""}, {ID: 0x10, DisplayName: "Nekdo", Login: "nekdo16", Password: ""}, {ID: 0x11, DisplayName: "Nekdo", Login: "nekdo17", Password: ""}, {ID: 0x12, DisplayName: "Nekdo", Login: "nekdo18", Password: ""}, {ID: 0x13, DisplayName: "Nekdo", Login: "nekdo19", Password: ""}, {ID: 0x14, DisplayName: "Nekdo", Login: "nekdo20", Password: ""}, {ID: 0x15, DisplayName: "Nekdo", Login: "nekdo21", Password: ""}, {ID: 0x16, DisplayName: "Nekdo", Login: "nekdo22", Password: ""}, {ID: 0x17, DisplayName: "Nekdo", Login: "nekdo23", Password: ""}, {ID: 0x18, DisplayName: "Nekdo", Login: "nekdo24", Password: ""}, {ID: 0x19, DisplayName: "Nekdo", Login: "nekdo25", Password: ""}, {ID: 0x1a, DisplayName: "Nekdo", Login: "nekdo26", Password: ""}, {ID: 0x1b, DisplayName: "Nekdo", Login: "nekdo27", Password: ""}, {ID: 0x1c, DisplayName: "Nekdo", Login: "nekdo28", Password: ""}, {ID: 0x1d, DisplayName: "Nekdo", Login: "nekdo29", Password: ""}, {ID: 0x1e, DisplayName: "Nekdo", Login: "nekdo30", Password: ""}, {ID: 0x1f, DisplayName: "Nekdo", Login: "nekdo31", Password: ""}, {ID: 0x20, DisplayName: "Nekdo", Login: "nekdo32", Password: ""}, {ID: 0x21, DisplayName: "Nekdo", Login: "nekdo33", Password: ""}, {ID: 0x22, DisplayName: "Nekdo", Login: "nekdo34", Password: ""}, {ID: 0x23, DisplayName: "Nekdo", Login: "nekdo35", Password: ""}, {ID: 0x24, DisplayName: "Nekdo", Login: "nekdo36", Password: ""}, {ID: 0x25, DisplayName: "Nekdo", Login: "nekdo37", Password: ""}, {ID: 0x26, DisplayName: "Nekdo", Login: "nekdo38", Password: ""}, {ID: 0x27, DisplayName: "Nekdo", Login: "nekdo39", Password: ""}, {ID: 0x28, DisplayName: "Nekdo", Login: "nekdo40", Password: ""}, {ID: 0x29, DisplayName: "Nekdo", Login: "nekdo41", Password: ""}, {ID: 0x2a, DisplayName: "Nekdo", Login: "nekdo42", Password: ""}, {ID: 0x2b, DisplayName: "Nekdo", Login: "nekdo43", Password: ""}, {ID: 0x2c, DisplayName: "Nekdo", Login: "nekdo44", Password: ""}, {ID: 0x2d, DisplayName: "Nekdo", Login: "nekdo45", Password: ""}, {ID: 0x2e, DisplayName: "Nekdo", Login: "nekdo46", Password: ""}, {ID: 0x2f, DisplayName: "Nekdo", Login: "nekdo47", Password: ""}, {ID: 0x30, DisplayName: "Nekdo", Login: "nekdo48", Password: ""}, {ID: 0x31, DisplayName: "Nekdo", Login: "nekdo49", Password: ""}, {ID: 0x32, DisplayName: "Nekdo", Login: "nekdo50", Password: ""}, {ID: 0x33, DisplayName: "Nekdo", Login: "nekdo51", Password: ""}, {ID: 0x34, DisplayName: "Nekdo", Login: "nekdo52", Password: ""}, {ID: 0x35, DisplayName: "Nekdo", Login: "nekdo53", Password: ""}, {ID: 0x36, DisplayName: "Nekdo", Login: "nekdo54", Password: ""}, {ID: 0x37, DisplayName: "Nekdo", Login: "nekdo55", Password: ""}, {ID: 0x38, DisplayName: "Nekdo", Login: "nekdo56", Password: ""}, {ID: 0x39, DisplayName: "Nekdo", Login: "nekdo57", Password: ""}, {ID: 0x3a, DisplayName: "Nekdo", Login: "nekdo58", Password: ""}, {ID: 0x3b, DisplayName: "Nekdo", Login: "nekdo59", Password: ""}, {ID: 0x3c, DisplayName: "Nekdo", Login: "nekdo60", Password: ""}, {ID: 0x3d, DisplayName: "Nekdo", Login: "nekdo61", Password: ""}, {ID: 0x3e, DisplayName: "Nekdo", Login: "nekdo62", Password: ""}, {ID: 0x3f, DisplayName: "Nekdo", Login: "nekdo63", Password: ""}, {ID: 0x40, DisplayName: "Nekdo", Login: "nekdo64", Password: ""}, {ID: 0x41, DisplayName: "Nekdo", Login: "nekdo65", Password: ""}, {ID: 0x42, DisplayName: "Nekdo", Login: "nekdo66", Password: ""}, {ID: 0x43, DisplayName: "Nekdo", Login: "nekdo67", Password: ""}, {ID: 0x44, DisplayName: "Nekdo", Login: "nekdo68", Password: ""}, {ID: 0x45, DisplayName: "Nekdo", Login: "nekdo69", Password: ""}, {ID: 0x46, DisplayName: "Nekdo", Login: "nekdo70", Password: ""}, {ID: 0x47, DisplayName: "Nekdo", Login: "nekdo71", Password: ""}, {ID: 0x48, DisplayName: "Nekdo", Login: "nekdo72", Password: ""}, {ID: 0x49, DisplayName: "Nekdo", Login: "nekdo73", Password: ""}, {ID: 0x4a, DisplayName: "Nekdo", Login: "nekdo74", Password: ""}, {ID: 0x4b, DisplayName: "Nekdo", Login: "nekdo75", Password: ""}, {ID: 0x4c, DisplayName: "Nekdo", Login: "nekdo76", Password: ""}, {ID: 0x4d, DisplayName: "Nekdo", Login: "nekdo77", Password: ""}, {ID: 0x4e, DisplayName: "Nekdo", Login: "nekdo78", Password: ""}, {ID: 0x4f, DisplayName: "Nekdo", Login: "nekdo79", Password: ""}, {ID: 0x50, DisplayName: "Nekdo", Login: "nekdo80", Password: ""}, {ID: 0x51, DisplayName: "Nekdo", Login: "nekdo81", Password: ""}, {ID: 0x52, DisplayName: "Nekdo", Login: "nekdo82", Password: ""}, {ID: 0x53, DisplayName: "Nekdo", Login: "nekdo83", Password: ""}, {ID: 0x54, DisplayName: "Nekdo", Login: "nekdo84", Password: ""}, {ID: 0x55, DisplayName: "Nekdo", Login: "nekdo85", Password: ""}, {ID: 0x56, DisplayName: "Nekdo", Login: "nekdo86", Password: ""}, {ID: 0x57, DisplayName: "Nekdo", Login: "nekdo87", Password: ""}, {ID: 0x58, DisplayName: "Nekdo", Login: "nekdo88", Password: ""}, {ID: 0x59, DisplayName: "Nekdo", Login: "nekdo89", Password: ""}, {ID: 0x5a, DisplayName: "Nekdo", Login: "nekdo90", Password: ""}, {ID: 0x5b, DisplayName: "Nekdo", Login: "nekdo91", Password: ""}, {ID: 0x5c, DisplayName: "Nekdo", Login: "nekdo92", Password: ""}, {ID: 0x5d, DisplayName: "Nekdo", Login: "nekdo93", Password: ""}, {ID: 0x5e, DisplayName: "Nekdo", Login: "nekdo94", Password: ""}, {ID: 0x5f, DisplayName: "Nekdo", Login: "nekdo95", Password: ""}, {ID: 0x60, DisplayName: "Nekdo", Login: "nekdo96", Password: ""}, {ID: 0x61, DisplayName: "Nekdo", Login: "nekdo97", Password: ""}, {ID: 0x62, DisplayName: "Nekdo", Login: "nekdo98", Password: ""}, {ID: 0x63, DisplayName: "Nekdo", Login: "nekdo99", Password: ""}, {ID: 0x64, DisplayName: "Nekdo", Login: "nekdo100", Password: ""}},
 			usersEqual)
 
 		// check if ranges are ok
 		checkGotWant("ListUsers(22,74)",
-			s.ListUsers(22, 74),
-			[]users.User{users.User{ID: 0x17, DisplayName: "Nekdo", Login: "nekdo23", Password: ""}, users.User{ID: 0x18, DisplayName: "Nekdo", Login: "nekdo24", Password: ""}, users.User{ID: 0x19, DisplayName: "Nekdo", Login: "nekdo25", Password: ""}, users.User{ID: 0x1a, DisplayName: "Nekdo", Login: "nekdo26", Password: ""}, users.User{ID: 0x1b, DisplayName: "Nekdo", Login: "nekdo27", Password: ""}, users.User{ID: 0x1c, DisplayName: "Nekdo", Login: "nekdo28", Password: ""}, users.User{ID: 0x1d, DisplayName: "Nekdo", Login: "nekdo29", Password: ""}, users.User{ID: 0x1e, DisplayName: "Nekdo", Login: "nekdo30", Password: ""}, users.User{ID: 0x1f, DisplayName: "Nekdo", Login: "nekdo31", Password: ""}, users.User{ID: 0x20, DisplayName: "Nekdo", Login: "nekdo32", Password: ""}, users.User{ID: 0x21, DisplayName: "Nekdo", Login: "nekdo33", Password: ""}, users.User{ID: 0x22, DisplayName: "Nekdo", Login: "nekdo34", Password: ""}, users.User{ID: 0x23, DisplayName: "Nekdo", Login: "nekdo35", Password: ""}, users.User{ID: 0x24, DisplayName: "Nekdo", Login: "nekdo36", Password: ""}, users.User{ID: 0x25, DisplayName: "Nekdo", Login: "nekdo37", Password: ""}, users.User{ID: 0x26, DisplayName: "Nekdo", Login: "nekdo38", Password: ""}, users.User{ID: 0x27, DisplayName: "Nekdo", Login: "nekdo39", Password: ""}, users.User{ID: 0x28, DisplayName: "Nekdo", Login: "nekdo40", Password: ""}, users.User{ID: 0x29, DisplayName: "Nekdo", Login: "nekdo41", Password: ""}, users.User{ID: 0x2a, DisplayName: "Nekdo", Login: "nekdo42", Password: ""}, users.User{ID: 0x2b, DisplayName: "Nekdo", Login: "nekdo43", Password: ""}, users.User{ID: 0x2c, DisplayName: "Nekdo", Login: "nekdo44", Password: ""}, users.User{ID: 0x2d, DisplayName: "Nekdo", Login: "nekdo45", Password: ""}, users.User{ID: 0x2e, DisplayName: "Nekdo", Login: "nekdo46", Password: ""}, users.User{ID: 0x2f, DisplayName: "Nekdo", Login: "nekdo47", Password: ""}, users.User{ID: 0x30, DisplayName: "Nekdo", Login: "nekdo48", Password: ""}, users.User{ID: 0x31, DisplayName: "Nekdo", Login: "nekdo49", Password: ""}, users.User{ID: 0x32, DisplayName: "Nekdo", Login: "nekdo50", Password: ""}, users.User{ID: 0x33, DisplayName: "Nekdo", Login: "nekdo51", Password: ""}, users.User{ID: 0x34, DisplayName: "Nekdo", Login: "nekdo52", Password: ""}, users.User{ID: 0x35, DisplayName: "Nekdo", Login: "nekdo53", Password: ""}, users.User{ID: 0x36, DisplayName: "Nekdo", Login: "nekdo54", Password: ""}, users.User{ID: 0x37, DisplayName: "Nekdo", Login: "nekdo55", Password: ""}, users.User{ID: 0x38, DisplayName: "Nekdo", Login: "nekdo56", Password: ""}, users.User{ID: 0x39, DisplayName: "Nekdo", Login: "nekdo57", Password: ""}, users.User{ID: 0x3a, DisplayName: "Nekdo", Login: "nekdo58", Password: ""}, users.User{ID: 0x3b, DisplayName: "Nekdo", Login: "nekdo59", Password: ""}, users.User{ID: 0x3c, DisplayName: "Nekdo", Login: "nekdo60", Password: ""}, users.User{ID: 0x3d, DisplayName: "Nekdo", Login: "nekdo61", Password: ""}, users.User{ID: 0x3e, DisplayName: "Nekdo", Login: "nekdo62", Password: ""}, users.User{ID: 0x3f, DisplayName: "Nekdo", Login: "nekdo63", Password: ""}, users.User{ID: 0x40, DisplayName: "Nekdo", Login: "nekdo64", Password: ""}, users.User{ID: 0x41, DisplayName: "Nekdo", Login: "nekdo65", Password: ""}, users.User{ID: 0x42, DisplayName: "Nekdo", Login: "nekdo66", Password: ""}, users.User{ID: 0x43, DisplayName: "Nekdo", Login: "nekdo67", Password: ""}, users.User{ID: 0x44, DisplayName: "Nekdo", Login: "nekdo68", Password: ""}, users.User{ID: 0x45, DisplayName: "Nekdo", Login: "nekdo69", Password: ""}, users.User{ID: 0x46, DisplayName: "Nekdo", Login: "nekdo70", Password: ""}, users.User{ID: 0x47, DisplayName: "Nekdo", Login: "nekdo71", Password: ""}, users.User{ID: 0x48, DisplayName: "Nekdo", Login: "nekdo72", Password: ""}, users.User{ID: 0x49, DisplayName: "Nekdo", Login: "nekdo73", Password: ""}, users.User{ID: 0x4a, DisplayName: "Nekdo", Login: "nekdo74", Password: ""}, users.User{ID: 0x4b, DisplayName: "Nekdo", Login: "nekdo75", Password: ""}, users.User{ID: 0x4c, DisplayName: "Nekdo", Login: "nekdo76", Password: ""}, users.User{ID: 0x4d, DisplayName: "Nekdo", Login: "nekdo77", Password: ""}, users.User{ID: 0x4e, DisplayName: "Nekdo", Login: "nekdo78", Password: ""}, users.User{ID: 0x4f, DisplayName: "Nekdo", Login: "nekdo79", Password: ""}, users.User{ID: 0x50, DisplayName: "Nekdo", Login: "nekdo80", Password: ""}, users.User{ID: 0x51, DisplayName: "Nekdo", Login: "nekdo81", Password: ""}, users.User{ID: 0x52, DisplayName: "Nekdo", Login: "nekdo82", Password: ""}, users.User{ID: 0x53, DisplayName: "Nekdo", Login: "nekdo83", Password: ""}, users.User{ID: 0x54, DisplayName: "Nekdo", Login: "nekdo84", Password: ""}, users.User{ID: 0x55, DisplayName: "Nekdo", Login: "nekdo85", Password: ""}, users.User{ID: 0x56, DisplayName: "Nekdo", Login: "nekdo86", Password: ""}, users.User{ID: 0x57, DisplayName: "Nekdo", Login: "nekdo87", Password: ""}, users.User{ID: 0x58, DisplayName: "Nekdo", Login: "nekdo88", Password: ""}, users.User{ID: 0x59, DisplayName: "Nekdo", Login: "nekdo89", Password: ""}, users.User{ID: 0x5a, DisplayName: "Nekdo", Login: "nekdo90", Password: ""}, users.User{ID: 0x5b, DisplayName: "Nekdo", Login: "nekdo91", Password: ""}, users.User{ID: 0x5c, DisplayName: "Nekdo", Login: "nekdo92", Password: ""}, users.User{ID: 0x5d, DisplayName: "Nekdo", Login: "nekdo93", Password: ""}, users.User{ID: 0x5e, DisplayName: "Nekdo", Login: "nekdo94", Password: ""}, users.User{ID: 0x5f, DisplayName: "Nekdo", Login: "nekdo95", Password: ""}, users.User{ID: 0x60, DisplayName: "Nekdo", Login: "nekdo96", Password: ""}},
+			listUsers(t, s, 22, 74),
+			[]users.User{users.User{ID: 0x17, DisplayName: "Nekdo", Login: "nekdo23", Password: ""}, users.User{ID: 0x18, DisplayName: "Nekdo", Login: "nekdo24", Password: ""}, users.User{ID: 0x19, DisplayName: "Nekdo", Login: "nekdo25", Password: ""}, users.User{ID: 0x1a, DisplayName: "Nekdo", Login: "nekdo26", Password: ""}, users.User{ID: 0x1b, DisplayName: "Nekdo", Login: "nekdo27", Password: ""}, users.User{ID: 0x1c, DisplayName: "Nekdo", Login: "nekdo28", Password: ""}, users.User{ID: 0x1d, DisplayName: "Nekdo", Login: "nekdo29", Password: ""}, users.User{ID: 0x1e, DisplayName: "Nekdo", Login: "nekdo30", Password: ""}, users.User{ID: 0x1f, DisplayName: "Nekdo", Login: "nekdo31", Password: ""}, users.User{ID: 0x20, DisplayName: "Nekdo", Login: "nekdo32", Password: ""}, users.User{ID: 0x21, DisplayName: "Nekdo", Login: "nekdo33", Password: ""}, users.User{ID: 0x22, DisplayName: "Nekdo", Login: "nekdo34", Password: ""}, users.User{ID: 0x23, DisplayName: "Nekdo", Login: "nekdo35", Password: ""}, users.User{ID: 0x24, DisplayName: "Nekdo", Login: "nekdo36", Password: ""}, users.User{ID: 0x25, DisplayName: "Nekdo", Login: "nekdo37", Password: ""}, users.User{ID: 0x26, DisplayName: "Nekdo", Login: "nekdo38", Password: ""}, users.User{ID: 0x27, DisplayName: "Nekdo", Login: "nekdo39", Password: ""}, users.User{ID: 0x28, DisplayName: "Nekdo", Login: "nekdo40", Password: ""}, users.User{ID: 0x29, DisplayName: "Nekdo", Login: "nekdo41", Password: ""}, users.User{ID: 0x2a, DisplayName: "Nekdo", Login: "nekdo42", Password: ""}, users.User{ID: 0x2b, DisplayName: "Nekdo", Login: "nekdo43", Password: ""}, users.User{ID: 0x2c, DisplayName: "Nekdo", Login: "nekdo44", Password: ""}, users.User{ID: 0x2d, DisplayName: "Nekdo", Login: "nekdo45", Password: ""}, users.User{ID: 0x2e, DisplayName: "Nekdo", Login: "nekdo46", Password: ""}, users.User{ID: 0x2f, DisplayName: "Nekdo", Login: "nekdo47", Password: ""}, users.User{ID: 0x30, DisplayName: "Nekdo", Login: "nekdo48", Password: ""}, users.User{ID: 0x31, DisplayName: "Nekdo", Login: "nekdo49", Password: ""}, users.User{ID: 0x32, DisplayName: "Nekdo", Login: "nekdo50", Password: ""}, users.User{ID: 0x33, DisplayName: "Nekdo", Login: "nekdo51", Password: ""}, users.User{ID: 0x34, DisplayName: "Nekdo", Login: "nekdo52", Password: ""}, users.User{ID: 0x35, DisplayName: "Nekdo", Login: "nekdo53", Password: ""}, users.User{ID: 0x36, DisplayName: "Nekdo", Login: "nekdo54", Password: ""}, users.User{ID: 0x37, DisplayName: "Nekdo", Login: "nekdo55", Password: ""}, users.User{ID: 0x38, DisplayName: "Nekdo", Login: "nekdo56", Password: ""}, users.User{ID: 0x39, DisplayName: "Nekdo", Login: "nekdo57", Password: ""}, users.User{ID: 0x3a, DisplayName: "Nekdo", Login: "nekdo58", Password: ""}, users.User{ID: 0x3b, DisplayName: "Nekdo", Login: "nekdo59", Password: ""}, users.User{ID: 0x3c, DisplayName: "Nekdo", Login: "nekdo60", Password: ""}, users.User{ID: 0x3d, DisplayName: "Nekdo", Login: "nekdo61", Password: ""}, users.User{ID: 0x3e, DisplayName: "Nekdo", Login: "nekdo62", Password: ""}, users.User{ID: 0x3f, DisplayName: "Nekdo", Login: "nekdo63", Password: ""}, users.User{ID: 0x40, DisplayName: "Nekdo", Login: "nekdo64", Password: ""}, users.User{ID: 0x41, DisplayName: "Nekdo", Login: "nekdo65", Password: ""}, users.User{ID: 0x42, DisplayName: "Nekdo", Login: "nekdo66", Password: ""}, users.User{ID: 0x43, DisplayName: "Nekdo", Login: "nekdo67", Password: ""}, users.User{ID: 0x44, DisplayName: "Nekdo", Login: "nekdo68", Password: ""}, users.User{ID: 0x45, DisplayName: "Nekdo", Login: "nekdo69", Password: ""}, users.User{ID: 0x46, DisplayName: "Nekdo", Login: "nekdo70", Password: ""}, users.User{ID: 0x47, DisplayName: "Nekdo", Login: "nekdo71", Password: ""}, users.User{ID: 0x48, DisplayName: "Nekdo", Login: "nekdo72", Password: ""}, users.User{ID: 0x49, DisplayName: "Nekdo", Login: "nekdo73", Password: ""}, users.User{ID: 0x4a, DisplayName: "Nekdo", Login: "nekdo74", Password: ""}},
 			usersEqual)
 
-		checkGotWant("ListUsers(31,5)",
-			s.ListUsers(31, 5),
+		checkGotWant("ListUsers(31,36)",
+			listUsers(t, s, 31, 36),
 			[]users.User{users.User{ID: 0x20, DisplayName: "Nekdo", Login: "nekdo32", Password: ""}, users.User{ID: 0x21, DisplayName: "Nekdo", Login: "nekdo33", Password: ""}, users.User{ID: 0x22, DisplayName: "Nekdo", Login: "nekdo34", Password: ""}, users.User{ID: 0x23, DisplayName: "Nekdo", Login: "nekdo35", Password: ""}, users.User{ID: 0x24, DisplayName: "Nekdo", Login: "nekdo36", Password: ""}},
 			usersEqual)
 
+		// a page further in has as many users as the ones on the first page
+		if got := listUsers(t, s, 40, 60); len(got) != 20 || got[0].Login != "nekdo41" {
+			t.Errorf("ListUsers(40,60) returned %v users starting with %#v, want 20 starting with nekdo41", len(got), got)
+		}
+
 		checkGotWant("ListUsers(98, 120)",
-			s.ListUsers(98, 120),
+			listUsers(t, s, 98, 120),
 			[]users.User{users.User{ID: 0x63, DisplayName: "Nekdo", Login: "nekdo99", Password: ""}, users.User{ID: 0x64, DisplayName: "Nekdo", Login: "nekdo100", Password: ""}},
 			usersEqual)
 
@@ -184,43 +237,44 @@ func Test_Users(t *testing.T) {
 		var str string
 		for i := 1; i < 101; i += 2 {
 			str = strconv.Itoa(i)
-			id, exists = s.GetUserID("nekdo" + str)
-			if !exists {
-				t.Fatalf("User could not be found using GetUserID")
-			}
-			s.EditUser(users.User{
+			id = getUserID(t, s, "nekdo"+str)
+			err := s.EditUser(ctx, users.User{
 				ID:          id,
 				DisplayName: "Nekdo++" + str,
 				Login:       "nekdo++" + str,
 				Password:    "neco" + str,
 			})
+			if err != nil {
+				t.Errorf("EditUser() returned an error: %v", err)
+			}
 		}
 
 		checkGotWant("ListUsers(0,130)",
-			s.ListUsers(0, 130),
+			listUsers(t, s, 0, 130),
 			[]users.User{users.User{ID: 0x1, DisplayName: "Nekdo++1", Login: "nekdo++1", Password: ""}, users.User{ID: 0x2, DisplayName: "Nekdo", Login: "nekdo2", Password: ""}, users.User{ID: 0x3, DisplayName: "Nekdo++3", Login: "nekdo++3", Password: ""}, users.User{ID: 0x4, DisplayName: "Nekdo", Login: "nekdo4", Password: ""}, users.User{ID: 0x5, DisplayName: "Nekdo++5", Login: "nekdo++5", Password: ""}, users.User{ID: 0x6, DisplayName: "Nekdo", Login: "nekdo6", Password: ""}, users.User{ID: 0x7, DisplayName: "Nekdo++7", Login: "nekdo++7", Password: ""}, users.User{ID: 0x8, DisplayName: "Nekdo", Login: "nekdo8", Password: ""}, users.User{ID: 0x9, DisplayName: "Nekdo++9", Login: "nekdo++9", Password: ""}, users.User{ID: 0xa, DisplayName: "Nekdo", Login: "nekdo10", Password: ""}, users.User{ID: 0xb, DisplayName: "Nekdo++11", Login: "nekdo++11", Password: ""}, users.User{ID: 0xc, DisplayName: "Nekdo", Login: "nekdo12", Password: ""}, users.User{ID: 0xd, DisplayName: "Nekdo++13", Login: "nekdo++13", Password: ""}, users.User{ID: 0xe, DisplayName: "Nekdo", Login: "nekdo14", Password: ""}, users.User{ID: 0xf, DisplayName: "Nekdo++15", Login: "nekdo++15", Password: ""}, users.User{ID: 0x10, DisplayName: "Nekdo", Login: "nekdo16", Password: ""}, users.User{ID: 0x11, DisplayName: "Nekdo++17", Login: "nekdo++17", Password: ""}, users.User{ID: 0x12, DisplayName: "Nekdo", Login: "nekdo18", Password: ""}, users.User{ID: 0x13, DisplayName: "Nekdo++19", Login: "nekdo++19", Password: ""}, users.User{ID: 0x14, DisplayName: "Nekdo", Login: "nekdo20", Password: ""}, users.User{ID: 0x15, DisplayName: "Nekdo++21", Login: "nekdo++21", Password: ""}, users.User{ID: 0x16, DisplayName: "Nekdo", Login: "nekdo22", Password: ""}, users.User{ID: 0x17, DisplayName: "Nekdo++23", Login: "nekdo++23", Password: ""}, users.User{ID: 0x18, DisplayName: "Nekdo", Login: "nekdo24", Password: ""}, users.User{ID: 0x19, DisplayName: "Nekdo++25", Login: "nekdo++25", Password: ""}, users.User{ID: 0x1a, DisplayName: "Nekdo", Login: "nekdo26", Password: ""}, users.User{ID: 0x1b, DisplayName: "Nekdo++27", Login: "nekdo++27", Password: ""}, users.User{ID: 0x1c, DisplayName: "Nekdo", Login: "nekdo28", Password: ""}, users.User{ID: 0x1d, DisplayName: "Nekdo++29", Login: "nekdo++29", Password: ""}, users.User{ID: 0x1e, DisplayName: "Nekdo", Login: "nekdo30", Password: ""}, users.User{ID: 0x1f, DisplayName: "Nekdo++31", Login: "nekdo++31", Password: ""}, users.User{ID: 0x20, DisplayName: "Nekdo", Login: "nekdo32", Password: ""}, users.User{ID: 0x21, DisplayName: "Nekdo++33", Login: "nekdo++33", Password: ""}, users.User{ID: 0x22, DisplayName: "Nekdo", Login: "nekdo34", Password: ""}, users.User{ID: 0x23, DisplayName: "Nekdo++35", Login: "nekdo++35", Password: ""}, users.User{ID: 0x24, DisplayName: "Nekdo", Login: "nekdo36", Password: ""}, users.User{ID: 0x25, DisplayName: "Nekdo++37", Login: "nekdo++37", Password: ""}, users.User{ID: 0x26, DisplayName: "Nekdo", Login: "nekdo38", Password: ""}, users.User{ID: 0x27, DisplayName: "Nekdo++39", Login: "nekdo++39", Password: ""}, users.User{ID: 0x28, DisplayName: "Nekdo", Login: "nekdo40", Password: ""}, users.User{ID: 0x29, DisplayName: "Nekdo++41", Login: "nekdo++41", Password: ""}, users.User{ID: 0x2a, DisplayName: "Nekdo", Login: "nekdo42", Password: ""}, users.User{ID: 0x2b, DisplayName: "Nekdo++43", Login: "nekdo++43", Password: ""}, users.User{ID: 0x2c, DisplayName: "Nekdo", Login: "nekdo44", Password: ""}, users.User{ID: 0x2d, DisplayName: "Nekdo++45", Login: "nekdo++45", Password: ""}, users.User{ID: 0x2e, DisplayName: "Nekdo", Login: "nekdo46", Password: ""}, users.User{ID: 0x2f, DisplayName: "Nekdo++47", Login: "nekdo++47", Password: ""}, users.User{ID: 0x30, DisplayName: "Nekdo", Login: "nekdo48", Password: ""}, users.User{ID: 0x31, DisplayName: "Nekdo++49", Login: "nekdo++49", Password: ""}, users.User{ID: 0x32, DisplayName: "Nekdo", Login: "nekdo50", Password: ""}, users.User{ID: 0x33, DisplayName: "Nekdo++51", Login: "nekdo++51", Password: ""}, users.User{ID: 0x34, DisplayName: "Nekdo", Login: "nekdo52", Password: ""}, users.User{ID: 0x35, DisplayName: "Nekdo++53", Login: "nekdo++53", Password: ""}, users.User{ID: 0x36, DisplayName: "Nekdo", Login: "nekdo54", Password: ""}, users.User{ID: 0x37, DisplayName: "Nekdo++55", Login: "nekdo++55", Password: ""}, users.User{ID: 0x38, DisplayName: "Nekdo", Login: "nekdo56", Password: ""}, users.User{ID: 0x39, DisplayName: "Nekdo++57", Login: "nekdo++57", Password: ""}, users.User{ID: 0x3a, DisplayName: "Nekdo", Login: "nekdo58", Password: ""}, users.User{ID: 0x3b, DisplayName: "Nekdo++59", Login: "nekdo++59", Password: ""}, users.User{ID: 0x3c, DisplayName: "Nekdo", Login: "nekdo60", Password: ""}, users.User{ID: 0x3d, DisplayName: "Nekdo++61", Login: "nekdo++61", Password: ""}, users.User{ID: 0x3e, DisplayName: "Nekdo", Login: "nekdo62", Password: ""}, users.User{ID: 0x3f, DisplayName: "Nekdo++63", Login: "nekdo++63", Password: ""}, users.User{ID: 0x40, DisplayName: "Nekdo", Login: "nekdo64", Password: ""}, users.User{ID: 0x41, DisplayName: "Nekdo++65", Login: "nekdo++65", Password: ""}, users.User{ID: 0x42, DisplayName: "Nekdo", Login: "nekdo66", Password: ""}, users.User{ID: 0x43, DisplayName: "Nekdo++67", Login: "nekdo++67", Password: ""}, users.User{ID: 0x44, DisplayName: "Nekdo", Login: "nekdo68", Password: ""}, users.User{ID: 0x45, DisplayName: "Nekdo++69", Login: "nekdo++69", Password: ""}, users.User{ID: 0x46, DisplayName: "Nekdo", Login: "nekdo70", Password: ""}, users.User{ID: 0x47, DisplayName: "Nekdo++71", Login: "nekdo++71", Password: ""}, users.User{ID: 0x48, DisplayName: "Nekdo", Login: "nekdo72", Password: ""}, users.User{ID: 0x49, DisplayName: "Nekdo++73", Login: "nekdo++73", Password: ""}, users.User{ID: 0x4a, DisplayName: "Nekdo", Login: "nekdo74", Password: ""}, users.User{ID: 0x4b, DisplayName: "Nekdo++75", Login: "nekdo++75", Password: ""}, users.User{ID: 0x4c, DisplayName: "Nekdo", Login: "nekdo76", Password: ""}, users.User{ID: 0x4d, DisplayName: "Nekdo++77", Login: "nekdo++77", Password: ""}, users.User{ID: 0x4e, DisplayName: "Nekdo", Login: "nekdo78", Password: ""}, users.User{ID: 0x4f, DisplayName: "Nekdo++79", Login: "nekdo++79", Password: ""}, users.User{ID: 0x50, DisplayName: "Nekdo", Login: "nekdo80", Password: ""}, users.User{ID: 0x51, DisplayName: "Nekdo++81", Login: "nekdo++81", Password: ""}, users.User{ID: 0x52, DisplayName: "Nekdo", Login: "nekdo82", Password: ""}, users.User{ID: 0x53, DisplayName: "Nekdo++83", Login: "nekdo++83", Password: ""}, users.User{ID: 0x54, DisplayName: "Nekdo", Login: "nekdo84", Password: ""}, users.User{ID: 0x55, DisplayName: "Nekdo++85", Login: "nekdo++85", Password: ""}, users.User{ID: 0x56, DisplayName: "Nekdo", Login: "nekdo86", Password: ""}, users.User{ID: 0x57, DisplayName: "Nekdo++87", Login: "nekdo++87", Password: ""}, users.User{ID: 0x58, DisplayName: "Nekdo", Login: "nekdo88", Password: ""}, users.User{ID: 0x59, DisplayName: "Nekdo++89", Login: "nekdo++89", Password: ""}, users.User{ID: 0x5a, DisplayName: "Nekdo", Login: "nekdo90", Password: ""}, users.User{ID: 0x5b, DisplayName: "Nekdo++91", Login: "nekdo++91", Password: ""}, users.User{ID: 0x5c, DisplayName: "Nekdo", Login: "nekdo92", Password: ""}, users.User{ID: 0x5d, DisplayName: "Nekdo++93", Login: "nekdo++93", Password: ""}, users.User{ID: 0x5e, DisplayName: "Nekdo", Login: "nekdo94", Password: ""}, users.User{ID: 0x5f, DisplayName: "Nekdo++95", Login: "nekdo++95", Password: ""}, users.User{ID: 0x60, DisplayName: "Nekdo", Login: "nekdo96", Password: ""}, users.User{ID: 0x61, DisplayName: "Nekdo++97", Login: "nekdo++97", Password: ""}, users.User{ID: 0x62, DisplayName: "Nekdo", Login: "nekdo98", Password: ""}, users.User{ID: 0x63, DisplayName: "Nekdo++99", Login: "nekdo++99", Password: ""}, users.User{ID: 0x64, DisplayName: "Nekdo", Login: "nekdo100", Password: ""}},
 			usersEqual)
 
 		// remove the other half
 		for i := 2; i < 101; i += 2 {
 			str = strconv.Itoa(i)
-			id, exists = s.GetUserID("nekdo" + str)
-			if !exists {
-				t.Fatalf("User could not be found using GetUserID")
+			id = getUserID(t, s, "nekdo"+str)
+			if err := s.RemoveUser(ctx, id); err != nil {
+				t.Errorf("RemoveUser() returned an error: %v", err)
 			}
-			s.RemoveUser(id)
 		}
 
 		// check if the other half was deleted
 		checkGotWant("ListUsers(0,120)",
-			s.ListUsers(0, 120),
+			listUsers(t, s, 0, 120),
 			[]users.User{users.User{ID: 0x1, DisplayName: "Nekdo++1", Login: "nekdo++1", Password: ""}, users.User{ID: 0x3, DisplayName: "Nekdo++3", Login: "nekdo++3", Password: ""}, users.User{ID: 0x5, DisplayName: "Nekdo++5", Login: "nekdo++5", Password: ""}, users.User{ID: 0x7, DisplayName: "Nekdo++7", Login: "nekdo++7", Password: ""}, users.User{ID: 0x9, DisplayName: "Nekdo++9", Login: "nekdo++9", Password: ""}, users.User{ID: 0xb, DisplayName: "Nekdo++11", Login: "nekdo++11", Password: ""}, users.User{ID: 0xd, DisplayName: "Nekdo++13", Login: "nekdo++13", Password: ""}, users.User{ID: 0xf, DisplayName: "Nekdo++15", Login: "nekdo++15", Password: ""}, users.User{ID: 0x11, DisplayName: "Nekdo++17", Login: "nekdo++17", Password: ""}, users.User{ID: 0x13, DisplayName: "Nekdo++19", Login: "nekdo++19", Password: ""}, users.User{ID: 0x15, DisplayName: "Nekdo++21", Login: "nekdo++21", Password: ""}, users.User{ID: 0x17, DisplayName: "Nekdo++23", Login: "nekdo++23", Password: ""}, users.User{ID: 0x19, DisplayName: "Nekdo++25", Login: "nekdo++25", Password: ""}, users.User{ID: 0x1b, DisplayName: "Nekdo++27", Login: "nekdo++27", Password: ""}, users.User{ID: 0x1d, DisplayName: "Nekdo++29", Login: "nekdo++29", Password: ""}, users.User{ID: 0x1f, DisplayName: "Nekdo++31", Login: "nekdo++31", Password: ""}, users.User{ID: 0x21, DisplayName: "Nekdo++33", Login: "nekdo++33", Password: ""}, users.User{ID: 0x23, DisplayName: "Nekdo++35", Login: "nekdo++35", Password: ""}, users.User{ID: 0x25, DisplayName: "Nekdo++37", Login: "nekdo++37", Password: ""}, users.User{ID: 0x27, DisplayName: "Nekdo++39", Login: "nekdo++39", Password: ""}, users.User{ID: 0x29, DisplayName: "Nekdo++41", Login: "nekdo++41", Password: ""}, users.User{ID: 0x2b, DisplayName: "Nekdo++43", Login: "nekdo++43", Password: ""}, users.User{ID: 0x2d, DisplayName: "Nekdo++45", Login: "nekdo++45", Password: ""}, users.User{ID: 0x2f, DisplayName: "Nekdo++47", Login: "nekdo++47", Password: ""}, users.User{ID: 0x31, DisplayName: "Nekdo++49", Login: "nekdo++49", Password: ""}, users.User{ID: 0x33, DisplayName: "Nekdo++51", Login: "nekdo++51", Password: ""}, users.User{ID: 0x35, DisplayName: "Nekdo++53", Login: "nekdo++53", Password: ""}, users.User{ID: 0x37, DisplayName: "Nekdo++55", Login: "nekdo++55", Password: ""}, users.User{ID: 0x39, DisplayName: "Nekdo++57", Login: "nekdo++57", Password: ""}, users.User{ID: 0x3b, DisplayName: "Nekdo++59", Login: "nekdo++59", Password: ""}, users.User{ID: 0x3d, DisplayName: "Nekdo++61", Login: "nekdo++61", Password: ""}, users.User{ID: 0x3f, DisplayName: "Nekdo++63", Login: "nekdo++63", Password: ""}, users.User{ID: 0x41, DisplayName: "Nekdo++65", Login: "nekdo++65", Password: ""}, users.User{ID: 0x43, DisplayName: "Nekdo++67", Login: "nekdo++67", Password: ""}, users.User{ID: 0x45, DisplayName: "Nekdo++69", Login: "nekdo++69", Password: ""}, users.User{ID: 0x47, DisplayName: "Nekdo++71", Login: "nekdo++71", Password: ""}, users.User{ID: 0x49, DisplayName: "Nekdo++73", Login: "nekdo++73", Password: ""}, users.User{ID: 0x4b, DisplayName: "Nekdo++75", Login: "nekdo++75", Password: ""}, users.User{ID: 0x4d, DisplayName: "Nekdo++77", Login: "nekdo++77", Password: ""}, users.User{ID: 0x4f, DisplayName: "Nekdo++79", Login: "nekdo++79", Password: ""}, users.User{ID: 0x51, DisplayName: "Nekdo++81", Login: "nekdo++81", Password: ""}, users.User{ID: 0x53, DisplayName: "Nekdo++83", Login: "nekdo++83", Password: ""}, users.User{ID: 0x55, DisplayName: "Nekdo++85", Login: "nekdo++85", Password: ""}, users.User{ID: 0x57, DisplayName: "Nekdo++87", Login: "nekdo++87", Password: ""}, users.User{ID: 0x59, DisplayName: "Nekdo++89", Login: "nekdo++89", Password: ""}, users.User{ID: 0x5b, DisplayName: "Nekdo++91", Login: "nekdo++91", Password: ""}, users.User{ID: 0x5d, DisplayName: "Nekdo++93", Login: "nekdo++93", Password: ""}, users.User{ID: 0x5f, DisplayName: "Nekdo++95", Login: "nekdo++95", Password: ""}, users.User{ID: 0x61, DisplayName: "Nekdo++97", Login: "nekdo++97", Password: ""}, users.User{ID: 0x63, DisplayName: "Nekdo++99", Login: "nekdo++99", Password: ""}},
 			usersEqual)
 
 		// delete all users
-		usrs := s.ListUsers(0, 101)
+		usrs := listUsers(t, s, 0, 101)
 		for _, v := range usrs {
-			s.RemoveUser(v.ID)
+			if err := s.RemoveUser(ctx, v.ID); err != nil {
+				t.Errorf("RemoveUser() returned an error: %v", err)
+			}
 		}
 	}
 }
@@ -253,52 +307,945 @@ func Test_Authors(t *testing.T) {
 		s := strs.Current()
 
 		// add a few users
-		s.AddUser("Nekdo 1", "nekdo1", "")
-		s.AddUser("Nekdo 2", "nekdo2", "")
-		s.AddUser("Nekdo 3", "nekdo3", "")
+		s.AddUser(ctx, "Nekdo 1", "nekdo1", "")
+		s.AddUser(ctx, "Nekdo 2", "nekdo2", "")
+		s.AddUser(ctx, "Nekdo 3", "nekdo3", "")
 
 		// make user 1 and user 2 authors
-		id, _ := s.GetUserID("nekdo1")
-		s.AddAuthor(id, "Nekdo Author 1")
+		id := getUserID(t, s, "nekdo1")
+		s.AddAuthor(ctx, id, "Nekdo Author 1")
 
-		id, _ = s.GetUserID("nekdo2")
-		s.AddAuthor(id, "Nekdo Author 2")
+		id = getUserID(t, s, "nekdo2")
+		s.AddAuthor(ctx, id, "Nekdo Author 2")
 
-		got := s.ListAuthors(0, 100)
+		got := listAuthors(t, s, 0, 100)
 		want := []users.Author{users.Author{User: users.User{ID: 0x65, DisplayName: "Nekdo 1", Login: "nekdo1", Password: ""}, AuthorID: 0x1, AuthorName: "Nekdo Author 1"}, users.Author{User: users.User{ID: 0x66, DisplayName: "Nekdo 2", Login: "nekdo2", Password: ""}, AuthorID: 0x2, AuthorName: "Nekdo Author 2"}}
 		checkGotWant("ListAuthors(0, 100)", got, want, authorsEqual)
 
-		uid, _ := s.GetUserID("nekdo1")
-		got2 := s.GetAuthor(uid)
+		uid := getUserID(t, s, "nekdo1")
+		got2 := getAuthor(t, s, uid)
 
 		if !(got2.AuthorName == "Nekdo Author 1") {
 			t.Errorf("GetAuthor(id of nekdo1); wanted author.AuthorName == `Nekdo Author 1, got author.AuthorName = %v", got2.AuthorName)
 		}
 
 		// relink author 2 to nekdo3 from nekdo2
-		uid, _ = s.GetUserID("nekdo2")
-		uid2, _ := s.GetUserID("nekdo3")
-		a := s.GetAuthor(uid)
-		s.LinkAuthor(a.AuthorID, uid2)
+		uid = getUserID(t, s, "nekdo2")
+		uid2 := getUserID(t, s, "nekdo3")
+		a := getAuthor(t, s, uid)
+		s.LinkAuthor(ctx, a.AuthorID, uid2)
 
 		// add another author
-		s.AddAuthor(uid, "nekdo 2")
+		s.AddAuthor(ctx, uid, "nekdo 2")
 
-		got = s.ListAuthors(2, 1)
+		got = listAuthors(t, s, 2, 3)
 		want = []users.Author{users.Author{User: users.User{ID: 0x67, DisplayName: "Nekdo 3", Login: "nekdo3", Password: ""}, AuthorID: 0x2, AuthorName: "Nekdo Author 2"}}
-		checkGotWant("ListAuthors(1,1)", got, want, authorsEqual)
+		checkGotWant("ListAuthors(2,3)", got, want, authorsEqual)
 
-		got = s.ListAuthors(0, 3)
+		got = listAuthors(t, s, 0, 3)
 		want = []users.Author{users.Author{User: users.User{ID: 0x65, DisplayName: "Nekdo 1", Login: "nekdo1", Password: ""}, AuthorID: 0x1, AuthorName: "Nekdo Author 1"}, users.Author{User: users.User{ID: 0x66, DisplayName: "Nekdo 2", Login: "nekdo2", Password: ""}, AuthorID: 0x3, AuthorName: "nekdo 2"}, users.Author{User: users.User{ID: 0x67, DisplayName: "Nekdo 3", Login: "nekdo3", Password: ""}, AuthorID: 0x2, AuthorName: "Nekdo Author 2"}}
 		checkGotWant("ListAuthors(0,3)", got, want, authorsEqual)
 
-		uid, _ = s.GetUserID("nekdo1")
-		aid := s.GetAuthor(uid)
-		s.RemoveAuthor(aid.AuthorID)
+		uid = getUserID(t, s, "nekdo1")
+		aid := getAuthor(t, s, uid)
+		s.RemoveAuthor(ctx, aid.AuthorID)
 
-		got = s.ListAuthors(0, 6)
+		got = listAuthors(t, s, 0, 6)
 		want = []users.Author{users.Author{User: users.User{ID: 0x66, DisplayName: "Nekdo 2", Login: "nekdo2", Password: ""}, AuthorID: 0x3, AuthorName: "nekdo 2"}, users.Author{User: users.User{ID: 0x67, DisplayName: "Nekdo 3", Login: "nekdo3", Password: ""}, AuthorID: 0x2, AuthorName: "Nekdo Author 2"}}
 		checkGotWant("ListAuthors(0,6)", got, want, authorsEqual)
 
+		// unlinking keeps the author, but without a user
+		uid = getUserID(t, s, "nekdo3")
+		a = getAuthor(t, s, uid)
+		if err := s.LinkAuthor(ctx, a.AuthorID, 0); err != nil {
+			t.Errorf("LinkAuthor() without a user returned an error: %v", err)
+		}
+		if _, err := s.GetAuthor(ctx, uid); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetAuthor() of an unlinked user returned %v, want ErrNotFound", err)
+		}
+		if unlinked, err := s.GetAuthorByID(ctx, a.AuthorID); err != nil || unlinked.AuthorName != "Nekdo Author 2" {
+			t.Errorf("GetAuthorByID() of an unlinked author = %#v, %v", unlinked, err)
+		}
+		if err := s.LinkAuthor(ctx, a.AuthorID, uid); err != nil {
+			t.Errorf("LinkAuthor() of the unlinked author returned an error: %v", err)
+		}
+	}
+}
+
+func Test_Sessions(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+
+		// sessions need a user they belong to
+		s.AddUser(ctx, "Session User", "session_user", "")
+		s.AddUser(ctx, "Session User 2", "session_user2", "")
+		uid := getUserID(t, s, "session_user")
+		uid2 := getUserID(t, s, "session_user2")
+
+		validUntil := time.Now().Add(time.Hour)
+		for _, session := range []users.Session{
+			{ID: 1001, UserID: uid, ValidUntil: validUntil},
+			{ID: 1002, UserID: uid, ValidUntil: validUntil},
+			{ID: 1003, UserID: uid2, ValidUntil: validUntil},
+			{ID: 1004, UserID: uid2, ValidUntil: time.Now().Add(-time.Hour)},
+		} {
+			if err := s.AddSession(ctx, session); err != nil {
+				t.Fatalf("AddSession(%v) returned an error: %v", session.ID, err)
+			}
+		}
+
+		// session IDs are unique
+		if err := s.AddSession(ctx, users.Session{ID: 1001, UserID: uid2, ValidUntil: validUntil}); !errors.Is(err, store.ErrConflict) {
+			t.Errorf("AddSession() with a duplicate ID returned %v, want ErrConflict", err)
+		}
+
+		// valid sessions can be resolved to their users
+		session, err := s.GetSession(ctx, 1001)
+		if err != nil || session.UserID != uid || session.ID != 1001 {
+			t.Errorf("GetSession(1001) = %#v, %v; want session of user %v", session, err, uid)
+		}
+		if diff := session.ValidUntil.Sub(validUntil); diff > time.Second || diff < -time.Second {
+			t.Errorf("GetSession(1001).ValidUntil = %v, want %v", session.ValidUntil, validUntil)
+		}
+
+		// expired and unknown sessions can't
+		if _, err = s.GetSession(ctx, 1004); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetSession(1004) of an expired session returned %v, want ErrNotFound", err)
+		}
+		if _, err = s.GetSession(ctx, 424242); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetSession(424242) of an unknown session returned %v, want ErrNotFound", err)
+		}
+
+		// extending a session makes it valid longer
+		if err = s.ExtendSession(ctx, 1001, validUntil.Add(time.Hour)); err != nil {
+			t.Errorf("ExtendSession(1001) returned an error: %v", err)
+		}
+		session, _ = s.GetSession(ctx, 1001)
+		if diff := session.ValidUntil.Sub(validUntil.Add(time.Hour)); diff > time.Second || diff < -time.Second {
+			t.Errorf("ExtendSession(1001) didn't extend the session, valid until %v", session.ValidUntil)
+		}
+		if err = s.ExtendSession(ctx, 424242, validUntil); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("ExtendSession(424242) of an unknown session returned %v, want ErrNotFound", err)
+		}
+
+		// extending an expired session makes it valid again
+		s.ExtendSession(ctx, 1004, validUntil)
+		if _, err = s.GetSession(ctx, 1004); err != nil {
+			t.Errorf("ExtendSession(1004) didn't make the session valid again")
+		}
+		s.ExtendSession(ctx, 1004, time.Now().Add(-time.Hour))
+
+		// removing a single session
+		if err = s.RemoveSession(ctx, 1002); err != nil {
+			t.Errorf("RemoveSession(1002) returned an error: %v", err)
+		}
+		if _, err = s.GetSession(ctx, 1002); err == nil {
+			t.Errorf("RemoveSession(1002) didn't remove the session")
+		}
+		if _, err = s.GetSession(ctx, 1001); err != nil {
+			t.Errorf("RemoveSession(1002) removed another session too")
+		}
+
+		// removing all sessions of a user
+		if err = s.RemoveUserSessions(ctx, uid); err != nil {
+			t.Errorf("RemoveUserSessions() returned an error: %v", err)
+		}
+		if _, err = s.GetSession(ctx, 1001); err == nil {
+			t.Errorf("RemoveUserSessions() didn't remove all sessions of the user")
+		}
+		if _, err = s.GetSession(ctx, 1003); err != nil {
+			t.Errorf("RemoveUserSessions() removed a session of another user")
+		}
+
+		// purging expired sessions mustn't touch valid ones
+		if err = s.RemoveExpiredSessions(ctx); err != nil {
+			t.Errorf("RemoveExpiredSessions() returned an error: %v", err)
+		}
+		if _, err = s.GetSession(ctx, 1003); err != nil {
+			t.Errorf("RemoveExpiredSessions() removed a valid session")
+		}
+		if err = s.ExtendSession(ctx, 1004, validUntil); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("RemoveExpiredSessions() didn't remove an expired session")
+		}
+
+		// clean up
+		s.RemoveUserSessions(ctx, uid2)
+		s.RemoveUser(ctx, uid)
+		s.RemoveUser(ctx, uid2)
+	}
+}
+
+func Test_Tokens(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+
+		s.AddUser(ctx, "Token User", "token_user", "")
+		uid := getUserID(t, s, "token_user")
+
+		created := time.Now()
+		id, err := s.AddToken(ctx, users.Token{UserID: uid, Name: "CI", Scope: users.ScopePublish,
+			Hash: "hash", Created: created})
+		if err != nil {
+			t.Fatalf("AddToken() returned an error: %v", err)
+		}
+		id2, _ := s.AddToken(ctx, users.Token{UserID: uid, Name: "Backup", Scope: users.ScopeRead,
+			Hash: "hash2", Created: created})
+
+		// tokens need an existing user
+		if _, err := s.AddToken(ctx, users.Token{UserID: 424242, Name: "Nobody", Scope: users.ScopeRead,
+			Created: created}); !errors.Is(err, store.ErrInvalidInput) {
+			t.Errorf("AddToken() of an unknown user returned %v, want ErrInvalidInput", err)
+		}
+
+		token, err := s.GetToken(ctx, id)
+		if err != nil || token.UserID != uid || token.Name != "CI" || token.Scope != users.ScopePublish ||
+			token.Hash != "hash" || !token.LastUsed.IsZero() {
+			t.Errorf("GetToken(%v) = %#v, %v", id, token, err)
+		}
+		if diff := token.Created.Sub(created); diff > time.Second || diff < -time.Second {
+			t.Errorf("GetToken(%v).Created = %v, want %v", id, token.Created, created)
+		}
+
+		tokens, err := s.ListTokens(ctx, uid)
+		if err != nil || len(tokens) != 2 || tokens[0].ID != id || tokens[1].ID != id2 {
+			t.Errorf("ListTokens() = %#v, %v", tokens, err)
+		}
+
+		// using a token is remembered
+		if err := s.TouchToken(ctx, id, created); err != nil {
+			t.Errorf("TouchToken(%v) returned an error: %v", id, err)
+		}
+		token, _ = s.GetToken(ctx, id)
+		if diff := token.LastUsed.Sub(created); diff > time.Second || diff < -time.Second {
+			t.Errorf("TouchToken(%v) didn't change the time, it's %v", id, token.LastUsed)
+		}
+
+		if err := s.RemoveToken(ctx, id); err != nil {
+			t.Errorf("RemoveToken(%v) returned an error: %v", id, err)
+		}
+		if _, err := s.GetToken(ctx, id); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetToken() of a removed token returned %v, want ErrNotFound", err)
+		}
+		if err := s.RemoveToken(ctx, id); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("RemoveToken() of a removed token returned %v, want ErrNotFound", err)
+		}
+
+		// clean up
+		s.RemoveToken(ctx, id2)
+		s.RemoveUser(ctx, uid)
+	}
+}
+
+func Test_Roles(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+
+		s.AddUser(ctx, "Role User", "role_user", "password")
+		uid := getUserID(t, s, "role_user")
+
+		// new users are readers
+		if u, err := s.GetUser(ctx, uid); err != nil || u.Role != users.RoleReader {
+			t.Errorf("GetUser() of a new user = %#v, %v; want a reader", u, err)
+		}
+
+		if err := s.SetRole(ctx, uid, users.RoleEditor); err != nil {
+			t.Errorf("SetRole() returned an error: %v", err)
+		}
+		// editing a user doesn't change the role
+		if err := s.EditUser(ctx, users.User{ID: uid, DisplayName: "Role User", Login: "role_user",
+			Password: "password"}); err != nil {
+			t.Errorf("EditUser() returned an error: %v", err)
+		}
+		if u, _ := s.GetUser(ctx, uid); u.Role != users.RoleEditor {
+			t.Errorf("GetUser().Role = %#v, want %#v", u.Role, users.RoleEditor)
+		}
+		if isAdmin, _ := s.IsAdmin(ctx, uid); isAdmin {
+			t.Errorf("IsAdmin() of an editor = true")
+		}
+
+		// admins are just users with the admin role
+		if err := s.PromoteToAdmin(ctx, uid); err != nil {
+			t.Errorf("PromoteToAdmin() returned an error: %v", err)
+		}
+		if u, _ := s.GetUser(ctx, uid); u.Role != users.RoleAdmin {
+			t.Errorf("GetUser().Role of an admin = %#v", u.Role)
+		}
+		// the hashes of passwords aren't listed
+		admins, err := s.ListAdmins(ctx, 0, 1000)
+		if err != nil {
+			t.Errorf("ListAdmins() returned an error: %v", err)
+		}
+		listed := false
+		for _, a := range admins {
+			listed = listed || a.ID == uid
+			if a.Password != "" {
+				t.Errorf("ListAdmins() returned the password of %v", a.Login)
+			}
+		}
+		if !listed {
+			t.Errorf("ListAdmins() = %#v, want the admin %v", admins, uid)
+		}
+		if err := s.DemoteFromAdmin(ctx, uid); err != nil {
+			t.Errorf("DemoteFromAdmin() returned an error: %v", err)
+		}
+		if err := s.DemoteFromAdmin(ctx, uid); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("DemoteFromAdmin() of a reader returned %v, want ErrNotFound", err)
+		}
+
+		if err := s.SetRole(ctx, uid, "owner"); !errors.Is(err, store.ErrInvalidInput) {
+			t.Errorf("SetRole() with an invalid role returned %v, want ErrInvalidInput", err)
+		}
+		if err := s.SetRole(ctx, 424242, users.RoleAuthor); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("SetRole() of a missing user returned %v, want ErrNotFound", err)
+		}
+
+		s.RemoveUser(ctx, uid)
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+
+		// things which don't exist
+		if _, err := s.GetUserID(ctx, "nobody"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetUserID() of a missing user returned %v, want ErrNotFound", err)
+		}
+		if _, err := s.GetUser(ctx, 424242); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetUser() of a missing user returned %v, want ErrNotFound", err)
+		}
+		if _, err := s.GetArticleByID(ctx, 424242); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetArticleByID() of a missing article returned %v, want ErrNotFound", err)
+		}
+		if err := s.RemoveUser(ctx, 424242); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("RemoveUser() of a missing user returned %v, want ErrNotFound", err)
+		}
+		if err := s.EditUser(ctx, users.User{ID: 424242, Login: "nobody"}); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("EditUser() of a missing user returned %v, want ErrNotFound", err)
+		}
+
+		// logins are unique
+		if err := s.AddUser(ctx, "Error User", "error_user", ""); err != nil {
+			t.Fatalf("AddUser() returned an error: %v", err)
+		}
+		if err := s.AddUser(ctx, "Error User", "error_user", ""); !errors.Is(err, store.ErrConflict) {
+			t.Errorf("AddUser() with a duplicate login returned %v, want ErrConflict", err)
+		}
+
+		// admins can't be promoted twice
+		uid := getUserID(t, s, "error_user")
+		if err := s.PromoteToAdmin(ctx, uid); err != nil {
+			t.Errorf("PromoteToAdmin() returned an error: %v", err)
+		}
+		if isAdmin, err := s.IsAdmin(ctx, uid); !isAdmin || err != nil {
+			t.Errorf("IsAdmin() = %v, %v; want true, nil", isAdmin, err)
+		}
+		if err := s.PromoteToAdmin(ctx, uid); !errors.Is(err, store.ErrConflict) {
+			t.Errorf("PromoteToAdmin() of an admin returned %v, want ErrConflict", err)
+		}
+		if err := s.DemoteFromAdmin(ctx, uid); err != nil {
+			t.Errorf("DemoteFromAdmin() returned an error: %v", err)
+		}
+
+		s.RemoveUser(ctx, uid)
+	}
+}
+
+func Test_Slugs(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+		uid, aid := addTestAuthor(t, s, "slug_user")
+
+		first := addTestArticle(t, s, article.Article{Title: "Hello, World!", AuthorID: aid}, uid)
+		second := addTestArticle(t, s, article.Article{Title: "Hello, World!", AuthorID: aid}, uid)
+		custom := addTestArticle(t, s, article.Article{Title: "Custom", Slug: "My Own Slug", AuthorID: aid}, uid)
+		numeric := addTestArticle(t, s, article.Article{Title: "2020", AuthorID: aid}, uid)
+		for id, want := range map[uint64]string{first: "hello-world", second: "hello-world-2",
+			custom: "my-own-slug", numeric: "article-2020"} {
+			if a, err := s.GetArticleByID(ctx, id); err != nil || a.Slug != want {
+				t.Errorf("GetArticleByID(%v).Slug = %#v, %v; want %#v", id, a.Slug, err, want)
+			}
+		}
+
+		// changing the title changes the slug, the old one still leads to the
+		// article and isn't given to other articles
+		a, _ := s.GetArticleByID(ctx, first)
+		a.ID, a.Title, a.Slug = first, "Goodbye", ""
+		if err := s.EditArticle(ctx, a, uid); err != nil {
+			t.Fatalf("EditArticle() returned an error: %v", err)
+		}
+		for _, slug := range []string{"goodbye", "hello-world"} {
+			if a, err := s.GetArticleBySlug(ctx, slug); err != nil || a.ID != first || a.Slug != "goodbye" {
+				t.Errorf("GetArticleBySlug(%#v) = %v, %v; want article %v", slug, a.ID, err, first)
+			}
+		}
+		third := addTestArticle(t, s, article.Article{Title: "Hello, World!", AuthorID: aid}, uid)
+		if a, _ := s.GetArticleByID(ctx, third); a.Slug != "hello-world-3" {
+			t.Errorf("GetArticleByID(%v).Slug = %#v, want \"hello-world-3\"", third, a.Slug)
+		}
+
+		// an article can get its old slug back
+		a, _ = s.GetArticleByID(ctx, first)
+		a.ID, a.Slug = first, "hello-world"
+		if err := s.EditArticle(ctx, a, uid); err != nil {
+			t.Fatalf("EditArticle() returned an error: %v", err)
+		}
+		if a, err := s.GetArticleBySlug(ctx, "hello-world"); err != nil || a.ID != first || a.Slug != "hello-world" {
+			t.Errorf("GetArticleBySlug(\"hello-world\") = %#v, %v; want the current slug of article %v", a, err, first)
+		}
+
+		if _, err := s.GetArticleBySlug(ctx, "no-such-slug"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetArticleBySlug() of a missing slug returned %v, want ErrNotFound", err)
+		}
+
+		removeTestAuthor(t, s, uid, aid, first, second, custom, numeric, third)
+	}
+}
+
+func Test_Statuses(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+		uid, aid := addTestAuthor(t, s, "status_user")
+		now := time.Now()
+		before, err := s.GetArticleNumber(ctx)
+		if err != nil {
+			t.Fatalf("GetArticleNumber() returned an error: %v", err)
+		}
+
+		// sorted from latest
+		ids := []uint64{
+			addTestArticle(t, s, article.Article{Title: "Future", AuthorID: aid, Status: article.Scheduled,
+				Timestamp: uint64(now.Add(time.Hour).Unix())}, uid),
+			addTestArticle(t, s, article.Article{Title: "Draft", AuthorID: aid, Status: article.Draft,
+				Timestamp: uint64(now.Unix())}, uid),
+			addTestArticle(t, s, article.Article{Title: "Due", AuthorID: aid, Status: article.Scheduled,
+				Timestamp: uint64(now.Add(-time.Minute).Unix())}, uid),
+			addTestArticle(t, s, article.Article{Title: "Published", AuthorID: aid, Status: article.Published,
+				Timestamp: uint64(now.Add(-time.Hour).Unix())}, uid),
+			addTestArticle(t, s, article.Article{Title: "Archived", AuthorID: aid, Status: article.Archived,
+				Timestamp: uint64(now.Add(-2 * time.Hour).Unix())}, uid),
+		}
+		future, draft, due, published := ids[0], ids[1], ids[2], ids[3]
+
+		if _, err := s.AddArticle(ctx, article.Article{Title: "Secret", AuthorID: aid, Status: "secret"},
+			uid); !errors.Is(err, store.ErrInvalidInput) {
+			t.Errorf("AddArticle() with an invalid status returned %v, want ErrInvalidInput", err)
+		}
+
+		// readers only get published articles, the admin panel gets all of them
+		if num, _ := s.GetArticleNumber(ctx); num != before+1 {
+			t.Errorf("GetArticleNumber() = %v, want %v", num, before+1)
+		}
+		if got := articleIDs(s.LoadArticlesSortedByLatest(ctx, 0, before+10)); !containsOnly(got, ids, published) {
+			t.Errorf("LoadArticlesSortedByLatest() = %v, want only article %v of %v", got, published, ids)
+		}
+		if got := articleIDs(s.LoadAllArticlesSortedByLatest(ctx, 0, before+10)); !containsOnly(got, ids, ids...) {
+			t.Errorf("LoadAllArticlesSortedByLatest() = %v, want all of %v", got, ids)
+		}
+		if a, err := s.GetArticleByID(ctx, draft); err != nil || a.Status != article.Draft {
+			t.Errorf("GetArticleByID() of a draft = %v, %v", a.Status, err)
+		}
+
+		// articles of an author are sorted from latest, a page further in has
+		// as many articles as asked for
+		if got := articleIDs(s.LoadArticlesByAuthor(ctx, aid, 0, 10)); fmt.Sprint(got) != fmt.Sprint(ids) {
+			t.Errorf("LoadArticlesByAuthor(0,10) = %v, want %v", got, ids)
+		}
+		if got := articleIDs(s.LoadArticlesByAuthor(ctx, aid, 1, 3)); fmt.Sprint(got) != fmt.Sprint(ids[1:3]) {
+			t.Errorf("LoadArticlesByAuthor(1,3) = %v, want %v", got, ids[1:3])
+		}
+
+		// only scheduled articles which are due are published
+		if num, err := s.PublishScheduledArticles(ctx, now); err != nil || num != 1 {
+			t.Errorf("PublishScheduledArticles() = %v, %v; want 1", num, err)
+		}
+		if a, _ := s.GetArticleByID(ctx, due); a.Status != article.Published {
+			t.Errorf("GetArticleByID() of a due article = %v, want published", a.Status)
+		}
+		if a, _ := s.GetArticleByID(ctx, future); a.Status != article.Scheduled {
+			t.Errorf("GetArticleByID() of a future article = %v, want scheduled", a.Status)
+		}
+		if num, _ := s.GetArticleNumber(ctx); num != before+2 {
+			t.Errorf("GetArticleNumber() after publishing = %v, want %v", num, before+2)
+		}
+
+		removeTestAuthor(t, s, uid, aid, ids...)
+	}
+}
+
+func Test_Revisions(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+		uid, aid := addTestAuthor(t, s, "revision_user")
+		s.AddUser(ctx, "Revision Editor", "revision_editor", "")
+		editor := getUserID(t, s, "revision_editor")
+
+		id := addTestArticle(t, s, article.Article{Title: "Revised", Source: "first", Summary: "one",
+			AuthorID: aid}, uid)
+		a, err := s.GetArticleByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetArticleByID() returned an error: %v", err)
+		}
+		a.ID, a.Source, a.Summary = id, "second", "two"
+		if err := s.EditArticle(ctx, a, editor); err != nil {
+			t.Fatalf("EditArticle() returned an error: %v", err)
+		}
+
+		// every version is a revision, the latest comes first
+		revisions, err := s.ListRevisions(ctx, id, 0, 10)
+		if err != nil || len(revisions) != 2 {
+			t.Fatalf("ListRevisions() = %#v, %v; want 2 revisions", revisions, err)
+		}
+		if r := revisions[0]; r.ArticleID != id || r.Source != "second" || r.Summary != "two" ||
+			r.UserID != editor || r.UserName != "Revision Editor" {
+			t.Errorf("ListRevisions()[0] = %#v, want the edit", r)
+		}
+		if r := revisions[1]; r.Source != "first" || r.UserID != uid || r.Title != "Revised" {
+			t.Errorf("ListRevisions()[1] = %#v, want the first version", r)
+		}
+		if r, err := s.GetRevision(ctx, revisions[1].ID); err != nil || r.Source != "first" || r.ArticleID != id {
+			t.Errorf("GetRevision(%v) = %#v, %v", revisions[1].ID, r, err)
+		}
+
+		// restoring a revision changes the article back and is a revision too
+		if err := s.RestoreRevision(ctx, revisions[1].ID, uid); err != nil {
+			t.Fatalf("RestoreRevision() returned an error: %v", err)
+		}
+		if a, _ := s.GetArticleByID(ctx, id); a.Source != "first" || a.Summary != "one" {
+			t.Errorf("RestoreRevision() didn't restore the article, it has %#v", a.Source)
+		}
+		revisions, _ = s.ListRevisions(ctx, id, 0, 10)
+		if len(revisions) != 3 || revisions[0].Source != "first" || revisions[0].UserID != uid {
+			t.Errorf("ListRevisions() after restoring = %#v, want 3 revisions", revisions)
+		}
+		if page, _ := s.ListRevisions(ctx, id, 1, 2); len(page) != 1 || page[0].ID != revisions[1].ID {
+			t.Errorf("ListRevisions(1,2) = %#v, want revision %v", page, revisions[1].ID)
+		}
+
+		if _, err := s.GetRevision(ctx, 424242); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetRevision() of a missing revision returned %v, want ErrNotFound", err)
+		}
+		if err := s.RestoreRevision(ctx, 424242, uid); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("RestoreRevision() of a missing revision returned %v, want ErrNotFound", err)
+		}
+
+		// revisions stay when their user is removed
+		if err := s.RemoveUser(ctx, editor); err != nil {
+			t.Errorf("RemoveUser() of an editor returned an error: %v", err)
+		}
+		revisions, _ = s.ListRevisions(ctx, id, 0, 10)
+		if len(revisions) != 3 || revisions[1].UserID != 0 {
+			t.Errorf("ListRevisions() after removing the editor = %#v", revisions)
+		}
+
+		removeTestAuthor(t, s, uid, aid, id)
+	}
+}
+
+func Test_LegacyRevisions(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+		uid, aid := addTestAuthor(t, s, "legacy_user")
+		id := addTestArticle(t, s, article.Article{Title: "Legacy", Source: "**Old** text", AuthorID: aid}, uid)
+		if !makeLegacyArticle(t, s, id) {
+			t.Logf("%T can't have articles without a source, skipping", s)
+			removeTestAuthor(t, s, uid, aid, id)
+			continue
+		}
+
+		// the first revision is made from the HTML, since there's no source
+		a, _ := s.GetArticleByID(ctx, id)
+		a.ID, a.Source = id, "New text"
+		if err := s.EditArticle(ctx, a, uid); err != nil {
+			t.Fatalf("EditArticle() returned an error: %v", err)
+		}
+		revisions, err := s.ListRevisions(ctx, id, 0, 10)
+		if err != nil || len(revisions) != 2 || !strings.Contains(revisions[1].Source, "<strong>Old</strong>") {
+			t.Fatalf("ListRevisions() = %#v, %v; want the HTML of the legacy article first", revisions, err)
+		}
+
+		if err := s.RestoreRevision(ctx, revisions[1].ID, uid); err != nil {
+			t.Fatalf("RestoreRevision() returned an error: %v", err)
+		}
+		if a, _ := s.GetArticleByID(ctx, id); !strings.Contains(string(a.Content), "<strong>Old</strong> text") {
+			t.Errorf("RestoreRevision() of a legacy article left %#v, want the old content", a.Content)
+		}
+
+		removeTestAuthor(t, s, uid, aid, id)
+	}
+}
+
+func Test_Tags(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+		uid, aid := addTestAuthor(t, s, "tag_user")
+		now := time.Now()
+
+		// sorted from latest
+		ids := make([]uint64, 0, 5)
+		for i := 0; i < 5; i++ {
+			ids = append(ids, addTestArticle(t, s, article.Article{Title: "Tagged " + strconv.Itoa(i),
+				AuthorID: aid, Tags: []string{"paging", "tag-" + strconv.Itoa(i)},
+				Timestamp: uint64(now.Add(-time.Duration(i) * time.Hour).Unix())}, uid))
+		}
+		draft := addTestArticle(t, s, article.Article{Title: "Tagged draft", AuthorID: aid,
+			Status: article.Draft, Tags: []string{"paging", "unpublished"}}, uid)
+
+		// tags are sorted by name
+		if a, err := s.GetArticleByID(ctx, ids[1]); err != nil || fmt.Sprint(a.Tags) != "[paging tag-1]" {
+			t.Errorf("GetArticleByID().Tags = %#v, %v; want paging and tag-1", a.Tags, err)
+		}
+		if num, err := s.GetArticleNumberByTag(ctx, "paging"); err != nil || num != 5 {
+			t.Errorf("GetArticleNumberByTag() = %v, %v; want 5", num, err)
+		}
+		if got := articleIDs(s.LoadArticlesByTag(ctx, "paging", 0, 10)); fmt.Sprint(got) != fmt.Sprint(ids) {
+			t.Errorf("LoadArticlesByTag(0,10) = %v, want %v", got, ids)
+		}
+
+		// pages of a tag with 2 articles per page, the way handlers ask for them
+		if got := articleIDs(s.LoadArticlesByTag(ctx, "paging", 2, 4)); fmt.Sprint(got) != fmt.Sprint(ids[2:4]) {
+			t.Errorf("LoadArticlesByTag(2,4) = %v, want %v", got, ids[2:4])
+		}
+		if got := articleIDs(s.LoadArticlesByTag(ctx, "paging", 4, 5)); fmt.Sprint(got) != fmt.Sprint(ids[4:]) {
+			t.Errorf("LoadArticlesByTag(4,5) = %v, want %v", got, ids[4:])
+		}
+
+		// tags only exist as long as a published article has them
+		tags, err := s.ListTags(ctx)
+		if err != nil {
+			t.Fatalf("ListTags() returned an error: %v", err)
+		}
+		counts := make(map[string]uint64)
+		for k, tag := range tags {
+			counts[tag.Name] = tag.Count
+			if k > 0 && tags[k-1].Name >= tag.Name {
+				t.Errorf("ListTags() isn't sorted by name: %v", tags)
+			}
+		}
+		if counts["paging"] != 5 || counts["tag-0"] != 1 || counts["unpublished"] != 0 {
+			t.Errorf("ListTags() = %v", tags)
+		}
+
+		// editing an article replaces its tags
+		a, _ := s.GetArticleByID(ctx, ids[0])
+		a.ID, a.Tags = ids[0], []string{"tag-1"}
+		if err := s.EditArticle(ctx, a, uid); err != nil {
+			t.Fatalf("EditArticle() returned an error: %v", err)
+		}
+		if num, _ := s.GetArticleNumberByTag(ctx, "paging"); num != 4 {
+			t.Errorf("GetArticleNumberByTag() after removing a tag = %v, want 4", num)
+		}
+		if got := articleIDs(s.LoadArticlesByTag(ctx, "tag-1", 0, 10)); fmt.Sprint(got) != fmt.Sprint(ids[:2]) {
+			t.Errorf("LoadArticlesByTag() of a new tag = %v, want %v", got, ids[:2])
+		}
+
+		removeTestAuthor(t, s, uid, aid, append(ids, draft)...)
+	}
+}
+
+func Test_Search(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+		uid, aid := addTestAuthor(t, s, "search_user")
+		now := time.Now()
+
+		// words in the title weigh more than the ones in the rest of the article
+		inTitle := addTestArticle(t, s, article.Article{Title: "Quokka island", AuthorID: aid,
+			Source: "Rottnest is a small island.", Timestamp: uint64(now.Add(-time.Hour).Unix())}, uid)
+		inText := addTestArticle(t, s, article.Article{Title: "Holiday trip", AuthorID: aid,
+			Source: "On the island we met a friendly **quokka** near the beach.", Timestamp: uint64(now.Unix())}, uid)
+		draft := addTestArticle(t, s, article.Article{Title: "Quokka draft", AuthorID: aid,
+			Status: article.Draft}, uid)
+		other := addTestArticle(t, s, article.Article{Title: "Unrelated", AuthorID: aid}, uid)
+
+		if num, err := s.GetSearchResultNumber(ctx, "quokka"); err != nil || num != 2 {
+			t.Errorf("GetSearchResultNumber() = %v, %v; want 2", num, err)
+		}
+		results, err := s.SearchArticles(ctx, "quokka", 0, 10)
+		if err != nil || len(results) != 2 || results[0].ID != inTitle || results[1].ID != inText {
+			t.Fatalf("SearchArticles() = %#v, %v; want articles %v and %v", results, err, inTitle, inText)
+		}
+		if snippet := string(results[1].Snippet); !strings.Contains(strings.ToLower(snippet), "<mark>quokka</mark>") ||
+			strings.Contains(snippet, "**") {
+			t.Errorf("SearchArticles() snippet = %#v, want the highlighted word without Markdown", snippet)
+		}
+
+		// the second page with a single result per page
+		if results, _ := s.SearchArticles(ctx, "quokka", 1, 2); len(results) != 1 || results[0].ID != inText {
+			t.Errorf("SearchArticles(1,2) = %#v, want article %v", results, inText)
+		}
+
+		// all words have to be found
+		if num, _ := s.GetSearchResultNumber(ctx, "quokka beach"); num != 1 {
+			t.Errorf("GetSearchResultNumber() of two words = %v, want 1", num)
+		}
+		if results, err := s.SearchArticles(ctx, "nothing-like-this", 0, 10); err != nil || len(results) != 0 {
+			t.Errorf("SearchArticles() without matches = %#v, %v", results, err)
+		}
+
+		removeTestAuthor(t, s, uid, aid, inTitle, inText, draft, other)
+	}
+}
+
+func Test_Comments(t *testing.T) {
+	strs := stores{}
+	for strs.Next() {
+		s := strs.Current()
+		uid, aid := addTestAuthor(t, s, "comment_user")
+		s.AddUser(ctx, "Comment Reader", "comment_reader", "")
+		reader := getUserID(t, s, "comment_reader")
+		id := addTestArticle(t, s, article.Article{Title: "Commented", AuthorID: aid}, uid)
+		other := addTestArticle(t, s, article.Article{Title: "Not commented", AuthorID: aid}, uid)
+
+		// comments of users get the name of the user, not the one sent
+		add := func(c comments.Comment) error {
+			c.ArticleID, c.UnsafeContent = id, "<b>Comment</b>"
+			return s.AddComment(ctx, c)
+		}
+		if err := add(comments.Comment{UserID: reader, Name: "Someone else", Status: comments.Visible}); err != nil {
+			t.Fatalf("AddComment() returned an error: %v", err)
+		}
+		cs, err := s.ListComments(ctx, id, 0, 10)
+		if err != nil || len(cs) != 1 {
+			t.Fatalf("ListComments() = %#v, %v; want 1 comment", cs, err)
+		}
+		first := cs[0]
+		if first.UserID != reader || first.Name != "Comment Reader" || first.UnsafeContent != "<b>Comment</b>" {
+			t.Errorf("ListComments()[0] = %#v, want the comment of the reader", first)
+		}
+
+		add(comments.Comment{ParentID: first.ID, Name: "Anonymous", Status: comments.Pending})
+		add(comments.Comment{ParentID: first.ID, Name: "Visitor", Status: comments.Visible})
+		add(comments.Comment{Name: "Latest", Status: comments.Visible})
+
+		invalid := []comments.Comment{
+			{ArticleID: 424242, Status: comments.Visible},
+			{ArticleID: id, UserID: 424242, Status: comments.Visible},
+			{ArticleID: other, ParentID: first.ID, Status: comments.Visible},
+		}
+		for _, c := range invalid {
+			if err := s.AddComment(ctx, c); !errors.Is(err, store.ErrInvalidInput) {
+				t.Errorf("AddComment(%#v) returned %v, want ErrInvalidInput", c, err)
+			}
+		}
+
+		// pending comments are only listed for moderation
+		if num, err := s.GetCommentNumber(ctx, id); err != nil || num != 3 {
+			t.Errorf("GetCommentNumber() = %v, %v; want 3", num, err)
+		}
+		cs, _ = s.ListComments(ctx, id, 0, 10)
+		if len(cs) != 3 || cs[0].ID != first.ID || cs[1].Name != "Visitor" || cs[1].ParentID != first.ID ||
+			cs[2].Name != "Latest" {
+			t.Fatalf("ListComments() = %#v, want the visible comments from oldest", cs)
+		}
+		if page, _ := s.ListComments(ctx, id, 1, 2); len(page) != 1 || page[0].ID != cs[1].ID {
+			t.Errorf("ListComments(1,2) = %#v, want comment %v", page, cs[1].ID)
+		}
+		pending, err := s.ListCommentsByStatus(ctx, comments.Pending, 0, 100)
+		if err != nil || len(pending) == 0 || pending[0].Name != "Anonymous" || pending[0].ArticleID != id {
+			t.Fatalf("ListCommentsByStatus() = %#v, %v; want the anonymous comment first", pending, err)
+		}
+		anonymous := pending[0]
+
+		// approving and hiding comments
+		if err := s.SetCommentStatus(ctx, anonymous.ID, comments.Visible); err != nil {
+			t.Errorf("SetCommentStatus() returned an error: %v", err)
+		}
+		if err := s.SetCommentStatus(ctx, cs[2].ID, comments.Hidden); err != nil {
+			t.Errorf("SetCommentStatus() returned an error: %v", err)
+		}
+		if c, err := s.GetComment(ctx, anonymous.ID); err != nil || c.Status != comments.Visible {
+			t.Errorf("GetComment(%v) = %#v, %v; want a visible comment", anonymous.ID, c, err)
+		}
+		hidden, _ := s.ListCommentsByStatus(ctx, comments.Hidden, 0, 100)
+		if len(hidden) == 0 || hidden[0].ID != cs[2].ID {
+			t.Errorf("ListCommentsByStatus() = %#v, want comment %v first", hidden, cs[2].ID)
+		}
+		if err := s.SetCommentStatus(ctx, 424242, comments.Hidden); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("SetCommentStatus() of a missing comment returned %v, want ErrNotFound", err)
+		}
+
+		// comments of removed users are kept without their user
+		if err := s.RemoveUser(ctx, reader); err != nil {
+			t.Fatalf("RemoveUser() of a commenter returned an error: %v", err)
+		}
+		if c, err := s.GetComment(ctx, first.ID); err != nil || !c.UserRemoved() || c.Author() != comments.RemovedUserName {
+			t.Errorf("GetComment(%v) = %#v, %v; want a comment of a removed user", first.ID, c, err)
+		}
+
+		// replies stay when their parent is removed
+		if err := s.RemoveComment(ctx, first.ID); err != nil {
+			t.Fatalf("RemoveComment() returned an error: %v", err)
+		}
+		if c, err := s.GetComment(ctx, anonymous.ID); err != nil || c.ParentID != 0 {
+			t.Errorf("GetComment(%v) = %#v, %v; want a comment which isn't a reply", anonymous.ID, c, err)
+		}
+		if err := s.RemoveComment(ctx, first.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("RemoveComment() of a removed comment returned %v, want ErrNotFound", err)
+		}
+
+		// comments are removed together with their article
+		removeTestAuthor(t, s, uid, aid, id, other)
+		if _, err := s.GetComment(ctx, anonymous.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetComment() of a removed article returned %v, want ErrNotFound", err)
+		}
+	}
+}
+
+// getUserID returns the ID of the user, the test fails if it can't be found
+func getUserID(t *testing.T, s store.Store, login string) uint64 {
+	id, err := s.GetUserID(ctx, login)
+	if err != nil {
+		t.Fatalf("User could not be found using GetUserID: %v", err)
+	}
+	return id
+}
+
+// getAuthor returns the author linked to the user, the test fails if it can't
+// be found
+func getAuthor(t *testing.T, s store.Store, userId uint64) users.Author {
+	a, err := s.GetAuthor(ctx, userId)
+	if err != nil {
+		t.Fatalf("Author could not be found using GetAuthor: %v", err)
+	}
+	return a
+}
+
+// listUsers lists the users, the test fails if an error is returned
+func listUsers(t *testing.T, s store.Store, from uint64, to uint64) []users.User {
+	us, err := s.ListUsers(ctx, from, to)
+	if err != nil {
+		t.Errorf("ListUsers(%v, %v) returned an error: %v", from, to, err)
+	}
+	return us
+}
+
+// listAuthors lists the authors, the test fails if an error is returned
+func listAuthors(t *testing.T, s store.Store, from uint64, to uint64) []users.Author {
+	authors, err := s.ListAuthors(ctx, from, to)
+	if err != nil {
+		t.Errorf("ListAuthors(%v, %v) returned an error: %v", from, to, err)
+	}
+	return authors
+}
+
+// addTestAuthor adds a user with the login together with an author, returns
+// their IDs
+func addTestAuthor(t *testing.T, s store.Store, login string) (uint64, uint64) {
+	if err := s.AddUser(ctx, login, login, ""); err != nil {
+		t.Fatalf("AddUser() returned an error: %v", err)
+	}
+	uid := getUserID(t, s, login)
+	if err := s.AddAuthor(ctx, uid, login); err != nil {
+		t.Fatalf("AddAuthor() returned an error: %v", err)
+	}
+	return uid, getAuthor(t, s, uid).AuthorID
+}
+
+// addTestArticle adds the article and returns its ID, articles without a
+// status are published and ones without a time are from now
+func addTestArticle(t *testing.T, s store.Store, a article.Article, userId uint64) uint64 {
+	if a.Status == "" {
+		a.Status = article.Published
+	}
+	if a.Timestamp == 0 {
+		a.Timestamp = uint64(time.Now().Unix())
+	}
+	if a.Source == "" {
+		a.Source = "Content of " + a.Title
+	}
+	id, err := s.AddArticle(ctx, a, userId)
+	if err != nil {
+		t.Fatalf("AddArticle(%#v) returned an error: %v", a.Title, err)
+	}
+	return id
+}
+
+// removeTestAuthor removes the articles, the author and the user
+func removeTestAuthor(t *testing.T, s store.Store, userId uint64, authorId uint64, articleIds ...uint64) {
+	for _, id := range articleIds {
+		if err := s.RemoveArticle(ctx, id); err != nil {
+			t.Errorf("RemoveArticle(%v) returned an error: %v", id, err)
+		}
+	}
+	if err := s.RemoveAuthor(ctx, authorId); err != nil {
+		t.Errorf("RemoveAuthor() returned an error: %v", err)
+	}
+	if err := s.RemoveUser(ctx, userId); err != nil {
+		t.Errorf("RemoveUser() returned an error: %v", err)
+	}
+}
+
+// articleIDs returns the IDs of the loaded articles, nil if there's an error
+func articleIDs(articles []article.Article, err error) []uint64 {
+	if err != nil {
+		return nil
+	}
+	ids := make([]uint64, 0, len(articles))
+	for _, a := range articles {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+// containsOnly returns true if got contains exactly the wanted IDs out of the
+// IDs in the set, IDs outside of the set don't matter
+func containsOnly(got []uint64, set []uint64, want ...uint64) bool {
+	found := make(map[uint64]bool)
+	for _, id := range got {
+		found[id] = true
+	}
+	for _, id := range set {
+		wanted := false
+		for _, w := range want {
+			wanted = wanted || w == id
+		}
+		if found[id] != wanted {
+			return false
+		}
+	}
+	return true
+}
+
+// makeLegacyArticle removes the source and the revisions of the article behind
+// the Store's back, the way articles written before either of them were kept
+// look, returns false if that can't be done with the Store
+func makeLegacyArticle(t *testing.T, s store.Store, id uint64) bool {
+	var err error
+	switch s.(type) {
+	case *postgres.Store:
+		var conn *pgx.Conn
+		if conn, err = pgx.Connect(ctx, pgConnString); err == nil {
+			defer conn.Close(ctx)
+			_, err = conn.Exec(ctx, "update montesquieu.articles set source = '' where article_id = $1;", id)
+		}
+		if err == nil {
+			_, err = conn.Exec(ctx, "delete from montesquieu.revisions where article_id = $1;", id)
+		}
+	case *sqlite.Store:
+		var db *sql.DB
+		if db, err = sql.Open("sqlite3", storeConfig.Path); err == nil {
+			defer db.Close()
+			_, err = db.ExecContext(ctx, "update articles set source = '' where article_id = ?1;", id)
+		}
+		if err == nil {
+			_, err = db.ExecContext(ctx, "delete from revisions where article_id = ?1;", id)
+		}
+	default:
+		return false
+	}
+	if err != nil {
+		t.Fatalf("Couldn't remove the source and revisions of article %v: %v", id, err)
 	}
+	return true
 }
@@ -1,18 +1,29 @@
 package mock
 
 import (
+	"context"
 	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/article/render"
+	"github.com/david-sorm/montesquieu/comments"
 	"github.com/david-sorm/montesquieu/store"
 	"github.com/david-sorm/montesquieu/users"
+	"html"
 	"html/template"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
 )
 
-// Store is a mock implementation of the Store interface
+// Store is an in-memory implementation of the Store interface, it behaves the
+// same way as the postgres Store, so it can be used in tests and for trying
+// Montesquieu out without a database
+// Nothing is saved, everything is lost once the program exits
 type Store struct {
 	cfg store.StoreConfig
 
+	// guards everything below, helpers with lower-case names expect it to be
+	// locked already
 	m sync.Mutex
 
 	// stores articles sorted from most recent[0] to oldest[...]
@@ -21,63 +32,168 @@ type Store struct {
 	// stores articles indexed by their IDs
 	articlesByID map[string]article.Article
 
-	users  []users.User
-	admins []users.User
+	// stores previews of articles indexed by their IDs, articles without one
+	// are listed with their whole content
+	previews map[uint64]template.HTML
+
+	// stores the IDs of articles indexed by the slugs they had before
+	oldSlugs map[string]uint64
+
+	// the ID of the last added article
+	lastArticleID uint64
+
+	// stores users sorted by their IDs
+	users []users.User
+
+	// the ID of the last added user
+	lastUserID uint64
+
+	// stores authors sorted by their IDs
+	authors []author
+
+	// the ID of the last added author
+	lastAuthorID uint64
+
+	// stores sessions indexed by their IDs
+	sessions map[uint64]users.Session
+
+	// stores revisions of all articles sorted from oldest to most recent
+	revisions []article.Revision
+
+	// the ID of the last added revision
+	lastRevisionID uint64
+
+	// stores comments sorted from oldest to most recent
+	comments []comments.Comment
+
+	// the ID of the last added comment
+	lastCommentID uint64
+
+	// stores API tokens sorted by their IDs
+	tokens []users.Token
+
+	// the ID of the last added token
+	lastTokenID uint64
+}
+
+// author is an Author as it's stored, userID is 0 if it isn't linked to a user
+type author struct {
+	id     uint64
+	userID uint64
+	name   string
+}
+
+// bounds cuts 'from' and 'to' to fit a slice with the length
+func bounds(length int, from uint64, to uint64) (uint64, uint64) {
+	if to > uint64(length) {
+		to = uint64(length)
+	}
+	if from > to {
+		from = to
+	}
+	return from, to
+}
+
+// published returns true if readers can see the article, articles saved
+// without a status are published, just like in postgres
+func published(a article.Article) bool {
+	return a.Status == article.Published || a.Status == ""
 }
 
-func (ms *Store) IsAdmin(id uint64) bool {
-	panic("implement me")
+func (ms *Store) IsAdmin(ctx context.Context, id uint64) (bool, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	u, err := ms.findUser(id)
+	if err != nil {
+		// users which don't exist aren't admins
+		return false, nil
+	}
+	return u.Role == users.RoleAdmin, nil
 }
 
-func (ms *Store) ListUsers(from uint64, to uint64) []users.User {
+func (ms *Store) ListUsers(ctx context.Context, from uint64, to uint64) ([]users.User, error) {
 	ms.m.Lock()
 	defer ms.m.Unlock()
-	return ms.users[from:to]
+
+	from, to = bounds(len(ms.users), from, to)
+	us := make([]users.User, 0, to-from)
+	for _, u := range ms.users[from:to] {
+		u.Password = ""
+		us = append(us, u)
+	}
+	return us, nil
 }
 
-func (ms *Store) GetUserID(login string) (uint64, bool) {
+func (ms *Store) GetUserID(ctx context.Context, login string) (uint64, error) {
 	ms.m.Lock()
 	defer ms.m.Unlock()
 	// find the user by login
 	for _, v := range ms.users {
 		if v.Login == login {
-			return v.ID, true
+			return v.ID, nil
 		}
 	}
-	return 0, false
+	return 0, store.NewError(store.ErrNotFound, "getting user's id", nil)
 }
 
-func (ms *Store) GetUser(id uint64) users.User {
+func (ms *Store) GetUser(ctx context.Context, id uint64) (users.User, error) {
 	ms.m.Lock()
 	defer ms.m.Unlock()
-	// find the user by ID
+	return ms.findUser(id)
+}
+
+// findUser searches for the user by ID, the mutex has to be locked already
+func (ms *Store) findUser(id uint64) (users.User, error) {
 	for _, v := range ms.users {
 		if v.ID == id {
-			return v
+			return v, nil
 		}
 	}
-	return users.User{}
+	return users.User{}, store.NewError(store.ErrNotFound, "getting a user", nil)
 }
 
-func (ms *Store) ListAuthors(from uint64, to uint64) []users.Author {
+// findAuthor returns the position of the author with the ID in ms.authors,
+// or -1 if there's none, the mutex has to be locked already
+func (ms *Store) findAuthor(authorId uint64) int {
+	for k, a := range ms.authors {
+		if a.id == authorId {
+			return k
+		}
+	}
+	return -1
+}
+
+func (ms *Store) ListAuthors(ctx context.Context, from uint64, to uint64) ([]users.Author, error) {
 	ms.m.Lock()
 	defer ms.m.Unlock()
 
+	// only authors linked to users are listed, sorted by the IDs of the users
 	authors := make([]users.Author, 0, 0)
-	for _, v := range ms.users[from:to] {
-		authors = append(authors, users.Author{
-			User:     v,
-			AuthorID: v.ID,
-		})
+	for _, u := range ms.users {
+		for _, a := range ms.authors {
+			if a.userID == u.ID {
+				u.Password = ""
+				authors = append(authors, users.Author{User: u, AuthorID: a.id, AuthorName: a.name})
+			}
+		}
 	}
-	return authors
+	from, to = bounds(len(authors), from, to)
+	return authors[from:to], nil
 }
 
-func (ms *Store) ListAdmins(from uint64, to uint64) []users.User {
+func (ms *Store) ListAdmins(ctx context.Context, from uint64, to uint64) ([]users.User, error) {
 	ms.m.Lock()
 	defer ms.m.Unlock()
 
-	return ms.admins[from:to]
+	admins := make([]users.User, 0, 0)
+	for _, v := range ms.users {
+		if v.Role == users.RoleAdmin {
+			v.Password = ""
+			admins = append(admins, v)
+		}
+	}
+	from, to = bounds(len(admins), from, to)
+	return admins[from:to], nil
 }
 
 func (ms *Store) Info() store.StoreInfo {
@@ -87,171 +203,1079 @@ func (ms *Store) Info() store.StoreInfo {
 	}
 }
 
-// too lazy to implement, and not needed
-func (ms *Store) AddArticle(name string, authorId uint64, timestamp uint64, content template.HTML) {
-	return
+func (ms *Store) GetArticleBySlug(ctx context.Context, slug string) (article.Article, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	for _, v := range ms.articlesByTimestamp {
+		if v.Slug == slug {
+			return v, nil
+		}
+	}
+	if id, exists := ms.oldSlugs[slug]; exists {
+		return ms.articlesByID[strconv.FormatUint(id, 10)], nil
+	}
+	return article.Article{}, store.NewError(store.ErrNotFound, "getting an article", nil)
+}
+
+// uniqueSlug returns the slug of the article (or the one generated from its
+// title) made unique among all other articles, the mutex has to be locked
+// already
+func (ms *Store) uniqueSlug(a article.Article) string {
+	slug := a.Slug
+	if slug == "" {
+		slug = a.Title
+	}
+	// the function never returns an error, so neither does UniqueSlug
+	slug, _ = article.UniqueSlug(article.Slugify(slug), func(slug string) (bool, error) {
+		for _, other := range ms.articlesByTimestamp {
+			if other.Slug == slug && other.ID != a.ID {
+				return true, nil
+			}
+		}
+		id, exists := ms.oldSlugs[slug]
+		return exists && id != a.ID, nil
+	})
+	return slug
+}
+
+// prepareArticle checks the article, renders it and makes its slug unique,
+// the preview is returned separately, the mutex has to be locked already
+func (ms *Store) prepareArticle(a article.Article, activity string) (article.Article, template.HTML, error) {
+	if _, err := article.ParseStatus(string(a.Status)); err != nil {
+		return article.Article{}, "", store.NewError(store.ErrInvalidInput, activity, err)
+	}
+	if ms.findAuthor(a.AuthorID) == -1 {
+		return article.Article{}, "", store.NewError(store.ErrInvalidInput, activity, nil)
+	}
+
+	content, preview, err := render.RenderArticle(ms.cfg.Renderer, a.Source, a.Summary, ms.cfg.PreviewLength)
+	if err != nil {
+		return article.Article{}, "", store.NewError(store.ErrInvalidInput, activity, err)
+	}
+	a.Content = content
+	a.Slug = ms.uniqueSlug(a)
+
+	// tags are sorted by name when they're loaded from postgres
+	a.Tags = append([]string{}, a.Tags...)
+	sort.Strings(a.Tags)
+	return a, preview, nil
+}
+
+// saveArticle adds the article or replaces the one with the same ID and keeps
+// the articles sorted, the mutex has to be locked already
+func (ms *Store) saveArticle(a article.Article) {
+	ms.articlesByID[strconv.FormatUint(a.ID, 10)] = a
+
+	saved := false
+	for k, v := range ms.articlesByTimestamp {
+		if v.ID == a.ID {
+			ms.articlesByTimestamp[k] = a
+			saved = true
+		}
+	}
+	if !saved {
+		ms.articlesByTimestamp = append(ms.articlesByTimestamp, a)
+	}
+	sort.SliceStable(ms.articlesByTimestamp, func(i, j int) bool {
+		return ms.articlesByTimestamp[i].Timestamp > ms.articlesByTimestamp[j].Timestamp
+	})
+}
+
+func (ms *Store) AddArticle(ctx context.Context, a article.Article, userId uint64) (uint64, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	a.ID = 0
+	a, preview, err := ms.prepareArticle(a, "adding an article")
+	if err != nil {
+		return 0, err
+	}
+
+	ms.lastArticleID++
+	a.ID = ms.lastArticleID
+	ms.previews[a.ID] = preview
+	ms.saveArticle(a)
+
+	// the first version is a revision too
+	ms.addRevision(a, userId, time.Now().UTC())
+	return a.ID, nil
+}
+
+func (ms *Store) EditArticle(ctx context.Context, a article.Article, userId uint64) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	return ms.editArticle(a, userId)
+}
+
+// editArticle changes the article and saves a revision, the mutex has to be
+// locked already
+func (ms *Store) editArticle(a article.Article, userId uint64) error {
+	const activity = "editing an article"
+	old, exists := ms.articlesByID[strconv.FormatUint(a.ID, 10)]
+	if !exists {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
+	a, preview, err := ms.prepareArticle(a, activity)
+	if err != nil {
+		return err
+	}
+
+	// articles added by Seed don't have any revisions, so the current version
+	// has to be saved before it's overwritten
+	// They don't have a source either, but their HTML is valid Markdown too
+	if ms.revisionNumber(a.ID) == 0 {
+		if old.Source == "" {
+			old.Source = string(old.Content)
+		}
+		ms.addRevision(old, 0, time.Unix(int64(old.Timestamp), 0).UTC())
+	}
+	ms.addRevision(a, userId, time.Now().UTC())
+
+	// the old slug keeps working, so links to the article don't break
+	if old.Slug != "" && old.Slug != a.Slug {
+		ms.oldSlugs[old.Slug] = a.ID
+	}
+	// the article might have gotten one of its old slugs back
+	delete(ms.oldSlugs, a.Slug)
+
+	ms.previews[a.ID] = preview
+	ms.saveArticle(a)
+	return nil
+}
+
+// the mutex has to be locked already
+func (ms *Store) addRevision(a article.Article, userId uint64, t time.Time) {
+	ms.lastRevisionID++
+	ms.revisions = append(ms.revisions, article.Revision{
+		ID:        ms.lastRevisionID,
+		ArticleID: a.ID,
+		UserID:    userId,
+		Time:      t,
+		Title:     a.Title,
+		Source:    a.Source,
+		Summary:   a.Summary,
+	})
+}
+
+// withRevisionUser sets the name of the user who made the revision to their
+// current display name, the mutex has to be locked already
+func (ms *Store) withRevisionUser(r article.Revision) article.Revision {
+	r.UserName = ""
+	if u, err := ms.findUser(r.UserID); err == nil {
+		r.UserName = u.DisplayName
+	}
+	return r
+}
+
+// the mutex has to be locked already
+func (ms *Store) revisionNumber(articleId uint64) int {
+	num := 0
+	for _, r := range ms.revisions {
+		if r.ArticleID == articleId {
+			num++
+		}
+	}
+	return num
+}
+
+func (ms *Store) ListRevisions(ctx context.Context, articleId uint64, from uint64, to uint64) ([]article.Revision, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	// from latest
+	revisions := make([]article.Revision, 0, 0)
+	for i := len(ms.revisions) - 1; i >= 0; i-- {
+		if ms.revisions[i].ArticleID == articleId {
+			revisions = append(revisions, ms.withRevisionUser(ms.revisions[i]))
+		}
+	}
+	from, to = bounds(len(revisions), from, to)
+	return revisions[from:to], nil
+}
+
+func (ms *Store) GetRevision(ctx context.Context, id uint64) (article.Revision, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	return ms.findRevision(id)
+}
+
+// findRevision searches for the revision by ID, the mutex has to be locked
+// already
+func (ms *Store) findRevision(id uint64) (article.Revision, error) {
+	for _, r := range ms.revisions {
+		if r.ID == id {
+			return ms.withRevisionUser(r), nil
+		}
+	}
+	return article.Revision{}, store.NewError(store.ErrNotFound, "getting a revision", nil)
 }
 
-func (ms *Store) EditArticle(a article.Article) {
-	return
+func (ms *Store) RestoreRevision(ctx context.Context, id uint64, userId uint64) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	r, err := ms.findRevision(id)
+	if err != nil {
+		return err
+	}
+	a, exists := ms.articlesByID[strconv.FormatUint(r.ArticleID, 10)]
+	if !exists {
+		return store.NewError(store.ErrNotFound, "restoring a revision", nil)
+	}
+	a.Title = r.Title
+	a.Source = r.Source
+	a.Summary = r.Summary
+	return ms.editArticle(a, userId)
 }
 
-func (ms *Store) RemoveArticle(id uint64) {
-	return
+func (ms *Store) RemoveArticle(ctx context.Context, id uint64) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	if _, exists := ms.articlesByID[strconv.FormatUint(id, 10)]; !exists {
+		return store.NewError(store.ErrNotFound, "removing an article", nil)
+	}
+	delete(ms.articlesByID, strconv.FormatUint(id, 10))
+	delete(ms.previews, id)
+	for k, a := range ms.articlesByTimestamp {
+		if a.ID == id {
+			ms.articlesByTimestamp = append(ms.articlesByTimestamp[:k], ms.articlesByTimestamp[k+1:]...)
+			break
+		}
+	}
+
+	// everything belonging to the article goes with it
+	for slug, articleId := range ms.oldSlugs {
+		if articleId == id {
+			delete(ms.oldSlugs, slug)
+		}
+	}
+	revisions := make([]article.Revision, 0, len(ms.revisions))
+	for _, r := range ms.revisions {
+		if r.ArticleID != id {
+			revisions = append(revisions, r)
+		}
+	}
+	ms.revisions = revisions
+	cs := make([]comments.Comment, 0, len(ms.comments))
+	for _, c := range ms.comments {
+		if c.ArticleID != id {
+			cs = append(cs, c)
+		}
+	}
+	ms.comments = cs
+	return nil
 }
 
-func (ms *Store) AddUser(displayName string, login string, password string) {
+func (ms *Store) AddUser(ctx context.Context, displayName string, login string, password string) error {
 	ms.m.Lock()
+	defer ms.m.Unlock()
+	return ms.addUser(displayName, login, password)
+}
+
+// the mutex has to be locked already
+func (ms *Store) addUser(displayName string, login string, password string) error {
+	// logins are unique
+	for _, v := range ms.users {
+		if v.Login == login {
+			return store.NewError(store.ErrConflict, "adding a new user", nil)
+		}
+	}
+	ms.lastUserID++
 	ms.users = append(ms.users, users.User{
-		ID:          uint64(len(ms.users) + 1),
+		ID:          ms.lastUserID,
 		DisplayName: displayName,
 		Login:       login,
 		Password:    password,
+		Role:        users.RoleReader,
 	})
-	ms.m.Unlock()
+	return nil
 }
 
-func (ms *Store) EditUser(user users.User) {
+func (ms *Store) EditUser(ctx context.Context, user users.User) error {
 	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	for _, v := range ms.users {
+		if v.Login == user.Login && v.ID != user.ID {
+			return store.NewError(store.ErrConflict, "editing a user", nil)
+		}
+	}
 	// find the user by ID
 	for k, v := range ms.users {
 		if v.ID == user.ID {
+			// the role is only changed by SetRole
+			user.Role = v.Role
 			ms.users[k] = user
+			return nil
 		}
 	}
-	ms.m.Unlock()
+	return store.NewError(store.ErrNotFound, "editing a user", nil)
 }
 
-func (ms *Store) RemoveUser(id uint64) {
+func (ms *Store) SetRole(ctx context.Context, userId uint64, role users.Role) error {
 	ms.m.Lock()
-	// find the user by ID
+	defer ms.m.Unlock()
+	return ms.setRole(userId, role, "changing the role of a user")
+}
+
+// setRole changes the role of the user, the mutex has to be locked already
+func (ms *Store) setRole(userId uint64, role users.Role, activity string) error {
+	if _, err := users.ParseRole(string(role)); err != nil {
+		return store.NewError(store.ErrInvalidInput, activity, err)
+	}
+	for k, v := range ms.users {
+		if v.ID == userId {
+			ms.users[k].Role = role
+			return nil
+		}
+	}
+	return store.NewError(store.ErrNotFound, activity, nil)
+}
+
+func (ms *Store) RemoveUser(ctx context.Context, id uint64) error {
+	const activity = "removing a user"
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	// users can't be removed while something refers to them
+	for _, a := range ms.authors {
+		if a.userID == id {
+			return store.NewError(store.ErrConflict, activity, nil)
+		}
+	}
+
+	found := false
 	for k, v := range ms.users {
 		if v.ID == id {
-			// copy the last user into the position of deleted user
-			ms.users[k] = ms.users[len(ms.users)-1]
+			ms.users = append(ms.users[:k], ms.users[k+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
 
-			// delete the last user
-			ms.users = ms.users[:len(ms.users)-1]
+	// sessions and tokens are removed with the user, revisions and comments
+	// are kept
+	for sessionId, s := range ms.sessions {
+		if s.UserID == id {
+			delete(ms.sessions, sessionId)
 		}
 	}
-	ms.m.Unlock()
+	tokens := make([]users.Token, 0, len(ms.tokens))
+	for _, t := range ms.tokens {
+		if t.UserID != id {
+			tokens = append(tokens, t)
+		}
+	}
+	ms.tokens = tokens
+	for k, r := range ms.revisions {
+		if r.UserID == id {
+			ms.revisions[k].UserID = 0
+		}
+	}
+	for k, c := range ms.comments {
+		if c.UserID == id {
+			ms.comments[k].UserID = 0
+		}
+	}
+	return nil
+}
+
+func (ms *Store) GetAuthor(ctx context.Context, userId uint64) (users.Author, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	for _, a := range ms.authors {
+		if a.userID == userId && userId != 0 {
+			return users.Author{AuthorID: a.id, AuthorName: a.name}, nil
+		}
+	}
+	return users.Author{}, store.NewError(store.ErrNotFound, "getting an author", nil)
 }
 
-// everyone is a an author since i'm way too lazy to implement this
-// also user id == author id
-func (ms *Store) GetAuthor(userId uint64) users.Author {
+func (ms *Store) GetAuthorByID(ctx context.Context, authorId uint64) (users.Author, error) {
 	ms.m.Lock()
 	defer ms.m.Unlock()
 
-	u2 := ms.GetUser(userId)
-	return users.Author{
-		User:     u2,
-		AuthorID: u2.ID,
+	k := ms.findAuthor(authorId)
+	if k == -1 {
+		return users.Author{}, store.NewError(store.ErrNotFound, "getting an author", nil)
 	}
+	return users.Author{AuthorID: ms.authors[k].id, AuthorName: ms.authors[k].name}, nil
 }
 
-func (ms *Store) AddAuthor(userId uint64, authorName string) {
-	return
+func (ms *Store) AddAuthor(ctx context.Context, userId uint64, authorName string) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	return ms.addAuthor(userId, authorName)
 }
 
-func (ms *Store) LinkAuthor(authorId uint64, userId uint64) {
-	return
+// the mutex has to be locked already
+func (ms *Store) addAuthor(userId uint64, authorName string) error {
+	const activity = "adding an author"
+	if err := ms.checkAuthorUser(userId, 0, activity); err != nil {
+		return err
+	}
+	ms.lastAuthorID++
+	ms.authors = append(ms.authors, author{id: ms.lastAuthorID, userID: userId, name: authorName})
+	return nil
 }
 
-func (ms *Store) RemoveAuthor(authorId uint64) {
-	return
+// checkAuthorUser returns an error unless the user exists and isn't linked to
+// an author other than the one with authorId, the mutex has to be locked
+// already
+func (ms *Store) checkAuthorUser(userId uint64, authorId uint64, activity string) error {
+	if _, err := ms.findUser(userId); err != nil {
+		return store.NewError(store.ErrInvalidInput, activity, nil)
+	}
+	// a user can only be linked to a single author
+	for _, a := range ms.authors {
+		if a.userID == userId && a.id != authorId {
+			return store.NewError(store.ErrConflict, activity, nil)
+		}
+	}
+	return nil
 }
 
-func (ms *Store) PromoteToAdmin(id uint64) {
+func (ms *Store) LinkAuthor(ctx context.Context, authorId uint64, userId uint64) error {
+	const activity = "linking a user to an author"
 	ms.m.Lock()
-	// find the user by ID
-	for _, v := range ms.users {
-		if v.ID == id {
-			ms.admins = append(ms.admins, v)
+	defer ms.m.Unlock()
+
+	k := ms.findAuthor(authorId)
+	if k == -1 {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
+	if userId != 0 {
+		if err := ms.checkAuthorUser(userId, authorId, activity); err != nil {
+			return err
 		}
 	}
-	ms.m.Unlock()
+	ms.authors[k].userID = userId
+	return nil
 }
 
-func (ms *Store) DemoteFromAdmin(id uint64) {
+func (ms *Store) RemoveAuthor(ctx context.Context, authorId uint64) error {
+	const activity = "removing an author"
 	ms.m.Lock()
-	// find the admin by ID
-	for k, v := range ms.users {
-		if v.ID == id {
-			// copy the last admin into the position of deleted admin
-			ms.admins[k] = ms.admins[len(ms.admins)-1]
+	defer ms.m.Unlock()
 
-			// delete the last admin
-			ms.admins = ms.admins[:len(ms.admins)-1]
+	k := ms.findAuthor(authorId)
+	if k == -1 {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
+	for _, a := range ms.articlesByTimestamp {
+		if a.AuthorID == authorId {
+			return store.NewError(store.ErrConflict, activity, nil)
+		}
+	}
+	ms.authors = append(ms.authors[:k], ms.authors[k+1:]...)
+	return nil
+}
+
+func (ms *Store) PromoteToAdmin(ctx context.Context, id uint64) error {
+	const activity = "promoting a user to an admin"
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	u, err := ms.findUser(id)
+	if err != nil {
+		return err
+	}
+	if u.Role == users.RoleAdmin {
+		return store.NewError(store.ErrConflict, activity, nil)
+	}
+	return ms.setRole(id, users.RoleAdmin, activity)
+}
+
+func (ms *Store) DemoteFromAdmin(ctx context.Context, id uint64) error {
+	const activity = "demoting a user from an admin"
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	if u, err := ms.findUser(id); err != nil || u.Role != users.RoleAdmin {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
+	return ms.setRole(id, users.RoleReader, activity)
+}
+
+func (ms *Store) AddSession(ctx context.Context, session users.Session) error {
+	const activity = "adding a session"
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	if _, exists := ms.sessions[session.ID]; exists {
+		return store.NewError(store.ErrConflict, activity, nil)
+	}
+	if _, err := ms.findUser(session.UserID); err != nil {
+		return store.NewError(store.ErrInvalidInput, activity, nil)
+	}
+	ms.sessions[session.ID] = session
+	return nil
+}
+
+func (ms *Store) GetSession(ctx context.Context, id uint64) (users.Session, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	session, exists := ms.sessions[id]
+	if !exists || !session.Valid() {
+		return users.Session{}, store.NewError(store.ErrNotFound, "getting a session", nil)
+	}
+	return session, nil
+}
+
+func (ms *Store) ExtendSession(ctx context.Context, id uint64, validUntil time.Time) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	session, exists := ms.sessions[id]
+	if !exists {
+		return store.NewError(store.ErrNotFound, "extending a session", nil)
+	}
+	session.ValidUntil = validUntil
+	ms.sessions[id] = session
+	return nil
+}
+
+func (ms *Store) RemoveSession(ctx context.Context, id uint64) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	if _, exists := ms.sessions[id]; !exists {
+		return store.NewError(store.ErrNotFound, "removing a session", nil)
+	}
+	delete(ms.sessions, id)
+	return nil
+}
+
+func (ms *Store) RemoveUserSessions(ctx context.Context, userId uint64) error {
+	ms.m.Lock()
+	for id, session := range ms.sessions {
+		if session.UserID == userId {
+			delete(ms.sessions, id)
+		}
+	}
+	ms.m.Unlock()
+	return nil
+}
+
+func (ms *Store) RemoveExpiredSessions(ctx context.Context) error {
+	ms.m.Lock()
+	for id, session := range ms.sessions {
+		if !session.Valid() {
+			delete(ms.sessions, id)
 		}
 	}
 	ms.m.Unlock()
+	return nil
 }
 
-func (ms *Store) LoadArticlesSortedByLatest(from uint64, to uint64) []article.Article {
-	/*
-		// return articles starting from
-		starti := ms.cfg.ArticlesPerIndexPage * page
-		// and ending with these...
-		endi := starti + ms.cfg.ArticlesPerIndexPage
-		if endi > ms.GetArticleNumber() {
-			endi = ms.GetArticleNumber()
+// filterArticles returns articles for which keep returns true sorted by latest,
+// with their previews as their content, the mutex has to be locked already
+func (ms *Store) filterArticles(keep func(a article.Article) bool) []article.Article {
+	articles := make([]article.Article, 0, 0)
+	for _, a := range ms.articlesByTimestamp {
+		if !keep(a) {
+			continue
 		}
-	*/
+		if preview, exists := ms.previews[a.ID]; exists {
+			a.Content = preview
+		}
+		articles = append(articles, a)
+	}
+	return articles
+}
 
-	return ms.articlesByTimestamp[from:to]
+func (ms *Store) LoadArticlesSortedByLatest(ctx context.Context, from uint64, to uint64) ([]article.Article, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	articles := ms.filterArticles(published)
+	from, to = bounds(len(articles), from, to)
+	return articles[from:to], nil
 }
 
-func (ms *Store) GetArticleByID(ID uint64) (article.Article, bool) {
+func (ms *Store) GetArticleByID(ctx context.Context, ID uint64) (article.Article, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
 	// val stores the value, if there's none, it simply stores a zeroed Article
 	// exists stores boolean value meaning the existence of an article with the ID
 	val, exists := ms.articlesByID[strconv.FormatUint(ID, 10)]
-	return val, exists
+	if !exists {
+		return val, store.NewError(store.ErrNotFound, "getting an article", nil)
+	}
+	return val, nil
+}
+
+func (ms *Store) LoadAllArticlesSortedByLatest(ctx context.Context, from uint64, to uint64) ([]article.Article, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	articles := ms.filterArticles(func(a article.Article) bool {
+		return true
+	})
+	from, to = bounds(len(articles), from, to)
+	return articles[from:to], nil
+}
+
+func (ms *Store) LoadArticlesByAuthor(ctx context.Context, authorId uint64, from uint64, to uint64) ([]article.Article, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	articles := ms.filterArticles(func(a article.Article) bool {
+		return a.AuthorID == authorId
+	})
+	from, to = bounds(len(articles), from, to)
+	return articles[from:to], nil
+}
+
+func (ms *Store) PublishScheduledArticles(ctx context.Context, now time.Time) (uint64, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	due := ms.filterArticles(func(a article.Article) bool {
+		return a.Status == article.Scheduled && int64(a.Timestamp) <= now.Unix()
+	})
+	for _, a := range due {
+		a = ms.articlesByID[strconv.FormatUint(a.ID, 10)]
+		a.Status = article.Published
+		ms.saveArticle(a)
+	}
+	return uint64(len(due)), nil
+}
+
+// articlesWithTag returns published articles with the tag sorted by latest,
+// the mutex has to be locked already
+func (ms *Store) articlesWithTag(tag string) []article.Article {
+	return ms.filterArticles(func(a article.Article) bool {
+		if !published(a) {
+			return false
+		}
+		for _, t := range a.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (ms *Store) LoadArticlesByTag(ctx context.Context, tag string, from uint64, to uint64) ([]article.Article, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	articles := ms.articlesWithTag(tag)
+	from, to = bounds(len(articles), from, to)
+	return articles[from:to], nil
+}
+
+func (ms *Store) GetArticleNumberByTag(ctx context.Context, tag string) (uint64, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	return uint64(len(ms.articlesWithTag(tag))), nil
+}
+
+func (ms *Store) ListTags(ctx context.Context) ([]article.TagCount, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	counts := make(map[string]uint64)
+	for _, a := range ms.articlesByTimestamp {
+		if !published(a) {
+			continue
+		}
+		for _, t := range a.Tags {
+			counts[t]++
+		}
+	}
+
+	tags := make([]article.TagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, article.TagCount{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// search returns published articles containing all words of the query,
+// sorted by how many times they contain them, words in the title count twice
+// the mutex has to be locked already
+func (ms *Store) search(query string) []article.SearchResult {
+	terms := article.Tokenize(query)
+	if len(terms) == 0 {
+		return make([]article.SearchResult, 0, 0)
+	}
+
+	results := make([]article.SearchResult, 0, 0)
+	scores := make(map[uint64]int)
+	for _, a := range ms.articlesByTimestamp {
+		if !published(a) {
+			continue
+		}
+
+		counts := make(map[string]int)
+		for _, t := range article.Tokenize(a.Title) {
+			counts[t] += 2
+		}
+		for _, t := range article.Tokenize(html.UnescapeString(article.StripTags(a.Content))) {
+			counts[t]++
+		}
+
+		score := 0
+		for _, t := range terms {
+			if counts[t] == 0 {
+				score = 0
+				break
+			}
+			score += counts[t]
+		}
+		if score == 0 {
+			continue
+		}
+
+		// the snippet is made from the whole article, but only the preview is
+		// returned
+		snippet := article.Snippet(a.Content, terms, article.SnippetLength)
+		if preview, exists := ms.previews[a.ID]; exists {
+			a.Content = preview
+		}
+		scores[a.ID] = score
+		results = append(results, article.SearchResult{
+			Article: a,
+			Snippet: snippet,
+		})
+	}
+
+	// articles are already sorted by latest, so equally good matches stay that way
+	sort.SliceStable(results, func(i, j int) bool {
+		return scores[results[i].ID] > scores[results[j].ID]
+	})
+	return results
+}
+
+func (ms *Store) SearchArticles(ctx context.Context, query string, from uint64, to uint64) ([]article.SearchResult, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	results := ms.search(query)
+	from, to = bounds(len(results), from, to)
+	return results[from:to], nil
+}
+
+func (ms *Store) GetSearchResultNumber(ctx context.Context, query string) (uint64, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	return uint64(len(ms.search(query))), nil
 }
 
-func (ms *Store) GetArticleNumber() uint64 {
-	num := len(ms.articlesByTimestamp)
-	return uint64(num)
+func (ms *Store) GetArticleNumber(ctx context.Context) (uint64, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	return uint64(len(ms.filterArticles(published))), nil
 }
 
 func (ms *Store) Init(_ func(), cfg store.StoreConfig) error {
-	// doesn't implement notify at all, since MockStore cannot change contents at runtime
+	// everything is changed through the Store itself, so there's nothing to
+	// monitor and notify about
+	ms.m.Lock()
+	defer ms.m.Unlock()
 
-	// copy cfg
+	if cfg.Renderer == nil {
+		cfg.Renderer = render.Markdown{}
+	}
+	if cfg.PreviewLength == 0 {
+		cfg.PreviewLength = render.DefaultPreviewLength
+	}
 	ms.cfg = cfg
 
-	// prepare the struct
+	// prepare the struct, anything left from before is thrown away
 	ms.articlesByTimestamp = make([]article.Article, 0, 0)
 	ms.articlesByID = make(map[string]article.Article)
+	ms.previews = make(map[uint64]template.HTML)
+	ms.oldSlugs = make(map[string]uint64)
 	ms.users = make([]users.User, 0, 0)
-	ms.admins = make([]users.User, 0, 0)
+	ms.authors = make([]author, 0, 0)
+	ms.sessions = make(map[uint64]users.Session)
+	ms.revisions = make([]article.Revision, 0, 0)
+	ms.comments = make([]comments.Comment, 0, 0)
+	ms.tokens = make([]users.Token, 0, 0)
+	ms.lastArticleID, ms.lastUserID, ms.lastAuthorID = 0, 0, 0
+	ms.lastRevisionID, ms.lastCommentID, ms.lastTokenID = 0, 0, 0
 
-	// example user and admin
-	ms.AddUser("", "", "")
+	return nil
+}
+
+// Seed implements Seeder's Seed function
+// It adds an admin, who can log in as "montesquieu" with the password
+// "montesquieu", an author linked to them and a few example articles written by
+// the author. It's meant to be called right after Init, while there aren't any
+// articles yet
+func (ms *Store) Seed() error {
+	hash, err := users.HashPassword("montesquieu")
+	if err != nil {
+		return err
+	}
+
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	// the admin, whose author wrote all example articles
+	if err := ms.addUser("Montesquieu", "montesquieu", hash); err != nil {
+		return err
+	}
+	userId := ms.lastUserID
+	ms.users[len(ms.users)-1].Role = users.RoleAdmin
+	if err := ms.addAuthor(userId, "Montesquieu"); err != nil {
+		return err
+	}
+	authorId := ms.lastAuthorID
 
-	// lets fill articles with some mock articles
-	ms.articlesByTimestamp = append(ms.articlesByTimestamp, article.Article{
+	// lets fill articles with some example articles
+	articles := []article.Article{{
 		Timestamp: 1585828351,
 		ID:        100,
 		Title:     "Welcome to your brand new Montesquieu installation!",
-		Content:   "Thank you for choosing Montesquieu! You should consider <b>changing the config.json</b>, since now montesquieu only displays mock content, and you won't be able to make articles until you use a real Store.",
-	})
+		Content:   "Thank you for choosing Montesquieu! You should consider <b>changing the config.json</b>, since now montesquieu only keeps everything in memory, and your articles will be gone once it stops. Use a real Store to keep them.",
+		Tags:      []string{"montesquieu"},
+	}}
 
-	// lets generate another mock articles
+	// lets generate another example articles
 	for i := 1; i < 11; i++ {
-		ms.articlesByTimestamp = append(ms.articlesByTimestamp, article.Article{
-			Timestamp: ms.articlesByTimestamp[i-1].Timestamp - 1,
+		tags := []string{"lorem-ipsum"}
+		if i%2 == 0 {
+			tags = append(tags, "even")
+		}
+		articles = append(articles, article.Article{
+			Timestamp: articles[i-1].Timestamp - 1,
 			ID:        uint64(i + 1),
 			Title:     "Article " + strconv.Itoa(i+1),
 			Content:   "Lorem ipsum dolor sit amet",
+			Tags:      tags,
 		})
 	}
 
-	// make a copy that's sorted by ID
-	for _, v := range ms.articlesByTimestamp {
+	// keep a copy that's sorted by ID as well
+	for _, v := range articles {
+		v.Slug = article.Slugify(v.Title)
+		v.Status = article.Published
+		v.AuthorID = authorId
+		ms.articlesByTimestamp = append(ms.articlesByTimestamp, v)
 		ms.articlesByID[strconv.FormatUint(v.ID, 10)] = v
+		if v.ID > ms.lastArticleID {
+			ms.lastArticleID = v.ID
+		}
+	}
+	return nil
+}
+
+func (ms *Store) Close() error {
+	// there's nothing to release, everything is lost once Montesquieu stops
+	return nil
+}
+
+func (ms *Store) AddComment(ctx context.Context, c comments.Comment) error {
+	const activity = "adding a comment"
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	if _, exists := ms.articlesByID[strconv.FormatUint(c.ArticleID, 10)]; !exists {
+		return store.NewError(store.ErrInvalidInput, activity, nil)
+	}
+	if c.UserID != 0 {
+		if _, err := ms.findUser(c.UserID); err != nil {
+			return store.NewError(store.ErrInvalidInput, activity, nil)
+		}
+		// the name of the user is looked up when the comment is loaded
+		c.Name = ""
+	}
+	if c.ParentID != 0 {
+		parent, err := ms.findComment(c.ParentID)
+		if err != nil || parent.ArticleID != c.ArticleID {
+			return store.NewError(store.ErrInvalidInput, activity, nil)
+		}
 	}
 
-	// i don't think there's even a remote possibility of error in this function
+	ms.lastCommentID++
+	c.ID = ms.lastCommentID
+	c.Time = time.Now().UTC()
+	ms.comments = append(ms.comments, c)
 	return nil
 }
+
+// findComment searches for the comment by ID and fills in the name of its user
+// the mutex has to be locked already
+func (ms *Store) findComment(id uint64) (comments.Comment, error) {
+	for _, c := range ms.comments {
+		if c.ID == id {
+			return ms.withUserName(c), nil
+		}
+	}
+	return comments.Comment{}, store.NewError(store.ErrNotFound, "getting a comment", nil)
+}
+
+// withUserName sets the name of comments written by users to the display name
+// of the user, the mutex has to be locked already
+func (ms *Store) withUserName(c comments.Comment) comments.Comment {
+	if c.UserID != 0 {
+		if u, err := ms.findUser(c.UserID); err == nil {
+			c.Name = u.DisplayName
+		}
+	}
+	return c
+}
+
+// filterComments returns comments for which keep returns true, the mutex has
+// to be locked already
+func (ms *Store) filterComments(keep func(c comments.Comment) bool) []comments.Comment {
+	cs := make([]comments.Comment, 0, 0)
+	for _, c := range ms.comments {
+		if keep(c) {
+			cs = append(cs, ms.withUserName(c))
+		}
+	}
+	return cs
+}
+
+func (ms *Store) ListComments(ctx context.Context, articleId uint64, from uint64, to uint64) ([]comments.Comment, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	cs := ms.filterComments(func(c comments.Comment) bool {
+		return c.ArticleID == articleId && c.Status == comments.Visible
+	})
+	from, to = bounds(len(cs), from, to)
+	return cs[from:to], nil
+}
+
+func (ms *Store) GetCommentNumber(ctx context.Context, articleId uint64) (uint64, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	cs := ms.filterComments(func(c comments.Comment) bool {
+		return c.ArticleID == articleId && c.Status == comments.Visible
+	})
+	return uint64(len(cs)), nil
+}
+
+func (ms *Store) ListCommentsByStatus(ctx context.Context, status comments.Status, from uint64, to uint64) ([]comments.Comment, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	cs := ms.filterComments(func(c comments.Comment) bool {
+		return c.Status == status
+	})
+
+	// from latest
+	for i, j := 0, len(cs)-1; i < j; i, j = i+1, j-1 {
+		cs[i], cs[j] = cs[j], cs[i]
+	}
+	from, to = bounds(len(cs), from, to)
+	return cs[from:to], nil
+}
+
+func (ms *Store) GetComment(ctx context.Context, id uint64) (comments.Comment, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	return ms.findComment(id)
+}
+
+func (ms *Store) SetCommentStatus(ctx context.Context, id uint64, status comments.Status) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	for k, c := range ms.comments {
+		if c.ID == id {
+			ms.comments[k].Status = status
+			return nil
+		}
+	}
+	return store.NewError(store.ErrNotFound, "changing the status of a comment", nil)
+}
+
+func (ms *Store) RemoveComment(ctx context.Context, id uint64) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	found := false
+	cs := make([]comments.Comment, 0, len(ms.comments))
+	for _, c := range ms.comments {
+		if c.ID == id {
+			found = true
+			continue
+		}
+		// replies stay, they just stop being replies
+		if c.ParentID == id {
+			c.ParentID = 0
+		}
+		cs = append(cs, c)
+	}
+	if !found {
+		return store.NewError(store.ErrNotFound, "removing a comment", nil)
+	}
+	ms.comments = cs
+	return nil
+}
+
+func (ms *Store) AddToken(ctx context.Context, token users.Token) (uint64, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	if _, err := ms.findUser(token.UserID); err != nil {
+		return 0, store.NewError(store.ErrInvalidInput, "adding a token", nil)
+	}
+
+	ms.lastTokenID++
+	token.ID = ms.lastTokenID
+	token.LastUsed = time.Time{}
+	ms.tokens = append(ms.tokens, token)
+	return token.ID, nil
+}
+
+func (ms *Store) ListTokens(ctx context.Context, userId uint64) ([]users.Token, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	tokens := make([]users.Token, 0, 0)
+	for _, t := range ms.tokens {
+		if t.UserID == userId {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (ms *Store) GetToken(ctx context.Context, id uint64) (users.Token, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	for _, t := range ms.tokens {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return users.Token{}, store.NewError(store.ErrNotFound, "getting a token", nil)
+}
+
+func (ms *Store) TouchToken(ctx context.Context, id uint64, lastUsed time.Time) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	for k, t := range ms.tokens {
+		if t.ID == id {
+			ms.tokens[k].LastUsed = lastUsed
+			return nil
+		}
+	}
+	return store.NewError(store.ErrNotFound, "touching a token", nil)
+}
+
+func (ms *Store) RemoveToken(ctx context.Context, id uint64) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+
+	for k, t := range ms.tokens {
+		if t.ID == id {
+			ms.tokens = append(ms.tokens[:k], ms.tokens[k+1:]...)
+			return nil
+		}
+	}
+	return store.NewError(store.ErrNotFound, "removing a token", nil)
+}
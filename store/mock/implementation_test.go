@@ -1,12 +1,21 @@
 package mock
 
 import (
+	"context"
+	"errors"
 	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/comments"
 	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/users"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
+// context used for all calls to the store
+var ctx = context.Background()
+
 // struct fields
 type fields struct {
 	cfg                 store.StoreConfig
@@ -158,18 +167,18 @@ func TestMockStore_GetArticleByID(t *testing.T) {
 	testFields := getTestFields()
 
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		want   article.Article
-		want1  bool
+		name    string
+		fields  fields
+		args    args
+		want    article.Article
+		wantErr bool
 	}{
 		{
-			name:   "Invalid Article",
-			fields: testFields,
-			args:   args{ID: 250604},
-			want:   article.Article{},
-			want1:  false,
+			name:    "Invalid Article",
+			fields:  testFields,
+			args:    args{ID: 250604},
+			want:    article.Article{},
+			wantErr: true,
 		},
 		{
 			name:   "Article 1",
@@ -181,7 +190,7 @@ func TestMockStore_GetArticleByID(t *testing.T) {
 				Title:     "Article 1",
 				Content:   "This is Article 1.",
 			},
-			want1: true,
+			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
@@ -191,12 +200,12 @@ func TestMockStore_GetArticleByID(t *testing.T) {
 				articlesByTimestamp: tt.fields.articlesByTimestamp,
 				articlesByID:        tt.fields.articlesByID,
 			}
-			got, got1 := ms.GetArticleByID(tt.args.ID)
+			got, err := ms.GetArticleByID(ctx, tt.args.ID)
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("GetArticleByID() got = %v, want %v", got, tt.want)
 			}
-			if got1 != tt.want1 {
-				t.Errorf("GetArticleByID() got1 = %v, want %v", got1, tt.want1)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetArticleByID() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -222,7 +231,7 @@ func TestMockStore_GetArticleNumber(t *testing.T) {
 				articlesByTimestamp: tt.fields.articlesByTimestamp,
 				articlesByID:        tt.fields.articlesByID,
 			}
-			if got := ms.GetArticleNumber(); got != tt.want {
+			if got, _ := ms.GetArticleNumber(ctx); got != tt.want {
 				t.Errorf("GetArticleNumber() = %v, want %v", got, tt.want)
 			}
 		})
@@ -265,6 +274,43 @@ func TestMockStore_Init(t *testing.T) {
 	}
 }
 
+func TestMockStore_Seed(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if n, err := ms.GetArticleNumber(ctx); err != nil || n != 0 {
+		t.Errorf("GetArticleNumber() = %v, %v; want an empty store before Seed", n, err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	if n, err := ms.GetArticleNumber(ctx); err != nil || n != 11 {
+		t.Errorf("GetArticleNumber() = %v, %v; want 11", n, err)
+	}
+
+	// the documented account has to be able to log in and manage the blog
+	id, err := ms.GetUserID(ctx, "montesquieu")
+	if err != nil {
+		t.Fatalf("GetUserID() error = %v", err)
+	}
+	u, err := ms.GetUser(ctx, id)
+	if err != nil || u.Role != users.RoleAdmin {
+		t.Errorf("GetUser() = %v, %v; want an admin", u, err)
+	}
+	if ok, err := users.VerifyPassword(u.Password, "montesquieu"); !ok {
+		t.Errorf("VerifyPassword() = %v, %v; want the password montesquieu to match", ok, err)
+	}
+	a, err := ms.GetAuthor(ctx, id)
+	if err != nil {
+		t.Fatalf("GetAuthor() error = %v", err)
+	}
+	if got, err := ms.GetArticleByID(ctx, 100); err != nil || got.AuthorID != a.AuthorID {
+		t.Errorf("GetArticleByID(100) = %v, %v; want an article of author %v", got, err, a.AuthorID)
+	}
+}
+
 func TestMockStore_LoadArticlesForIndex(t *testing.T) {
 	type args struct {
 		from uint64
@@ -349,9 +395,458 @@ func TestMockStore_LoadArticlesForIndex(t *testing.T) {
 				articlesByTimestamp: tt.fields.articlesByTimestamp,
 				articlesByID:        tt.fields.articlesByID,
 			}
-			if got := ms.LoadArticlesSortedByLatest(tt.args.from, tt.args.to); !reflect.DeepEqual(got, tt.want) {
+			if got, _ := ms.LoadArticlesSortedByLatest(ctx, tt.args.from, tt.args.to); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("LoadArticlesForIndex() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestMockStore_Sessions(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	ms.AddSession(ctx, users.Session{ID: 1, UserID: 1, ValidUntil: time.Now().Add(time.Hour)})
+	ms.AddSession(ctx, users.Session{ID: 2, UserID: 1, ValidUntil: time.Now().Add(-time.Hour)})
+
+	if got, err := ms.GetSession(ctx, 1); err != nil || got.UserID != 1 {
+		t.Errorf("GetSession(1) = %v, %v; want a session of user 1", got, err)
+	}
+	if _, err := ms.GetSession(ctx, 2); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSession(2) returned an expired session")
+	}
+
+	// extending an expired session makes it valid again
+	if err := ms.ExtendSession(ctx, 2, time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("ExtendSession(2) error = %v", err)
+	}
+	if _, err := ms.GetSession(ctx, 2); err != nil {
+		t.Errorf("GetSession(2) error = %v after ExtendSession()", err)
+	}
+	if err := ms.ExtendSession(ctx, 3, time.Now()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("ExtendSession(3) error = %v, want ErrNotFound", err)
+	}
+	ms.ExtendSession(ctx, 2, time.Now().Add(-time.Hour))
+
+	ms.RemoveExpiredSessions(ctx)
+	if len(ms.sessions) != 1 {
+		t.Errorf("RemoveExpiredSessions() left %v sessions, want 1", len(ms.sessions))
+	}
+
+	ms.RemoveUserSessions(ctx, 1)
+	if len(ms.sessions) != 0 {
+		t.Errorf("RemoveUserSessions(1) left %v sessions, want 0", len(ms.sessions))
+	}
+}
+
+func TestMockStore_Tokens(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	ms.AddUser(ctx, "Token User", "token", "")
+	uid, _ := ms.GetUserID(ctx, "token")
+
+	id, err := ms.AddToken(ctx, users.Token{UserID: uid, Name: "CI", Scope: users.ScopeRead})
+	if err != nil || id == 0 {
+		t.Fatalf("AddToken() = %v, %v", id, err)
+	}
+	if _, err := ms.AddToken(ctx, users.Token{UserID: 424242}); !errors.Is(err, store.ErrInvalidInput) {
+		t.Errorf("AddToken() of an unknown user returned %v, want ErrInvalidInput", err)
+	}
+
+	now := time.Now()
+	ms.TouchToken(ctx, id, now)
+	if got, err := ms.GetToken(ctx, id); err != nil || got.Name != "CI" || !got.LastUsed.Equal(now) {
+		t.Errorf("GetToken(%v) = %v, %v", id, got, err)
+	}
+	if tokens, _ := ms.ListTokens(ctx, uid); len(tokens) != 1 {
+		t.Errorf("ListTokens() returned %v tokens, want 1", len(tokens))
+	}
+
+	ms.RemoveToken(ctx, id)
+	if _, err := ms.GetToken(ctx, id); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetToken() of a removed token returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestMockStore_Revisions(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	a, _ := ms.GetArticleByID(ctx, 2)
+	a.Title = "Edited"
+	a.Source = "New content"
+	if err := ms.EditArticle(ctx, a, 1); err != nil {
+		t.Fatalf("EditArticle() error = %v", err)
+	}
+
+	// the original article is kept as the first revision
+	revisions, err := ms.ListRevisions(ctx, 2, 0, 10)
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("ListRevisions() = %v, %v; want 2 revisions", revisions, err)
+	}
+	if revisions[0].Title != "Edited" || revisions[1].Title != "Article 2" {
+		t.Errorf("ListRevisions() = %v, want the latest revision first", revisions)
+	}
+
+	if err := ms.RestoreRevision(ctx, revisions[1].ID, 1); err != nil {
+		t.Fatalf("RestoreRevision() error = %v", err)
+	}
+	// articles of Init don't have a source, so their content is restored
+	if got, _ := ms.GetArticleByID(ctx, 2); got.Title != "Article 2" || !strings.Contains(string(got.Content), "Lorem ipsum") {
+		t.Errorf("RestoreRevision() left the title %q and the content %q", got.Title, got.Content)
+	}
+	if revisions, _ := ms.ListRevisions(ctx, 2, 0, 10); len(revisions) != 3 {
+		t.Errorf("RestoreRevision() didn't add a revision, got %v", len(revisions))
+	}
+
+	if _, err := ms.GetRevision(ctx, 100); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetRevision(100) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMockStore_Tags(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	if num, _ := ms.GetArticleNumberByTag(ctx, "even"); num != 5 {
+		t.Errorf("GetArticleNumberByTag() = %v, want 5", num)
+	}
+	articles, _ := ms.LoadArticlesByTag(ctx, "even", 1, 3)
+	if len(articles) != 2 || articles[0].ID != 5 || articles[1].ID != 7 {
+		t.Errorf("LoadArticlesByTag() = %v, want articles 5 and 7", articles)
+	}
+	if articles, _ := ms.LoadArticlesByTag(ctx, "even", 10, 20); len(articles) != 0 {
+		t.Errorf("LoadArticlesByTag() past the end = %v, want none", articles)
+	}
+
+	want := []article.TagCount{{Name: "even", Count: 5}, {Name: "lorem-ipsum", Count: 10}, {Name: "montesquieu", Count: 1}}
+	if got, _ := ms.ListTags(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListTags() = %v, want %v", got, want)
+	}
+}
+
+func TestMockStore_Search(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	// the title counts more, so the matching article goes first
+	a, _ := ms.GetArticleByID(ctx, 5)
+	a.Title = "Lorem"
+	a.Source = "Lorem ipsum dolor sit amet"
+	if err := ms.EditArticle(ctx, a, 1); err != nil {
+		t.Fatalf("EditArticle() error = %v", err)
+	}
+
+	if num, _ := ms.GetSearchResultNumber(ctx, "LOREM, ipsum"); num != 10 {
+		t.Errorf("GetSearchResultNumber() = %v, want 10", num)
+	}
+	results, _ := ms.SearchArticles(ctx, "lorem ipsum", 0, 2)
+	if len(results) != 2 || results[0].ID != 5 || results[1].ID != 2 {
+		t.Fatalf("SearchArticles() = %v, want articles 5 and 2", results)
+	}
+	if results[1].Snippet != "<mark>Lorem</mark> <mark>ipsum</mark> dolor sit amet" {
+		t.Errorf("SearchArticles() snippet = %#v", results[1].Snippet)
+	}
+
+	// all words have to match
+	if num, _ := ms.GetSearchResultNumber(ctx, "lorem montesquieu"); num != 0 {
+		t.Errorf("GetSearchResultNumber() = %v, want 0", num)
+	}
+	if results, _ := ms.SearchArticles(ctx, "  ", 0, 10); len(results) != 0 {
+		t.Errorf("SearchArticles() with an empty query = %v, want none", results)
+	}
+}
+
+func TestMockStore_Comments(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	ms.AddUser(ctx, "Commenter", "commenter", "")
+	userID, _ := ms.GetUserID(ctx, "commenter")
+
+	add := func(c comments.Comment) error {
+		c.UnsafeContent = "<b>hi</b>"
+		return ms.AddComment(ctx, c)
+	}
+	if err := add(comments.Comment{ArticleID: 2, UserID: userID, Status: comments.Visible}); err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+	add(comments.Comment{ArticleID: 2, ParentID: 1, Name: "Anonymous", Status: comments.Pending})
+	add(comments.Comment{ArticleID: 2, ParentID: 1, Name: "Reader", Status: comments.Visible})
+
+	invalid := []comments.Comment{
+		{ArticleID: 250604, Status: comments.Visible},
+		{ArticleID: 2, UserID: 250604, Status: comments.Visible},
+		{ArticleID: 3, ParentID: 1, Status: comments.Visible},
+	}
+	for _, c := range invalid {
+		if err := add(c); !errors.Is(err, store.ErrInvalidInput) {
+			t.Errorf("AddComment(%v) error = %v, want ErrInvalidInput", c, err)
+		}
+	}
+
+	// pending comments aren't listed under the article
+	if num, _ := ms.GetCommentNumber(ctx, 2); num != 2 {
+		t.Errorf("GetCommentNumber() = %v, want 2", num)
+	}
+	pending, _ := ms.ListCommentsByStatus(ctx, comments.Pending, 0, 10)
+	if len(pending) != 1 || pending[0].ID != 2 {
+		t.Fatalf("ListCommentsByStatus() = %v, want comment 2", pending)
+	}
+
+	ms.SetCommentStatus(ctx, 2, comments.Visible)
+	ms.SetCommentStatus(ctx, 3, comments.Hidden)
+	cs, _ := ms.ListComments(ctx, 2, 0, 10)
+	if len(cs) != 2 || cs[0].ID != 1 || cs[1].ID != 2 {
+		t.Fatalf("ListComments() = %v, want comments 1 and 2", cs)
+	}
+	if cs[0].Name != "Commenter" {
+		t.Errorf("ListComments() didn't fill in the name of the user")
+	}
+
+	// the reply stays after its parent is removed
+	if err := ms.RemoveComment(ctx, 1); err != nil {
+		t.Fatalf("RemoveComment() error = %v", err)
+	}
+	if c, err := ms.GetComment(ctx, 2); err != nil || c.ParentID != 0 {
+		t.Errorf("GetComment(2) = %v, %v; want a comment which isn't a reply", c, err)
+	}
+	if err := ms.RemoveComment(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RemoveComment() of a removed comment error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMockStore_Articles(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{PreviewLength: 2}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	a := article.Article{Title: "Article 2", AuthorID: 1, Status: article.Draft, Timestamp: 1,
+		Source: "one two three", Tags: []string{"b", "a"}}
+	id, err := ms.AddArticle(ctx, a, 1)
+	if err != nil || id != 101 {
+		t.Fatalf("AddArticle() = %v, %v; want 101", id, err)
+	}
+	got, _ := ms.GetArticleByID(ctx, id)
+	if got.Slug != "article-2-2" || got.Content != "<p>one two three</p>\n" || !reflect.DeepEqual(got.Tags, []string{"a", "b"}) {
+		t.Errorf("GetArticleByID() = %#v", got)
+	}
+	if revisions, _ := ms.ListRevisions(ctx, id, 0, 10); len(revisions) != 1 {
+		t.Errorf("AddArticle() saved %v revisions, want 1", len(revisions))
+	}
+
+	invalid := []article.Article{
+		{Title: "No author", AuthorID: 42, Status: article.Draft},
+		{Title: "No status", AuthorID: 1},
+	}
+	for _, a := range invalid {
+		if _, err := ms.AddArticle(ctx, a, 1); !errors.Is(err, store.ErrInvalidInput) {
+			t.Errorf("AddArticle(%v) error = %v, want ErrInvalidInput", a.Title, err)
+		}
+	}
+
+	// drafts aren't listed, but their previews are shown in the admin panel
+	if num, _ := ms.GetArticleNumber(ctx); num != 11 {
+		t.Errorf("GetArticleNumber() = %v, want 11", num)
+	}
+	all, _ := ms.LoadAllArticlesSortedByLatest(ctx, 11, 100)
+	if len(all) != 1 || all[0].Content != "<p>one two…</p>" {
+		t.Errorf("LoadAllArticlesSortedByLatest() = %#v, want the preview of the draft", all)
+	}
+
+	// the old slug still leads to the article
+	got.Slug = "renamed"
+	got.Status = article.Scheduled
+	if err := ms.EditArticle(ctx, got, 1); err != nil {
+		t.Fatalf("EditArticle() error = %v", err)
+	}
+	if byOld, err := ms.GetArticleBySlug(ctx, "article-2-2"); err != nil || byOld.ID != id {
+		t.Errorf("GetArticleBySlug() of the old slug = %v, %v", byOld.ID, err)
+	}
+
+	if num, _ := ms.PublishScheduledArticles(ctx, time.Unix(1, 0)); num != 1 {
+		t.Errorf("PublishScheduledArticles() = %v, want 1", num)
+	}
+	if latest, _ := ms.LoadArticlesSortedByLatest(ctx, 11, 12); len(latest) != 1 || latest[0].ID != id {
+		t.Errorf("LoadArticlesSortedByLatest() = %v, want the published article last", latest)
+	}
+
+	// everything belonging to the article is removed with it
+	ms.AddComment(ctx, comments.Comment{ArticleID: id, Status: comments.Visible})
+	if err := ms.RemoveArticle(ctx, id); err != nil {
+		t.Fatalf("RemoveArticle() error = %v", err)
+	}
+	if _, err := ms.GetArticleBySlug(ctx, "article-2-2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetArticleBySlug() of a removed article error = %v, want ErrNotFound", err)
+	}
+	if revisions, _ := ms.ListRevisions(ctx, id, 0, 10); len(revisions) != 0 || len(ms.comments) != 0 {
+		t.Errorf("RemoveArticle() left %v revisions and %v comments", len(revisions), len(ms.comments))
+	}
+	if err := ms.RemoveArticle(ctx, id); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RemoveArticle() of a removed article error = %v, want ErrNotFound", err)
+	}
+
+	// IDs aren't reused
+	if id, _ := ms.AddArticle(ctx, a, 1); id != 102 {
+		t.Errorf("AddArticle() after RemoveArticle() = %v, want 102", id)
+	}
+}
+
+func TestMockStore_Authors(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	ms.AddUser(ctx, "Writer", "writer", "")
+	userID, _ := ms.GetUserID(ctx, "writer")
+
+	// users aren't authors until they're linked to one
+	if _, err := ms.GetAuthor(ctx, userID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAuthor() of a user without an author error = %v, want ErrNotFound", err)
+	}
+	if err := ms.AddAuthor(ctx, userID, "Pen Name"); err != nil {
+		t.Fatalf("AddAuthor() error = %v", err)
+	}
+	if err := ms.AddAuthor(ctx, userID, "Second"); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("AddAuthor() of a linked user error = %v, want ErrConflict", err)
+	}
+	if err := ms.AddAuthor(ctx, 424242, "Nobody"); !errors.Is(err, store.ErrInvalidInput) {
+		t.Errorf("AddAuthor() of an unknown user error = %v, want ErrInvalidInput", err)
+	}
+	a, err := ms.GetAuthor(ctx, userID)
+	if err != nil || a.AuthorID != 2 || a.AuthorName != "Pen Name" {
+		t.Fatalf("GetAuthor() = %v, %v", a, err)
+	}
+	if authors, _ := ms.ListAuthors(ctx, 0, 100); len(authors) != 2 || authors[1].Login != "writer" {
+		t.Errorf("ListAuthors() = %v, want the mock author and the writer", authors)
+	}
+
+	// neither can be removed while they're in use
+	if err := ms.RemoveUser(ctx, userID); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("RemoveUser() of a linked user error = %v, want ErrConflict", err)
+	}
+	if err := ms.RemoveAuthor(ctx, 1); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("RemoveAuthor() of an author with articles error = %v, want ErrConflict", err)
+	}
+
+	if err := ms.LinkAuthor(ctx, a.AuthorID, 0); err != nil {
+		t.Fatalf("LinkAuthor() error = %v", err)
+	}
+	if _, err := ms.GetAuthor(ctx, userID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAuthor() of an unlinked user error = %v, want ErrNotFound", err)
+	}
+	if err := ms.RemoveUser(ctx, userID); err != nil {
+		t.Errorf("RemoveUser() error = %v", err)
+	}
+	if err := ms.RemoveAuthor(ctx, a.AuthorID); err != nil {
+		t.Errorf("RemoveAuthor() error = %v", err)
+	}
+	if _, err := ms.GetAuthorByID(ctx, a.AuthorID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAuthorByID() of a removed author error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMockStore_Users(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	for _, login := range []string{"a", "b", "c"} {
+		ms.AddUser(ctx, login, login, "secret")
+	}
+
+	if list, _ := ms.ListUsers(ctx, 2, 100); len(list) != 2 || list[0].Login != "b" || list[0].Password != "" {
+		t.Errorf("ListUsers() = %#v, want users b and c without passwords", list)
+	}
+	if list, _ := ms.ListUsers(ctx, 100, 200); len(list) != 0 {
+		t.Errorf("ListUsers() past the end = %v, want none", list)
+	}
+
+	// users stay sorted and their IDs aren't reused
+	ms.AddSession(ctx, users.Session{ID: 1, UserID: 3, ValidUntil: time.Now().Add(time.Hour)})
+	if err := ms.RemoveUser(ctx, 3); err != nil {
+		t.Fatalf("RemoveUser() error = %v", err)
+	}
+	if _, err := ms.GetSession(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RemoveUser() didn't remove the sessions of the user")
+	}
+	ms.AddUser(ctx, "d", "d", "")
+	if list, _ := ms.ListUsers(ctx, 0, 100); len(list) != 4 || list[2].Login != "c" || list[3].ID != 5 {
+		t.Errorf("ListUsers() = %v, want users 1, 2, 4 and 5", list)
+	}
+	if err := ms.EditUser(ctx, users.User{ID: 2, Login: "c"}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("EditUser() to a taken login error = %v, want ErrConflict", err)
+	}
+}
+
+func TestMockStore_Concurrency(t *testing.T) {
+	ms := &Store{}
+	if err := ms.Init(func() {}, store.StoreConfig{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := ms.Seed(); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	done := make(chan bool)
+	for i := 0; i < 10; i++ {
+		go func() {
+			a := article.Article{Title: "Same", AuthorID: 1, Status: article.Published}
+			ms.AddArticle(ctx, a, 1)
+			ms.LoadArticlesSortedByLatest(ctx, 0, 5)
+			ms.SearchArticles(ctx, "same", 0, 5)
+			done <- true
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+
+	// every article got its own ID and slug
+	if num, _ := ms.GetArticleNumber(ctx); num != 21 {
+		t.Errorf("GetArticleNumber() = %v, want 21", num)
+	}
+	if _, err := ms.GetArticleBySlug(ctx, "same-10"); err != nil {
+		t.Errorf("GetArticleBySlug(\"same-10\") error = %v", err)
+	}
+}
@@ -4,96 +4,185 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/david-sorm/montesquieu/article/render"
 	"github.com/david-sorm/montesquieu/store"
-	pgx "github.com/jackc/pgx/v4/pgxpool"
-	"regexp"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// pgx connection pool
-var pool *pgx.Pool
+// the timeout of a single query, unless it's set in StoreConfig
+const defaultTimeout = 5 * time.Second
 
-// this is global context for this store, if this store is shutting down, the
-// context is shutting down too
-// it's used as a parent to all other contexts
-var ctx context.Context
-var ctxCancelFunc context.CancelFunc
+// the schema of the tables, unless it's set in StoreConfig
+const defaultSchema = "montesquieu"
 
-// Init implements Store's Init function
-func (p *Store) Init(f func(), cfg store.StoreConfig) error {
-	p.ArticlesPerIndexPage = cfg.ArticlesPerIndexPage
-	ctx, ctxCancelFunc = context.WithCancel(context.Background())
+// the most connections kept open, unless it's set in StoreConfig
+const defaultMaxConns = 20
 
-	err := dbInit(cfg.Host, cfg.Database, cfg.Username, cfg.Password, cfg.Port)
+// how long connecting can take and how long to wait before the first retry,
+// unless they're set in StoreConfig
+const defaultConnectTimeout = 30 * time.Second
+const defaultRetryBackoff = time.Second
 
-	if err != nil {
+// Init implements Store's Init function
+func (p *Store) Init(f func(), cfg store.StoreConfig) error {
+	p.configure(cfg)
+	if err := p.dbInit(cfg); err != nil {
 		return err
 	}
-	return nil
+	return p.migrate(p.ctx)
 }
 
-// Close implements Store's Close (future) function
-func (p *Store) Close() {
-	ctxCancelFunc()
+// PendingMigrations implements Migrator's PendingMigrations function
+func (p *Store) PendingMigrations(cfg store.StoreConfig) ([]store.Migration, error) {
+	p.configure(cfg)
+	// the Store is only used to list the migrations, so it's closed right away
+	defer p.Close()
+	if err := p.dbInit(cfg); err != nil {
+		return nil, err
+	}
+	return p.pendingMigrations(p.ctx)
 }
 
-// prepares the db for operation
-func dbInit(host string, db string, user string, password string, port string) error {
+// Close implements Store's Close function
+// Queries which are still running are cancelled
+func (p *Store) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	// the pool is nil if Init couldn't connect
+	if p.pool != nil {
+		p.pool.Close()
+	}
+	return nil
+}
 
-	// make a new connection pool
-	// TODO connection timeout
-	var err error
-	c, _ := context.WithCancel(ctx)
+// configure copies the config into the Store, using defaults for whatever
+// isn't set
+func (p *Store) configure(cfg store.StoreConfig) {
+	p.ArticlesPerIndexPage = cfg.ArticlesPerIndexPage
+	p.Timeout = cfg.Timeout
+	if p.Timeout <= 0 {
+		p.Timeout = defaultTimeout
+	}
+	p.Renderer = cfg.Renderer
+	if p.Renderer == nil {
+		p.Renderer = render.Markdown{}
+	}
+	p.PreviewLength = cfg.PreviewLength
+	if p.PreviewLength == 0 {
+		p.PreviewLength = render.DefaultPreviewLength
+	}
+	p.Schema = cfg.Schema
+	if p.Schema == "" {
+		p.Schema = defaultSchema
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+}
 
-	// use the default port if it's undefined by the user
-	if port == "" {
-		port = "5432"
+// connects to the db, the schema is brought up to date by migrate
+// connecting is retried until cfg.ConnectTimeout runs out, since postgres might
+// still be starting up
+func (p *Store) dbInit(cfg store.StoreConfig) error {
+	connString, err := connString(cfg)
+	if err != nil {
+		return err
 	}
-	dsn := fmt.Sprintf("user=%v password=%v host=%v dbname=%v port=%v pool_max_conns=20",
-		user, password, host, db, port)
-	config, err := pgx.ParseConfig(dsn)
+	config, err := pgxpool.ParseConfig(connString)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("invalid postgres connection settings: %w", err)
 	}
+	// every connection only sees the tables in the schema of this Store
+	config.ConnConfig.RuntimeParams["search_path"] = pgx.Identifier{p.Schema}.Sanitize()
 
-	err = errors.New("")
-
-	// up to 30 seconds timeout for postgres
-	maxCount := 30
-	for count := 1; count <= maxCount; count++ {
-		fmt.Println()
-		connectionContext, cancel := context.WithTimeout(c, 5*time.Second)
-		defer cancel()
-		pool, err = pgx.ConnectConfig(connectionContext, config)
-		if err != nil {
-			fmt.Printf("\rConnecting to postgres... (%v/%v)", count, maxCount)
-			count++
-		} else {
-			break
-		}
-		time.Sleep(1 * time.Second)
+	timeout := cfg.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
 	}
-	if err != nil {
-		return errors.New("Postgres connection timeout exceeded.")
+	backoff := cfg.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
 	}
+	connectCtx, cancel := context.WithTimeout(p.ctx, timeout)
+	defer cancel()
 
-	// execute the 'startup' stmt
-	_, err = pool.Exec(returnConnectionCtx(), stmtStartup)
-	if err != nil {
-		// check if its an actual error or just "schema already exists"
-		if matched, _ := regexp.Match(".*\\(SQLSTATE 42P06\\)", []byte(err.Error())); matched {
-			fmt.Println("Schema in database exists, let's assume it's correct...")
+	for {
+		p.pool, err = pgxpool.ConnectConfig(connectCtx, config)
+		if err == nil {
 			return nil
 		}
-		return err
+		fmt.Printf("Connecting to postgres failed, retrying in %v: %v\n", backoff, err)
+
+		select {
+		case <-connectCtx.Done():
+			return fmt.Errorf("couldn't connect to postgres within %v: %w", timeout, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
 	}
+}
 
-	fmt.Println("Created new schema on Postgres server.")
-	return nil
+// connString returns the connection string of cfg, either cfg.URL or a URL made
+// of the host, database and login, together with the TLS and pool settings
+func connString(cfg store.StoreConfig) (string, error) {
+	u := &url.URL{Scheme: "postgres"}
+	if cfg.URL != "" {
+		var err error
+		u, err = url.Parse(cfg.URL)
+		if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			return "", errors.New("the postgres connection string has to be a postgres:// URL")
+		}
+	} else {
+		// use the default port if it's undefined by the user
+		port := cfg.Port
+		if port == "" {
+			port = "5432"
+		}
+		u.User = url.User(cfg.Username)
+		if cfg.Password != "" {
+			u.User = url.UserPassword(cfg.Username, cfg.Password)
+		}
+		u.Path = "/" + cfg.Database
+		if strings.HasPrefix(cfg.Host, "/") {
+			// unix sockets don't fit into the host of a URL
+			u.Host = ":" + port
+			q := u.Query()
+			q.Set("host", cfg.Host)
+			u.RawQuery = q.Encode()
+		} else {
+			u.Host = net.JoinHostPort(cfg.Host, port)
+		}
+	}
+
+	q := u.Query()
+	set := func(key string, value string) {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	set("sslmode", cfg.SSLMode)
+	set("sslrootcert", cfg.SSLRootCert)
+	set("sslcert", cfg.SSLCert)
+	set("sslkey", cfg.SSLKey)
+	if cfg.MinConns != 0 {
+		set("pool_min_conns", strconv.FormatUint(cfg.MinConns, 10))
+	}
+	if cfg.MaxConns != 0 {
+		set("pool_max_conns", strconv.FormatUint(cfg.MaxConns, 10))
+	} else if q.Get("pool_max_conns") == "" {
+		set("pool_max_conns", strconv.Itoa(defaultMaxConns))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
 }
 
-// returns context for every connection
-func returnConnectionCtx() context.Context {
-	r, _ := context.WithTimeout(ctx, 5*time.Second)
-	return r
+// withTimeout returns a context for a single query, which is cancelled either
+// when the parent is cancelled or when the query takes too long
+func (p *Store) withTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, p.Timeout)
 }
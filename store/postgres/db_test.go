@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"testing"
+)
+
+func TestConnString(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     store.StoreConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "password with spaces",
+			cfg:  store.StoreConfig{Host: "db", Database: "blog", Username: "me", Password: "correct horse's"},
+			want: "postgres://me:correct%20horse%27s@db:5432/blog?pool_max_conns=20",
+		},
+		{
+			name: "unix socket",
+			cfg:  store.StoreConfig{Host: "/run/postgresql", Database: "blog", Username: "me", Port: "5433"},
+			want: "postgres://me@:5433/blog?host=%2Frun%2Fpostgresql&pool_max_conns=20",
+		},
+		{
+			name: "url with settings",
+			cfg: store.StoreConfig{URL: "postgresql://me@db/blog?pool_max_conns=5", SSLMode: "verify-full",
+				SSLRootCert: "/ca.pem", MinConns: 2},
+			want: "postgresql://me@db/blog?pool_max_conns=5&pool_min_conns=2&sslmode=verify-full&sslrootcert=%2Fca.pem",
+		},
+		{
+			name: "max conns override the url",
+			cfg:  store.StoreConfig{URL: "postgres://db/blog?pool_max_conns=5", MaxConns: 50},
+			want: "postgres://db/blog?pool_max_conns=50",
+		},
+		{
+			name:    "not a url",
+			cfg:     store.StoreConfig{URL: "host=db dbname=blog"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := connString(tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: connString() returned %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v: connString() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// pgx has to understand the password and the settings
+	got, _ := connString(store.StoreConfig{Host: "db", Database: "blog", Username: "me", Password: "a b'c",
+		SSLMode: "disable", MinConns: 2, MaxConns: 8})
+	config, err := pgxpool.ParseConfig(got)
+	if err != nil {
+		t.Fatalf("ParseConfig() returned %v", err)
+	}
+	if config.ConnConfig.Password != "a b'c" || config.MinConns != 2 || config.MaxConns != 8 || config.ConnConfig.TLSConfig != nil {
+		t.Errorf("ParseConfig() of %v = %+v", got, config)
+	}
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"strings"
+)
+
+// wrapError converts errors returned by pgx into errors of the Store
+// stmt is the statement which has caused the error, activity describes what we
+// were doing
+func wrapError(stmt string, activity string, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		// not an error reported by postgres, so it's most likely a connection
+		// problem or a timeout
+		return store.NewError(store.ErrUnavailable, activity, err)
+	}
+
+	switch {
+	// unique_violation
+	case pgErr.Code == "23505":
+		return store.NewError(store.ErrConflict, activity, err)
+
+	// foreign_key_violation
+	case pgErr.Code == "23503":
+		// if we're deleting something, it's still referenced by something else,
+		// otherwise we're referencing something which doesn't exist
+		if strings.HasPrefix(strings.TrimSpace(stmt), "delete") {
+			return store.NewError(store.ErrConflict, activity, err)
+		}
+		return store.NewError(store.ErrInvalidInput, activity, err)
+
+	// not_null_violation, check_violation and data exceptions
+	case pgErr.Code == "23502" || pgErr.Code == "23514" || strings.HasPrefix(pgErr.Code, "22"):
+		return store.NewError(store.ErrInvalidInput, activity, err)
+	}
+
+	return store.NewError(store.ErrUnavailable, activity, err)
+}
@@ -1,35 +1,60 @@
 package postgres
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/article/render"
+	"github.com/david-sorm/montesquieu/comments"
 	"github.com/david-sorm/montesquieu/store"
 	"github.com/david-sorm/montesquieu/users"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"html/template"
+	"time"
 )
 
 // Postgres implementation of Store
 type Store struct {
 	ArticlesPerIndexPage uint64
+
+	// how long a single query can take
+	Timeout time.Duration
+
+	// renders the source of articles into HTML
+	Renderer render.Renderer
+
+	// how many words long the generated previews are
+	PreviewLength uint64
+
+	// the schema all tables are in, several Stores can share a database as long
+	// as their schemas differ
+	Schema string
+
+	// pgx connection pool
+	pool *pgxpool.Pool
+
+	// the context of the Store, it's cancelled once the Store is closed
+	// it's used as a parent to all other contexts
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // The comments are here to please code quality analysis tools.
 // They are absolutely useless.
 
 // IsAdmin implements Store's IsAdmin function
-func (p *Store) IsAdmin(id uint64) bool {
-	rows, err := pool.Query(returnConnectionCtx(), stmtIsAdmin, id)
-
-	if err != nil {
-		fmt.Println("[Postgres Store] An error has happened while checking if the user is an admin:", err)
-	}
+func (p *Store) IsAdmin(ctx context.Context, id uint64) (bool, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	var count uint8
-	for rows.Next() {
-		rows.Scan(&count)
+	err := p.pool.QueryRow(ctx, stmtIsAdmin, id).Scan(&count)
+	if err != nil {
+		return false, wrapError(stmtIsAdmin, "checking if the user is an admin", err)
 	}
-	rows.Close()
-	return count == 1
+	return count == 1, nil
 }
 
 // Info implements Store's Info function
@@ -41,287 +66,923 @@ func (p *Store) Info() store.StoreInfo {
 }
 
 // ListUsers implements Store's ListUsers function
-func (p *Store) ListUsers(from uint64, to uint64) []users.User {
-	rows, err := pool.Query(returnConnectionCtx(), stmtListUsers, from, to)
+func (p *Store) ListUsers(ctx context.Context, from uint64, to uint64) ([]users.User, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
+	const activity = "listing users"
+	rows, err := p.pool.Query(ctx, stmtListUsers, from, rowLimit(from, to))
 	if err != nil {
-		fmt.Println("[Postgres Store] An error has happened while listing users:", err)
+		return nil, wrapError(stmtListUsers, activity, err)
 	}
+	defer rows.Close()
 
 	us := make([]users.User, 0, 0)
 	for rows.Next() {
 		u := users.User{}
-		rows.Scan(&u.ID, &u.DisplayName, &u.Login)
+		var role string
+		if err := rows.Scan(&u.ID, &u.DisplayName, &u.Login, &role); err != nil {
+			return nil, wrapError(stmtListUsers, activity, err)
+		}
+		u.Role = users.Role(role)
 		us = append(us, u)
 	}
-	rows.Close()
-	return us
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListUsers, activity, err)
+	}
+	return us, nil
 }
 
 // GetUserID implements Store's GetUserID function
-func (p *Store) GetUserID(login string) (uint64, bool) {
-	rows, err := pool.Query(returnConnectionCtx(), stmtGetUserID, login)
+func (p *Store) GetUserID(ctx context.Context, login string) (uint64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
+	var id uint64
+	err := p.pool.QueryRow(ctx, stmtGetUserID, login).Scan(&id)
 	if err != nil {
-		fmt.Println("[Postgres Store] An error has happened while getting user's id:", err)
-	}
-
-	defer rows.Close()
-	if rows.Next() {
-		// if there are any rows
-		var id uint64
-		rows.Scan(&id)
-		return id, true
-	} else {
-		// if there aren't any rows
-		return 0, false
+		return 0, wrapError(stmtGetUserID, "getting user's id", err)
 	}
+	return id, nil
 }
 
 // GetUser implements Store's GetUser function
-func (p *Store) GetUser(id uint64) users.User {
-	rows, err := pool.Query(returnConnectionCtx(), stmtGetUser, id)
-
-	if err != nil {
-		fmt.Println("[Postgres Store] An error has happened while getting a user:", err)
-	}
+func (p *Store) GetUser(ctx context.Context, id uint64) (users.User, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	u := users.User{}
-	for rows.Next() {
-		rows.Scan(&u.ID, &u.DisplayName, &u.Login, &u.Password)
+	var role string
+	err := p.pool.QueryRow(ctx, stmtGetUser, id).Scan(&u.ID,
+		&u.DisplayName, &u.Login, &u.Password, &role)
+	if err != nil {
+		return users.User{}, wrapError(stmtGetUser, "getting a user", err)
 	}
-	rows.Close()
-	return u
+	u.Role = users.Role(role)
+	return u, nil
 }
 
 // ListAuthors implements Store's ListAuthors function
-func (p *Store) ListAuthors(from uint64, to uint64) []users.Author {
-	rows, err := pool.Query(returnConnectionCtx(), stmtListAuthors, from, to)
+func (p *Store) ListAuthors(ctx context.Context, from uint64, to uint64) ([]users.Author, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
+	const activity = "listing authors"
+	rows, err := p.pool.Query(ctx, stmtListAuthors, from, rowLimit(from, to))
 	if err != nil {
-		fmt.Println("[Postgres Store] An error has happened while listing authors:", err)
+		return nil, wrapError(stmtListAuthors, activity, err)
 	}
+	defer rows.Close()
 
 	authors := make([]users.Author, 0, 0)
 	for rows.Next() {
 		a := users.Author{}
-		rows.Scan(&a.ID, &a.DisplayName, &a.Login, &a.AuthorID, &a.AuthorName)
+		if err := rows.Scan(&a.ID, &a.DisplayName, &a.Login, &a.AuthorID, &a.AuthorName); err != nil {
+			return nil, wrapError(stmtListAuthors, activity, err)
+		}
 		authors = append(authors, a)
 	}
-	rows.Close()
-	return authors
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListAuthors, activity, err)
+	}
+	return authors, nil
 }
 
 // ListAdmins implements Store's ListAdmins function
-func (p *Store) ListAdmins(from uint64, to uint64) []users.User {
-	rows, err := pool.Query(returnConnectionCtx(), stmtListAdmins, from, to)
+func (p *Store) ListAdmins(ctx context.Context, from uint64, to uint64) ([]users.User, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
+	const activity = "listing admins"
+	rows, err := p.pool.Query(ctx, stmtListAdmins, from, rowLimit(from, to))
 	if err != nil {
-		fmt.Println("[Postgres Store] An error has happened while listing admins:", err)
+		return nil, wrapError(stmtListAdmins, activity, err)
 	}
+	defer rows.Close()
 
 	admins := make([]users.User, 0, 0)
 	for rows.Next() {
 		u := users.User{}
-		rows.Scan(&u.ID, &u.DisplayName, &u.Login)
+		var role string
+		if err := rows.Scan(&u.ID, &u.DisplayName, &u.Login, &role); err != nil {
+			return nil, wrapError(stmtListAdmins, activity, err)
+		}
+		u.Role = users.Role(role)
 		admins = append(admins, u)
 	}
-	rows.Close()
-
-	return admins
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListAdmins, activity, err)
+	}
+	return admins, nil
 }
 
 // LoadArticlesSortedByLatest implements Store's LoadArticlesSortedByLatest function
-func (p *Store) LoadArticlesSortedByLatest(from uint64, to uint64) []article.Article {
-	rows, err := pool.Query(returnConnectionCtx(), stmtLoadArticlesSortedByNewest, from, to)
+func (p *Store) LoadArticlesSortedByLatest(ctx context.Context, from uint64, to uint64) ([]article.Article, error) {
+	return p.loadArticles(ctx, stmtLoadArticlesSortedByNewest, from, to)
+}
+
+// LoadAllArticlesSortedByLatest implements Store's LoadAllArticlesSortedByLatest function
+func (p *Store) LoadAllArticlesSortedByLatest(ctx context.Context, from uint64, to uint64) ([]article.Article, error) {
+	return p.loadArticles(ctx, stmtLoadAllArticlesSortedByNewest, from, to)
+}
+
+// LoadArticlesByAuthor implements Store's LoadArticlesByAuthor function
+func (p *Store) LoadArticlesByAuthor(ctx context.Context, authorId uint64, from uint64, to uint64) ([]article.Article, error) {
+	return p.loadArticles(ctx, stmtLoadArticlesByAuthor, from, to, authorId)
+}
+
+// LoadArticlesByTag implements Store's LoadArticlesByTag function
+func (p *Store) LoadArticlesByTag(ctx context.Context, tag string, from uint64, to uint64) ([]article.Article, error) {
+	return p.loadArticles(ctx, stmtLoadArticlesByTag, from, to, tag)
+}
+
+// loadArticles loads articles sorted by latest using stmt, 'from' and 'to'
+// work the same way as in LoadArticlesSortedByLatest, args are passed to stmt
+// after them
+func (p *Store) loadArticles(ctx context.Context, stmt string, from uint64, to uint64,
+	args ...interface{}) ([]article.Article, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "loading articles"
+	rows, err := p.pool.Query(ctx, stmt, append([]interface{}{from, rowLimit(from, to)}, args...)...)
 	if err != nil {
-		fmt.Println("An error has happened while loading articles for index:", err.Error())
-		return []article.Article{}
+		return nil, wrapError(stmt, activity, err)
 	}
+	defer rows.Close()
 
 	articles := make([]article.Article, 0, p.ArticlesPerIndexPage)
+	for rows.Next() {
+		a, err := scanListedArticle(rows)
+		if err != nil {
+			return nil, wrapError(stmt, activity, err)
+		}
+		articles = append(articles, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+
+	return articles, nil
+}
+
+// rowLimit converts 'from' and 'to' of Store's functions into the limit of a
+// statement, which skips 'from' rows itself
+func rowLimit(from uint64, to uint64) uint64 {
+	if to < from {
+		return 0
+	}
+	return to - from
+}
+
+// scans the articleListColumns of a row, the rest of the columns is scanned
+// into dest
+func scanListedArticle(row pgx.Row, dest ...interface{}) (article.Article, error) {
 	var title string
-	var articleId int32
+	var articleId uint64
+	var slug string
 	var authorId uint64
 	var htmlPreview string
 	var timestamp int64
+	var status string
+	var tags []string
+
+	err := row.Scan(append([]interface{}{&title, &articleId, &slug, &authorId, &htmlPreview,
+		&timestamp, &status, &tags}, dest...)...)
+	return article.Article{
+		Title:     title,
+		ID:        articleId,
+		Slug:      slug,
+		AuthorID:  authorId,
+		Timestamp: uint64(timestamp),
+		Content:   template.HTML(htmlPreview),
+		Status:    article.Status(status),
+		Tags:      tags,
+	}, err
+}
+
+// options of the snippets made by ts_headline
+var headlineOptions = fmt.Sprintf("StartSel=%v, StopSel=%v, MaxWords=%v, MinWords=%v",
+	article.HighlightStart, article.HighlightStop, article.SnippetLength, article.SnippetLength/2)
+
+// SearchArticles implements Store's SearchArticles function
+func (p *Store) SearchArticles(ctx context.Context, query string, from uint64, to uint64) ([]article.SearchResult, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
+	const activity = "searching articles"
+	rows, err := p.pool.Query(ctx, stmtSearchArticles, from, rowLimit(from, to), query, headlineOptions)
+	if err != nil {
+		return nil, wrapError(stmtSearchArticles, activity, err)
+	}
+	defer rows.Close()
+
+	results := make([]article.SearchResult, 0, 0)
 	for rows.Next() {
-		rows.Scan(&title, &articleId, &authorId, &htmlPreview, &timestamp)
-		articles = append(articles, article.Article{
-			Title:     title,
-			ID:        uint64(articleId),
-			AuthorID:  authorId,
-			Timestamp: uint64(timestamp),
-			Content:   template.HTML(htmlPreview),
-		})
+		var snippet string
+		a, err := scanListedArticle(rows, &snippet)
+		if err != nil {
+			return nil, wrapError(stmtSearchArticles, activity, err)
+		}
+		results = append(results, article.SearchResult{Article: a, Snippet: article.Highlight(snippet)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtSearchArticles, activity, err)
 	}
-	rows.Close()
+	return results, nil
+}
+
+// GetSearchResultNumber implements Store's GetSearchResultNumber function
+func (p *Store) GetSearchResultNumber(ctx context.Context, query string) (uint64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	return articles
+	count := uint64(0)
+	err := p.pool.QueryRow(ctx, stmtSearchResultNumber, query).Scan(&count)
+	if err != nil {
+		return 0, wrapError(stmtSearchResultNumber, "getting the number of search results", err)
+	}
+	return count, nil
 }
 
 // AddArticle implements Store's AddArticle function
-func (p *Store) AddArticle(title string, authorId uint64, timestamp uint64,
-	content template.HTML) {
-	doExec(stmtNewArticle, "adding an article", title, authorId, content,
-		content, timestamp)
+func (p *Store) AddArticle(ctx context.Context, a article.Article, userId uint64) (uint64, error) {
+	const activity = "adding an article"
+	content, preview, err := render.RenderArticle(p.Renderer, a.Source, a.Summary, p.PreviewLength)
+	if err != nil {
+		return 0, store.NewError(store.ErrInvalidInput, activity, err)
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, wrapError("", activity, err)
+	}
+	defer tx.Rollback(ctx)
+
+	slug, err := uniqueSlug(ctx, tx, a)
+	if err != nil {
+		return 0, wrapError(stmtSlugTaken, activity, err)
+	}
+	err = tx.QueryRow(ctx, stmtNewArticle, a.Title, a.AuthorID, a.Source, a.Summary,
+		string(content), string(preview), a.Timestamp, slug, string(a.Status)).Scan(&a.ID)
+	if err != nil {
+		return 0, wrapError(stmtNewArticle, activity, err)
+	}
+
+	if _, err := tx.Exec(ctx, stmtAddArticleTags, a.ID, a.Tags); err != nil {
+		return 0, wrapError(stmtAddArticleTags, activity, err)
+	}
+
+	// the first version is a revision too
+	_, err = tx.Exec(ctx, stmtAddRevision, a.ID, userId, time.Now().UTC(), a.Title, a.Source, a.Summary)
+	if err != nil {
+		return 0, wrapError(stmtAddRevision, activity, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, wrapError("", activity, err)
+	}
+	return a.ID, nil
 }
 
 // EditArticle implements Store's EditArticle function
-func (p *Store) EditArticle(a article.Article) {
-	doExec(stmtEditArticle, "editing an article", a.Title, a.AuthorID,
-		string(a.Content), string(a.Content), a.Timestamp, a.ID)
+func (p *Store) EditArticle(ctx context.Context, a article.Article, userId uint64) error {
+	const activity = "editing an article"
+	content, preview, err := render.RenderArticle(p.Renderer, a.Source, a.Summary, p.PreviewLength)
+	if err != nil {
+		return store.NewError(store.ErrInvalidInput, activity, err)
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return wrapError("", activity, err)
+	}
+	defer tx.Rollback(ctx)
+
+	old := article.Article{ID: a.ID}
+	var oldSlug string
+	var oldTimestamp int64
+	err = tx.QueryRow(ctx, stmtGetArticleForEdit, a.ID).Scan(&oldSlug, &old.Title, &old.Source,
+		&old.Summary, &oldTimestamp)
+	if err != nil {
+		return wrapError(stmtGetArticleForEdit, activity, err)
+	}
+
+	// articles written before revisions were introduced don't have any, so the
+	// current version has to be saved before it's overwritten
+	var revisions uint64
+	if err := tx.QueryRow(ctx, stmtRevisionNumber, a.ID).Scan(&revisions); err != nil {
+		return wrapError(stmtRevisionNumber, activity, err)
+	}
+	if revisions == 0 {
+		_, err = tx.Exec(ctx, stmtAddRevision, a.ID, 0, time.Unix(oldTimestamp, 0).UTC(),
+			old.Title, old.Source, old.Summary)
+		if err != nil {
+			return wrapError(stmtAddRevision, activity, err)
+		}
+	}
+
+	slug, err := uniqueSlug(ctx, tx, a)
+	if err != nil {
+		return wrapError(stmtSlugTaken, activity, err)
+	}
+
+	_, err = tx.Exec(ctx, stmtEditArticle, a.Title, a.AuthorID, a.Source, a.Summary,
+		string(content), string(preview), a.Timestamp, slug, string(a.Status), a.ID)
+	if err != nil {
+		return wrapError(stmtEditArticle, activity, err)
+	}
+
+	if _, err := tx.Exec(ctx, stmtRemoveArticleTags, a.ID); err != nil {
+		return wrapError(stmtRemoveArticleTags, activity, err)
+	}
+	if _, err := tx.Exec(ctx, stmtAddArticleTags, a.ID, a.Tags); err != nil {
+		return wrapError(stmtAddArticleTags, activity, err)
+	}
+
+	// the old slug keeps working, so links to the article don't break
+	if oldSlug != "" && oldSlug != slug {
+		if _, err := tx.Exec(ctx, stmtAddOldSlug, oldSlug, a.ID); err != nil {
+			return wrapError(stmtAddOldSlug, activity, err)
+		}
+	}
+	// the article might have gotten one of its old slugs back
+	if _, err := tx.Exec(ctx, stmtRemoveOldSlug, slug); err != nil {
+		return wrapError(stmtRemoveOldSlug, activity, err)
+	}
+
+	_, err = tx.Exec(ctx, stmtAddRevision, a.ID, userId, time.Now().UTC(), a.Title, a.Source, a.Summary)
+	if err != nil {
+		return wrapError(stmtAddRevision, activity, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapError("", activity, err)
+	}
+	return nil
+}
+
+// ListRevisions implements Store's ListRevisions function
+func (p *Store) ListRevisions(ctx context.Context, articleId uint64, from uint64, to uint64) ([]article.Revision, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "listing revisions"
+	rows, err := p.pool.Query(ctx, stmtListRevisions, articleId, from, rowLimit(from, to))
+	if err != nil {
+		return nil, wrapError(stmtListRevisions, activity, err)
+	}
+	defer rows.Close()
+
+	revisions := make([]article.Revision, 0, 0)
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, wrapError(stmtListRevisions, activity, err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListRevisions, activity, err)
+	}
+	return revisions, nil
+}
+
+// GetRevision implements Store's GetRevision function
+func (p *Store) GetRevision(ctx context.Context, id uint64) (article.Revision, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	r, err := scanRevision(p.pool.QueryRow(ctx, stmtGetRevision, id))
+	if err != nil {
+		return article.Revision{}, wrapError(stmtGetRevision, "getting a revision", err)
+	}
+	return r, nil
+}
+
+// RestoreRevision implements Store's RestoreRevision function
+func (p *Store) RestoreRevision(ctx context.Context, id uint64, userId uint64) error {
+	r, err := p.GetRevision(ctx, id)
+	if err != nil {
+		return err
+	}
+	a, err := p.GetArticleByID(ctx, r.ArticleID)
+	if err != nil {
+		return err
+	}
+
+	a.Title = r.Title
+	a.Source = r.Source
+	a.Summary = r.Summary
+	return p.EditArticle(ctx, a, userId)
+}
+
+// scans a row of stmtListRevisions or stmtGetRevision
+func scanRevision(row pgx.Row) (article.Revision, error) {
+	r := article.Revision{}
+	err := row.Scan(&r.ID, &r.ArticleID, &r.UserID, &r.UserName, &r.Time, &r.Title, &r.Source, &r.Summary)
+	r.Time = inUTC(r.Time)
+	return r, err
+}
+
+// GetArticleBySlug implements Store's GetArticleBySlug function
+func (p *Store) GetArticleBySlug(ctx context.Context, slug string) (article.Article, error) {
+	queryCtx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var id uint64
+	if err := p.pool.QueryRow(queryCtx, stmtGetArticleIDBySlug, slug).Scan(&id); err != nil {
+		return article.Article{}, wrapError(stmtGetArticleIDBySlug, "getting an article", err)
+	}
+	return p.GetArticleByID(ctx, id)
+}
+
+// queryRower is satisfied both by the pool and by transactions
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// uniqueSlug returns the slug of the article (or the one generated from its
+// title) made unique among all other articles
+func uniqueSlug(ctx context.Context, q queryRower, a article.Article) (string, error) {
+	slug := a.Slug
+	if slug == "" {
+		slug = a.Title
+	}
+	return article.UniqueSlug(article.Slugify(slug), func(slug string) (bool, error) {
+		var count uint64
+		err := q.QueryRow(ctx, stmtSlugTaken, slug, a.ID).Scan(&count)
+		return count > 0, err
+	})
 }
 
 // RemoveArticle implements Store's RemoveArticle function
-func (p *Store) RemoveArticle(id uint64) {
-	doExec(stmtRemoveArticle, "removing an article", id)
+func (p *Store) RemoveArticle(ctx context.Context, id uint64) error {
+	return p.doExec(ctx, stmtRemoveArticle, "removing an article", id)
 }
 
 // AddUser implements Store's AddUser function
-func (p *Store) AddUser(displayName string, login string, password string) {
-	doExec(stmtAddUser, "adding a new user", displayName, login, password)
+func (p *Store) AddUser(ctx context.Context, displayName string, login string, password string) error {
+	return p.doExec(ctx, stmtAddUser, "adding a new user", displayName, login, password)
 }
 
 // EditUser implements Store's EditUser function
-func (p *Store) EditUser(user users.User) {
-	doExec(stmtEditUser, "editing a user", user.DisplayName, user.Login,
+func (p *Store) EditUser(ctx context.Context, user users.User) error {
+	return p.doExec(ctx, stmtEditUser, "editing a user", user.DisplayName, user.Login,
 		user.Password, user.ID)
 }
 
+// SetRole implements Store's SetRole function
+func (p *Store) SetRole(ctx context.Context, userId uint64, role users.Role) error {
+	return p.doExec(ctx, stmtSetRole, "changing the role of a user", string(role), userId)
+}
+
 // RemoveUser implements Store's RemoveUser function
-func (p *Store) RemoveUser(id uint64) {
-	doExec(stmtRemoveUser, "removing a user", id)
+func (p *Store) RemoveUser(ctx context.Context, id uint64) error {
+	return p.doExec(ctx, stmtRemoveUser, "removing a user", id)
 }
 
 // GetAuthor implements Store's GetAuthor function
-func (p *Store) GetAuthor(userId uint64) users.Author {
-	rows, err := pool.Query(returnConnectionCtx(), stmtGetAuthor, userId)
+func (p *Store) GetAuthor(ctx context.Context, userId uint64) (users.Author, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	author := users.Author{}
+	err := p.pool.QueryRow(ctx, stmtGetAuthor, userId).Scan(
+		&author.AuthorID, &author.AuthorName)
 	if err != nil {
-		fmt.Println("[Postgres Store] An error has happened while getting an author:", err)
+		return users.Author{}, wrapError(stmtGetAuthor, "getting an author", err)
 	}
+	return author, nil
+}
+
+// GetAuthorByID implements Store's GetAuthorByID function
+func (p *Store) GetAuthorByID(ctx context.Context, authorId uint64) (users.Author, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	author := users.Author{}
-	for rows.Next() {
-		rows.Scan(&author.AuthorID, &author.AuthorName)
+	err := p.pool.QueryRow(ctx, stmtGetAuthorByID, authorId).Scan(
+		&author.AuthorID, &author.AuthorName)
+	if err != nil {
+		return users.Author{}, wrapError(stmtGetAuthorByID, "getting an author", err)
 	}
-	rows.Close()
-
-	return author
+	return author, nil
 }
 
 // AddAuthor implements Store's AddAuthor function
-func (p *Store) AddAuthor(userId uint64, authorName string) {
-	doExec(stmtAddAuthor, "adding an author", userId, authorName)
+func (p *Store) AddAuthor(ctx context.Context, userId uint64, authorName string) error {
+	return p.doExec(ctx, stmtAddAuthor, "adding an author", userId, authorName)
 }
 
 // LinkAuthor implements Store's LinkAuthor function
-func (p *Store) LinkAuthor(authorId uint64, userId uint64) {
-	doExec(stmtLinkAuthor, "linking a user to an author", userId, authorId)
+func (p *Store) LinkAuthor(ctx context.Context, authorId uint64, userId uint64) error {
+	return p.doExec(ctx, stmtLinkAuthor, "linking a user to an author", userId, authorId)
 }
 
 // RemoveAuthor implements Store's RemoveAuthor function
-func (p *Store) RemoveAuthor(authorId uint64) {
-	doExec(stmtRemoveAuthor, "removing an author", authorId)
+func (p *Store) RemoveAuthor(ctx context.Context, authorId uint64) error {
+	return p.doExec(ctx, stmtRemoveAuthor, "removing an author", authorId)
 }
 
 // PromoteToAdmin implements Store's PromoteToAdmin function
-func (p *Store) PromoteToAdmin(userId uint64) {
-	doExec(stmtPromoteToAdmin, "promoting a user to an admin", userId)
+func (p *Store) PromoteToAdmin(ctx context.Context, userId uint64) error {
+	const activity = "promoting a user to an admin"
+	err := p.doExec(ctx, stmtPromoteToAdmin, activity, userId)
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	// nothing has changed, either the user doesn't exist or is an admin already
+	isAdmin, err := p.IsAdmin(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if isAdmin {
+		return store.NewError(store.ErrConflict, activity, nil)
+	}
+	return store.NewError(store.ErrNotFound, activity, nil)
 }
 
 // DemoteFromAdmin implements Store's DemoteFromAdmin function
-func (p *Store) DemoteFromAdmin(userId uint64) {
-	doExec(stmtDemoteFromAdmin, "demoting a user from an admin", userId)
+func (p *Store) DemoteFromAdmin(ctx context.Context, userId uint64) error {
+	return p.doExec(ctx, stmtDemoteFromAdmin, "demoting a user from an admin", userId)
 }
 
 // GetArticleNumber implements Store's GetArticleNumber function
-func (p *Store) GetArticleNumber() uint64 {
-	rows, err := pool.Query(returnConnectionCtx(), stmtArticleNumber)
+func (p *Store) GetArticleNumber(ctx context.Context) (uint64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	count := uint64(0)
+	err := p.pool.QueryRow(ctx, stmtArticleNumber).Scan(&count)
 	if err != nil {
-		fmt.Println("An error has happened while getting the number of articles from Postgres:", err.Error())
+		return 0, wrapError(stmtArticleNumber, "getting the number of articles", err)
 	}
+	return count, nil
+}
+
+// GetArticleNumberByTag implements Store's GetArticleNumberByTag function
+func (p *Store) GetArticleNumberByTag(ctx context.Context, tag string) (uint64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	count := uint64(0)
+	err := p.pool.QueryRow(ctx, stmtArticleNumberByTag, tag).Scan(&count)
+	if err != nil {
+		return 0, wrapError(stmtArticleNumberByTag, "getting the number of articles with a tag", err)
+	}
+	return count, nil
+}
+
+// ListTags implements Store's ListTags function
+func (p *Store) ListTags(ctx context.Context) ([]article.TagCount, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "listing tags"
+	rows, err := p.pool.Query(ctx, stmtListTags)
+	if err != nil {
+		return nil, wrapError(stmtListTags, activity, err)
+	}
+	defer rows.Close()
+
+	tags := make([]article.TagCount, 0, 0)
 	for rows.Next() {
-		err = rows.Scan(&count)
-		if err != nil {
-			fmt.Println("An error has happened while getting the article number: ", err.Error())
+		t := article.TagCount{}
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, wrapError(stmtListTags, activity, err)
 		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListTags, activity, err)
 	}
-	return count
+	return tags, nil
+}
+
+// PublishScheduledArticles implements Store's PublishScheduledArticles function
+func (p *Store) PublishScheduledArticles(ctx context.Context, now time.Time) (uint64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
+	ct, err := p.pool.Exec(ctx, stmtPublishScheduledArticles, now.Unix())
+	if err != nil {
+		return 0, wrapError(stmtPublishScheduledArticles, "publishing scheduled articles", err)
+	}
+	return uint64(ct.RowsAffected()), nil
 }
 
 // LoadArticlesForIndex implements Store's LoadArticlesForIndex function
-func (p *Store) LoadArticlesForIndex(page uint64) []article.Article {
+func (p *Store) LoadArticlesForIndex(ctx context.Context, page uint64) ([]article.Article, error) {
 	// return articles starting from
-	offset := p.ArticlesPerIndexPage * page
-	limit := p.ArticlesPerIndexPage
+	from := p.ArticlesPerIndexPage * page
+	to := from + p.ArticlesPerIndexPage
 
-	rows, err := pool.Query(returnConnectionCtx(), stmtLoadArticlesSortedByNewest, offset, limit)
-	if err != nil {
-		fmt.Println("An error has happened while loading articles for index:", err.Error())
-		return []article.Article{}
-	}
+	return p.loadArticles(ctx, stmtLoadArticlesSortedByNewest, from, to)
+}
+
+// GetArticleByID implements Store's GetArticleByID function
+func (p *Store) GetArticleByID(ctx context.Context, id uint64) (article.Article, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	articles := make([]article.Article, 0, p.ArticlesPerIndexPage)
 	var title string
-	var articleId uint64
+	var slug string
 	var authorId uint64
-	var htmlPreview string
+	var source string
+	var summary string
+	var htmlContent string
 	var timestamp int64
+	var status string
+	var tags []string
 
-	for rows.Next() {
-		rows.Scan(&title, &articleId, &authorId, &htmlPreview, &timestamp)
-		articles = append(articles, article.Article{
-			Title:     title,
-			ID:        articleId,
-			AuthorID:  authorId,
-			Timestamp: uint64(timestamp),
-			Content:   template.HTML(htmlPreview),
-		})
+	err := p.pool.QueryRow(ctx, stmtGetArticleByID, id).Scan(&title,
+		&slug, &authorId, &source, &summary, &htmlContent, &timestamp, &status, &tags)
+	if err != nil {
+		return article.Article{}, wrapError(stmtGetArticleByID, "getting an article", err)
 	}
 
-	return articles
+	return article.Article{
+		Title:     title,
+		ID:        id,
+		Slug:      slug,
+		AuthorID:  authorId,
+		Timestamp: uint64(timestamp),
+		Content:   template.HTML(htmlContent),
+		Source:    source,
+		Summary:   summary,
+		Status:    article.Status(status),
+		Tags:      tags,
+	}, nil
+}
 
+// AddSession implements Store's AddSession function
+func (p *Store) AddSession(ctx context.Context, session users.Session) error {
+	// the column is a timestamp without time zone, so we always store UTC
+	return p.doExec(ctx, stmtAddSession, "adding a session", session.ID, session.UserID,
+		session.ValidUntil.UTC())
 }
 
-// GetArticleByID implements Store's GetArticleByID function
-func (p *Store) GetArticleByID(id uint64) (article.Article, bool) {
-	rows, err := pool.Query(returnConnectionCtx(), stmtGetArticleByID, id)
+// GetSession implements Store's GetSession function
+func (p *Store) GetSession(ctx context.Context, id uint64) (users.Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	s := users.Session{}
+	var validUntil time.Time
+	err := p.pool.QueryRow(ctx, stmtGetSession, id, time.Now().UTC()).Scan(
+		&s.ID, &s.UserID, &validUntil)
 	if err != nil {
-		fmt.Println("An error has happened while loading articles for index:", err.Error())
-		return article.Article{}, false
+		return users.Session{}, wrapError(stmtGetSession, "getting a session", err)
 	}
 
-	var title string
-	var authorId uint64
-	var htmlContent string
-	var timestamp int64
+	s.ValidUntil = inUTC(validUntil)
+	return s, nil
+}
 
-	for rows.Next() {
-		rows.Scan(&title, &authorId, &htmlContent, &timestamp)
-		return article.Article{
-			Title:     title,
-			ID:        id,
-			AuthorID:  authorId,
-			Timestamp: uint64(timestamp),
-			Content:   template.HTML(htmlContent),
-		}, true
+// ExtendSession implements Store's ExtendSession function
+func (p *Store) ExtendSession(ctx context.Context, id uint64, validUntil time.Time) error {
+	return p.doExec(ctx, stmtExtendSession, "extending a session", validUntil.UTC(), id)
+}
+
+// RemoveSession implements Store's RemoveSession function
+func (p *Store) RemoveSession(ctx context.Context, id uint64) error {
+	return p.doExec(ctx, stmtRemoveSession, "removing a session", id)
+}
+
+// RemoveUserSessions implements Store's RemoveUserSessions function
+func (p *Store) RemoveUserSessions(ctx context.Context, userId uint64) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	// users without any sessions are fine, so doExec isn't used
+	_, err := p.pool.Exec(ctx, stmtRemoveUserSessions, userId)
+	if err != nil {
+		return wrapError(stmtRemoveUserSessions, "removing sessions of a user", err)
 	}
+	return nil
+}
+
+// RemoveExpiredSessions implements Store's RemoveExpiredSessions function
+func (p *Store) RemoveExpiredSessions(ctx context.Context) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.pool.Exec(ctx, stmtRemoveExpiredSessions, time.Now().UTC())
+	if err != nil {
+		return wrapError(stmtRemoveExpiredSessions, "removing expired sessions", err)
+	}
+	return nil
+}
 
-	return article.Article{}, false
+// inUTC fixes the time zone of a scanned timestamp
+// Timestamps are saved without a time zone, they're in UTC, but pgx doesn't
+// know that
+func inUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+		t.Nanosecond(), time.UTC)
 }
 
 // doExec is a helper function that helps prevent code duplication when doing
 // simple pgx exec queries
-func doExec(stmt string, activity string, arguments ...interface{}) {
-	ct, err := pool.Exec(returnConnectionCtx(), stmt, arguments...)
-	if err != nil || ct.RowsAffected() == 0 {
-		fmt.Println("[Postgres Store] An error has happened while "+activity+": ", err)
+// If no rows were affected, ErrNotFound is returned
+func (p *Store) doExec(ctx context.Context, stmt string, activity string, arguments ...interface{}) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	ct, err := p.pool.Exec(ctx, stmt, arguments...)
+	if err != nil {
+		return wrapError(stmt, activity, err)
+	}
+	if ct.RowsAffected() == 0 {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
+	return nil
+}
+
+// AddComment implements Store's AddComment function
+func (p *Store) AddComment(ctx context.Context, c comments.Comment) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "adding a comment"
+	// comments of users get the current display name of the user
+	if c.UserID != 0 {
+		c.Name = ""
+	}
+	ct, err := p.pool.Exec(ctx, stmtAddComment, c.ArticleID, c.ParentID, c.UserID, c.Name,
+		time.Now().UTC(), string(c.Status), c.UnsafeContent)
+	if err != nil {
+		return wrapError(stmtAddComment, activity, err)
+	}
+	// the parent doesn't belong to the article
+	if ct.RowsAffected() == 0 {
+		return store.NewError(store.ErrInvalidInput, activity, nil)
+	}
+	return nil
+}
+
+// ListComments implements Store's ListComments function
+func (p *Store) ListComments(ctx context.Context, articleId uint64, from uint64, to uint64) ([]comments.Comment, error) {
+	return p.listComments(ctx, stmtListComments, articleId, from, rowLimit(from, to))
+}
+
+// ListCommentsByStatus implements Store's ListCommentsByStatus function
+func (p *Store) ListCommentsByStatus(ctx context.Context, status comments.Status, from uint64, to uint64) ([]comments.Comment, error) {
+	return p.listComments(ctx, stmtListCommentsByStatus, string(status), from, rowLimit(from, to))
+}
+
+// listComments lists comments using stmt, which takes the arguments
+func (p *Store) listComments(ctx context.Context, stmt string, arguments ...interface{}) ([]comments.Comment, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "listing comments"
+	rows, err := p.pool.Query(ctx, stmt, arguments...)
+	if err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+	defer rows.Close()
+
+	cs := make([]comments.Comment, 0, 0)
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, wrapError(stmt, activity, err)
+		}
+		cs = append(cs, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+	return cs, nil
+}
+
+// GetCommentNumber implements Store's GetCommentNumber function
+func (p *Store) GetCommentNumber(ctx context.Context, articleId uint64) (uint64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	count := uint64(0)
+	err := p.pool.QueryRow(ctx, stmtCommentNumber, articleId).Scan(&count)
+	if err != nil {
+		return 0, wrapError(stmtCommentNumber, "getting the number of comments", err)
 	}
-	ct.Delete()
+	return count, nil
+}
+
+// GetComment implements Store's GetComment function
+func (p *Store) GetComment(ctx context.Context, id uint64) (comments.Comment, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
+	c, err := scanComment(p.pool.QueryRow(ctx, stmtGetComment, id))
+	if err != nil {
+		return comments.Comment{}, wrapError(stmtGetComment, "getting a comment", err)
+	}
+	return c, nil
+}
+
+// SetCommentStatus implements Store's SetCommentStatus function
+func (p *Store) SetCommentStatus(ctx context.Context, id uint64, status comments.Status) error {
+	return p.doExec(ctx, stmtSetCommentStatus, "changing the status of a comment", string(status), id)
+}
+
+// RemoveComment implements Store's RemoveComment function
+func (p *Store) RemoveComment(ctx context.Context, id uint64) error {
+	return p.doExec(ctx, stmtRemoveComment, "removing a comment", id)
+}
+
+// scans a row with commentColumns
+func scanComment(row pgx.Row) (comments.Comment, error) {
+	c := comments.Comment{}
+	var status string
+	err := row.Scan(&c.ID, &c.ArticleID, &c.ParentID, &c.UserID, &c.Name, &c.Time, &status, &c.UnsafeContent)
+	c.Status = comments.Status(status)
+	c.Time = inUTC(c.Time)
+	return c, err
+}
+
+// AddToken implements Store's AddToken function
+func (p *Store) AddToken(ctx context.Context, token users.Token) (uint64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var id uint64
+	err := p.pool.QueryRow(ctx, stmtAddToken, token.UserID, token.Name, string(token.Scope),
+		token.Hash, token.Created.UTC()).Scan(&id)
+	if err != nil {
+		return 0, wrapError(stmtAddToken, "adding a token", err)
+	}
+	return id, nil
+}
+
+// ListTokens implements Store's ListTokens function
+func (p *Store) ListTokens(ctx context.Context, userId uint64) ([]users.Token, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "listing tokens"
+	rows, err := p.pool.Query(ctx, stmtListTokens, userId)
+	if err != nil {
+		return nil, wrapError(stmtListTokens, activity, err)
+	}
+	defer rows.Close()
+
+	tokens := make([]users.Token, 0, 0)
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, wrapError(stmtListTokens, activity, err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListTokens, activity, err)
+	}
+	return tokens, nil
+}
+
+// GetToken implements Store's GetToken function
+func (p *Store) GetToken(ctx context.Context, id uint64) (users.Token, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	t, err := scanToken(p.pool.QueryRow(ctx, stmtGetToken, id))
+	if err != nil {
+		return users.Token{}, wrapError(stmtGetToken, "getting a token", err)
+	}
+	return t, nil
+}
+
+// TouchToken implements Store's TouchToken function
+func (p *Store) TouchToken(ctx context.Context, id uint64, lastUsed time.Time) error {
+	return p.doExec(ctx, stmtTouchToken, "touching a token", lastUsed.UTC(), id)
+}
+
+// RemoveToken implements Store's RemoveToken function
+func (p *Store) RemoveToken(ctx context.Context, id uint64) error {
+	return p.doExec(ctx, stmtRemoveToken, "removing a token", id)
+}
+
+// scanToken scans a row with the columns in tokenColumns
+func scanToken(row pgx.Row) (users.Token, error) {
+	t := users.Token{}
+	var scope string
+	var lastUsed *time.Time
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &scope, &t.Hash, &t.Created, &lastUsed)
+	t.Scope = users.TokenScope(scope)
+	t.Created = inUTC(t.Created)
+	// tokens which were never used don't have the time
+	if lastUsed != nil {
+		t.LastUsed = inUTC(*lastUsed)
+	}
+	return t, err
 }
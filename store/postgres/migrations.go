@@ -0,0 +1,508 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/article/render"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/jackc/pgx/v4"
+	"html/template"
+)
+
+// migration is a single change of the schema, every migration is applied only
+// once, in the order of their versions
+type migration struct {
+	version uint64
+	name    string
+	stmt    string
+
+	// optional, changes the data once stmt is executed, in the same transaction
+	run func(p *Store, ctx context.Context, tx pgx.Tx) error
+}
+
+// All migrations sorted by their versions, new ones are only ever appended
+// Versions of Montesquieu before migrations brought the schema up to date on
+// every startup, so migrations up to 11 can find some of their changes already
+// made and have to skip them (using 'if not exists' and so on)
+var migrations = []migration{
+	{version: 1, name: "initial schema", stmt: stmtMigrationInitial},
+	{version: 2, name: "article sources", stmt: stmtMigrationSources},
+	{version: 3, name: "article summaries and previews", stmt: stmtMigrationSummaries,
+		run: (*Store).generatePreviews},
+	{version: 4, name: "article slugs", stmt: stmtMigrationSlugs, run: (*Store).generateSlugs},
+	{version: 5, name: "article statuses", stmt: stmtMigrationStatuses},
+	{version: 6, name: "article revisions", stmt: stmtMigrationRevisions},
+	{version: 7, name: "article tags", stmt: stmtMigrationTags},
+	{version: 8, name: "full-text search", stmt: stmtMigrationSearch},
+	{version: 9, name: "comment threads and moderation", stmt: stmtMigrationComments},
+	{version: 10, name: "API tokens", stmt: stmtMigrationTokens},
+	{version: 11, name: "user roles", stmt: stmtMigrationRoles},
+	{version: 12, name: "comments of removed users", stmt: stmtMigrationCommentUsers},
+}
+
+// a random key of the advisory lock held while migrating, so instances of
+// Montesquieu starting at the same time don't apply migrations twice
+const migrationsLock = 8305711604
+
+// migrate applies all migrations which haven't been applied yet, each one in
+// its own transaction
+// If the database has been migrated by a newer version of Montesquieu, an
+// error is returned and nothing is changed
+func (p *Store) migrate(ctx context.Context) error {
+	// migrations can take a while on big databases, so they don't have the
+	// timeout of queries
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, stmtLockMigrations, migrationsLock); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), stmtUnlockMigrations, migrationsLock)
+
+	// the name of the schema can't be a parameter
+	createSchema := "create schema if not exists " + pgx.Identifier{p.Schema}.Sanitize()
+	if _, err := conn.Exec(ctx, createSchema); err != nil {
+		return err
+	}
+	if _, err := conn.Exec(ctx, stmtMigrationsTable); err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, conn.Conn())
+	if err != nil {
+		return err
+	}
+	if err := checkSchemaVersion(applied); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := p.applyMigration(ctx, conn.Conn(), m); err != nil {
+			return fmt.Errorf("migration %v (%v) failed: %w", m.version, m.name, err)
+		}
+		fmt.Printf("Applied migration %v (%v)\n", m.version, m.name)
+	}
+	return nil
+}
+
+// applyMigration applies the migration and records it in a single transaction
+func (p *Store) applyMigration(ctx context.Context, conn *pgx.Conn, m migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, m.stmt); err != nil {
+		return err
+	}
+	if m.run != nil {
+		if err := m.run(p, ctx, tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, stmtAddMigration, m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// pendingMigrations returns migrations which migrate would apply, without
+// changing anything in the database
+func (p *Store) pendingMigrations(ctx context.Context) ([]store.Migration, error) {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	// databases which have never been migrated don't have the table yet
+	var exists bool
+	if err := conn.QueryRow(ctx, stmtMigrationsTableExists).Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := make(map[uint64]bool)
+	if exists {
+		if applied, err = appliedMigrations(ctx, conn.Conn()); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkSchemaVersion(applied); err != nil {
+		return nil, err
+	}
+
+	pending := make([]store.Migration, 0, 0)
+	for _, m := range migrations {
+		if !applied[m.version] {
+			pending = append(pending, store.Migration{Version: m.version, Name: m.name})
+		}
+	}
+	return pending, nil
+}
+
+// appliedMigrations returns the versions of all applied migrations
+func appliedMigrations(ctx context.Context, conn *pgx.Conn) (map[uint64]bool, error) {
+	rows, err := conn.Query(ctx, stmtListMigrations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[uint64]bool)
+	for rows.Next() {
+		var version uint64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// checkSchemaVersion returns an error if a migration unknown to this version
+// of Montesquieu has been applied, its queries might not work with the schema
+func checkSchemaVersion(applied map[uint64]bool) error {
+	latest := migrations[len(migrations)-1].version
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("the database schema is at version %v, but this version of "+
+				"Montesquieu only knows versions up to %v, please upgrade Montesquieu", version, latest)
+		}
+	}
+	return nil
+}
+
+// articles made by older versions of Montesquieu don't have slugs
+func (p *Store) generateSlugs(ctx context.Context, tx pgx.Tx) error {
+	rows, err := tx.Query(ctx, stmtListArticlesWithoutSlug)
+	if err != nil {
+		return err
+	}
+	var articles []article.Article
+	for rows.Next() {
+		a := article.Article{}
+		if err := rows.Scan(&a.ID, &a.Title); err != nil {
+			rows.Close()
+			return err
+		}
+		articles = append(articles, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, a := range articles {
+		slug, err := uniqueSlug(ctx, tx, a)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, stmtSetSlug, slug, a.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// older versions of Montesquieu used the whole article as its preview, so
+// generate proper previews for such articles
+func (p *Store) generatePreviews(ctx context.Context, tx pgx.Tx) error {
+	rows, err := tx.Query(ctx, stmtListFullPreviews)
+	if err != nil {
+		return err
+	}
+	previews := make(map[uint64]template.HTML)
+	for rows.Next() {
+		var id uint64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		preview := render.Truncate(template.HTML(content), p.PreviewLength)
+		if string(preview) != content {
+			previews[id] = preview
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, preview := range previews {
+		if _, err := tx.Exec(ctx, stmtSetPreview, string(preview), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the table of applied migrations, it's made before any migration is applied,
+// right after the schema
+const stmtMigrationsTable = `
+create table if not exists schema_migrations
+(
+    version bigint    not null
+        constraint schema_migrations_pk
+            primary key,
+    name    text      not null,
+    applied timestamp not null default (now() at time zone 'utc')
+);
+`
+
+const stmtMigrationsTableExists = `select to_regclass('schema_migrations') is not null;`
+
+const stmtListMigrations = `select version from schema_migrations;`
+
+const stmtAddMigration = `insert into schema_migrations (version, name) values ($1, $2);`
+
+const stmtLockMigrations = `select pg_advisory_lock($1);`
+
+const stmtUnlockMigrations = `select pg_advisory_unlock($1);`
+
+// the schema made by the first versions of Montesquieu
+const stmtMigrationInitial = `
+create table if not exists users
+(
+    id           bigserial not null
+        constraint users_pk
+            primary key,
+    display_name text,
+    login        text unique,
+    password     text
+);
+create unique index if not exists users_id_uindex
+    on users (id);
+create table if not exists authors
+(
+    id      bigserial not null
+        constraint authors_pk
+            primary key,
+    user_id bigint
+        constraint authors_users_id_fk
+            references users
+            unique,
+    name    text
+);
+create unique index if not exists authors_id_uindex
+    on authors (id);
+create table if not exists articles
+(
+    title        text,
+    article_id   bigserial not null
+        constraint articles_pk
+            primary key,
+    author_id    integer not null
+        constraint articles_authors_id_fk
+            references authors,
+    html_content text,
+    html_preview text,
+    timestamp    bigint
+);
+create unique index if not exists articles_article_id_uindex
+    on articles (article_id);
+create table if not exists sessions
+(
+    id          bigint not null
+        constraint sessions_pk
+            primary key
+            unique,
+    user_id     bigint not null
+        constraint sessions_users_id_fk
+            references users
+            on delete cascade,
+    valid_until timestamp
+);
+create unique index if not exists sessions_id_uindex
+    on sessions (id);
+create table if not exists comments
+(
+    comment_id     bigint not null
+        constraint comments_pk
+            primary key
+            unique,
+    user_id        bigint not null
+        constraint comments_users_id_fk
+            references users
+            on update cascade,
+    unsafe_content text
+);
+create unique index if not exists comments_comment_id_uindex
+    on comments (comment_id);
+do $$
+begin
+    -- admins were replaced by roles later, databases which have roles already
+    -- mustn't get the table back, it would be migrated to roles again
+    if not exists (select from information_schema.columns
+                   where table_schema = current_schema() and table_name = 'users'
+                   and column_name = 'role') then
+        create table if not exists admins
+        (
+            user_id bigint not null
+                constraint admins_pk
+                    primary key
+                    unique
+                constraint admins_users_id_fk
+                    references users
+                    on delete cascade
+        );
+    end if;
+end $$;
+`
+
+const stmtMigrationSources = `
+alter table articles add column if not exists source text not null default '';
+`
+
+const stmtMigrationSummaries = `
+alter table articles add column if not exists summary text not null default '';
+`
+
+const stmtMigrationSlugs = `
+alter table articles add column if not exists slug text unique;
+create table if not exists article_slugs
+(
+    slug       text not null
+        constraint article_slugs_pk
+            primary key,
+    article_id bigint not null
+        constraint article_slugs_articles_article_id_fk
+            references articles
+            on delete cascade
+);
+`
+
+const stmtMigrationStatuses = `
+alter table articles add column if not exists status text not null default 'published'
+    constraint articles_status_check
+        check (status in ('draft', 'scheduled', 'published', 'archived'));
+create index if not exists articles_status_timestamp_index
+    on articles (status, timestamp desc);
+`
+
+const stmtMigrationRevisions = `
+create table if not exists revisions
+(
+    id         bigserial not null
+        constraint revisions_pk
+            primary key,
+    article_id bigint not null
+        constraint revisions_articles_article_id_fk
+            references articles
+            on delete cascade,
+    user_id    bigint
+        constraint revisions_users_id_fk
+            references users
+            on delete set null,
+    time       timestamp not null,
+    title      text,
+    source     text not null default '',
+    summary    text not null default ''
+);
+create index if not exists revisions_article_id_index
+    on revisions (article_id, id desc);
+`
+
+const stmtMigrationTags = `
+create table if not exists article_tags
+(
+    article_id bigint not null
+        constraint article_tags_articles_article_id_fk
+            references articles
+            on delete cascade,
+    tag        text   not null,
+    constraint article_tags_pk
+        primary key (article_id, tag)
+);
+create index if not exists article_tags_tag_index
+    on article_tags (tag);
+`
+
+const stmtMigrationSearch = `
+create index if not exists articles_search_index
+    on articles using gin ((` + searchDocument + `));
+`
+
+const stmtMigrationComments = `
+create sequence if not exists comments_comment_id_seq owned by comments.comment_id;
+alter table comments alter column comment_id
+    set default nextval('comments_comment_id_seq');
+alter table comments alter column user_id drop not null;
+alter table comments add column if not exists article_id bigint not null
+    constraint comments_articles_article_id_fk
+        references articles
+        on delete cascade;
+alter table comments add column if not exists parent_id bigint
+    constraint comments_comments_comment_id_fk
+        references comments
+        on delete set null;
+alter table comments add column if not exists name text not null default '';
+alter table comments add column if not exists time timestamp not null
+    default (now() at time zone 'utc');
+alter table comments add column if not exists status text not null default 'visible'
+    constraint comments_status_check
+        check (status in ('pending', 'visible', 'hidden'));
+create index if not exists comments_article_id_index
+    on comments (article_id, status, time);
+create index if not exists comments_status_index
+    on comments (status, time desc);
+`
+
+const stmtMigrationTokens = `
+create table if not exists tokens
+(
+    id        bigserial not null
+        constraint tokens_pk
+            primary key,
+    user_id   bigint    not null
+        constraint tokens_users_id_fk
+            references users
+            on delete cascade,
+    name      text      not null,
+    scope     text      not null
+        constraint tokens_scope_check
+            check (scope in ('read', 'publish', 'admin')),
+    hash      text      not null,
+    created   timestamp not null,
+    last_used timestamp
+);
+create index if not exists tokens_user_id_index
+    on tokens (user_id, id);
+`
+
+const stmtMigrationRoles = `
+alter table users add column if not exists role text not null default 'reader'
+    constraint users_role_check
+        check (role in ('reader', 'author', 'editor', 'admin'));
+do $$
+begin
+    -- admins used to have their own table, users linked to authors couldn't
+    -- do anything without being admins, so they become authors
+    if exists (select from information_schema.tables
+               where table_schema = current_schema() and table_name = 'admins') then
+        update users set role = 'author'
+            where id in (select user_id from authors);
+        update users set role = 'admin'
+            where id in (select user_id from admins);
+        drop table admins;
+    end if;
+end $$;
+`
+
+// comments of removed users are kept without their user, instead of keeping
+// the user from being removed
+const stmtMigrationCommentUsers = `
+alter table comments drop constraint if exists comments_users_id_fk;
+alter table comments add constraint comments_users_id_fk foreign key (user_id)
+    references users
+    on update cascade
+    on delete set null;
+create index if not exists comments_user_id_index
+    on comments (user_id);
+`
@@ -0,0 +1,24 @@
+package postgres
+
+import "testing"
+
+func TestMigrations_Order(t *testing.T) {
+	for k, m := range migrations {
+		if m.version != uint64(k+1) {
+			t.Errorf("migration %q has version %v, want %v", m.name, m.version, k+1)
+		}
+		if m.name == "" || m.stmt == "" {
+			t.Errorf("migration %v doesn't have a name or a statement", m.version)
+		}
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	latest := migrations[len(migrations)-1].version
+	if err := checkSchemaVersion(map[uint64]bool{1: true, latest: true}); err != nil {
+		t.Errorf("checkSchemaVersion() of a known version returned %v", err)
+	}
+	if err := checkSchemaVersion(map[uint64]bool{latest + 1: true}); err == nil {
+		t.Errorf("checkSchemaVersion() of a newer version didn't return an error")
+	}
+}
@@ -1,159 +1,234 @@
 package postgres
 
-// schema name
-const prefix = `montesquieu`
-
-// This stmt is run on every startup to ensure that database structures exist, and if they don't, it creates them
-const stmtStartup = `
-create schema ` + prefix + `
-    create table if not exists users
-    (
-        id           bigserial not null
-            constraint users_pk
-                primary key,
-        display_name text,
-        login        text unique,
-        password     text
-    )
-
-	create unique index if not exists users_id_uindex
-    on users (id)
-
-    create table if not exists authors
-    (
-        id      bigserial not null
-            constraint authors_pk
-                primary key,
-        user_id bigint
-            constraint authors_users_id_fk
-                references users
-				unique,
-        name    text
-    )
-
-	create unique index if not exists authors_id_uindex
-    on authors (id)
-
-    create table if not exists articles
-    (
-        title        text,
-        article_id   bigserial not null
-            constraint articles_pk
-                primary key,
-        author_id    integer not null
-            constraint articles_authors_id_fk
-                references authors,
-        html_content text,
-		html_preview text,
-		timestamp bigint
-    )
-    
-    create unique index if not exists articles_article_id_uindex
-        on articles (article_id)
-    
-    create unique index if not exists authors_id_uindex
-        on authors (id)
-    
-    create unique index if not exists users_id_uindex
-        on users (id)
-    
-    create table if not exists sessions
-    (
-        id          bigint not null
-            constraint sessions_pk
-                primary key
-				unique,
-        user_id     bigint not null
-            constraint sessions_users_id_fk
-                references users
-				on delete cascade,
-        valid_until timestamp
-    )
-    
-    create unique index if not exists sessions_id_uindex
-        on sessions (id)
-    
-    create table if not exists comments
-    (
-        comment_id     bigint  not null
-            constraint comments_pk
-                primary key
-				unique,
-        user_id        bigint not null
-            constraint comments_users_id_fk
-                references users
-                on update cascade,
-        unsafe_content text
-    )
-    
-    create unique index if not exists comments_comment_id_uindex
-        on comments (comment_id)
-    
-    create table if not exists admins
-    (
-        user_id bigint not null
-            constraint admins_pk
-                primary key
-				unique
-            constraint admins_users_id_fk
-                references users
-                on delete cascade
-    );
-`
+// All statements use tables without their schema, the schema of the Store is
+// the search_path of its connections
 
 // articles
-const stmtLoadArticlesSortedByNewest = `select title, article_id, author_id, html_preview, timestamp from 
-` + prefix + `.articles order by timestamp desc offset $1 limit $2;`
+const stmtListFullPreviews = `select article_id, html_content from articles 
+where html_preview = html_content;`
 
-const stmtNewArticle = `insert into ` + prefix + `.articles (title, author_id, html_content, 
-html_preview, timestamp) values ($1,$2,$3,$4,$5);`
+const stmtSetPreview = `update articles set html_preview = $1 where article_id = $2;`
 
-const stmtEditArticle = `update ` + prefix + `.articles set title = $1, author_id = $2, 
-html_content = $3, html_preview = $4, timestamp = $5 where article_id = $6;`
+const stmtListArticlesWithoutSlug = `select article_id, title from articles 
+where slug is null;`
 
-const stmtRemoveArticle = `delete from ` + prefix + `.articles where article_id = $1;`
+const stmtSetSlug = `update articles set slug = $1 where article_id = $2;`
 
-const stmtGetArticleByID = `select title, author_id, html_content, timestamp from ` + prefix + `.articles where article_id = $1;`
+// columns of articles loaded by loadArticles, the articles table has to be
+// called 'a'
+const articleListColumns = `a.title, a.article_id, coalesce(a.slug, ''), a.author_id, a.html_preview, 
+a.timestamp, a.status, ` + articleTagsColumn
 
-const stmtArticleNumber = `select count(article_id) from ` + prefix + `.articles;`
+// tags of the article 'a' as an array
+const articleTagsColumn = `coalesce((select array_agg(tag order by tag) from article_tags 
+where article_id = a.article_id), '{}')`
+
+const stmtLoadArticlesSortedByNewest = `select ` + articleListColumns + ` from articles a 
+where a.status = 'published' order by a.timestamp desc offset $1 limit $2;`
+
+const stmtLoadAllArticlesSortedByNewest = `select ` + articleListColumns + ` from articles a 
+order by a.timestamp desc offset $1 limit $2;`
+
+const stmtLoadArticlesByAuthor = `select ` + articleListColumns + ` from articles a 
+where a.author_id = $3 order by a.timestamp desc offset $1 limit $2;`
+
+const stmtLoadArticlesByTag = `select ` + articleListColumns + ` from articles a 
+inner join article_tags t on t.article_id = a.article_id 
+where a.status = 'published' and t.tag = $3 order by a.timestamp desc offset $1 limit $2;`
+
+const stmtArticleNumberByTag = `select count(a.article_id) from articles a 
+inner join article_tags t on t.article_id = a.article_id 
+where a.status = 'published' and t.tag = $1;`
+
+const stmtPublishScheduledArticles = `update articles set status = 'published' 
+where status = 'scheduled' and timestamp <= $1;`
+
+const stmtNewArticle = `insert into articles (title, author_id, source, summary, 
+html_content, html_preview, timestamp, slug, status) values ($1,$2,$3,$4,$5,$6,$7,$8,$9) 
+returning article_id;`
+
+const stmtEditArticle = `update articles set title = $1, author_id = $2, 
+source = $3, summary = $4, html_content = $5, html_preview = $6, timestamp = $7, slug = $8, 
+status = $9 where article_id = $10;`
+
+const stmtRemoveArticle = `delete from articles where article_id = $1;`
+
+const stmtGetArticleByID = `select a.title, coalesce(a.slug, ''), a.author_id, a.source, a.summary, 
+a.html_content, a.timestamp, a.status, ` + articleTagsColumn + ` from articles a 
+where a.article_id = $1;`
+
+// the article is locked until the edit is done
+// articles written before sources were kept only have their HTML, which is
+// valid Markdown too, so it's used as their source
+const stmtGetArticleForEdit = `select coalesce(slug, ''), title, coalesce(nullif(source, ''), html_content, ''), 
+summary, timestamp from articles where article_id = $1 for update;`
+
+// search
+// the text search configuration used for all articles
+const searchConfig = `'english'`
+
+// the title weighs more than the rest of the article, the HTML tags in the
+// content are skipped by the parser
+// it has to be the same in the index and in the queries, so the index is used
+const searchDocument = `setweight(to_tsvector(` + searchConfig + `, coalesce(title, '')), 'A') || 
+setweight(to_tsvector(` + searchConfig + `, coalesce(summary, '') || ' ' || coalesce(html_content, '')), 'B')`
+
+const stmtSearchArticles = `select ` + articleListColumns + `, ts_headline(` + searchConfig + `, 
+regexp_replace(coalesce(a.html_content, ''), '<[^>]*>', ' ', 'g'), q, $4) 
+from articles a, plainto_tsquery(` + searchConfig + `, $3) q 
+where a.status = 'published' and (` + searchDocument + `) @@ q 
+order by ts_rank((` + searchDocument + `), q) desc, a.timestamp desc offset $1 limit $2;`
+
+const stmtSearchResultNumber = `select count(a.article_id) from articles a, 
+plainto_tsquery(` + searchConfig + `, $1) q where a.status = 'published' and (` + searchDocument + `) @@ q;`
+
+// tags
+const stmtRemoveArticleTags = `delete from article_tags where article_id = $1;`
+
+const stmtAddArticleTags = `insert into article_tags (article_id, tag) 
+select $1, unnest($2::text[]) on conflict do nothing;`
+
+const stmtListTags = `select t.tag, count(a.article_id) from article_tags t 
+inner join articles a on a.article_id = t.article_id where a.status = 'published' 
+group by t.tag order by t.tag;`
+
+// revisions
+const stmtAddRevision = `insert into revisions (article_id, user_id, time, title, 
+source, summary) values ($1, nullif($2::bigint, 0), $3, $4, $5, $6);`
+
+const stmtRevisionNumber = `select count(id) from revisions where article_id = $1;`
+
+const stmtListRevisions = `select r.id, r.article_id, coalesce(r.user_id, 0), 
+coalesce(u.display_name, ''), r.time, r.title, r.source, r.summary from revisions r 
+left join users u on u.id = r.user_id where r.article_id = $1 order by r.id desc 
+offset $2 limit $3;`
+
+const stmtGetRevision = `select r.id, r.article_id, coalesce(r.user_id, 0), 
+coalesce(u.display_name, ''), r.time, r.title, r.source, r.summary from revisions r 
+left join users u on u.id = r.user_id where r.id = $1;`
+
+// slugs
+// a slug is taken if another article uses it now or has used it before
+const stmtSlugTaken = `select count(*) from (
+    select article_id from articles where slug = $1 and article_id <> $2
+    union all
+    select article_id from article_slugs where slug = $1 and article_id <> $2
+) as taken;`
+
+// current slugs take precedence over old ones
+const stmtGetArticleIDBySlug = `select article_id from (
+    select article_id, 0 as priority from articles where slug = $1
+    union all
+    select article_id, 1 as priority from article_slugs where slug = $1
+) as found order by priority limit 1;`
+
+const stmtAddOldSlug = `insert into article_slugs (slug, article_id) values ($1, $2) 
+on conflict (slug) do update set article_id = excluded.article_id;`
+
+const stmtRemoveOldSlug = `delete from article_slugs where slug = $1;`
+
+const stmtArticleNumber = `select count(article_id) from articles where status = 'published';`
 
 // users
-const stmtListUsers = `select id, display_name, login from ` + prefix + `.users order by 
+const stmtListUsers = `select id, display_name, login, role from users order by 
 id offset $1 limit $2;`
 
-const stmtAddUser = `insert into ` + prefix + `.users (display_name, login, password) values 
+const stmtAddUser = `insert into users (display_name, login, password) values 
 ($1,$2,$3);`
 
-const stmtEditUser = `update ` + prefix + `.users set display_name = $1, login = $2, password = $3 
+const stmtEditUser = `update users set display_name = $1, login = $2, password = $3 
 where id = $4;`
 
-const stmtRemoveUser = `delete from ` + prefix + `.users where id = $1;`
+const stmtRemoveUser = `delete from users where id = $1;`
 
-const stmtGetUserID = `select id from ` + prefix + `.users where login = $1;`
+const stmtGetUserID = `select id from users where login = $1;`
 
-const stmtGetUser = `select id, display_name, login, password from ` + prefix + `.users where id = $1;`
+const stmtGetUser = `select id, display_name, login, password, role from users 
+where id = $1;`
+
+const stmtSetRole = `update users set role = $1 where id = $2;`
 
 // authors
-const stmtListAuthors = `select ` + prefix + `.users.id as user_id, display_name as user_display_name, 
-login,` + prefix + `.authors.id as author_id, name as author_name from ` + prefix +
-	`.users inner join ` + prefix + `.authors on ` + prefix + `.authors.user_id=` +
-	prefix + `.users.id order by ` + prefix + `.users.id offset $1 limit $2;`
+const stmtListAuthors = `select users.id as user_id, display_name as user_display_name, 
+login, authors.id as author_id, name as author_name from users 
+inner join authors on authors.user_id = users.id order by users.id offset $1 limit $2;`
+
+const stmtGetAuthor = `select id, name from authors where user_id = $1;`
 
-const stmtGetAuthor = `select id, name from ` + prefix + `.authors where user_id = $1;`
+const stmtGetAuthorByID = `select id, name from authors where id = $1;`
 
-const stmtAddAuthor = `insert into ` + prefix + `.authors (user_id, name) values ($1, $2);`
+const stmtAddAuthor = `insert into authors (user_id, name) values ($1, $2);`
 
-const stmtLinkAuthor = `update ` + prefix + `.authors set user_id = $1 where id = $2;`
+const stmtLinkAuthor = `update authors set user_id = nullif($1::bigint, 0) where id = $2;`
 
-const stmtRemoveAuthor = `delete from ` + prefix + `.authors where id = $1;`
+const stmtRemoveAuthor = `delete from authors where id = $1;`
 
 // admins
-const stmtPromoteToAdmin = `insert into ` + prefix + `.admins values ($1);`
+const stmtPromoteToAdmin = `update users set role = 'admin' 
+where id = $1 and role <> 'admin';`
+
+const stmtDemoteFromAdmin = `update users set role = 'reader' 
+where id = $1 and role = 'admin';`
+
+const stmtIsAdmin = `select count(id) from users where id = $1 and role = 'admin';`
+
+const stmtListAdmins = `select id, display_name, login, role from users 
+where role = 'admin' order by id offset $1 limit $2;`
+
+// comments
+// the parent has to belong to the same article, otherwise nothing is inserted
+const stmtAddComment = `insert into comments (article_id, parent_id, user_id, name, time, 
+status, unsafe_content) select $1, nullif($2::bigint, 0), nullif($3::bigint, 0), $4, $5, $6, $7 
+where $2::bigint = 0 or exists (select 1 from comments 
+where comment_id = $2::bigint and article_id = $1);`
+
+// columns of comments loaded by scanComment, the comments table has to be
+// called 'c'
+const commentColumns = `c.comment_id, c.article_id, coalesce(c.parent_id, 0), coalesce(c.user_id, 0), 
+coalesce(u.display_name, c.name), c.time, c.status, coalesce(c.unsafe_content, '') 
+from comments c left join users u on u.id = c.user_id`
+
+const stmtListComments = `select ` + commentColumns + ` where c.article_id = $1 and c.status = 'visible' 
+order by c.time, c.comment_id offset $2 limit $3;`
+
+const stmtCommentNumber = `select count(comment_id) from comments where article_id = $1 
+and status = 'visible';`
+
+const stmtListCommentsByStatus = `select ` + commentColumns + ` where c.status = $1 
+order by c.time desc, c.comment_id desc offset $2 limit $3;`
+
+const stmtGetComment = `select ` + commentColumns + ` where c.comment_id = $1;`
+
+const stmtSetCommentStatus = `update comments set status = $1 where comment_id = $2;`
+
+const stmtRemoveComment = `delete from comments where comment_id = $1;`
+
+// sessions
+const stmtAddSession = `insert into sessions (id, user_id, valid_until) values 
+($1,$2,$3);`
+
+const stmtGetSession = `select id, user_id, valid_until from sessions where id = $1 
+and valid_until > $2;`
+
+const stmtExtendSession = `update sessions set valid_until = $1 where id = $2;`
+
+const stmtRemoveSession = `delete from sessions where id = $1;`
+
+const stmtRemoveUserSessions = `delete from sessions where user_id = $1;`
+
+const stmtRemoveExpiredSessions = `delete from sessions where valid_until <= $1;`
+
+// tokens
+const stmtAddToken = `insert into tokens (user_id, name, scope, hash, created) 
+values ($1, $2, $3, $4, $5) returning id;`
+
+// columns of tokens loaded by scanToken
+const tokenColumns = `id, user_id, name, scope, hash, created, last_used from tokens`
+
+const stmtListTokens = `select ` + tokenColumns + ` where user_id = $1 order by id;`
 
-const stmtDemoteFromAdmin = `delete from ` + prefix + `.admins where user_id = $1;`
+const stmtGetToken = `select ` + tokenColumns + ` where id = $1;`
 
-const stmtIsAdmin = `select count(user_id) from ` + prefix + `.admins where user_id = $1;`
+const stmtTouchToken = `update tokens set last_used = $1 where id = $2;`
 
-const stmtListAdmins = `select id, display_name, login from ` + prefix + `.users 
-inner join ` + prefix + `.admins on user_id=id order by ` + prefix +
-	`.users.id offset $1 limit $2;`
+const stmtRemoveToken = `delete from tokens where id = $1;`
@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/david-sorm/montesquieu/article/render"
+	"github.com/david-sorm/montesquieu/store"
+	"net/url"
+	"time"
+
+	// registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// the timeout of a single query, unless it's set in StoreConfig
+const defaultTimeout = 5 * time.Second
+
+// how long a connection waits for another one to finish writing, before the
+// database is reported as busy
+const busyTimeout = 5 * time.Second
+
+// Init implements Store's Init function
+func (s *Store) Init(f func(), cfg store.StoreConfig) error {
+	s.configure(cfg)
+	if err := s.dbInit(); err != nil {
+		return err
+	}
+	return s.migrate(s.ctx)
+}
+
+// PendingMigrations implements Migrator's PendingMigrations function
+func (s *Store) PendingMigrations(cfg store.StoreConfig) ([]store.Migration, error) {
+	s.configure(cfg)
+	// the Store is only used to list the migrations, so it's closed right away
+	defer s.Close()
+	if err := s.dbInit(); err != nil {
+		return nil, err
+	}
+	return s.pendingMigrations(s.ctx)
+}
+
+// Close implements Store's Close function
+// Queries which are still running are cancelled
+func (s *Store) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	// the db is nil if Init couldn't open it
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// configure copies the config into the Store, using defaults for whatever
+// isn't set
+func (s *Store) configure(cfg store.StoreConfig) {
+	s.ArticlesPerIndexPage = cfg.ArticlesPerIndexPage
+	s.Timeout = cfg.Timeout
+	if s.Timeout <= 0 {
+		s.Timeout = defaultTimeout
+	}
+	s.Renderer = cfg.Renderer
+	if s.Renderer == nil {
+		s.Renderer = render.Markdown{}
+	}
+	s.PreviewLength = cfg.PreviewLength
+	if s.PreviewLength == 0 {
+		s.PreviewLength = render.DefaultPreviewLength
+	}
+	s.Path = cfg.Path
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+// opens the database file, creating it if it doesn't exist yet, the schema is
+// brought up to date by migrate
+func (s *Store) dbInit() error {
+	if s.Path == "" {
+		return errors.New("the path of the sqlite database isn't set")
+	}
+
+	var err error
+	s.db, err = sql.Open("sqlite3", dsn(s.Path))
+	if err != nil {
+		return fmt.Errorf("couldn't open the sqlite database: %w", err)
+	}
+	// sqlite only opens the file once it's needed
+	if err := s.db.PingContext(s.ctx); err != nil {
+		s.db.Close()
+		s.db = nil
+		return fmt.Errorf("couldn't open the sqlite database %v: %w", s.Path, err)
+	}
+	return nil
+}
+
+// dsn returns the data source name of the database at path
+// The settings apply to every connection: WAL lets readers work while an
+// article is being written, foreign keys are off in sqlite by default and
+// transactions take the write lock right away, so two of them can't deadlock
+// trying to upgrade their read locks
+func dsn(path string) string {
+	q := url.Values{}
+	q.Set("_journal_mode", "WAL")
+	q.Set("_foreign_keys", "on")
+	q.Set("_busy_timeout", fmt.Sprint(busyTimeout.Milliseconds()))
+	q.Set("_txlock", "immediate")
+	// the path isn't a URI, so it's used as it is
+	return path + "?" + q.Encode()
+}
+
+// withTimeout returns a context for a single query, which is cancelled either
+// when the parent is cancelled or when the query takes too long
+func (s *Store) withTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, s.Timeout)
+}
@@ -0,0 +1,45 @@
+//go:build cgo
+// +build cgo
+
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/mattn/go-sqlite3"
+	"strings"
+)
+
+// wrapError converts errors returned by sqlite into errors of the Store
+// stmt is the statement which has caused the error, activity describes what we
+// were doing
+func wrapError(stmt string, activity string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		// not an error reported by sqlite, so it's most likely a timeout
+		return store.NewError(store.ErrUnavailable, activity, err)
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return store.NewError(store.ErrConflict, activity, err)
+
+	case sqlite3.ErrConstraintForeignKey:
+		// if we're deleting something, it's still referenced by something else,
+		// otherwise we're referencing something which doesn't exist
+		if strings.HasPrefix(strings.TrimSpace(stmt), "delete") {
+			return store.NewError(store.ErrConflict, activity, err)
+		}
+		return store.NewError(store.ErrInvalidInput, activity, err)
+
+	case sqlite3.ErrConstraintNotNull, sqlite3.ErrConstraintCheck:
+		return store.NewError(store.ErrInvalidInput, activity, err)
+	}
+
+	return store.NewError(store.ErrUnavailable, activity, err)
+}
@@ -0,0 +1,20 @@
+//go:build !cgo
+// +build !cgo
+
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/david-sorm/montesquieu/store"
+)
+
+// wrapError converts errors returned by sqlite into errors of the Store
+// Without cgo, the driver can't open any database, so Init fails before any
+// query is made
+func wrapError(stmt string, activity string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
+	return store.NewError(store.ErrUnavailable, activity, err)
+}
@@ -0,0 +1,1049 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/article/render"
+	"github.com/david-sorm/montesquieu/comments"
+	"github.com/david-sorm/montesquieu/store"
+	"github.com/david-sorm/montesquieu/users"
+	"html"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SQLite implementation of Store, the whole blog is kept in a single file
+type Store struct {
+	ArticlesPerIndexPage uint64
+
+	// how long a single query can take
+	Timeout time.Duration
+
+	// renders the source of articles into HTML
+	Renderer render.Renderer
+
+	// how many words long the generated previews are
+	PreviewLength uint64
+
+	// the file of the database, it's created if it doesn't exist
+	Path string
+
+	// the opened database
+	db *sql.DB
+
+	// the context of the Store, it's cancelled once the Store is closed
+	// it's used as a parent to all other contexts
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// IsAdmin implements Store's IsAdmin function
+func (s *Store) IsAdmin(ctx context.Context, id uint64) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count uint8
+	err := s.db.QueryRowContext(ctx, stmtIsAdmin, id).Scan(&count)
+	if err != nil {
+		return false, wrapError(stmtIsAdmin, "checking if the user is an admin", err)
+	}
+	return count == 1, nil
+}
+
+// Info implements Store's Info function
+func (s *Store) Info() store.StoreInfo {
+	return store.StoreInfo{
+		Name:      "sqlite",
+		Developer: "david-sorm",
+	}
+}
+
+// ListUsers implements Store's ListUsers function
+func (s *Store) ListUsers(ctx context.Context, from uint64, to uint64) ([]users.User, error) {
+	return s.listUsers(ctx, stmtListUsers, "listing users", from, to)
+}
+
+// ListAdmins implements Store's ListAdmins function
+func (s *Store) ListAdmins(ctx context.Context, from uint64, to uint64) ([]users.User, error) {
+	return s.listUsers(ctx, stmtListAdmins, "listing admins", from, to)
+}
+
+// listUsers lists users using stmt, which takes the offset and the limit,
+// 'from' and 'to' work the same way as in ListUsers
+func (s *Store) listUsers(ctx context.Context, stmt string, activity string, from uint64,
+	to uint64) ([]users.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, stmt, from, rowLimit(from, to))
+	if err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+	defer rows.Close()
+
+	us := make([]users.User, 0, 0)
+	for rows.Next() {
+		u := users.User{}
+		var role string
+		if err := rows.Scan(&u.ID, &u.DisplayName, &u.Login, &role); err != nil {
+			return nil, wrapError(stmt, activity, err)
+		}
+		u.Role = users.Role(role)
+		us = append(us, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+	return us, nil
+}
+
+// GetUserID implements Store's GetUserID function
+func (s *Store) GetUserID(ctx context.Context, login string) (uint64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var id uint64
+	err := s.db.QueryRowContext(ctx, stmtGetUserID, login).Scan(&id)
+	if err != nil {
+		return 0, wrapError(stmtGetUserID, "getting user's id", err)
+	}
+	return id, nil
+}
+
+// GetUser implements Store's GetUser function
+func (s *Store) GetUser(ctx context.Context, id uint64) (users.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	u := users.User{}
+	var role string
+	err := s.db.QueryRowContext(ctx, stmtGetUser, id).Scan(&u.ID,
+		&u.DisplayName, &u.Login, &u.Password, &role)
+	if err != nil {
+		return users.User{}, wrapError(stmtGetUser, "getting a user", err)
+	}
+	u.Role = users.Role(role)
+	return u, nil
+}
+
+// ListAuthors implements Store's ListAuthors function
+func (s *Store) ListAuthors(ctx context.Context, from uint64, to uint64) ([]users.Author, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "listing authors"
+	rows, err := s.db.QueryContext(ctx, stmtListAuthors, from, rowLimit(from, to))
+	if err != nil {
+		return nil, wrapError(stmtListAuthors, activity, err)
+	}
+	defer rows.Close()
+
+	authors := make([]users.Author, 0, 0)
+	for rows.Next() {
+		a := users.Author{}
+		if err := rows.Scan(&a.ID, &a.DisplayName, &a.Login, &a.AuthorID, &a.AuthorName); err != nil {
+			return nil, wrapError(stmtListAuthors, activity, err)
+		}
+		authors = append(authors, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListAuthors, activity, err)
+	}
+	return authors, nil
+}
+
+// LoadArticlesSortedByLatest implements Store's LoadArticlesSortedByLatest function
+func (s *Store) LoadArticlesSortedByLatest(ctx context.Context, from uint64, to uint64) ([]article.Article, error) {
+	return s.loadArticles(ctx, stmtLoadArticlesSortedByNewest, from, to)
+}
+
+// LoadAllArticlesSortedByLatest implements Store's LoadAllArticlesSortedByLatest function
+func (s *Store) LoadAllArticlesSortedByLatest(ctx context.Context, from uint64, to uint64) ([]article.Article, error) {
+	return s.loadArticles(ctx, stmtLoadAllArticlesSortedByNewest, from, to)
+}
+
+// LoadArticlesByAuthor implements Store's LoadArticlesByAuthor function
+func (s *Store) LoadArticlesByAuthor(ctx context.Context, authorId uint64, from uint64, to uint64) ([]article.Article, error) {
+	return s.loadArticles(ctx, stmtLoadArticlesByAuthor, from, to, authorId)
+}
+
+// LoadArticlesByTag implements Store's LoadArticlesByTag function
+func (s *Store) LoadArticlesByTag(ctx context.Context, tag string, from uint64, to uint64) ([]article.Article, error) {
+	return s.loadArticles(ctx, stmtLoadArticlesByTag, from, to, tag)
+}
+
+// loadArticles loads articles sorted by latest using stmt, 'from' and 'to'
+// work the same way as in LoadArticlesSortedByLatest, args are passed to stmt
+// after them
+func (s *Store) loadArticles(ctx context.Context, stmt string, from uint64, to uint64,
+	args ...interface{}) ([]article.Article, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "loading articles"
+	rows, err := s.db.QueryContext(ctx, stmt, append([]interface{}{from, rowLimit(from, to)}, args...)...)
+	if err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+	defer rows.Close()
+
+	articles := make([]article.Article, 0, s.ArticlesPerIndexPage)
+	for rows.Next() {
+		a, err := scanListedArticle(rows)
+		if err != nil {
+			return nil, wrapError(stmt, activity, err)
+		}
+		articles = append(articles, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+
+	return articles, nil
+}
+
+// scanner is satisfied both by a single row and by rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// rowLimit converts 'from' and 'to' of Store's functions into the limit of a
+// statement, which skips 'from' rows itself
+func rowLimit(from uint64, to uint64) uint64 {
+	if to < from {
+		return 0
+	}
+	return to - from
+}
+
+// scans the articleListColumns of a row, the rest of the columns is scanned
+// into dest
+func scanListedArticle(row scanner, dest ...interface{}) (article.Article, error) {
+	var title string
+	var articleId uint64
+	var slug string
+	var authorId uint64
+	var htmlPreview string
+	var timestamp int64
+	var status string
+	var tags string
+
+	err := row.Scan(append([]interface{}{&title, &articleId, &slug, &authorId, &htmlPreview,
+		&timestamp, &status, &tags}, dest...)...)
+	return article.Article{
+		Title:     title,
+		ID:        articleId,
+		Slug:      slug,
+		AuthorID:  authorId,
+		Timestamp: uint64(timestamp),
+		Content:   template.HTML(htmlPreview),
+		Status:    article.Status(status),
+		Tags:      splitTags(tags),
+	}, err
+}
+
+// splitTags splits the tags of articleTagsColumn and sorts them
+func splitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	split := strings.Split(tags, ",")
+	sort.Strings(split)
+	return split
+}
+
+// SearchArticles implements Store's SearchArticles function
+func (s *Store) SearchArticles(ctx context.Context, query string, from uint64, to uint64) ([]article.SearchResult, error) {
+	results := make([]article.SearchResult, 0, 0)
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "searching articles"
+	stmt, args := searchStmt(stmtSearchArticles, terms, 3)
+	rows, err := s.db.QueryContext(ctx, stmt, append([]interface{}{from, rowLimit(from, to)}, args...)...)
+	if err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var content string
+		a, err := scanListedArticle(rows, &content)
+		if err != nil {
+			return nil, wrapError(stmt, activity, err)
+		}
+		// the snippet is made from the whole article, but only the preview is
+		// returned
+		snippet := article.Snippet(template.HTML(content), terms, article.SnippetLength)
+		results = append(results, article.SearchResult{Article: a, Snippet: snippet})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+	return results, nil
+}
+
+// GetSearchResultNumber implements Store's GetSearchResultNumber function
+func (s *Store) GetSearchResultNumber(ctx context.Context, query string) (uint64, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	count := uint64(0)
+	stmt, args := searchStmt(stmtSearchResultNumber, terms, 1)
+	err := s.db.QueryRowContext(ctx, stmt, args...).Scan(&count)
+	if err != nil {
+		return 0, wrapError(stmt, "getting the number of search results", err)
+	}
+	return count, nil
+}
+
+// searchTerms returns the words of the query, each of them only once
+func searchTerms(query string) []string {
+	terms := make([]string, 0, 0)
+	seen := make(map[string]bool)
+	for _, t := range article.Tokenize(query) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// searchStmt fills the placeholders of the terms into stmt, their parameters
+// are numbered from first on
+func searchStmt(stmt string, terms []string, first int) (string, []interface{}) {
+	placeholders := make([]string, len(terms))
+	args := make([]interface{}, len(terms))
+	for k, t := range terms {
+		placeholders[k] = fmt.Sprintf("?%v", first+k)
+		args[k] = t
+	}
+	return fmt.Sprintf(stmt, strings.Join(placeholders, ", "), len(terms)), args
+}
+
+// articleWords returns the words of the article together with their weights,
+// the title weighs more than the content
+func articleWords(title string, content template.HTML) map[string]int {
+	words := make(map[string]int)
+	for _, t := range article.Tokenize(title) {
+		words[t] += 2
+	}
+	for _, t := range article.Tokenize(html.UnescapeString(article.StripTags(content))) {
+		words[t]++
+	}
+	return words
+}
+
+// setArticleContents replaces the tags and the words of the article
+func setArticleContents(ctx context.Context, tx *sql.Tx, activity string, a article.Article,
+	content template.HTML) error {
+	if _, err := tx.ExecContext(ctx, stmtRemoveArticleTags, a.ID); err != nil {
+		return wrapError(stmtRemoveArticleTags, activity, err)
+	}
+	for _, tag := range a.Tags {
+		if _, err := tx.ExecContext(ctx, stmtAddArticleTag, a.ID, tag); err != nil {
+			return wrapError(stmtAddArticleTag, activity, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, stmtRemoveArticleWords, a.ID); err != nil {
+		return wrapError(stmtRemoveArticleWords, activity, err)
+	}
+	for word, weight := range articleWords(a.Title, content) {
+		if _, err := tx.ExecContext(ctx, stmtAddArticleWord, a.ID, word, weight); err != nil {
+			return wrapError(stmtAddArticleWord, activity, err)
+		}
+	}
+	return nil
+}
+
+// AddArticle implements Store's AddArticle function
+func (s *Store) AddArticle(ctx context.Context, a article.Article, userId uint64) (uint64, error) {
+	const activity = "adding an article"
+	content, preview, err := render.RenderArticle(s.Renderer, a.Source, a.Summary, s.PreviewLength)
+	if err != nil {
+		return 0, store.NewError(store.ErrInvalidInput, activity, err)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, wrapError("", activity, err)
+	}
+	defer tx.Rollback()
+
+	slug, err := uniqueSlug(ctx, tx, a)
+	if err != nil {
+		return 0, wrapError(stmtSlugTaken, activity, err)
+	}
+	result, err := tx.ExecContext(ctx, stmtNewArticle, a.Title, a.AuthorID, a.Source, a.Summary,
+		string(content), string(preview), a.Timestamp, slug, string(a.Status))
+	if err != nil {
+		return 0, wrapError(stmtNewArticle, activity, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, wrapError(stmtNewArticle, activity, err)
+	}
+	a.ID = uint64(id)
+
+	if err := setArticleContents(ctx, tx, activity, a, content); err != nil {
+		return 0, err
+	}
+
+	// the first version is a revision too
+	_, err = tx.ExecContext(ctx, stmtAddRevision, a.ID, userId, time.Now().Unix(), a.Title, a.Source, a.Summary)
+	if err != nil {
+		return 0, wrapError(stmtAddRevision, activity, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, wrapError("", activity, err)
+	}
+	return a.ID, nil
+}
+
+// EditArticle implements Store's EditArticle function
+func (s *Store) EditArticle(ctx context.Context, a article.Article, userId uint64) error {
+	const activity = "editing an article"
+	content, preview, err := render.RenderArticle(s.Renderer, a.Source, a.Summary, s.PreviewLength)
+	if err != nil {
+		return store.NewError(store.ErrInvalidInput, activity, err)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("", activity, err)
+	}
+	defer tx.Rollback()
+
+	old := article.Article{ID: a.ID}
+	var oldSlug string
+	var oldTimestamp int64
+	err = tx.QueryRowContext(ctx, stmtGetArticleForEdit, a.ID).Scan(&oldSlug, &old.Title, &old.Source,
+		&old.Summary, &oldTimestamp)
+	if err != nil {
+		return wrapError(stmtGetArticleForEdit, activity, err)
+	}
+
+	// articles whose revisions have been lost don't have any, so the current
+	// version has to be saved before it's overwritten
+	var revisions uint64
+	if err := tx.QueryRowContext(ctx, stmtRevisionNumber, a.ID).Scan(&revisions); err != nil {
+		return wrapError(stmtRevisionNumber, activity, err)
+	}
+	if revisions == 0 {
+		_, err = tx.ExecContext(ctx, stmtAddRevision, a.ID, 0, oldTimestamp, old.Title, old.Source, old.Summary)
+		if err != nil {
+			return wrapError(stmtAddRevision, activity, err)
+		}
+	}
+
+	slug, err := uniqueSlug(ctx, tx, a)
+	if err != nil {
+		return wrapError(stmtSlugTaken, activity, err)
+	}
+
+	_, err = tx.ExecContext(ctx, stmtEditArticle, a.Title, a.AuthorID, a.Source, a.Summary,
+		string(content), string(preview), a.Timestamp, slug, string(a.Status), a.ID)
+	if err != nil {
+		return wrapError(stmtEditArticle, activity, err)
+	}
+
+	if err := setArticleContents(ctx, tx, activity, a, content); err != nil {
+		return err
+	}
+
+	// the old slug keeps working, so links to the article don't break
+	if oldSlug != "" && oldSlug != slug {
+		if _, err := tx.ExecContext(ctx, stmtAddOldSlug, oldSlug, a.ID); err != nil {
+			return wrapError(stmtAddOldSlug, activity, err)
+		}
+	}
+	// the article might have gotten one of its old slugs back
+	if _, err := tx.ExecContext(ctx, stmtRemoveOldSlug, slug); err != nil {
+		return wrapError(stmtRemoveOldSlug, activity, err)
+	}
+
+	_, err = tx.ExecContext(ctx, stmtAddRevision, a.ID, userId, time.Now().Unix(), a.Title, a.Source, a.Summary)
+	if err != nil {
+		return wrapError(stmtAddRevision, activity, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return wrapError("", activity, err)
+	}
+	return nil
+}
+
+// ListRevisions implements Store's ListRevisions function
+func (s *Store) ListRevisions(ctx context.Context, articleId uint64, from uint64, to uint64) ([]article.Revision, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "listing revisions"
+	rows, err := s.db.QueryContext(ctx, stmtListRevisions, articleId, from, rowLimit(from, to))
+	if err != nil {
+		return nil, wrapError(stmtListRevisions, activity, err)
+	}
+	defer rows.Close()
+
+	revisions := make([]article.Revision, 0, 0)
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, wrapError(stmtListRevisions, activity, err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListRevisions, activity, err)
+	}
+	return revisions, nil
+}
+
+// GetRevision implements Store's GetRevision function
+func (s *Store) GetRevision(ctx context.Context, id uint64) (article.Revision, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	r, err := scanRevision(s.db.QueryRowContext(ctx, stmtGetRevision, id))
+	if err != nil {
+		return article.Revision{}, wrapError(stmtGetRevision, "getting a revision", err)
+	}
+	return r, nil
+}
+
+// RestoreRevision implements Store's RestoreRevision function
+func (s *Store) RestoreRevision(ctx context.Context, id uint64, userId uint64) error {
+	r, err := s.GetRevision(ctx, id)
+	if err != nil {
+		return err
+	}
+	a, err := s.GetArticleByID(ctx, r.ArticleID)
+	if err != nil {
+		return err
+	}
+
+	a.Title = r.Title
+	a.Source = r.Source
+	a.Summary = r.Summary
+	return s.EditArticle(ctx, a, userId)
+}
+
+// scans a row of stmtListRevisions or stmtGetRevision
+func scanRevision(row scanner) (article.Revision, error) {
+	r := article.Revision{}
+	var t int64
+	err := row.Scan(&r.ID, &r.ArticleID, &r.UserID, &r.UserName, &t, &r.Title, &r.Source, &r.Summary)
+	r.Time = fromUnix(t)
+	return r, err
+}
+
+// GetArticleBySlug implements Store's GetArticleBySlug function
+func (s *Store) GetArticleBySlug(ctx context.Context, slug string) (article.Article, error) {
+	queryCtx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var id uint64
+	if err := s.db.QueryRowContext(queryCtx, stmtGetArticleIDBySlug, slug).Scan(&id); err != nil {
+		return article.Article{}, wrapError(stmtGetArticleIDBySlug, "getting an article", err)
+	}
+	return s.GetArticleByID(ctx, id)
+}
+
+// uniqueSlug returns the slug of the article (or the one generated from its
+// title) made unique among all other articles
+func uniqueSlug(ctx context.Context, q querier, a article.Article) (string, error) {
+	slug := a.Slug
+	if slug == "" {
+		slug = a.Title
+	}
+	return article.UniqueSlug(article.Slugify(slug), func(slug string) (bool, error) {
+		var count uint64
+		err := q.QueryRowContext(ctx, stmtSlugTaken, slug, a.ID).Scan(&count)
+		return count > 0, err
+	})
+}
+
+// RemoveArticle implements Store's RemoveArticle function
+func (s *Store) RemoveArticle(ctx context.Context, id uint64) error {
+	return s.doExec(ctx, stmtRemoveArticle, "removing an article", id)
+}
+
+// AddUser implements Store's AddUser function
+func (s *Store) AddUser(ctx context.Context, displayName string, login string, password string) error {
+	return s.doExec(ctx, stmtAddUser, "adding a new user", displayName, login, password)
+}
+
+// EditUser implements Store's EditUser function
+func (s *Store) EditUser(ctx context.Context, user users.User) error {
+	return s.doExec(ctx, stmtEditUser, "editing a user", user.DisplayName, user.Login,
+		user.Password, user.ID)
+}
+
+// SetRole implements Store's SetRole function
+func (s *Store) SetRole(ctx context.Context, userId uint64, role users.Role) error {
+	return s.doExec(ctx, stmtSetRole, "changing the role of a user", string(role), userId)
+}
+
+// RemoveUser implements Store's RemoveUser function
+func (s *Store) RemoveUser(ctx context.Context, id uint64) error {
+	return s.doExec(ctx, stmtRemoveUser, "removing a user", id)
+}
+
+// GetAuthor implements Store's GetAuthor function
+func (s *Store) GetAuthor(ctx context.Context, userId uint64) (users.Author, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	author := users.Author{}
+	err := s.db.QueryRowContext(ctx, stmtGetAuthor, userId).Scan(
+		&author.AuthorID, &author.AuthorName)
+	if err != nil {
+		return users.Author{}, wrapError(stmtGetAuthor, "getting an author", err)
+	}
+	return author, nil
+}
+
+// GetAuthorByID implements Store's GetAuthorByID function
+func (s *Store) GetAuthorByID(ctx context.Context, authorId uint64) (users.Author, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	author := users.Author{}
+	err := s.db.QueryRowContext(ctx, stmtGetAuthorByID, authorId).Scan(
+		&author.AuthorID, &author.AuthorName)
+	if err != nil {
+		return users.Author{}, wrapError(stmtGetAuthorByID, "getting an author", err)
+	}
+	return author, nil
+}
+
+// AddAuthor implements Store's AddAuthor function
+func (s *Store) AddAuthor(ctx context.Context, userId uint64, authorName string) error {
+	return s.doExec(ctx, stmtAddAuthor, "adding an author", userId, authorName)
+}
+
+// LinkAuthor implements Store's LinkAuthor function
+func (s *Store) LinkAuthor(ctx context.Context, authorId uint64, userId uint64) error {
+	return s.doExec(ctx, stmtLinkAuthor, "linking a user to an author", userId, authorId)
+}
+
+// RemoveAuthor implements Store's RemoveAuthor function
+func (s *Store) RemoveAuthor(ctx context.Context, authorId uint64) error {
+	return s.doExec(ctx, stmtRemoveAuthor, "removing an author", authorId)
+}
+
+// PromoteToAdmin implements Store's PromoteToAdmin function
+func (s *Store) PromoteToAdmin(ctx context.Context, userId uint64) error {
+	const activity = "promoting a user to an admin"
+	err := s.doExec(ctx, stmtPromoteToAdmin, activity, userId)
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	// nothing has changed, either the user doesn't exist or is an admin already
+	isAdmin, err := s.IsAdmin(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if isAdmin {
+		return store.NewError(store.ErrConflict, activity, nil)
+	}
+	return store.NewError(store.ErrNotFound, activity, nil)
+}
+
+// DemoteFromAdmin implements Store's DemoteFromAdmin function
+func (s *Store) DemoteFromAdmin(ctx context.Context, userId uint64) error {
+	return s.doExec(ctx, stmtDemoteFromAdmin, "demoting a user from an admin", userId)
+}
+
+// GetArticleNumber implements Store's GetArticleNumber function
+func (s *Store) GetArticleNumber(ctx context.Context) (uint64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	count := uint64(0)
+	err := s.db.QueryRowContext(ctx, stmtArticleNumber).Scan(&count)
+	if err != nil {
+		return 0, wrapError(stmtArticleNumber, "getting the number of articles", err)
+	}
+	return count, nil
+}
+
+// GetArticleNumberByTag implements Store's GetArticleNumberByTag function
+func (s *Store) GetArticleNumberByTag(ctx context.Context, tag string) (uint64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	count := uint64(0)
+	err := s.db.QueryRowContext(ctx, stmtArticleNumberByTag, tag).Scan(&count)
+	if err != nil {
+		return 0, wrapError(stmtArticleNumberByTag, "getting the number of articles with a tag", err)
+	}
+	return count, nil
+}
+
+// ListTags implements Store's ListTags function
+func (s *Store) ListTags(ctx context.Context) ([]article.TagCount, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "listing tags"
+	rows, err := s.db.QueryContext(ctx, stmtListTags)
+	if err != nil {
+		return nil, wrapError(stmtListTags, activity, err)
+	}
+	defer rows.Close()
+
+	tags := make([]article.TagCount, 0, 0)
+	for rows.Next() {
+		t := article.TagCount{}
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, wrapError(stmtListTags, activity, err)
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListTags, activity, err)
+	}
+	return tags, nil
+}
+
+// PublishScheduledArticles implements Store's PublishScheduledArticles function
+func (s *Store) PublishScheduledArticles(ctx context.Context, now time.Time) (uint64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "publishing scheduled articles"
+	result, err := s.db.ExecContext(ctx, stmtPublishScheduledArticles, now.Unix())
+	if err != nil {
+		return 0, wrapError(stmtPublishScheduledArticles, activity, err)
+	}
+	published, err := result.RowsAffected()
+	if err != nil {
+		return 0, wrapError(stmtPublishScheduledArticles, activity, err)
+	}
+	return uint64(published), nil
+}
+
+// GetArticleByID implements Store's GetArticleByID function
+func (s *Store) GetArticleByID(ctx context.Context, id uint64) (article.Article, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var title string
+	var slug string
+	var authorId uint64
+	var source string
+	var summary string
+	var htmlContent string
+	var timestamp int64
+	var status string
+	var tags string
+
+	err := s.db.QueryRowContext(ctx, stmtGetArticleByID, id).Scan(&title,
+		&slug, &authorId, &source, &summary, &htmlContent, &timestamp, &status, &tags)
+	if err != nil {
+		return article.Article{}, wrapError(stmtGetArticleByID, "getting an article", err)
+	}
+
+	return article.Article{
+		Title:     title,
+		ID:        id,
+		Slug:      slug,
+		AuthorID:  authorId,
+		Timestamp: uint64(timestamp),
+		Content:   template.HTML(htmlContent),
+		Source:    source,
+		Summary:   summary,
+		Status:    article.Status(status),
+		Tags:      splitTags(tags),
+	}, nil
+}
+
+// AddSession implements Store's AddSession function
+func (s *Store) AddSession(ctx context.Context, session users.Session) error {
+	return s.doExec(ctx, stmtAddSession, "adding a session", session.ID, session.UserID,
+		session.ValidUntil.Unix())
+}
+
+// GetSession implements Store's GetSession function
+func (s *Store) GetSession(ctx context.Context, id uint64) (users.Session, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	session := users.Session{}
+	var validUntil int64
+	err := s.db.QueryRowContext(ctx, stmtGetSession, id, time.Now().Unix()).Scan(
+		&session.ID, &session.UserID, &validUntil)
+	if err != nil {
+		return users.Session{}, wrapError(stmtGetSession, "getting a session", err)
+	}
+
+	session.ValidUntil = fromUnix(validUntil)
+	return session, nil
+}
+
+// ExtendSession implements Store's ExtendSession function
+func (s *Store) ExtendSession(ctx context.Context, id uint64, validUntil time.Time) error {
+	return s.doExec(ctx, stmtExtendSession, "extending a session", validUntil.Unix(), id)
+}
+
+// RemoveSession implements Store's RemoveSession function
+func (s *Store) RemoveSession(ctx context.Context, id uint64) error {
+	return s.doExec(ctx, stmtRemoveSession, "removing a session", id)
+}
+
+// RemoveUserSessions implements Store's RemoveUserSessions function
+func (s *Store) RemoveUserSessions(ctx context.Context, userId uint64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// users without any sessions are fine, so doExec isn't used
+	_, err := s.db.ExecContext(ctx, stmtRemoveUserSessions, userId)
+	if err != nil {
+		return wrapError(stmtRemoveUserSessions, "removing sessions of a user", err)
+	}
+	return nil
+}
+
+// RemoveExpiredSessions implements Store's RemoveExpiredSessions function
+func (s *Store) RemoveExpiredSessions(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, stmtRemoveExpiredSessions, time.Now().Unix())
+	if err != nil {
+		return wrapError(stmtRemoveExpiredSessions, "removing expired sessions", err)
+	}
+	return nil
+}
+
+// fromUnix converts a saved unix timestamp back to the time in UTC
+func fromUnix(t int64) time.Time {
+	return time.Unix(t, 0).UTC()
+}
+
+// doExec is a helper function that helps prevent code duplication when doing
+// simple exec queries
+// If no rows were affected, ErrNotFound is returned
+func (s *Store) doExec(ctx context.Context, stmt string, activity string, arguments ...interface{}) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, stmt, arguments...)
+	if err != nil {
+		return wrapError(stmt, activity, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError(stmt, activity, err)
+	}
+	if affected == 0 {
+		return store.NewError(store.ErrNotFound, activity, nil)
+	}
+	return nil
+}
+
+// AddComment implements Store's AddComment function
+func (s *Store) AddComment(ctx context.Context, c comments.Comment) error {
+	const activity = "adding a comment"
+	// comments of users get the current display name of the user
+	if c.UserID != 0 {
+		c.Name = ""
+	}
+	err := s.doExec(ctx, stmtAddComment, activity, c.ArticleID, c.ParentID, c.UserID, c.Name,
+		time.Now().Unix(), string(c.Status), c.UnsafeContent)
+	// the parent doesn't belong to the article
+	if errors.Is(err, store.ErrNotFound) {
+		return store.NewError(store.ErrInvalidInput, activity, nil)
+	}
+	return err
+}
+
+// ListComments implements Store's ListComments function
+func (s *Store) ListComments(ctx context.Context, articleId uint64, from uint64, to uint64) ([]comments.Comment, error) {
+	return s.listComments(ctx, stmtListComments, articleId, from, rowLimit(from, to))
+}
+
+// ListCommentsByStatus implements Store's ListCommentsByStatus function
+func (s *Store) ListCommentsByStatus(ctx context.Context, status comments.Status, from uint64, to uint64) ([]comments.Comment, error) {
+	return s.listComments(ctx, stmtListCommentsByStatus, string(status), from, rowLimit(from, to))
+}
+
+// listComments lists comments using stmt, which takes the arguments
+func (s *Store) listComments(ctx context.Context, stmt string, arguments ...interface{}) ([]comments.Comment, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "listing comments"
+	rows, err := s.db.QueryContext(ctx, stmt, arguments...)
+	if err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+	defer rows.Close()
+
+	cs := make([]comments.Comment, 0, 0)
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, wrapError(stmt, activity, err)
+		}
+		cs = append(cs, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmt, activity, err)
+	}
+	return cs, nil
+}
+
+// GetCommentNumber implements Store's GetCommentNumber function
+func (s *Store) GetCommentNumber(ctx context.Context, articleId uint64) (uint64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	count := uint64(0)
+	err := s.db.QueryRowContext(ctx, stmtCommentNumber, articleId).Scan(&count)
+	if err != nil {
+		return 0, wrapError(stmtCommentNumber, "getting the number of comments", err)
+	}
+	return count, nil
+}
+
+// GetComment implements Store's GetComment function
+func (s *Store) GetComment(ctx context.Context, id uint64) (comments.Comment, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	c, err := scanComment(s.db.QueryRowContext(ctx, stmtGetComment, id))
+	if err != nil {
+		return comments.Comment{}, wrapError(stmtGetComment, "getting a comment", err)
+	}
+	return c, nil
+}
+
+// SetCommentStatus implements Store's SetCommentStatus function
+func (s *Store) SetCommentStatus(ctx context.Context, id uint64, status comments.Status) error {
+	return s.doExec(ctx, stmtSetCommentStatus, "changing the status of a comment", string(status), id)
+}
+
+// RemoveComment implements Store's RemoveComment function
+func (s *Store) RemoveComment(ctx context.Context, id uint64) error {
+	return s.doExec(ctx, stmtRemoveComment, "removing a comment", id)
+}
+
+// scans a row with commentColumns
+func scanComment(row scanner) (comments.Comment, error) {
+	c := comments.Comment{}
+	var t int64
+	var status string
+	err := row.Scan(&c.ID, &c.ArticleID, &c.ParentID, &c.UserID, &c.Name, &t, &status, &c.UnsafeContent)
+	c.Status = comments.Status(status)
+	c.Time = fromUnix(t)
+	return c, err
+}
+
+// AddToken implements Store's AddToken function
+func (s *Store) AddToken(ctx context.Context, token users.Token) (uint64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "adding a token"
+	result, err := s.db.ExecContext(ctx, stmtAddToken, token.UserID, token.Name, string(token.Scope),
+		token.Hash, token.Created.Unix())
+	if err != nil {
+		return 0, wrapError(stmtAddToken, activity, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, wrapError(stmtAddToken, activity, err)
+	}
+	return uint64(id), nil
+}
+
+// ListTokens implements Store's ListTokens function
+func (s *Store) ListTokens(ctx context.Context, userId uint64) ([]users.Token, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const activity = "listing tokens"
+	rows, err := s.db.QueryContext(ctx, stmtListTokens, userId)
+	if err != nil {
+		return nil, wrapError(stmtListTokens, activity, err)
+	}
+	defer rows.Close()
+
+	tokens := make([]users.Token, 0, 0)
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, wrapError(stmtListTokens, activity, err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(stmtListTokens, activity, err)
+	}
+	return tokens, nil
+}
+
+// GetToken implements Store's GetToken function
+func (s *Store) GetToken(ctx context.Context, id uint64) (users.Token, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	t, err := scanToken(s.db.QueryRowContext(ctx, stmtGetToken, id))
+	if err != nil {
+		return users.Token{}, wrapError(stmtGetToken, "getting a token", err)
+	}
+	return t, nil
+}
+
+// TouchToken implements Store's TouchToken function
+func (s *Store) TouchToken(ctx context.Context, id uint64, lastUsed time.Time) error {
+	return s.doExec(ctx, stmtTouchToken, "touching a token", lastUsed.Unix(), id)
+}
+
+// RemoveToken implements Store's RemoveToken function
+func (s *Store) RemoveToken(ctx context.Context, id uint64) error {
+	return s.doExec(ctx, stmtRemoveToken, "removing a token", id)
+}
+
+// scanToken scans a row with the columns in tokenColumns
+func scanToken(row scanner) (users.Token, error) {
+	t := users.Token{}
+	var scope string
+	var created int64
+	var lastUsed sql.NullInt64
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &scope, &t.Hash, &created, &lastUsed)
+	t.Scope = users.TokenScope(scope)
+	t.Created = fromUnix(created)
+	// tokens which were never used don't have the time
+	if lastUsed.Valid {
+		t.LastUsed = fromUnix(lastUsed.Int64)
+	}
+	return t, err
+}
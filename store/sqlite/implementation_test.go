@@ -0,0 +1,398 @@
+//go:build cgo
+// +build cgo
+
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"github.com/david-sorm/montesquieu/article"
+	"github.com/david-sorm/montesquieu/comments"
+	"github.com/david-sorm/montesquieu/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// context used for all calls to the store
+var ctx = context.Background()
+
+// newTestStore returns a Store with an empty database, it's removed once the
+// test is done
+func newTestStore(t *testing.T, cfg store.StoreConfig) *Store {
+	dir, err := ioutil.TempDir("", "montesquieu")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	cfg.Path = filepath.Join(dir, "blog.db")
+
+	s := &Store{}
+	if err := s.Init(func() {}, cfg); err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Init() error = %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		os.RemoveAll(dir)
+	})
+	return s
+}
+
+// addAuthor adds a user together with an author and returns their IDs
+func addAuthor(t *testing.T, s *Store, login string) (uint64, uint64) {
+	if err := s.AddUser(ctx, login, login, ""); err != nil {
+		t.Fatalf("AddUser() error = %v", err)
+	}
+	userID, _ := s.GetUserID(ctx, login)
+	if err := s.AddAuthor(ctx, userID, login); err != nil {
+		t.Fatalf("AddAuthor() error = %v", err)
+	}
+	author, _ := s.GetAuthor(ctx, userID)
+	return userID, author.AuthorID
+}
+
+// addArticle adds a published article and returns its ID
+func addArticle(t *testing.T, s *Store, a article.Article, userID uint64) uint64 {
+	if a.Status == "" {
+		a.Status = article.Published
+	}
+	id, err := s.AddArticle(ctx, a, userID)
+	if err != nil {
+		t.Fatalf("AddArticle(%v) error = %v", a.Title, err)
+	}
+	return id
+}
+
+func TestStore_Init(t *testing.T) {
+	s := newTestStore(t, store.StoreConfig{})
+
+	var mode string
+	if err := s.db.QueryRowContext(ctx, "pragma journal_mode;").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %v, %v; want wal", mode, err)
+	}
+	var foreignKeys bool
+	if err := s.db.QueryRowContext(ctx, "pragma foreign_keys;").Scan(&foreignKeys); err != nil || !foreignKeys {
+		t.Errorf("foreign_keys = %v, %v; want on", foreignKeys, err)
+	}
+
+	// opening the database again doesn't migrate it twice and keeps the data
+	s.AddUser(ctx, "Reader", "reader", "")
+	s.Close()
+	pending, err := (&Store{}).PendingMigrations(store.StoreConfig{Path: s.Path})
+	if err != nil || len(pending) != 0 {
+		t.Errorf("PendingMigrations() = %v, %v; want none", pending, err)
+	}
+	if err := s.Init(func() {}, store.StoreConfig{Path: s.Path}); err != nil {
+		t.Fatalf("Init() of an existing database error = %v", err)
+	}
+	if _, err := s.GetUserID(ctx, "reader"); err != nil {
+		t.Errorf("GetUserID() after Init() error = %v", err)
+	}
+
+	if err := (&Store{}).Init(func() {}, store.StoreConfig{}); err == nil {
+		t.Errorf("Init() without a path didn't return an error")
+	}
+}
+
+// comments made before migration 2 are kept, together with their replies
+func TestStore_MigrateCommentUsers(t *testing.T) {
+	all := migrations
+	migrations = all[:1]
+	s := newTestStore(t, store.StoreConfig{})
+	migrations = all
+
+	userID, authorID := addAuthor(t, s, "author")
+	id := addArticle(t, s, article.Article{Title: "Article", AuthorID: authorID}, userID)
+	s.AddUser(ctx, "Reader", "reader", "")
+	readerID, _ := s.GetUserID(ctx, "reader")
+	s.AddComment(ctx, comments.Comment{ArticleID: id, UserID: readerID, Status: comments.Visible})
+	s.AddComment(ctx, comments.Comment{ArticleID: id, ParentID: 1, Name: "Anonymous", Status: comments.Visible})
+	s.Close()
+
+	if err := s.Init(func() {}, store.StoreConfig{Path: s.Path}); err != nil {
+		t.Fatalf("Init() of a database with comments error = %v", err)
+	}
+	if cs, _ := s.ListComments(ctx, id, 0, 10); len(cs) != 2 || cs[0].Name != "Reader" || cs[1].ParentID != 1 {
+		t.Fatalf("ListComments() = %v, want both comments", cs)
+	}
+	if err := s.RemoveUser(ctx, readerID); err != nil {
+		t.Fatalf("RemoveUser() of a commenter error = %v", err)
+	}
+	if c, err := s.GetComment(ctx, 1); err != nil || !c.UserRemoved() {
+		t.Errorf("GetComment(1) = %v, %v; want a comment of a removed user", c, err)
+	}
+	s.AddComment(ctx, comments.Comment{ArticleID: id, Name: "Anonymous", Status: comments.Visible})
+	if c, err := s.GetComment(ctx, 3); err != nil || c.Name != "Anonymous" {
+		t.Errorf("GetComment(3) = %v, %v; want a new comment", c, err)
+	}
+}
+
+func TestStore_Articles(t *testing.T) {
+	s := newTestStore(t, store.StoreConfig{PreviewLength: 2})
+	userID, authorID := addAuthor(t, s, "author")
+
+	addArticle(t, s, article.Article{Title: "Article 2", AuthorID: authorID, Timestamp: 5}, userID)
+	a := article.Article{Title: "Article 2", AuthorID: authorID, Status: article.Draft, Timestamp: 1,
+		Source: "one two three", Tags: []string{"b", "a"}}
+	id, err := s.AddArticle(ctx, a, userID)
+	if err != nil {
+		t.Fatalf("AddArticle() error = %v", err)
+	}
+	got, _ := s.GetArticleByID(ctx, id)
+	if got.Slug != "article-2-2" || got.Content != "<p>one two three</p>\n" || !reflect.DeepEqual(got.Tags, []string{"a", "b"}) {
+		t.Errorf("GetArticleByID() = %#v", got)
+	}
+
+	invalid := []article.Article{
+		{Title: "No author", AuthorID: 42, Status: article.Draft},
+		{Title: "No status", AuthorID: authorID},
+	}
+	for _, a := range invalid {
+		if _, err := s.AddArticle(ctx, a, userID); !errors.Is(err, store.ErrInvalidInput) {
+			t.Errorf("AddArticle(%v) error = %v, want ErrInvalidInput", a.Title, err)
+		}
+	}
+
+	// drafts aren't listed, but their previews are shown in the admin panel
+	if num, _ := s.GetArticleNumber(ctx); num != 1 {
+		t.Errorf("GetArticleNumber() = %v, want 1", num)
+	}
+	all, _ := s.LoadAllArticlesSortedByLatest(ctx, 1, 100)
+	if len(all) != 1 || all[0].Content != "<p>one two…</p>" {
+		t.Errorf("LoadAllArticlesSortedByLatest() = %#v, want the preview of the draft", all)
+	}
+	if byAuthor, _ := s.LoadArticlesByAuthor(ctx, authorID, 0, 10); len(byAuthor) != 2 {
+		t.Errorf("LoadArticlesByAuthor() = %v, want both articles", byAuthor)
+	}
+
+	// the old slug still leads to the article
+	got.Slug = "renamed"
+	got.Status = article.Scheduled
+	if err := s.EditArticle(ctx, got, userID); err != nil {
+		t.Fatalf("EditArticle() error = %v", err)
+	}
+	if byOld, err := s.GetArticleBySlug(ctx, "article-2-2"); err != nil || byOld.ID != id {
+		t.Errorf("GetArticleBySlug() of the old slug = %v, %v", byOld.ID, err)
+	}
+	if err := s.EditArticle(ctx, article.Article{ID: 250604, Title: "Missing"}, userID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("EditArticle() of a missing article error = %v, want ErrNotFound", err)
+	}
+
+	if num, _ := s.PublishScheduledArticles(ctx, time.Unix(1, 0)); num != 1 {
+		t.Errorf("PublishScheduledArticles() = %v, want 1", num)
+	}
+	if latest, _ := s.LoadArticlesSortedByLatest(ctx, 1, 2); len(latest) != 1 || latest[0].ID != id {
+		t.Errorf("LoadArticlesSortedByLatest() = %v, want the published article last", latest)
+	}
+
+	// authors of articles can't be removed
+	if err := s.RemoveAuthor(ctx, authorID); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("RemoveAuthor() of an author of articles error = %v, want ErrConflict", err)
+	}
+
+	// everything belonging to the article is removed with it
+	s.AddComment(ctx, comments.Comment{ArticleID: id, Status: comments.Visible})
+	if err := s.RemoveArticle(ctx, id); err != nil {
+		t.Fatalf("RemoveArticle() error = %v", err)
+	}
+	if _, err := s.GetArticleBySlug(ctx, "article-2-2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetArticleBySlug() of a removed article error = %v, want ErrNotFound", err)
+	}
+	if revisions, _ := s.ListRevisions(ctx, id, 0, 10); len(revisions) != 0 {
+		t.Errorf("RemoveArticle() left %v revisions", len(revisions))
+	}
+	if err := s.RemoveArticle(ctx, id); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RemoveArticle() of a removed article error = %v, want ErrNotFound", err)
+	}
+
+	// IDs aren't reused
+	if newID, _ := s.AddArticle(ctx, a, userID); newID != id+1 {
+		t.Errorf("AddArticle() after RemoveArticle() = %v, want %v", newID, id+1)
+	}
+}
+
+func TestStore_Revisions(t *testing.T) {
+	s := newTestStore(t, store.StoreConfig{})
+	userID, authorID := addAuthor(t, s, "author")
+
+	id := addArticle(t, s, article.Article{Title: "Original", AuthorID: authorID, Source: "Content"}, userID)
+	a, _ := s.GetArticleByID(ctx, id)
+	a.Title = "Edited"
+	a.Source = "New content"
+	if err := s.EditArticle(ctx, a, userID); err != nil {
+		t.Fatalf("EditArticle() error = %v", err)
+	}
+
+	revisions, err := s.ListRevisions(ctx, id, 0, 10)
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("ListRevisions() = %v, %v; want 2 revisions", revisions, err)
+	}
+	if revisions[0].Title != "Edited" || revisions[1].Title != "Original" || revisions[0].UserName != "author" {
+		t.Errorf("ListRevisions() = %v, want the latest revision first", revisions)
+	}
+
+	if err := s.RestoreRevision(ctx, revisions[1].ID, userID); err != nil {
+		t.Fatalf("RestoreRevision() error = %v", err)
+	}
+	if got, _ := s.GetArticleByID(ctx, id); got.Title != "Original" || got.Content != "<p>Content</p>\n" {
+		t.Errorf("RestoreRevision() left %q, %q", got.Title, got.Content)
+	}
+	if revisions, _ := s.ListRevisions(ctx, id, 0, 10); len(revisions) != 3 {
+		t.Errorf("RestoreRevision() didn't add a revision, got %v", len(revisions))
+	}
+
+	if _, err := s.GetRevision(ctx, 100); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetRevision(100) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_Tags(t *testing.T) {
+	s := newTestStore(t, store.StoreConfig{})
+	userID, authorID := addAuthor(t, s, "author")
+
+	for i := uint64(1); i <= 4; i++ {
+		tags := []string{"lorem-ipsum"}
+		if i%2 == 0 {
+			tags = append(tags, "even")
+		}
+		addArticle(t, s, article.Article{Title: "Article", AuthorID: authorID, Timestamp: i, Tags: tags}, userID)
+	}
+	addArticle(t, s, article.Article{Title: "Draft", AuthorID: authorID, Status: article.Draft,
+		Tags: []string{"draft"}}, userID)
+
+	if num, _ := s.GetArticleNumberByTag(ctx, "even"); num != 2 {
+		t.Errorf("GetArticleNumberByTag() = %v, want 2", num)
+	}
+	articles, _ := s.LoadArticlesByTag(ctx, "lorem-ipsum", 1, 3)
+	if len(articles) != 2 || articles[0].Timestamp != 3 || articles[1].Timestamp != 2 {
+		t.Errorf("LoadArticlesByTag() = %v, want the 2nd and 3rd latest articles", articles)
+	}
+
+	// tags of drafts aren't listed
+	want := []article.TagCount{{Name: "even", Count: 2}, {Name: "lorem-ipsum", Count: 4}}
+	if got, _ := s.ListTags(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListTags() = %v, want %v", got, want)
+	}
+}
+
+func TestStore_Search(t *testing.T) {
+	s := newTestStore(t, store.StoreConfig{})
+	userID, authorID := addAuthor(t, s, "author")
+
+	addArticle(t, s, article.Article{Title: "Montesquieu", AuthorID: authorID, Timestamp: 3,
+		Source: "The spirit of the laws"}, userID)
+	body := addArticle(t, s, article.Article{Title: "Body", AuthorID: authorID, Timestamp: 2,
+		Source: "Lorem *ipsum* dolor sit amet"}, userID)
+	title := addArticle(t, s, article.Article{Title: "Lorem", AuthorID: authorID, Timestamp: 1,
+		Source: "Lorem ipsum"}, userID)
+	addArticle(t, s, article.Article{Title: "Lorem ipsum", AuthorID: authorID, Status: article.Draft}, userID)
+
+	// the title counts more, so the matching article goes first
+	if num, _ := s.GetSearchResultNumber(ctx, "LOREM, ipsum lorem"); num != 2 {
+		t.Errorf("GetSearchResultNumber() = %v, want 2", num)
+	}
+	results, _ := s.SearchArticles(ctx, "lorem ipsum", 0, 10)
+	if len(results) != 2 || results[0].ID != title || results[1].ID != body {
+		t.Fatalf("SearchArticles() = %v, want articles %v and %v", results, title, body)
+	}
+	if results[1].Snippet != "<mark>Lorem</mark> <mark>ipsum</mark> dolor sit amet" {
+		t.Errorf("SearchArticles() snippet = %#v", results[1].Snippet)
+	}
+	if results, _ := s.SearchArticles(ctx, "lorem ipsum", 1, 10); len(results) != 1 || results[0].ID != body {
+		t.Errorf("SearchArticles() from 1 = %v, want article %v", results, body)
+	}
+
+	// all words have to match
+	if num, _ := s.GetSearchResultNumber(ctx, "lorem montesquieu"); num != 0 {
+		t.Errorf("GetSearchResultNumber() = %v, want 0", num)
+	}
+	if results, _ := s.SearchArticles(ctx, "  ", 0, 10); len(results) != 0 {
+		t.Errorf("SearchArticles() with an empty query = %v, want none", results)
+	}
+
+	// edited articles are found by their new words
+	a, _ := s.GetArticleByID(ctx, body)
+	a.Source = "Something else"
+	if err := s.EditArticle(ctx, a, userID); err != nil {
+		t.Fatalf("EditArticle() error = %v", err)
+	}
+	if num, _ := s.GetSearchResultNumber(ctx, "dolor"); num != 0 {
+		t.Errorf("GetSearchResultNumber() of a removed word = %v, want 0", num)
+	}
+	if num, _ := s.GetSearchResultNumber(ctx, "else"); num != 1 {
+		t.Errorf("GetSearchResultNumber() of a new word = %v, want 1", num)
+	}
+}
+
+func TestStore_Comments(t *testing.T) {
+	s := newTestStore(t, store.StoreConfig{})
+	userID, authorID := addAuthor(t, s, "commenter")
+	id := addArticle(t, s, article.Article{Title: "Article", AuthorID: authorID}, userID)
+	other := addArticle(t, s, article.Article{Title: "Other", AuthorID: authorID}, userID)
+
+	add := func(c comments.Comment) error {
+		c.UnsafeContent = "<b>hi</b>"
+		return s.AddComment(ctx, c)
+	}
+	if err := add(comments.Comment{ArticleID: id, UserID: userID, Status: comments.Visible}); err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+	add(comments.Comment{ArticleID: id, ParentID: 1, Name: "Anonymous", Status: comments.Pending})
+	add(comments.Comment{ArticleID: id, ParentID: 1, Name: "Reader", Status: comments.Visible})
+
+	invalid := []comments.Comment{
+		{ArticleID: 250604, Status: comments.Visible},
+		{ArticleID: id, UserID: 250604, Status: comments.Visible},
+		{ArticleID: other, ParentID: 1, Status: comments.Visible},
+		{ArticleID: id, Status: "deleted"},
+	}
+	for _, c := range invalid {
+		if err := add(c); !errors.Is(err, store.ErrInvalidInput) {
+			t.Errorf("AddComment(%v) error = %v, want ErrInvalidInput", c, err)
+		}
+	}
+
+	// pending comments aren't listed under the article
+	if num, _ := s.GetCommentNumber(ctx, id); num != 2 {
+		t.Errorf("GetCommentNumber() = %v, want 2", num)
+	}
+	pending, _ := s.ListCommentsByStatus(ctx, comments.Pending, 0, 10)
+	if len(pending) != 1 || pending[0].ID != 2 {
+		t.Fatalf("ListCommentsByStatus() = %v, want comment 2", pending)
+	}
+
+	s.SetCommentStatus(ctx, 2, comments.Visible)
+	s.SetCommentStatus(ctx, 3, comments.Hidden)
+	cs, _ := s.ListComments(ctx, id, 0, 10)
+	if len(cs) != 2 || cs[0].ID != 1 || cs[1].ID != 2 {
+		t.Fatalf("ListComments() = %v, want comments 1 and 2", cs)
+	}
+	if cs[0].Name != "commenter" || cs[0].UnsafeContent != "<b>hi</b>" {
+		t.Errorf("ListComments() = %v, want the name of the user", cs[0])
+	}
+
+	// comments of removed users are kept without their user
+	s.AddUser(ctx, "Reader", "reader", "")
+	readerID, _ := s.GetUserID(ctx, "reader")
+	add(comments.Comment{ArticleID: id, UserID: readerID, Name: "Not the reader", Status: comments.Visible})
+	if err := s.RemoveUser(ctx, readerID); err != nil {
+		t.Fatalf("RemoveUser() of a commenter error = %v", err)
+	}
+	if c, err := s.GetComment(ctx, 4); err != nil || !c.UserRemoved() {
+		t.Errorf("GetComment(4) = %v, %v; want a comment of a removed user", c, err)
+	}
+
+	// the reply stays after its parent is removed
+	if err := s.RemoveComment(ctx, 1); err != nil {
+		t.Fatalf("RemoveComment() error = %v", err)
+	}
+	if c, err := s.GetComment(ctx, 2); err != nil || c.ParentID != 0 {
+		t.Errorf("GetComment(2) = %v, %v; want a comment which isn't a reply", c, err)
+	}
+	if err := s.RemoveComment(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RemoveComment() of a removed comment error = %v, want ErrNotFound", err)
+	}
+}
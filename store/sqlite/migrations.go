@@ -0,0 +1,368 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/david-sorm/montesquieu/store"
+)
+
+// migration is a single change of the schema, every migration is applied only
+// once, in the order of their versions
+type migration struct {
+	version uint64
+	name    string
+	stmt    string
+}
+
+// All migrations sorted by their versions, new ones are only ever appended
+// The versions are independent of the ones of postgres, the first migration
+// makes the schema postgres ended up with
+var migrations = []migration{
+	{version: 1, name: "initial schema", stmt: stmtMigrationInitial},
+	{version: 2, name: "comments of removed users", stmt: stmtMigrationCommentUsers},
+}
+
+// migrate applies all migrations which haven't been applied yet
+// They're applied in a single transaction, which holds the write lock of the
+// database, so instances of Montesquieu starting at the same time don't apply
+// migrations twice
+// If the database has been migrated by a newer version of Montesquieu, an
+// error is returned and nothing is changed
+func (s *Store) migrate(ctx context.Context) error {
+	// migrations can take a while on big databases, so they don't have the
+	// timeout of queries
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, stmtMigrationsTable); err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := checkSchemaVersion(applied); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.stmt); err != nil {
+			return fmt.Errorf("migration %v (%v) failed: %w", m.version, m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, stmtAddMigration, m.version, m.name); err != nil {
+			return err
+		}
+		fmt.Printf("Applying migration %v (%v)\n", m.version, m.name)
+	}
+	return tx.Commit()
+}
+
+// pendingMigrations returns migrations which migrate would apply, without
+// changing anything in the database
+func (s *Store) pendingMigrations(ctx context.Context) ([]store.Migration, error) {
+	// databases which have never been migrated don't have the table yet
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, stmtMigrationsTableExists).Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := make(map[uint64]bool)
+	if exists {
+		var err error
+		if applied, err = appliedMigrations(ctx, s.db); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkSchemaVersion(applied); err != nil {
+		return nil, err
+	}
+
+	pending := make([]store.Migration, 0, 0)
+	for _, m := range migrations {
+		if !applied[m.version] {
+			pending = append(pending, store.Migration{Version: m.version, Name: m.name})
+		}
+	}
+	return pending, nil
+}
+
+// querier is satisfied both by the database and by transactions
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// appliedMigrations returns the versions of all applied migrations
+func appliedMigrations(ctx context.Context, q querier) (map[uint64]bool, error) {
+	rows, err := q.QueryContext(ctx, stmtListMigrations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[uint64]bool)
+	for rows.Next() {
+		var version uint64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// checkSchemaVersion returns an error if a migration unknown to this version
+// of Montesquieu has been applied, its queries might not work with the schema
+func checkSchemaVersion(applied map[uint64]bool) error {
+	latest := migrations[len(migrations)-1].version
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("the database schema is at version %v, but this version of "+
+				"Montesquieu only knows versions up to %v, please upgrade Montesquieu", version, latest)
+		}
+	}
+	return nil
+}
+
+// the table of applied migrations, it's made before any migration is applied
+const stmtMigrationsTable = `
+create table if not exists schema_migrations
+(
+    version integer not null
+        constraint schema_migrations_pk
+            primary key,
+    name    text    not null,
+    applied integer not null default (strftime('%s', 'now'))
+);
+`
+
+const stmtMigrationsTableExists = `select count(*) > 0 from sqlite_master
+where type = 'table' and name = 'schema_migrations';`
+
+const stmtListMigrations = `select version from schema_migrations;`
+
+const stmtAddMigration = `insert into schema_migrations (version, name) values (?1, ?2);`
+
+// the schema of postgres after all of its migrations, together with the words
+// of articles used for searching
+const stmtMigrationInitial = `
+create table users
+(
+    id           integer not null
+        constraint users_pk
+            primary key autoincrement,
+    display_name text,
+    login        text unique,
+    password     text,
+    role         text not null default 'reader'
+        constraint users_role_check
+            check (role in ('reader', 'author', 'editor', 'admin'))
+);
+create table authors
+(
+    id      integer not null
+        constraint authors_pk
+            primary key autoincrement,
+    user_id integer
+        constraint authors_users_id_fk
+            references users
+            unique,
+    name    text
+);
+create table articles
+(
+    title        text,
+    article_id   integer not null
+        constraint articles_pk
+            primary key autoincrement,
+    author_id    integer not null
+        constraint articles_authors_id_fk
+            references authors,
+    html_content text,
+    html_preview text,
+    timestamp    integer,
+    source       text not null default '',
+    summary      text not null default '',
+    slug         text unique,
+    status       text not null default 'published'
+        constraint articles_status_check
+            check (status in ('draft', 'scheduled', 'published', 'archived'))
+);
+create index articles_status_timestamp_index
+    on articles (status, timestamp desc);
+create index articles_author_id_index
+    on articles (author_id, timestamp desc);
+create table article_slugs
+(
+    slug       text not null
+        constraint article_slugs_pk
+            primary key,
+    article_id integer not null
+        constraint article_slugs_articles_article_id_fk
+            references articles
+            on delete cascade
+);
+create index article_slugs_article_id_index
+    on article_slugs (article_id);
+create table revisions
+(
+    id         integer not null
+        constraint revisions_pk
+            primary key autoincrement,
+    article_id integer not null
+        constraint revisions_articles_article_id_fk
+            references articles
+            on delete cascade,
+    user_id    integer
+        constraint revisions_users_id_fk
+            references users
+            on delete set null,
+    time       integer not null,
+    title      text,
+    source     text not null default '',
+    summary    text not null default ''
+);
+create index revisions_article_id_index
+    on revisions (article_id, id desc);
+create index revisions_user_id_index
+    on revisions (user_id);
+create table article_tags
+(
+    article_id integer not null
+        constraint article_tags_articles_article_id_fk
+            references articles
+            on delete cascade,
+    tag        text    not null,
+    constraint article_tags_pk
+        primary key (article_id, tag)
+);
+create index article_tags_tag_index
+    on article_tags (tag);
+create table article_words
+(
+    article_id integer not null
+        constraint article_words_articles_article_id_fk
+            references articles
+            on delete cascade,
+    word       text    not null,
+    weight     integer not null,
+    constraint article_words_pk
+        primary key (article_id, word)
+);
+create index article_words_word_index
+    on article_words (word);
+create table comments
+(
+    comment_id     integer not null
+        constraint comments_pk
+            primary key autoincrement,
+    user_id        integer
+        constraint comments_users_id_fk
+            references users
+            on update cascade,
+    unsafe_content text,
+    article_id     integer not null
+        constraint comments_articles_article_id_fk
+            references articles
+            on delete cascade,
+    parent_id      integer
+        constraint comments_comments_comment_id_fk
+            references comments
+            on delete set null,
+    name           text    not null default '',
+    time           integer not null default (strftime('%s', 'now')),
+    status         text    not null default 'visible'
+        constraint comments_status_check
+            check (status in ('pending', 'visible', 'hidden'))
+);
+create index comments_article_id_index
+    on comments (article_id, status, time);
+create index comments_status_index
+    on comments (status, time desc);
+create index comments_user_id_index
+    on comments (user_id);
+create index comments_parent_id_index
+    on comments (parent_id);
+create table sessions
+(
+    id          integer not null
+        constraint sessions_pk
+            primary key,
+    user_id     integer not null
+        constraint sessions_users_id_fk
+            references users
+            on delete cascade,
+    valid_until integer
+);
+create index sessions_user_id_index
+    on sessions (user_id);
+create table tokens
+(
+    id        integer not null
+        constraint tokens_pk
+            primary key autoincrement,
+    user_id   integer not null
+        constraint tokens_users_id_fk
+            references users
+            on delete cascade,
+    name      text    not null,
+    scope     text    not null
+        constraint tokens_scope_check
+            check (scope in ('read', 'publish', 'admin')),
+    hash      text    not null,
+    created   integer not null,
+    last_used integer
+);
+create index tokens_user_id_index
+    on tokens (user_id, id);
+`
+
+// comments of removed users are kept without their user, instead of keeping
+// the user from being removed
+// SQLite can't change constraints of a table, so the table is made again
+const stmtMigrationCommentUsers = `
+alter table comments rename to comments_old;
+create table comments
+(
+    comment_id     integer not null
+        constraint comments_pk
+            primary key autoincrement,
+    user_id        integer
+        constraint comments_users_id_fk
+            references users
+            on update cascade
+            on delete set null,
+    unsafe_content text,
+    article_id     integer not null
+        constraint comments_articles_article_id_fk
+            references articles
+            on delete cascade,
+    parent_id      integer
+        constraint comments_comments_comment_id_fk
+            references comments
+            on delete set null,
+    name           text    not null default '',
+    time           integer not null default (strftime('%s', 'now')),
+    status         text    not null default 'visible'
+        constraint comments_status_check
+            check (status in ('pending', 'visible', 'hidden'))
+);
+insert into comments (comment_id, user_id, unsafe_content, article_id, parent_id, name, time, status)
+select comment_id, user_id, unsafe_content, article_id, parent_id, name, time, status
+from comments_old;
+drop table comments_old;
+create index comments_article_id_index
+    on comments (article_id, status, time);
+create index comments_status_index
+    on comments (status, time desc);
+create index comments_user_id_index
+    on comments (user_id);
+create index comments_parent_id_index
+    on comments (parent_id);
+`
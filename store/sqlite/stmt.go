@@ -0,0 +1,221 @@
+package sqlite
+
+// All times are saved as unix timestamps in UTC
+// Parameters are numbered (?1, ?2...), since some of them are used twice
+
+// articles
+// columns of articles loaded by loadArticles, the articles table has to be
+// called 'a'
+const articleListColumns = `a.title, a.article_id, coalesce(a.slug, ''), a.author_id, a.html_preview,
+a.timestamp, a.status, ` + articleTagsColumn
+
+// tags of the article 'a' separated by commas, tags can't contain them
+// group_concat doesn't sort them, that's done by splitTags
+const articleTagsColumn = `coalesce((select group_concat(tag, ',') from article_tags
+where article_id = a.article_id), '')`
+
+const stmtLoadArticlesSortedByNewest = `select ` + articleListColumns + ` from articles a
+where a.status = 'published' order by a.timestamp desc limit ?2 offset ?1;`
+
+const stmtLoadAllArticlesSortedByNewest = `select ` + articleListColumns + ` from articles a
+order by a.timestamp desc limit ?2 offset ?1;`
+
+const stmtLoadArticlesByAuthor = `select ` + articleListColumns + ` from articles a
+where a.author_id = ?3 order by a.timestamp desc limit ?2 offset ?1;`
+
+const stmtLoadArticlesByTag = `select ` + articleListColumns + ` from articles a
+inner join article_tags t on t.article_id = a.article_id
+where a.status = 'published' and t.tag = ?3 order by a.timestamp desc limit ?2 offset ?1;`
+
+const stmtArticleNumberByTag = `select count(a.article_id) from articles a
+inner join article_tags t on t.article_id = a.article_id
+where a.status = 'published' and t.tag = ?1;`
+
+const stmtPublishScheduledArticles = `update articles set status = 'published'
+where status = 'scheduled' and timestamp <= ?1;`
+
+const stmtNewArticle = `insert into articles (title, author_id, source, summary,
+html_content, html_preview, timestamp, slug, status) values (?1,?2,?3,?4,?5,?6,?7,?8,?9);`
+
+const stmtEditArticle = `update articles set title = ?1, author_id = ?2,
+source = ?3, summary = ?4, html_content = ?5, html_preview = ?6, timestamp = ?7, slug = ?8,
+status = ?9 where article_id = ?10;`
+
+const stmtRemoveArticle = `delete from articles where article_id = ?1;`
+
+const stmtGetArticleByID = `select a.title, coalesce(a.slug, ''), a.author_id, a.source, a.summary,
+a.html_content, a.timestamp, a.status, ` + articleTagsColumn + ` from articles a
+where a.article_id = ?1;`
+
+// transactions are immediate, so the article is locked until the edit is done
+// articles without a source only have their HTML, which is valid Markdown too,
+// so it's used as their source
+const stmtGetArticleForEdit = `select coalesce(slug, ''), title, coalesce(nullif(source, ''), html_content, ''),
+summary, timestamp from articles where article_id = ?1;`
+
+// search
+// articles are found using the words they contain, see articleWords
+const stmtRemoveArticleWords = `delete from article_words where article_id = ?1;`
+
+const stmtAddArticleWord = `insert into article_words (article_id, word, weight) values (?1, ?2, ?3);`
+
+// the articles have to contain all of the words, which are added as parameters
+// from ?3 on, by searchStmt
+const stmtSearchArticles = `select ` + articleListColumns + `, a.html_content from articles a
+inner join article_words w on w.article_id = a.article_id
+where a.status = 'published' and w.word in (%v) group by a.article_id having count(w.word) = %v
+order by sum(w.weight) desc, a.timestamp desc limit ?2 offset ?1;`
+
+const stmtSearchResultNumber = `select count(*) from (select a.article_id from articles a
+inner join article_words w on w.article_id = a.article_id
+where a.status = 'published' and w.word in (%v) group by a.article_id having count(w.word) = %v);`
+
+// tags
+const stmtRemoveArticleTags = `delete from article_tags where article_id = ?1;`
+
+const stmtAddArticleTag = `insert or ignore into article_tags (article_id, tag) values (?1, ?2);`
+
+const stmtListTags = `select t.tag, count(a.article_id) from article_tags t
+inner join articles a on a.article_id = t.article_id where a.status = 'published'
+group by t.tag order by t.tag;`
+
+// revisions
+const stmtAddRevision = `insert into revisions (article_id, user_id, time, title,
+source, summary) values (?1, nullif(?2, 0), ?3, ?4, ?5, ?6);`
+
+const stmtRevisionNumber = `select count(id) from revisions where article_id = ?1;`
+
+const stmtListRevisions = `select r.id, r.article_id, coalesce(r.user_id, 0),
+coalesce(u.display_name, ''), r.time, r.title, r.source, r.summary from revisions r
+left join users u on u.id = r.user_id where r.article_id = ?1 order by r.id desc
+limit ?3 offset ?2;`
+
+const stmtGetRevision = `select r.id, r.article_id, coalesce(r.user_id, 0),
+coalesce(u.display_name, ''), r.time, r.title, r.source, r.summary from revisions r
+left join users u on u.id = r.user_id where r.id = ?1;`
+
+// slugs
+// a slug is taken if another article uses it now or has used it before
+const stmtSlugTaken = `select count(*) from (
+    select article_id from articles where slug = ?1 and article_id <> ?2
+    union all
+    select article_id from article_slugs where slug = ?1 and article_id <> ?2
+);`
+
+// current slugs take precedence over old ones
+const stmtGetArticleIDBySlug = `select article_id from (
+    select article_id, 0 as priority from articles where slug = ?1
+    union all
+    select article_id, 1 as priority from article_slugs where slug = ?1
+) order by priority limit 1;`
+
+const stmtAddOldSlug = `insert into article_slugs (slug, article_id) values (?1, ?2)
+on conflict (slug) do update set article_id = excluded.article_id;`
+
+const stmtRemoveOldSlug = `delete from article_slugs where slug = ?1;`
+
+const stmtArticleNumber = `select count(article_id) from articles where status = 'published';`
+
+// users
+const stmtListUsers = `select id, display_name, login, role from users order by
+id limit ?2 offset ?1;`
+
+const stmtAddUser = `insert into users (display_name, login, password) values
+(?1,?2,?3);`
+
+const stmtEditUser = `update users set display_name = ?1, login = ?2, password = ?3
+where id = ?4;`
+
+const stmtRemoveUser = `delete from users where id = ?1;`
+
+const stmtGetUserID = `select id from users where login = ?1;`
+
+const stmtGetUser = `select id, display_name, login, password, role from users
+where id = ?1;`
+
+const stmtSetRole = `update users set role = ?1 where id = ?2;`
+
+// authors
+const stmtListAuthors = `select users.id as user_id, display_name as user_display_name,
+login, authors.id as author_id, name as author_name from users
+inner join authors on authors.user_id = users.id order by users.id limit ?2 offset ?1;`
+
+const stmtGetAuthor = `select id, name from authors where user_id = ?1;`
+
+const stmtGetAuthorByID = `select id, name from authors where id = ?1;`
+
+const stmtAddAuthor = `insert into authors (user_id, name) values (?1, ?2);`
+
+const stmtLinkAuthor = `update authors set user_id = nullif(?1, 0) where id = ?2;`
+
+const stmtRemoveAuthor = `delete from authors where id = ?1;`
+
+// admins
+const stmtPromoteToAdmin = `update users set role = 'admin'
+where id = ?1 and role <> 'admin';`
+
+const stmtDemoteFromAdmin = `update users set role = 'reader'
+where id = ?1 and role = 'admin';`
+
+const stmtIsAdmin = `select count(id) from users where id = ?1 and role = 'admin';`
+
+const stmtListAdmins = `select id, display_name, login, role from users
+where role = 'admin' order by id limit ?2 offset ?1;`
+
+// comments
+// the parent has to belong to the same article, otherwise nothing is inserted
+const stmtAddComment = `insert into comments (article_id, parent_id, user_id, name, time,
+status, unsafe_content) select ?1, nullif(?2, 0), nullif(?3, 0), ?4, ?5, ?6, ?7
+where ?2 = 0 or exists (select 1 from comments
+where comment_id = ?2 and article_id = ?1);`
+
+// columns of comments loaded by scanComment, the comments table has to be
+// called 'c'
+const commentColumns = `c.comment_id, c.article_id, coalesce(c.parent_id, 0), coalesce(c.user_id, 0),
+coalesce(u.display_name, c.name), c.time, c.status, coalesce(c.unsafe_content, '')
+from comments c left join users u on u.id = c.user_id`
+
+const stmtListComments = `select ` + commentColumns + ` where c.article_id = ?1 and c.status = 'visible'
+order by c.time, c.comment_id limit ?3 offset ?2;`
+
+const stmtCommentNumber = `select count(comment_id) from comments where article_id = ?1
+and status = 'visible';`
+
+const stmtListCommentsByStatus = `select ` + commentColumns + ` where c.status = ?1
+order by c.time desc, c.comment_id desc limit ?3 offset ?2;`
+
+const stmtGetComment = `select ` + commentColumns + ` where c.comment_id = ?1;`
+
+const stmtSetCommentStatus = `update comments set status = ?1 where comment_id = ?2;`
+
+const stmtRemoveComment = `delete from comments where comment_id = ?1;`
+
+// sessions
+const stmtAddSession = `insert into sessions (id, user_id, valid_until) values
+(?1,?2,?3);`
+
+const stmtGetSession = `select id, user_id, valid_until from sessions where id = ?1
+and valid_until > ?2;`
+
+const stmtExtendSession = `update sessions set valid_until = ?1 where id = ?2;`
+
+const stmtRemoveSession = `delete from sessions where id = ?1;`
+
+const stmtRemoveUserSessions = `delete from sessions where user_id = ?1;`
+
+const stmtRemoveExpiredSessions = `delete from sessions where valid_until <= ?1;`
+
+// tokens
+const stmtAddToken = `insert into tokens (user_id, name, scope, hash, created)
+values (?1, ?2, ?3, ?4, ?5);`
+
+// columns of tokens loaded by scanToken
+const tokenColumns = `id, user_id, name, scope, hash, created, last_used from tokens`
+
+const stmtListTokens = `select ` + tokenColumns + ` where user_id = ?1 order by id;`
+
+const stmtGetToken = `select ` + tokenColumns + ` where id = ?1;`
+
+const stmtTouchToken = `update tokens set last_used = ?1 where id = ?2;`
+
+const stmtRemoveToken = `delete from tokens where id = ?1;`
@@ -21,9 +21,22 @@ var liveTemplatesRunning = false
 var requiredTemplates = []string{
 	"article.gohtml",
 	"index.gohtml",
+	"search.gohtml",
+	"searchForm.gohtml",
+	"login.gohtml",
+	"tagCloud.gohtml",
+	"articleTags.gohtml",
+	"comments.gohtml",
 	"adminPanel.gohtml",
 	"adminPanelHeader.gohtml",
 	"adminPanelFooter.gohtml",
+	"adminPanelArticles.gohtml",
+	"adminPanelArticleEditor.gohtml",
+	"adminPanelArticleDelete.gohtml",
+	"adminPanelArticleRevisions.gohtml",
+	"adminPanelRevision.gohtml",
+	"adminPanelComments.gohtml",
+	"adminPanelTokens.gohtml",
 	"adminPanelUsers.gohtml",
 	"adminPanelAuthors.gohtml",
 	"adminPanelAdmins.gohtml",
@@ -14,14 +14,23 @@ func HashPassword(input string) (string, error) {
 	return hashedPassword, nil
 }
 
+// dummyHash is verified instead of the hash of users who don't exist or don't
+// have a password, so they take as long to check as users with a wrong password
+// and it can't be told from the time whether a login exists
+// Nobody knows the password, it's only ever compared to be thrown away
+const dummyHash = "argon2$4$32768$4$32$/de81283vS39kld8g0BF7g==$IgNAB6QCFnzDNbQG55NvFU4UjiWQ6PVBzr8Fa8LTPqc="
+
 // VerifyPassword verifies the password that has user provided ('input') against
 // the hash
 // Returns true if the password does match, and false if it doesn't or if error
 // has occured during the check
+// An empty hash is used for users who don't exist, it never matches, but it
+// takes as long as a wrong password
 func VerifyPassword(hash string, input string) (bool, error) {
 
 	// Don't allow login for users without any password hash
 	if hash == "" {
+		argon2pw.CompareHashWithPassword(dummyHash, input)
 		return false, nil
 	}
 
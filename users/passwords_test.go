@@ -57,3 +57,10 @@ func TestPasswordHashing(t *testing.T) {
 		t.Errorf("VerifyPassword() should always return false for empty hashes")
 	}
 }
+
+func TestVerifyPassword_DummyHash(t *testing.T) {
+	// a broken hash would be rejected right away, without any hashing
+	if _, err := VerifyPassword(dummyHash, "password"); err == nil || err.Error() != "Password did not match" {
+		t.Errorf("VerifyPassword() of the dummy hash returned %v, want a mismatch", err)
+	}
+}
@@ -0,0 +1,94 @@
+package users
+
+import "errors"
+
+// Role says what a User is allowed to do, every role can do everything the
+// roles before it can
+type Role string
+
+const (
+	// RoleReader can only log in and comment
+	RoleReader Role = "reader"
+	// RoleAuthor can write articles and edit their own ones, but can't publish
+	// them
+	RoleAuthor Role = "author"
+	// RoleEditor can edit and publish anyone's articles and moderate comments
+	RoleEditor Role = "editor"
+	// RoleAdmin can manage users and the configuration too
+	RoleAdmin Role = "admin"
+)
+
+// Roles lists all valid roles, every role includes the ones before it
+var Roles = []Role{RoleReader, RoleAuthor, RoleEditor, RoleAdmin}
+
+// ParseRole converts a string to a Role, returning an error if it's not a
+// valid role
+func ParseRole(str string) (Role, error) {
+	for _, r := range Roles {
+		if string(r) == str {
+			return r, nil
+		}
+	}
+	return "", errors.New("invalid role")
+}
+
+// Permission is something only some roles can do
+type Permission int
+
+const (
+	// AccessAdminPanel lets the user into the admin panel
+	AccessAdminPanel Permission = iota
+	// WriteArticles lets the user write articles and edit their own ones
+	WriteArticles
+	// EditAllArticles lets the user edit and delete anyone's articles
+	EditAllArticles
+	// PublishArticles lets the user publish and schedule articles
+	PublishArticles
+	// ModerateComments lets the user approve, hide and delete comments
+	ModerateComments
+	// ManageUsers lets the user manage users, authors, roles and see the
+	// configuration
+	ManageUsers
+)
+
+// the lowest role which has the permission
+var permissionRoles = map[Permission]Role{
+	AccessAdminPanel: RoleAuthor,
+	WriteArticles:    RoleAuthor,
+	EditAllArticles:  RoleEditor,
+	PublishArticles:  RoleEditor,
+	ModerateComments: RoleEditor,
+	ManageUsers:      RoleAdmin,
+}
+
+// Can returns true if users with the role have the permission
+func (r Role) Can(p Permission) bool {
+	min, exists := permissionRoles[p]
+	return exists && r.Includes(min)
+}
+
+// Includes returns true if the role can do everything other can
+func (r Role) Includes(other Role) bool {
+	return r.level() >= other.level() && other.level() >= 0
+}
+
+// level returns the position of the role in Roles, -1 for invalid roles
+func (r Role) level() int {
+	for k, v := range Roles {
+		if v == r {
+			return k
+		}
+	}
+	return -1
+}
+
+// Scope returns the scope an API token needs to use the permission for
+// anything but reading
+func (p Permission) Scope() TokenScope {
+	switch p {
+	case WriteArticles, EditAllArticles, PublishArticles:
+		return ScopePublish
+	default:
+		return ScopeAdmin
+	}
+}
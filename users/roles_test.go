@@ -0,0 +1,40 @@
+package users
+
+import "testing"
+
+func TestRole_Can(t *testing.T) {
+	tests := []struct {
+		role Role
+		perm Permission
+		want bool
+	}{
+		{role: RoleReader, perm: AccessAdminPanel, want: false},
+		{role: RoleAuthor, perm: AccessAdminPanel, want: true},
+		{role: RoleAuthor, perm: WriteArticles, want: true},
+		{role: RoleAuthor, perm: EditAllArticles, want: false},
+		{role: RoleAuthor, perm: PublishArticles, want: false},
+		{role: RoleEditor, perm: PublishArticles, want: true},
+		{role: RoleEditor, perm: ModerateComments, want: true},
+		{role: RoleEditor, perm: ManageUsers, want: false},
+		{role: RoleAdmin, perm: ManageUsers, want: true},
+		{role: RoleAdmin, perm: WriteArticles, want: true},
+		{role: "", perm: AccessAdminPanel, want: false},
+		{role: RoleAdmin, perm: Permission(-1), want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Can(tt.perm); got != tt.want {
+			t.Errorf("%#v.Can(%v) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, r := range Roles {
+		if parsed, err := ParseRole(string(r)); parsed != r || err != nil {
+			t.Errorf("ParseRole(%#v) = %#v, %v", string(r), parsed, err)
+		}
+	}
+	if _, err := ParseRole("owner"); err == nil {
+		t.Errorf("ParseRole(\"owner\") didn't return an error")
+	}
+}
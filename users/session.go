@@ -0,0 +1,22 @@
+package users
+
+import "time"
+
+// Session represents a logged in User
+// The ID is stored in user's cookie, so it has to be random and hard to guess
+type Session struct {
+	// Unique and random identifier of the session
+	ID uint64
+
+	// The User which is logged in using this session
+	UserID uint64
+
+	// After this time the session isn't valid anymore and the user has to log
+	// in again
+	ValidUntil time.Time
+}
+
+// Valid returns true if the session hasn't expired yet
+func (s Session) Valid() bool {
+	return time.Now().Before(s.ValidUntil)
+}
@@ -0,0 +1,119 @@
+package users
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenScope says what an API token can be used for
+type TokenScope string
+
+const (
+	// ScopeRead tokens can only read
+	ScopeRead TokenScope = "read"
+
+	// ScopePublish tokens can write, edit and delete articles too
+	ScopePublish TokenScope = "publish"
+
+	// ScopeAdmin tokens can do anything their user can do
+	ScopeAdmin TokenScope = "admin"
+)
+
+// TokenScopes lists all valid scopes, every scope includes the ones before it
+var TokenScopes = []TokenScope{ScopeRead, ScopePublish, ScopeAdmin}
+
+// ParseTokenScope converts a string to a TokenScope, returning an error if
+// it's not a valid scope
+func ParseTokenScope(str string) (TokenScope, error) {
+	for _, s := range TokenScopes {
+		if string(s) == str {
+			return s, nil
+		}
+	}
+	return "", errors.New("invalid token scope")
+}
+
+// Includes returns true if a token with the scope can do what other allows
+func (s TokenScope) Includes(other TokenScope) bool {
+	return s.level() >= other.level()
+}
+
+// level returns the position of the scope in TokenScopes, -1 for invalid scopes
+func (s TokenScope) level() int {
+	for k, v := range TokenScopes {
+		if v == s {
+			return k
+		}
+	}
+	return -1
+}
+
+// the beginning of every token, it makes leaked tokens easy to search for
+const tokenPrefix = "mqt_"
+
+// Token is a personal access token, it lets scripts use the API in the name
+// of a User
+// The token itself is only shown once when it's made, only its hash is stored
+type Token struct {
+	// Unique identifier, it's a part of the token, so the hash can be found
+	ID uint64
+
+	// The User the token acts for
+	UserID uint64
+
+	// Name chosen by the user, so they know what the token is used for
+	Name string
+
+	Scope TokenScope
+
+	// The secret part of the token hashed by HashPassword
+	Hash string
+
+	// When the token was made
+	Created time.Time
+
+	// When the token was used for the last time, zero if it was never used
+	LastUsed time.Time
+}
+
+// GenerateTokenSecret returns a new random secret for a token together with
+// its hash, which should be saved in Token.Hash
+func GenerateTokenSecret() (secret string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	hash, err = HashPassword(secret)
+	return secret, hash, err
+}
+
+// FormatToken returns the token which is given to the user
+func FormatToken(id uint64, secret string) string {
+	return tokenPrefix + strconv.FormatUint(id, 10) + "_" + secret
+}
+
+// ParseToken splits the token made by FormatToken into its ID and its secret
+func ParseToken(token string) (id uint64, secret string, err error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return 0, "", errors.New("invalid token")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, tokenPrefix), "_", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return 0, "", errors.New("invalid token")
+	}
+	id, err = strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", errors.New("invalid token")
+	}
+	return id, parts[1], nil
+}
+
+// Verify returns true if the secret belongs to the token
+func (t Token) Verify(secret string) (bool, error) {
+	return VerifyPassword(t.Hash, secret)
+}
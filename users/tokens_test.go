@@ -0,0 +1,51 @@
+package users
+
+import "testing"
+
+func TestToken(t *testing.T) {
+	secret, hash, err := GenerateTokenSecret()
+	if err != nil {
+		t.Fatalf("GenerateTokenSecret() returned an error: %v", err)
+	}
+
+	token := FormatToken(42, secret)
+	id, parsed, err := ParseToken(token)
+	if err != nil || id != 42 || parsed != secret {
+		t.Fatalf("ParseToken(%v) = %v, %v, %v", token, id, parsed, err)
+	}
+
+	if valid, err := (Token{Hash: hash}).Verify(secret); !valid || err != nil {
+		t.Errorf("Verify() of the right secret = %v, %v", valid, err)
+	}
+	if valid, _ := (Token{Hash: hash}).Verify(secret + "x"); valid {
+		t.Errorf("Verify() of a wrong secret = true")
+	}
+}
+
+func TestParseToken_Invalid(t *testing.T) {
+	for _, token := range []string{"", "mqt_", "mqt_42", "mqt_42_", "mqt_x_secret", "abc_42_secret"} {
+		if _, _, err := ParseToken(token); err == nil {
+			t.Errorf("ParseToken(%#v) didn't return an error", token)
+		}
+	}
+}
+
+func TestTokenScope_Includes(t *testing.T) {
+	tests := []struct {
+		scope TokenScope
+		other TokenScope
+		want  bool
+	}{
+		{scope: ScopeRead, other: ScopeRead, want: true},
+		{scope: ScopeRead, other: ScopePublish, want: false},
+		{scope: ScopePublish, other: ScopeRead, want: true},
+		{scope: ScopePublish, other: ScopeAdmin, want: false},
+		{scope: ScopeAdmin, other: ScopePublish, want: true},
+		{scope: "", other: ScopeRead, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.scope.Includes(tt.other); got != tt.want {
+			t.Errorf("%#v.Includes(%#v) = %v, want %v", tt.scope, tt.other, got, tt.want)
+		}
+	}
+}
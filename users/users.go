@@ -15,6 +15,9 @@ type User struct {
 
 	// User's password for login. Usually left empty, unless changing password
 	Password string
+
+	// What the user is allowed to do, new users are readers
+	Role Role
 }
 
 // Author is a kind of User which can publish Articles
@@ -28,5 +31,5 @@ type Author struct {
 	AuthorName string
 }
 
-// Admin is a kind of User that has access to the Admin Panel
+// Admin is a kind of User that has the admin Role
 type Admin User